@@ -0,0 +1,68 @@
+package payloader
+
+import (
+	"encoding/json"
+	"github.com/valyala/fasthttp"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// echoedRequest is the response body in --echo mode, what the server received so it can be checked end-to-end
+type echoedRequest struct {
+	Method string `json:"method"`
+	// Path is the request URI as sent including the query string
+	Path    string              `json:"path"`
+	Proto   string              `json:"proto"`
+	Headers map[string][]string `json:"headers"`
+	Body    string              `json:"body"`
+}
+
+func echoFastHTTP(c *fasthttp.RequestCtx) {
+	req := echoedRequest{
+		Method:  string(c.Method()),
+		Path:    string(c.RequestURI()),
+		Proto:   string(c.Request.Header.Protocol()),
+		Headers: make(map[string][]string),
+		Body:    string(c.Request.Body()),
+	}
+	c.Request.Header.VisitAll(func(key, value []byte) {
+		req.Headers[string(key)] = append(req.Headers[string(key)], string(value))
+	})
+
+	c.SetContentType("application/json")
+	if err := json.NewEncoder(c).Encode(&req); err != nil {
+		log.Println(err)
+	}
+}
+
+func echoNetHTTP(w http.ResponseWriter, r *http.Request) {
+	body := &strings.Builder{}
+	if _, err := io.Copy(body, r.Body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	path := r.RequestURI
+	if path == "" {
+		path = r.URL.RequestURI()
+	}
+	req := echoedRequest{
+		Method:  r.Method,
+		Path:    path,
+		Proto:   r.Proto,
+		Headers: make(map[string][]string, len(r.Header)+1),
+		Body:    body.String(),
+	}
+	for k, v := range r.Header {
+		req.Headers[k] = v
+	}
+	// net/http moves Host out of the headers, put it back so the echo is the same as fasthttp's
+	req.Headers["Host"] = []string{r.Host}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(&req); err != nil {
+		log.Println(err)
+	}
+}
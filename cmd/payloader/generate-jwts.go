@@ -0,0 +1,95 @@
+package payloader
+
+import (
+	"context"
+	"errors"
+	"github.com/domsolutions/gopayloader/config"
+	jwt_claims "github.com/domsolutions/gopayloader/pkgs/jwt-claims"
+	jwt_generator "github.com/domsolutions/gopayloader/pkgs/jwt-generator"
+	"github.com/pterm/pterm"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const (
+	argJWTCount  = "count"
+	argJWTOutput = "output"
+)
+
+var (
+	jwtCount  int64
+	jwtOutput string
+)
+
+var generateJWTsCmd = &cobra.Command{
+	Use:   "generate-jwts",
+	Short: "Generate a file of jwts to reuse across runs with --jwts-filename",
+	Long:  ``,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if jwtCount <= 0 {
+			return errors.New("count must be more than 0")
+		}
+		if jwtCustomClaims != "" {
+			if _, err := config.JwtCustomClaimsJSONStringToMap(jwtCustomClaims); err != nil {
+				return err
+			}
+		}
+		if jwtClaimsTmpl != "" {
+			if _, err := jwt_claims.Parse(jwtClaimsTmpl); err != nil {
+				return err
+			}
+		}
+
+		keyConf := &config.Config{JwtKeyEnv: jwtKeyEnv, JwtKeyStdin: jwtKeyStdin}
+		if jwtKey == "" && jwtKeyEnv == "" && !jwtKeyStdin {
+			return errors.New("one of --jwt-key, --jwt-key-env or --jwt-key-stdin is required")
+		}
+		if err := keyConf.LoadJwtKey(); err != nil {
+			return err
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer cancel()
+
+		jwt := jwt_generator.NewJWTGenerator(&jwt_generator.Config{
+			Ctx:                 ctx,
+			Kid:                 jwtKID,
+			JwtKeyPath:          jwtKey,
+			JwtKey:              keyConf.JwtKeyBlob,
+			JwtSub:              jwtSub,
+			JwtCustomClaimsJSON: jwtCustomClaims,
+			JwtClaimsTemplate:   jwtClaimsTmpl,
+			JwtIss:              jwtIss,
+			JwtAud:              jwtAud,
+			Seed:                seed,
+		})
+		if err := jwt.GenerateFile(jwtCount, jwtOutput); err != nil {
+			return err
+		}
+		pterm.Success.Printf("Saved %d jwts to %s\n", jwtCount, jwtOutput)
+		return nil
+	},
+}
+
+func init() {
+	generateJWTsCmd.Flags().Int64VarP(&jwtCount, argJWTCount, "n", 0, "Number of jwts to generate")
+	generateJWTsCmd.Flags().StringVarP(&jwtOutput, argJWTOutput, "o", "", "File to write the jwts to, one per line")
+	generateJWTsCmd.Flags().StringVar(&jwtKID, argJWTKid, "", "JWT KID")
+	generateJWTsCmd.Flags().StringVar(&jwtKey, argJWTKey, "", "JWT signing private key path")
+	generateJWTsCmd.Flags().StringVar(&jwtKeyEnv, argJWTKeyEnv, "", "Environment variable containing the JWT signing private key, instead of a --jwt-key file i.e. --jwt-key-env JWT_KEY")
+	generateJWTsCmd.Flags().BoolVar(&jwtKeyStdin, argJWTKeyStdin, false, "Read the JWT signing private key from stdin, instead of a --jwt-key file")
+	generateJWTsCmd.Flags().StringVar(&jwtAud, argJWTAud, "", "JWT audience (aud) claim")
+	generateJWTsCmd.Flags().StringVar(&jwtIss, argJWTIss, "", "JWT issuer (iss) claim")
+	generateJWTsCmd.Flags().StringVar(&jwtSub, argJWTSUb, "", "JWT subject (sub) claim")
+	generateJWTsCmd.Flags().StringVar(&jwtCustomClaims, argJWTCustomClaims, "", "JWT custom claims")
+	generateJWTsCmd.Flags().StringVar(&jwtClaimsTmpl, argJWTClaimsTmpl, "", "Vary claims between JWTs, a JSON object of claims to one of {\"cycle\": [...]}, {\"oneOf\": [...]}, {\"int\": [min, max]} or {\"string\": length} i.e. {\"sub\": {\"cycle\": [\"user-1\", \"user-2\"]}}")
+	generateJWTsCmd.Flags().Int64Var(&seed, argSeed, 0, "Seed for the jti claims so the same jwts are generated every time, 0 for random jtis")
+
+	generateJWTsCmd.MarkFlagRequired(argJWTCount)
+	generateJWTsCmd.MarkFlagRequired(argJWTOutput)
+	generateJWTsCmd.MarkFlagsMutuallyExclusive(argJWTKey, argJWTKeyEnv, argJWTKeyStdin)
+
+	rootCmd.AddCommand(generateJWTsCmd)
+}
@@ -1,10 +1,15 @@
 package payloader
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
 	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
 	"github.com/domsolutions/gopayloader/wrapper"
 	"github.com/spf13/cobra"
+	"strconv"
 	"time"
 )
 
@@ -14,24 +19,133 @@ const (
 	argRequests        = "requests"
 	argKeepAlive       = "disable-keep-alive"
 	argVerifySigner    = "skip-verify"
+	argTLSResumption   = "tls-session-resumption"
+	argTCPStats        = "tcp-stats"
 	argTime            = "time"
 	argMTLSKey         = "mtls-key"
 	argMTLSCert        = "mtls-cert"
+	argMTLSCertDir     = "mtls-cert-dir"
+	argIdentities      = "identities"
 	argReadTimeout     = "read-timeout"
 	argWriteTimeout    = "write-timeout"
+	argMaxIdleConnDur  = "max-idle-conn-duration"
+	argMaxConnDur      = "max-conn-duration"
+	argH2PingInterval  = "h2-ping-interval"
+	argCloseRate       = "close-rate"
+	argTimeoutJitter   = "timeout-jitter"
+	argCertInfo        = "cert-info"
+	argCACert          = "ca-cert"
+	argTLSFingerprint  = "tls-fingerprint"
+	argWaitForReady    = "wait-for-ready"
+	argReadyPath       = "ready-path"
+	argReadyTimeout    = "ready-timeout"
+	argRampDown        = "ramp-down"
+	argRunUntilStable  = "run-until-stable"
+	argStableMin       = "stable-min"
+	argStableInterval  = "stable-interval"
+	argStableThreshold = "stable-threshold"
+	argConnBatchSize   = "conn-batch-size"
+	argConnBatchIntvl  = "conn-batch-interval"
+	argMaxBytes        = "max-bytes"
+	argMaxHeaderSize   = "max-header-size"
+	argMaxExpectedBody = "max-expected-body"
+	argHTTPVersion     = "http-version"
+	argConnectRetries  = "connect-retries"
+	argRetryOnReset    = "retry-on-reset"
+	argHonorRetryAfter = "honor-retry-after"
+	argThinkTime       = "adaptive-think-time"
 	argVerbose         = "verbose"
 	argTicker          = "ticker"
+	argProgressFD      = "progress-fd"
+	argProgressPipe    = "progress-pipe"
+	argProgress        = "progress"
 	argJWTKey          = "jwt-key"
+	argJWTKeyEnv       = "jwt-key-env"
+	argJWTKeyStdin     = "jwt-key-stdin"
 	argJWTSUb          = "jwt-sub"
 	argJWTCustomClaims = "jwt-claims"
+	argJWTClaimsTmpl   = "jwt-claims-template"
 	argJWTIss          = "jwt-iss"
 	argJWTAud          = "jwt-aud"
 	argJWTHeader       = "jwt-header"
 	argJWTKid          = "jwt-kid"
 	argJWTsFilename    = "jwts-filename"
+	argJWTRotateEvery  = "jwt-rotate-every"
 	argHeaders         = "headers"
 	argBody            = "body"
 	argBodyFile        = "body-file"
+	argBodyHex         = "body-hex"
+	argBodyProtoDesc   = "body-proto-descriptor"
+	argBodyProtoMsg    = "body-proto-message"
+	argBodyStream      = "body-stream"
+	argStreamResp      = "stream-response-body"
+	argDiscardBody     = "discard-body"
+	argTTFB            = "ttfb"
+	argExpectSHA256    = "expect-sha256"
+	argBodyTemplate    = "body-template-file"
+	argDataCSV         = "data-csv"
+	argQueryFuzz       = "query-fuzz"
+	argReplayHAR       = "replay-har"
+	argReplayFactor    = "replay-multiplier"
+	argScenario        = "scenario"
+	argExpectHeader    = "expect-header"
+	argExpectPresent   = "expect-header-present"
+	argAssertJSONPath  = "assert-json-path"
+	argAssertJSONEq    = "assert-json-equals"
+	argValidateEvery   = "validate-every"
+	argSuccessExpr     = "success-expr"
+	argRate            = "rate"
+	argRatePerConn     = "rate-per-conn"
+	argRequireRate     = "require-rate"
+	argOpenModel       = "open-model"
+	argMaxInflight     = "max-inflight"
+	argInflightOver    = "inflight-overflow"
+	argFairRate        = "fair-rate"
+	argInteractive     = "interactive"
+	argDoHURL          = "doh-url"
+	argDNSServer       = "dns-server"
+	argSRVRecord       = "srv-record"
+	argSourcePorts     = "source-port-range"
+	argMetricsAddr     = "metrics-addr"
+	argStatsDAddr      = "statsd-addr"
+	argTargetMetrics   = "target-metrics-url"
+	argTargetScrape    = "target-metrics-interval"
+	argRawPath         = "raw-path"
+	argMinSamples      = "min-samples"
+	argSlowest         = "slowest"
+	argConnOutliers    = "conn-outlier-factor"
+	argMaxSamples      = "max-samples"
+	argSLOSuccessRate  = "slo-success-rate"
+	argSLOLatency      = "slo-latency"
+	argAbortOnSLO      = "abort-on-slo-breach"
+	argSLOWindow       = "slo-window"
+	argSuccessCodes    = "success-codes"
+	argTraceHeader     = "trace-header"
+	argHMACSecret      = "hmac-secret"
+	argHMACHeader      = "hmac-header"
+	argHMACAlgo        = "hmac-algo"
+	argContentHash     = "content-hash-header"
+	argMethodConc      = "method-concurrency"
+	argForceCL         = "force-content-length"
+	argCompareTarget   = "compare-target"
+	argCompareHeader   = "compare-header"
+	argHeaderOrder     = "header-order"
+	argAdaptiveBackoff = "adaptive-backoff"
+	argBackoffThresh   = "backoff-threshold"
+	argBackoffWindow   = "backoff-window"
+	argSQLite          = "sqlite"
+	argOutputJTL       = "output-jtl"
+	argConnsReport     = "connections-report"
+	argFlamegraph      = "latency-flamegraph"
+	argLatencyUnit     = "latency-unit"
+	argLatencyPrec     = "latency-precision"
+	argOutputIntervals = "output-interval-csv"
+	argLogSampleRate   = "log-sample-rate"
+	argSeed            = "seed"
+	argCompareProtos   = "compare-protocols"
+	argRepeat          = "repeat"
+	argEstimate        = "estimate"
+	argEstimateProbes  = "estimate-probes"
 	argClient          = "client"
 )
 
@@ -40,26 +154,136 @@ var (
 	method           string
 	mTLSCert         string
 	mTLSKey          string
+	mTLSCertDir      string
+	identities       string
 	duration         time.Duration
+	rampDown         time.Duration
+	runUntilStable   bool
+	stableMin        time.Duration
+	stableInterval   time.Duration
+	stableThreshold  float64
+	connBatchSize    uint
+	connBatchIntvl   time.Duration
+	maxBytes         string
+	maxHeaderSize    string
+	maxExpectedBody  string
+	httpVersion      string
 	readTimeout      time.Duration
 	writeTimeout     time.Duration
+	maxIdleConnDur   time.Duration
+	maxConnDur       time.Duration
+	h2PingInterval   time.Duration
+	closeRate        float64
+	timeoutJitter    time.Duration
+	connectRetries   int
+	retryOnReset     bool
+	honorRetryAfter  bool
+	thinkTime        time.Duration
+	compareProtocols bool
+	repeatRuns       int
+	estimate         bool
+	estimateProbes   int
 	disableKeepAlive bool
 	conns            uint
+	connsAuto        bool
 	reqs             int64
 	skipVerify       bool
+	tlsResumption    bool
+	tcpStats         bool
+	certInfo         bool
+	caCert           string
+	tlsFingerprint   string
+	waitForReady     bool
+	readyPath        string
+	readyTimeout     time.Duration
+	metricsAddr      string
+	statsdAddr       string
+	targetMetrics    string
+	targetScrape     time.Duration
+	rawPath          bool
+	minSamples       int64
+	slowest          int
+	connOutliers     float64
+	maxSamples       int
+	sloSuccessRate   float64
+	sloLatency       []string
+	abortOnSLO       bool
+	sloWindow        time.Duration
+	successCodes     []int
+	traceHeader      string
+	hmacSecret       string
+	contentHash      string
+	methodConc       string
+	forceCL          string
+	hmacHeader       string
+	hmacAlgo         string
 	verbose          bool
 	ticker           time.Duration
+	progressFD       int
+	progressPipe     string
+	progress         bool
 	jwtKey           string
+	jwtKeyEnv        string
+	jwtKeyStdin      bool
 	jwtSub           string
 	jwtCustomClaims  string
+	jwtClaimsTmpl    string
 	jwtIss           string
 	jwtAud           string
 	jwtHeader        string
 	jwtKID           string
 	jwtsFilename     string
+	jwtRotateEvery   int64
 	headers          *[]string
 	body             string
 	bodyFile         string
+	bodyHex          string
+	bodyProtoDesc    string
+	bodyProtoMsg     string
+	bodyStream       bool
+	streamRespBody   bool
+	discardBody      bool
+	ttfb             bool
+	expectSHA256     string
+	bodyTemplate     string
+	dataCSV          string
+	queryFuzz        string
+	replayHAR        string
+	replayMultiplier int
+	scenario         string
+	expectHeaders    *[]string
+	expectPresent    *[]string
+	assertJSONPath   string
+	assertJSONEquals string
+	validateEvery    int
+	successExpr      string
+	rate             float64
+	ratePerConn      float64
+	requireRate      bool
+	openModel        bool
+	maxInflight      int
+	inflightOverflow string
+	fairRate         bool
+	interactive      bool
+	dohURL           string
+	dnsServer        string
+	srvRecord        string
+	sourcePorts      string
+	compareTarget    string
+	compareHeaders   *[]string
+	headerOrder      *[]string
+	adaptiveBackoff  bool
+	backoffThreshold float64
+	backoffWindow    time.Duration
+	sqlitePath       string
+	jtlPath          string
+	connsReport      string
+	flamegraph       string
+	latencyUnit      string
+	latencyPrecision int
+	intervalCSVPath  string
+	logSampleRate    float64
+	seed             int64
 )
 
 var runCmd = &cobra.Command{
@@ -74,7 +298,8 @@ var runCmd = &cobra.Command{
 	Long: ``,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		reqURI := args[0]
-		return wrapper.RunGoPayLoader(reqURI,
+		conf := config.NewConfig(context.Background(),
+			reqURI,
 			mTLSCert,
 			mTLSKey,
 			disableKeepAlive,
@@ -99,26 +324,261 @@ var runCmd = &cobra.Command{
 			body,
 			bodyFile,
 			client)
+		conf.JwtKeyEnv = jwtKeyEnv
+		conf.JwtKeyStdin = jwtKeyStdin
+		conf.JwtClaimsTemplate = jwtClaimsTmpl
+		conf.JwtRotateEvery = jwtRotateEvery
+		conf.BodyHex = bodyHex
+		conf.BodyProtoDescriptor = bodyProtoDesc
+		conf.BodyProtoMessage = bodyProtoMsg
+		conf.BodyStream = bodyStream
+		conf.StreamRespBody = streamRespBody
+		conf.DiscardBody = discardBody
+		conf.TTFB = ttfb
+		conf.ExpectSHA256 = expectSHA256
+		conf.BodyTemplateFile = bodyTemplate
+		conf.DataCSV = dataCSV
+		conf.QueryFuzz = queryFuzz
+		conf.ReplayHAR = replayHAR
+		conf.ReplayMultiplier = replayMultiplier
+		conf.Scenario = scenario
+		conf.ExpectHeaders = *expectHeaders
+		conf.ExpectHeadersPresent = *expectPresent
+		conf.AssertJSONPath = assertJSONPath
+		conf.AssertJSONEquals = assertJSONEquals
+		conf.ValidateEvery = validateEvery
+		conf.SuccessExpr = successExpr
+		conf.Rate = rate
+		conf.RatePerConn = ratePerConn
+		conf.RequireRate = requireRate
+		conf.OpenModel = openModel
+		conf.MaxInflight = maxInflight
+		conf.InflightOverflow = inflightOverflow
+		conf.FairRate = fairRate
+		conf.Interactive = interactive
+		conf.ConnsAuto = connsAuto
+		conf.DoHURL = dohURL
+		conf.DNSServer = dnsServer
+		conf.SRVRecord = srvRecord
+		conf.SourcePortRange = sourcePorts
+		conf.CompareURI = compareTarget
+		conf.CompareHeaders = *compareHeaders
+		conf.HeaderOrder = *headerOrder
+		conf.AdaptiveBackoff = adaptiveBackoff
+		conf.BackoffThreshold = backoffThreshold
+		conf.BackoffWindow = backoffWindow
+		conf.SQLitePath = sqlitePath
+		conf.JTLPath = jtlPath
+		conf.ConnsReportPath = connsReport
+		conf.LatencyFlamegraph = flamegraph
+		conf.IntervalCSVPath = intervalCSVPath
+		conf.LatencyUnit = latencyUnit
+		conf.LatencyPrecision = latencyPrecision
+		conf.LogSampleRate = logSampleRate
+		conf.Seed = seed
+		conf.MaxIdleConnDuration = maxIdleConnDur
+		conf.MaxConnDuration = maxConnDur
+		conf.H2PingInterval = h2PingInterval
+		conf.CloseRate = closeRate
+		conf.TimeoutJitter = timeoutJitter
+		conf.ConnectRetries = connectRetries
+		conf.RetryOnReset = retryOnReset
+		conf.HonorRetryAfter = honorRetryAfter
+		conf.AdaptiveThinkTime = thinkTime
+		conf.CompareProtocols = compareProtocols
+		conf.Repeat = repeatRuns
+		conf.Estimate = estimate
+		conf.EstimateProbes = estimateProbes
+		conf.TLSSessionResumption = tlsResumption
+		conf.TCPStats = tcpStats
+		conf.CertInfo = certInfo
+		conf.CACert = caCert
+		conf.TLSFingerprint = tlsFingerprint
+		conf.MTLSCertDir = mTLSCertDir
+		conf.Identities = identities
+		conf.RampDown = rampDown
+		conf.RunUntilStable = runUntilStable
+		conf.StableMin = stableMin
+		conf.StableInterval = stableInterval
+		conf.StableThreshold = stableThreshold
+		conf.ConnBatchSize = connBatchSize
+		conf.ConnBatchInterval = connBatchIntvl
+		if maxBytes != "" {
+			n, err := config.ParseByteSize(maxBytes)
+			if err != nil {
+				return fmt.Errorf("invalid --%s; %v", argMaxBytes, err)
+			}
+			conf.MaxBytes = n
+		}
+		if maxHeaderSize != "" {
+			n, err := config.ParseByteSize(maxHeaderSize)
+			if err != nil {
+				return fmt.Errorf("invalid --%s; %v", argMaxHeaderSize, err)
+			}
+			conf.MaxHeaderSize = n
+		}
+		if maxExpectedBody != "" {
+			n, err := config.ParseByteSize(maxExpectedBody)
+			if err != nil {
+				return fmt.Errorf("invalid --%s; %v", argMaxExpectedBody, err)
+			}
+			conf.MaxExpectedBody = n
+		}
+		conf.HTTPVersion = httpVersion
+		conf.WaitForReady = waitForReady
+		conf.ReadyPath = readyPath
+		conf.ReadyTimeout = readyTimeout
+		conf.MetricsAddr = metricsAddr
+		conf.StatsDAddr = statsdAddr
+		conf.TargetMetricsURL = targetMetrics
+		conf.TargetMetricsEvery = targetScrape
+		conf.RawPath = rawPath
+		conf.MinSamples = minSamples
+		conf.Slowest = slowest
+		conf.ConnOutlierFactor = connOutliers
+		conf.MaxSamples = maxSamples
+		conf.SLOSuccessRate = sloSuccessRate
+		conf.SLOLatency = sloLatency
+		conf.AbortOnSLOBreach = abortOnSLO
+		conf.SLOWindow = sloWindow
+		conf.SuccessCodes = successCodes
+		conf.TraceHeader = traceHeader
+		conf.ProgressFD = progressFD
+		conf.ProgressPipe = progressPipe
+		conf.Progress = progress
+		conf.HMACSecret = hmacSecret
+		conf.ContentHashHeader = contentHash
+		conf.MethodConcurrency = methodConc
+		conf.ForceContentLength = forceCL
+		conf.HMACHeader = hmacHeader
+		conf.HMACAlgo = hmacAlgo
+		return wrapper.RunGoPayLoader(conf)
 	},
 }
 
 func init() {
 	runCmd.Flags().Int64VarP(&reqs, argRequests, "r", 0, "Number of requests")
-	runCmd.Flags().UintVarP(&conns, argConnections, "c", 1, "Number of simultaneous connections")
+	conns = 1
+	runCmd.Flags().VarP(&connsValue{conns: &conns, auto: &connsAuto}, argConnections, "c", "Number of simultaneous connections, or auto to derive it from GOMAXPROCS capped below the open file limit")
 	runCmd.Flags().BoolVarP(&disableKeepAlive, argKeepAlive, "k", false, "Disable keep-alive connections")
 
+	runCmd.Flags().BoolVar(&rawPath, argRawPath, false, "Send the url's path and query exactly as given without validating, normalizing or re-encoding them, only the host is validated")
 	runCmd.Flags().BoolVar(&skipVerify, argVerifySigner, false, "Skip verify SSL cert signer")
+	runCmd.Flags().StringVar(&caCert, argCACert, "", "PEM bundle of CA certs to verify the server cert against instead of the system roots, for private CAs without --skip-verify")
+	runCmd.Flags().StringVar(&tlsFingerprint, argTLSFingerprint, "go", "Offer the same TLS ciphers and key exchanges as a browser in the ClientHello, one of chrome, firefox or go, for testing WAFs and bot detection which fingerprint clients")
+	runCmd.Flags().BoolVar(&tcpStats, argTCPStats, false, "Report the kernel's TCP stats of the connections, retransmits, RTT and congestion window, to tell network congestion from a slow server, Linux with fasthttp-1 and nethttp only")
+	runCmd.Flags().BoolVar(&tlsResumption, argTLSResumption, false, "Resume TLS sessions with session tickets on new connections, by default every new connection does a full handshake")
+	runCmd.Flags().BoolVar(&waitForReady, argWaitForReady, false, "Before running, poll the target until it responds with a 2xx status, for targets which are still starting i.e. in CI")
+	runCmd.Flags().StringVar(&readyPath, argReadyPath, "", "Path polled with --wait-for-ready instead of the target's path i.e. /healthz")
+	runCmd.Flags().DurationVar(&readyTimeout, argReadyTimeout, 30*time.Second, "How long --wait-for-ready polls for before giving up")
+	runCmd.Flags().BoolVar(&certInfo, argCertInfo, false, "Before running, print the target's TLS certificate subject, issuer and expiry, warning if it's untrusted or expires within 30 days")
 	runCmd.Flags().DurationVarP(&duration, argTime, "t", 0, "Execution time window, if used with -r will uniformly distribute reqs within time window, without -r reqs are unlimited")
+	runCmd.Flags().StringVar(&httpVersion, argHTTPVersion, "1.1", "HTTP/1 version of request lines, 1.0 for testing legacy servers sends Connection: keep-alive unless keep-alive is disabled, fasthttp-1 only")
+	runCmd.Flags().StringVar(&maxExpectedBody, argMaxExpectedBody, "", "Count responses larger than this as oversized without failing them i.e. --max-expected-body 1MB, measured with their headers")
+	runCmd.Flags().StringVar(&maxHeaderSize, argMaxHeaderSize, "", "Largest response headers accepted i.e. --max-header-size 64KB, by default 4KB for fasthttp-1 and 10MB for nethttp and nethttp-3, not supported by fasthttp-2")
+	runCmd.Flags().StringVar(&maxBytes, argMaxBytes, "", "Stop once this many request and response bytes are transferred i.e. --max-bytes 1GB, can be used with or instead of -r and -t")
+	runCmd.Flags().UintVar(&connBatchSize, argConnBatchSize, 0, "Open connections in batches of this size, --conn-batch-interval apart, instead of all at once to avoid a burst of dials and file descriptors at startup")
+	runCmd.Flags().DurationVar(&connBatchIntvl, argConnBatchIntvl, time.Second, "Time between opening each batch of --conn-batch-size connections")
+	runCmd.Flags().BoolVar(&runUntilStable, argRunUntilStable, false, "End the run early once the p99 latency of each --stable-interval has converged, -t is the longest the run can take")
+	runCmd.Flags().DurationVar(&stableMin, argStableMin, 10*time.Second, "Shortest time to run for with --run-until-stable, before results can count as stable")
+	runCmd.Flags().DurationVar(&stableInterval, argStableInterval, time.Second, "How often the p99 latency is taken with --run-until-stable, it's stable once the last 5 vary by less than --stable-threshold")
+	runCmd.Flags().Float64Var(&stableThreshold, argStableThreshold, 0.05, "Relative standard deviation of the last 5 p99 latencies below which --run-until-stable counts them as stable i.e. 0.05 for within ~5%")
+	runCmd.Flags().DurationVar(&rampDown, argRampDown, 0, "Retire connections one by one over this window at the end of -t, so load tapers off instead of stopping at once, can't be used with -r")
+	runCmd.Flags().Float64Var(&rate, argRate, 0, "Max requests per second shared across all connections, 0 for unlimited")
+	runCmd.Flags().Float64Var(&ratePerConn, argRatePerConn, 0, "Max requests per second for each connection, total rate grows with connections, can't be used with --rate")
+	runCmd.Flags().BoolVar(&requireRate, argRequireRate, false, "Fail the run if the achieved rate is more than 5% below --rate or --rate-per-conn, a warning is shown either way, for trusting that the intended load was applied")
+	runCmd.Flags().BoolVar(&fairRate, argFairRate, false, "Split --rate evenly between connections, each with its own schedule, so connections which are free more often don't take the share of slower ones")
+	runCmd.Flags().IntVar(&maxInflight, argMaxInflight, 0, "With --open-model, cap the requests which have arrived but not completed, being sent or waiting for a connection, so a stalled target can't build an endless backlog")
+	runCmd.Flags().StringVar(&inflightOverflow, argInflightOver, "delay", "What happens to requests arriving at --max-inflight, delay them until a request's done or drop them")
+	runCmd.Flags().BoolVar(&openModel, argOpenModel, false, "Send requests on the --rate schedule even when the target falls behind, queued requests are sent once a connection is free and their latency is also reported from when they were scheduled")
+	runCmd.Flags().BoolVar(&adaptiveBackoff, argAdaptiveBackoff, false, "Halve the rate when the error rate (failures, 5xx and 429 responses) crosses --backoff-threshold, ramping back up to --rate once it recovers")
+	runCmd.Flags().Float64Var(&backoffThreshold, argBackoffThresh, 0.1, "Error rate between 0 and 1 which triggers a backoff with --adaptive-backoff")
+	runCmd.Flags().DurationVar(&backoffWindow, argBackoffWindow, 10*time.Second, "Sliding window the error rate is measured over with --adaptive-backoff")
+	runCmd.Flags().BoolVar(&interactive, argInteractive, false, "Change rate with +/- and active connections with ]/[ keys while running, requires --rate and --time")
+	runCmd.Flags().StringVar(&dohURL, argDoHURL, "", "Resolve the target host via this DNS-over-HTTPS endpoint instead of the system resolver i.e. https://cloudflare-dns.com/dns-query")
+	runCmd.Flags().StringVar(&dnsServer, argDNSServer, "", "Resolve the target host via this DNS server instead of the system resolver i.e. 10.0.0.53:53, port 53 if not given")
+	runCmd.Flags().StringVar(&srvRecord, argSRVRecord, "", "Spread connections across the targets of this SRV record by weight i.e. _http._tcp.example.com, requests keep the target URL's host")
+	runCmd.Flags().StringVar(&sourcePorts, argSourcePorts, "", "Open connections from local ports in this range i.e. 20000-30000, needs at least as many ports as connections")
+	runCmd.Flags().StringVar(&metricsAddr, argMetricsAddr, "", "Serve request counts and a latency histogram on this address at /metrics while running, in OpenMetrics format with exemplars if the scraper accepts it i.e. :9100")
+	runCmd.Flags().StringVar(&statsdAddr, argStatsDAddr, "", "Send request counts and a sample of latencies as timers to this StatsD UDP address every --ticker i.e. localhost:8125")
+	runCmd.Flags().StringVar(&targetMetrics, argTargetMetrics, "", "Scrape the target's own Prometheus metrics from this URL while running i.e. http://localhost:8080/metrics, to report its CPU and memory from process_cpu_seconds_total and process_resident_memory_bytes with the results")
+	runCmd.Flags().DurationVar(&targetScrape, argTargetScrape, 5*time.Second, "How often --target-metrics-url is scraped")
+	runCmd.Flags().StringVar(&traceHeader, argTraceHeader, "", "Send a random trace ID with every request in this header, used as the trace_id of --metrics-addr exemplars, traceparent sends a W3C trace context i.e. --trace-header traceparent")
+	runCmd.Flags().StringVar(&hmacSecret, argHMACSecret, "", "Sign every request with an HMAC of its timestamp and body using this shared secret, sent as t=<unix seconds>,<algo>=<hex HMAC of \"<unix seconds>.<body>\">")
+	runCmd.Flags().StringVar(&hmacHeader, argHMACHeader, "X-Signature", "Header the --hmac-secret signature is sent in")
+	runCmd.Flags().StringVar(&hmacAlgo, argHMACAlgo, "sha256", "Hash used for the --hmac-secret signature, one of sha256, sha1 or sha512")
+	runCmd.Flags().StringVar(&contentHash, argContentHash, "", "Send a hex SHA-256 of every request's method, path and body in this header so the server can detect duplicates i.e. X-Content-Hash")
+	runCmd.Flags().StringVar(&methodConc, argMethodConc, "", "Cap how many requests of each method are in flight at once across connections i.e. POST=2,PUT=4, for method mixes from --data-csv or --replay-har")
+	runCmd.Flags().StringVar(&forceCL, argForceCL, "", "Send intentionally malformed requests with this Content-Length whatever the size of the body, or none to leave it out, for testing how servers handle bad framing. fasthttp-1 only")
 	runCmd.Flags().DurationVar(&readTimeout, argReadTimeout, 5*time.Second, "Read timeout")
 	runCmd.Flags().DurationVar(&writeTimeout, argWriteTimeout, 5*time.Second, "Write timeout")
-	runCmd.Flags().StringVarP(&method, argMethod, "m", "GET", "request method")
+	runCmd.Flags().DurationVar(&maxIdleConnDur, argMaxIdleConnDur, 0, "Close connections idle for longer than this, a new connection is opened for the next request, 0 for the client's default")
+	runCmd.Flags().DurationVar(&timeoutJitter, argTimeoutJitter, 0, "Add a random offset up to this to each request's timeout so requests don't all time out at once against a struggling server, not supported by fasthttp-2 or nethttp-3")
+	runCmd.Flags().DurationVar(&maxConnDur, argMaxConnDur, 0, "Close connections once they're older than this to spread requests across backends behind a load balancer, 0 for unlimited, not supported by nethttp-3")
+	runCmd.Flags().DurationVar(&h2PingInterval, argH2PingInterval, 0, "Send a PING frame this often on HTTP/2 connections to keep idle connections open through proxies with idle timeouts, fasthttp-2 only, 0 for its default of 3s")
+	runCmd.Flags().Float64Var(&closeRate, argCloseRate, 0, "Fraction of requests between 0 and 1 sent with Connection: close so their connection is reopened for the next request i.e. 0.1 closes after ~10% of requests, HTTP/1.1 only")
+	runCmd.Flags().BoolVar(&retryOnReset, argRetryOnReset, false, "Resend requests up to 3 times with backoff when the target resets the connection i.e. closing idle keep-alive connections, instead of failing them")
+	runCmd.Flags().DurationVar(&thinkTime, argThinkTime, 0, "Pause each connection this long between requests like a real client, doubling up to 16 times as long after a failure, 5xx or 429 and scaled by how slow the last response was against the connection's average i.e. --adaptive-think-time 200ms")
+	runCmd.Flags().BoolVar(&honorRetryAfter, argHonorRetryAfter, false, "When a 429 or 503 response has a Retry-After header, wait that long before the connection sends its next request")
+	runCmd.Flags().IntVar(&connectRetries, argConnectRetries, 0, "Times each connection retries opening with backoff before its first request fails, for targets which are still starting i.e. in CI")
+	runCmd.Flags().StringVarP(&method, argMethod, "m", "GET", "request method, one of GET, PUT, POST, DELETE or TRACE")
 	runCmd.Flags().StringVarP(&body, argBody, "b", "", "request body")
 	runCmd.Flags().StringVar(&bodyFile, argBodyFile, "", "read request body from file")
+	runCmd.Flags().StringVar(&bodyHex, argBodyHex, "", "request body as hex for binary bodies, whitespace between bytes is ignored i.e. --body-hex '00ff 0d0a'")
+	runCmd.Flags().StringVar(&bodyProtoDesc, argBodyProtoDesc, "", "FileDescriptorSet with the --body-proto-message, as written by protoc --include_imports --descriptor_set_out")
+	runCmd.Flags().StringVar(&bodyProtoMsg, argBodyProtoMsg, "", "Send the JSON of -b or --body-file encoded as this protobuf message i.e. shop.v1.Order, Content-Type is application/x-protobuf unless set with -H")
+	runCmd.Flags().BoolVar(&bodyStream, argBodyStream, false, "stream request body from --body-file on every request instead of loading it into memory, for very large bodies")
+	runCmd.Flags().BoolVar(&ttfb, argTTFB, false, "Measure time to first byte, from sending each request to the first byte of its response, and report its percentiles separately from latency which includes reading the body, not supported by fasthttp-2")
+	runCmd.Flags().BoolVar(&discardBody, argDiscardBody, false, "Don't keep response bodies for the most throughput when only status codes matter, they're drained so keep-alive connections are reused, or with fasthttp-1 not read at all when the connection closes anyway i.e. with -k, not supported by fasthttp-2")
+	runCmd.Flags().BoolVar(&streamRespBody, argStreamResp, false, "stream response bodies as they're downloaded and discard them instead of holding them in memory, the download is included in latency and download throughput is reported")
+	runCmd.Flags().StringVar(&expectSHA256, argExpectSHA256, "", "hex SHA-256 every streamed response body must have otherwise counted as failed, requires --stream-response-body")
+	runCmd.Flags().StringVar(&replayHAR, argReplayHAR, "", "Replay the requests of a HAR file against the target with their recorded methods, paths, headers, bodies and timings, spread across connections in order, instead of -r or -t")
+	runCmd.Flags().IntVar(&replayMultiplier, argReplayFactor, 1, "Send every request of --replay-har this many times at its recorded offset to replay a multiple of the recorded traffic i.e. 2 for twice the peak")
+	runCmd.Flags().StringVar(&scenario, argScenario, "", "JSON file of request steps sent in order on each connection, values extracted from a step's response by JSON path or header are sent in later steps with {{.Vars.<name>}}")
+	runCmd.Flags().StringVar(&queryFuzz, argQueryFuzz, "", "add these query parameters to every request, generating values with randInt(min,max), randString(n) or list(a,b,...) i.e. --query-fuzz 'page=randInt(1,100)&sort=list(asc,desc)'")
+	runCmd.Flags().StringVar(&bodyTemplate, argBodyTemplate, "", "render every request body from this Go text/template file, with the request's {{.Index}}, {{.WorkerID}}, {{.Time}} and random helpers i.e. {{.RandInt 1 100}}")
+	runCmd.Flags().StringVar(&dataCSV, argDataCSV, "", "bind the columns of this CSV file to {{.Data.<column>}} in the URL, header and body templates, one row per request cycling through the rows, a method column sets the request method i.e. https://localhost/users/{{.Data.id}}")
+	runCmd.Flags().StringVar(&sqlitePath, argSQLite, "", "append results as a run to this SQLite database, created if it doesn't exist i.e. --sqlite ./runs.db")
+	runCmd.Flags().StringVar(&intervalCSVPath, argOutputIntervals, "", "write a row every --ticker to this CSV file with the interval's requests, RPS, errors, p50 and p99 latency, bytes and bytes/sec, for plotting trends i.e. --output-interval-csv ./intervals.csv")
+	runCmd.Flags().StringVar(&latencyUnit, argLatencyUnit, units.Auto, "Unit of latencies in the results and --output-interval-csv, one of ns, us, ms, s or auto which picks one for each latency by its size, interval CSV columns are ms with auto")
+	runCmd.Flags().IntVar(&latencyPrecision, argLatencyPrec, units.AsNeeded, "Decimal places of latencies in the results and --output-interval-csv, -1 for as many as needed i.e. --latency-unit ms --latency-precision 2 shows 12.35ms")
+	runCmd.Flags().StringVar(&jtlPath, argOutputJTL, "", "write a row for every request to this JMeter JTL CSV file, for tooling which reads JMeter results i.e. --output-jtl ./results.jtl")
+	runCmd.Flags().StringVar(&flamegraph, argFlamegraph, "", "write the time spent resolving, connecting, in the TLS handshake, waiting for the first byte and reading the response across all requests to this file as folded stacks for flamegraph tools, nethttp only i.e. --latency-flamegraph ./latency.folded")
+	runCmd.Flags().StringVar(&connsReport, argConnsReport, "", "write a row for every connection to this CSV file with when it was opened and closed, how many requests it served and why it was closed, fasthttp-1 and nethttp only i.e. --connections-report ./conns.csv")
 	runCmd.Flags().BoolVarP(&verbose, argVerbose, "v", false, "verbose - slows down RPS slightly for long running tests")
+	runCmd.Flags().Float64Var(&logSampleRate, argLogSampleRate, 0, "Fraction of requests between 0 and 1 to log in verbose mode i.e. 0.01 logs ~1% of requests")
+	runCmd.Flags().Int64Var(&seed, argSeed, 0, "Seed for random choices such as which requests are logged, so runs are repeatable, 0 for a random seed")
+	runCmd.Flags().Float64Var(&sloSuccessRate, argSLOSuccessRate, 0, "Success rate SLO as a percentage i.e. 99.9, the results show whether completed/(completed+failed) met it")
+	runCmd.Flags().IntSliceVar(&successCodes, argSuccessCodes, nil, "Response codes counted as successful for goodput, any 2xx by default i.e. --success-codes 200,201,304")
+	runCmd.Flags().StringArrayVar(&sloLatency, argSLOLatency, nil, "Latency SLO as a percentile and the latency it needs to be within, can have multiple i.e. --slo-latency p99=250ms, or for one endpoint prefixed by its path i.e. /search:p99=250ms, the results show whether each was met")
+	runCmd.Flags().BoolVar(&abortOnSLO, argAbortOnSLO, false, "Stop the run as soon as the requests of the last --slo-window breach --slo-success-rate or an --slo-latency, once there are at least --min-samples of them, and exit with an error")
+	runCmd.Flags().DurationVar(&sloWindow, argSLOWindow, 10*time.Second, "Rolling window of requests --abort-on-slo-breach checks the SLOs against")
+	runCmd.Flags().IntVar(&slowest, argSlowest, 0, "Report the URL, status, latency and trace ID of this many of the slowest requests to investigate tail latency")
+	runCmd.Flags().Float64Var(&connOutliers, argConnOutliers, 0, "Warn about connections whose median latency is this many times the median of all connections i.e. 3, to find connections pinned to a slow backend")
+	runCmd.Flags().IntVar(&maxSamples, argMaxSamples, 0, "Latencies each connection keeps in a random sample for --conn-outlier-factor, fewer use less memory but estimate medians less precisely, 0 for 1024")
+	runCmd.Flags().Int64Var(&minSamples, argMinSamples, 100, "Minimum successful requests for latency percentiles to be reported, with fewer a warning is shown instead")
 	runCmd.Flags().DurationVar(&ticker, argTicker, time.Second, "How often to print results while running in verbose mode")
-	headers = runCmd.Flags().StringSliceP(argHeaders, "H", []string{}, "headers to send in request, can have multiple i.e -H 'content-type:application/json' -H' connection:close'")
+	runCmd.Flags().IntVar(&progressFD, argProgressFD, 0, "Write a JSON progress snapshot every --ticker to this file descriptor opened by the caller i.e. 3, for frontends wrapping gopayloader")
+	runCmd.Flags().StringVar(&progressPipe, argProgressPipe, "", "Write a JSON progress snapshot every --ticker to this named pipe, created as a regular file if it doesn't exist")
+	runCmd.Flags().BoolVar(&progress, argProgress, false, "Show a bar of requests sent out of -r and the estimated time left on stderr, updated every --ticker, only when stderr is a terminal")
+	headers = runCmd.Flags().StringSliceP(argHeaders, "H", []string{}, "headers to send in request, can have multiple i.e -H 'content-type:application/json' -H' connection:close', values with {{ are templates rendered for every request like --body-template-file i.e. -H 'X-Nonce: {{.UUID}}'")
+	headerOrder = runCmd.Flags().StringSlice(argHeaderOrder, []string{}, "order and casing to send headers in, unlisted headers follow, fasthttp-1 only i.e --header-order Host,User-Agent,Accept,Content-Length")
+	expectHeaders = runCmd.Flags().StringArray(argExpectHeader, []string{}, "response header and value every response must have otherwise counted as failed, can have multiple i.e --expect-header 'content-type: application/json'")
+	expectPresent = runCmd.Flags().StringArray(argExpectPresent, []string{}, "response header every response must contain otherwise counted as failed, can have multiple i.e --expect-header-present x-cache")
+	runCmd.Flags().StringVar(&assertJSONPath, argAssertJSONPath, "", "JSONPath of a field every JSON response must contain otherwise counted as failed, supports members and indexes i.e. --assert-json-path '$.items[0].status'")
+	runCmd.Flags().StringVar(&assertJSONEquals, argAssertJSONEq, "", "value the --assert-json-path field must have, strings as is and anything else as JSON i.e. ok, 42 or true, any value if not set")
+	runCmd.Flags().IntVar(&validateEvery, argValidateEvery, 1, "only check --assert-json-path on every Nth response of each connection, parsing every body can limit throughput")
+	runCmd.Flags().StringVar(&successExpr, argSuccessExpr, "", "expression a response must make true to be successful otherwise counted as failed, of status, latency, body, header(name), json(path), contains(s, sub) and matches(s, regexp) i.e. 'status == 200 && latency < 100ms && header(\"X-Cache\") == \"HIT\"'")
+	runCmd.Flags().StringVar(&compareTarget, argCompareTarget, "", "send every request to this target too and report responses which diverge in status code, body or --compare-header i.e. https://localhost:8443/some-path")
+	compareHeaders = runCmd.Flags().StringArray(argCompareHeader, []string{}, "response header which must match between both targets with --compare-target, can have multiple i.e --compare-header content-type")
+	runCmd.Flags().BoolVar(&compareProtocols, argCompareProtos, false, "run the same workload over HTTP/1.1, HTTP/2 and HTTP/3 one after the other and compare throughput and latency, protocols the target doesn't support are skipped, --client is ignored")
+	runCmd.Flags().IntVar(&repeatRuns, argRepeat, 1, "Run the whole test this many times one after the other, reporting each run and the mean, standard deviation and range of throughput and latency across them")
+	runCmd.Flags().BoolVar(&estimate, argEstimate, false, "Send a few probe requests and print the RPS projected for -c connections from their latency instead of running, a rough closed model estimate to sanity check parameters")
+	runCmd.Flags().IntVar(&estimateProbes, argEstimateProbes, 20, "Number of probe requests sent one after the other with --estimate")
 	runCmd.Flags().StringVar(&mTLSCert, argMTLSCert, "", "mTLS cert path")
 	runCmd.Flags().StringVar(&mTLSKey, argMTLSKey, "", "mTLS cert private key path")
+	runCmd.Flags().StringVar(&identities, argIdentities, "", "JSON file of virtual users, each with its own client cert, JWT and headers i.e. {\"identities\": [{\"name\": \"tenant-a\", \"cert\": \"a.crt\", \"key\": \"a.key\", \"jwt\": \"...\", \"headers\": [\"X-Tenant: a\"]}]}, connection N acts as identity N for every request")
+	runCmd.Flags().StringVar(&mTLSCertDir, argMTLSCertDir, "", "Directory of mTLS certs and keys to give each connection its own client cert, <name>.crt, .pem or .cert with <name>.key, pairs are assigned to connections in turn")
 
 	runCmd.Flags().StringVar(&client, argClient, worker.HttpClientFastHTTP1, worker.HttpClientFastHTTP1+` for fast http/1.1 requests
 `+worker.HttpClientFastHTTP2+` for fast http/2 requests 
@@ -127,20 +587,66 @@ func init() {
 
 	runCmd.Flags().StringVar(&jwtKID, argJWTKid, "", "JWT KID")
 	runCmd.Flags().StringVar(&jwtKey, argJWTKey, "", "JWT signing private key path")
+	runCmd.Flags().StringVar(&jwtKeyEnv, argJWTKeyEnv, "", "Environment variable containing the JWT signing private key, instead of a --jwt-key file i.e. --jwt-key-env JWT_KEY")
+	runCmd.Flags().BoolVar(&jwtKeyStdin, argJWTKeyStdin, false, "Read the JWT signing private key from stdin, instead of a --jwt-key file")
 	runCmd.Flags().StringVar(&jwtAud, argJWTAud, "", "JWT audience (aud) claim")
 	runCmd.Flags().StringVar(&jwtIss, argJWTIss, "", "JWT issuer (iss) claim")
 	runCmd.Flags().StringVar(&jwtSub, argJWTSUb, "", "JWT subject (sub) claim")
 	runCmd.Flags().StringVar(&jwtCustomClaims, argJWTCustomClaims, "", "JWT custom claims")
-	runCmd.Flags().StringVarP(&jwtsFilename, argJWTsFilename, "f", "", "File path for pre-generated JWTs, separated by new lines")
+	runCmd.Flags().StringVar(&jwtClaimsTmpl, argJWTClaimsTmpl, "", "Vary claims between JWTs, a JSON object of claims to one of {\"cycle\": [...]}, {\"oneOf\": [...]}, {\"int\": [min, max]} or {\"string\": length} i.e. {\"sub\": {\"cycle\": [\"user-1\", \"user-2\"]}}")
+	runCmd.Flags().StringVarP(&jwtsFilename, argJWTsFilename, "f", "", "File path for pre-generated JWTs, separated by new lines. A comma separated list or glob of files takes from each in turn")
 	runCmd.Flags().StringVar(&jwtHeader, argJWTHeader, "", "JWT header field name")
+	runCmd.Flags().Int64Var(&jwtRotateEvery, argJWTRotateEvery, 1, "Number of requests each connection sends with a JWT before moving on to the next one")
 
 	runCmd.MarkFlagsRequiredTogether(argMTLSCert, argMTLSKey)
-	runCmd.MarkFlagsMutuallyExclusive(argBody, argBodyFile)
+	runCmd.MarkFlagsRequiredTogether(argBodyProtoDesc, argBodyProtoMsg)
+	runCmd.MarkFlagsMutuallyExclusive(argMTLSCert, argMTLSCertDir)
+	runCmd.MarkFlagsMutuallyExclusive(argIdentities, argMTLSCert, argMTLSCertDir)
+	runCmd.MarkFlagsMutuallyExclusive(argIdentities, argJWTsFilename)
+	runCmd.MarkFlagsMutuallyExclusive(argBody, argBodyFile, argBodyHex)
+	runCmd.MarkFlagsMutuallyExclusive(argRate, argRatePerConn)
+	runCmd.MarkFlagsMutuallyExclusive(argReplayHAR, argScenario)
+	runCmd.MarkFlagsMutuallyExclusive(argDoHURL, argDNSServer)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTKid)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTAud)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTIss)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTCustomClaims)
+	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTClaimsTmpl)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTSUb)
 	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTKey)
+	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTKeyEnv)
+	runCmd.MarkFlagsMutuallyExclusive(argJWTsFilename, argJWTKeyStdin)
+	runCmd.MarkFlagsMutuallyExclusive(argJWTKey, argJWTKeyEnv, argJWTKeyStdin)
 	rootCmd.AddCommand(runCmd)
 }
+
+// connsValue is -c, a number of connections or auto
+type connsValue struct {
+	conns *uint
+	auto  *bool
+}
+
+func (v *connsValue) Set(s string) error {
+	if s == "auto" {
+		*v.auto = true
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 10, 0)
+	if err != nil {
+		return errors.New("must be a number of connections or auto")
+	}
+	*v.conns = uint(n)
+	*v.auto = false
+	return nil
+}
+
+func (v *connsValue) String() string {
+	if *v.auto {
+		return "auto"
+	}
+	return strconv.FormatUint(uint64(*v.conns), 10)
+}
+
+func (v *connsValue) Type() string {
+	return "string"
+}
@@ -0,0 +1,77 @@
+package payloader
+
+import (
+	"context"
+	"errors"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/domsolutions/gopayloader/wrapper"
+	"github.com/spf13/cobra"
+	"time"
+)
+
+const (
+	smokeReqs  = 100
+	smokeConns = 10
+)
+
+var (
+	smokeReqTarget  int64
+	smokeConnCount  uint
+	smokeHeaders    *[]string
+	smokeSkipVerify bool
+	smokeClient     string
+)
+
+var smokeCmd = &cobra.Command{
+	Use:   "smoke <host>(host format - protocol://host:port/path i.e. https://localhost:443/health)",
+	Short: "Quick health check - sends 100 requests over 10 connections and prints PASS or FAIL",
+	Args: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("no request uri specified as argument")
+		}
+		return nil
+	},
+	Long: `Runs a small fixed load with the default timeouts and passes if every request gets a 2xx response, exiting
+non-zero otherwise so it can gate a deploy i.e. gopayloader smoke https://localhost/health`,
+	// a FAIL is the verdict, not a misused command
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		conf := config.NewConfig(context.Background(),
+			args[0],
+			"",
+			"",
+			false,
+			smokeReqTarget,
+			smokeConnCount,
+			0,
+			smokeSkipVerify,
+			5*time.Second,
+			5*time.Second,
+			"GET",
+			false,
+			time.Second,
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			"",
+			*smokeHeaders,
+			"",
+			"",
+			smokeClient)
+		return wrapper.Smoke(conf)
+	},
+}
+
+func init() {
+	smokeCmd.Flags().Int64VarP(&smokeReqTarget, argRequests, "r", smokeReqs, "Number of requests")
+	smokeCmd.Flags().UintVarP(&smokeConnCount, argConnections, "c", smokeConns, "Number of simultaneous connections")
+	smokeHeaders = smokeCmd.Flags().StringSliceP(argHeaders, "H", []string{}, "headers to send in request, can have multiple i.e -H 'authorization: Bearer abc'")
+	smokeCmd.Flags().BoolVar(&smokeSkipVerify, argVerifySigner, false, "Skip verify SSL cert signer")
+	smokeCmd.Flags().StringVar(&smokeClient, argClient, worker.HttpClientFastHTTP1, "client to send requests with, see run --help for the clients")
+	rootCmd.AddCommand(smokeCmd)
+}
@@ -0,0 +1,48 @@
+package payloader
+
+import (
+	"bytes"
+	"github.com/pterm/pterm"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSmoke(t *testing.T) {
+	out := &bytes.Buffer{}
+	pterm.SetDefaultOutput(out)
+	pterm.DisableStyling()
+	defer func() {
+		pterm.SetDefaultOutput(os.Stdout)
+		pterm.EnableStyling()
+	}()
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	rootCmd.SetArgs([]string{"smoke", server.URL})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "PASS 100/100 requests succeeded against " + server.URL; !strings.Contains(out.String(), want) {
+		t.Errorf("wanted %q got %s", want, out.String())
+	}
+
+	out.Reset()
+	status = http.StatusServiceUnavailable
+	rootCmd.SetArgs([]string{"smoke", server.URL, "-r", "20", "-c", "2"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Error("wanted the smoke test to fail against an unavailable server")
+	}
+	if want := "FAIL 0/20 requests succeeded against " + server.URL; !strings.Contains(out.String(), want) {
+		t.Errorf("wanted %q got %s", want, out.String())
+	}
+	if !strings.Contains(out.String(), "responses 503: 20") {
+		t.Errorf("wanted the failing responses listed got %s", out.String())
+	}
+}
@@ -2,8 +2,10 @@ package payloader
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"github.com/quic-go/quic-go"
 	httpv3server "github.com/quic-go/quic-go/http3"
 	"github.com/spf13/cobra"
@@ -26,6 +28,14 @@ var (
 	nethttp2     bool
 	httpv3       bool
 	debug        bool
+	echo         bool
+)
+
+// ports for running several protocols at once, 0 leaves the protocol to its bool flag and --port
+var (
+	fasthttp1Port int
+	nethttp2Port  int
+	httpv3Port    int
 )
 
 var (
@@ -64,108 +74,222 @@ func tlsConfig() *tls.Config {
 	}
 }
 
+// shutdownTimeout is how long in-flight requests get to finish once the server is stopped
+const shutdownTimeout = 5 * time.Second
+
 var runServerCmd = &cobra.Command{
 	Use:   "http-server",
 	Short: "Start a local HTTP server",
 	Long:  ``,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		response := strings.Repeat("a", responseSize)
-		addr := "localhost:" + strconv.Itoa(port)
-		log.Println("Starting HTTP server on:", addr)
-
-		if fasthttp1 {
-			var err error
-
-			server := fasthttp.Server{
-				Handler: func(c *fasthttp.RequestCtx) {
-					_, err = c.WriteString(response)
-					if err != nil {
-						log.Println(err)
-					}
-					if debug {
-						log.Printf("%s\n", c.Request.Header.String())
-						log.Printf("%s\n", c.Request.Body())
-					}
-				},
-			}
+		servers, err := newTestServers(strings.Repeat("a", responseSize))
+		if err != nil {
+			return err
+		}
+		return serveUntilSignal(servers...)
+	},
+}
 
-			errs := make(chan error)
-			go func() {
-				if err := server.ListenAndServe(addr); err != nil {
-					log.Println(err)
-					errs <- err
-				}
-			}()
-
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-
-			select {
-			case <-c:
-				log.Println("User cancelled, shutting down")
-				server.Shutdown()
-			case err := <-errs:
-				log.Printf("Got error from server; %v \n", err)
-			}
+// testServer is one protocol being served, several can run at once on different ports
+type testServer struct {
+	name     string
+	addr     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
 
-			return nil
+// newTestServers returns a server for each protocol enabled by its flag, which listens on --port, or by its own port
+// flag
+func newTestServers(response string) ([]testServer, error) {
+	protocolPort := func(enabled bool, p int) int {
+		if p != 0 {
+			return p
+		}
+		if enabled {
+			return port
 		}
+		return 0
+	}
+
+	var servers []testServer
+	fasthttpPort := protocolPort(fasthttp1, fasthttp1Port)
+	if fasthttpPort != 0 {
+		servers = append(servers, newFastHTTP1Server(portAddr(fasthttpPort), response))
+	}
 
-		if nethttp2 {
-			server := &http.Server{
-				Addr:         addr,
-				ReadTimeout:  5 * time.Second,
-				WriteTimeout: 10 * time.Second,
-				TLSConfig:    tlsConfig(),
+	http2Port := protocolPort(nethttp2, nethttp2Port)
+	if http2Port != 0 {
+		// fasthttp-1 and netHTTP-2 both listen on TCP, HTTP/3 is on UDP so can share a port with either
+		if http2Port == fasthttpPort {
+			return nil, fmt.Errorf("fasthttp-1 and netHTTP-2 servers can't both listen on port %d", http2Port)
+		}
+		server := newHTTP2Server(portAddr(http2Port), response)
+		servers = append(servers, testServer{
+			name: "net/http HTTP/2",
+			addr: server.Addr,
+			serve: func() error {
+				return server.ListenAndServeTLS("", "")
+			},
+			shutdown: server.Shutdown,
+		})
+	}
+
+	http3Port := protocolPort(httpv3, httpv3Port)
+	if http3Port != 0 {
+		servers = append(servers, newHTTP3Server(portAddr(http3Port), response))
+	}
+
+	if len(servers) == 0 {
+		return nil, errors.New("http option not recognised")
+	}
+	return servers, nil
+}
+
+func portAddr(p int) string {
+	return "localhost:" + strconv.Itoa(p)
+}
+
+func newFastHTTP1Server(addr, response string) testServer {
+	var err error
+
+	server := &fasthttp.Server{
+		Handler: func(c *fasthttp.RequestCtx) {
+			if echo {
+				echoFastHTTP(c)
+				return
+			}
+			_, err = c.WriteString(response)
+			if err != nil {
+				log.Println(err)
 			}
-			var err error
-
-			http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-				_, err = w.Write([]byte(response))
-				if err != nil {
-					log.Println(err)
-				}
-				if debug {
-					log.Printf("%+v\n", r.Header.Get("Some-Jwt"))
-				}
-			})
-
-			if err := server.ListenAndServeTLS("", ""); err != nil {
-				log.Fatal(err)
+			if debug {
+				log.Printf("%s\n", c.Request.Header.String())
+				log.Printf("%s\n", c.Request.Body())
 			}
+		},
+	}
+
+	return testServer{
+		name: "fasthttp HTTP/1.1",
+		addr: addr,
+		serve: func() error {
+			return server.ListenAndServe(addr)
+		},
+		shutdown: server.ShutdownWithContext,
+	}
+}
+
+func newHTTP2Server(addr, response string) *http.Server {
+	var err error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if echo {
+			echoNetHTTP(w, r)
+			return
 		}
+		_, err = w.Write([]byte(response))
+		if err != nil {
+			log.Println(err)
+		}
+		if debug {
+			log.Printf("%+v\n", r.Header.Get("Some-Jwt"))
+		}
+	})
+
+	return &http.Server{
+		Addr:         addr,
+		Handler:      mux,
+		ReadTimeout:  5 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		TLSConfig:    tlsConfig(),
+	}
+}
 
-		if httpv3 {
-			var err error
+func newHTTP3Server(addr, response string) testServer {
+	var err error
+
+	quicConf := &quic.Config{
+		EnableDatagrams: true,
+	}
 
-			quicConf := &quic.Config{
-				EnableDatagrams: true,
+	tlsConfigServer := tlsConfig()
+
+	server := &httpv3server.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if echo {
+				echoNetHTTP(w, r)
+				return
+			}
+			_, err = w.Write([]byte(response))
+			if err != nil {
+				log.Println(err)
+			}
+			if debug {
+				log.Printf("%+v\n", r.Header)
 			}
+		}),
+		Addr:       addr,
+		QuicConfig: quicConf,
+		TLSConfig:  tlsConfigServer,
+	}
+
+	return testServer{
+		name:  "HTTP/3",
+		addr:  addr,
+		serve: server.ListenAndServe,
+		shutdown: func(ctx context.Context) error {
+			// quic-go doesn't implement graceful close yet, this sends CONNECTION_CLOSE to clients so they
+			// don't wait for the idle timeout
+			return server.Close()
+		},
+	}
+}
 
-			tlsConfigServer := tlsConfig()
-
-			server := httpv3server.Server{
-				Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-					_, err = w.Write([]byte(response))
-					if err != nil {
-						log.Println(err)
-					}
-					if debug {
-						log.Printf("%+v\n", r.Header)
-					}
-				}),
-				Addr:       addr,
-				QuicConfig: quicConf,
-				TLSConfig:  tlsConfigServer,
+// serveUntilSignal runs the servers until an interrupt or SIGTERM, or until one of them fails, then shuts them all
+// down together so in-flight requests can finish and connections are closed cleanly
+func serveUntilSignal(servers ...testServer) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	errs := make(chan error, len(servers))
+	for _, s := range servers {
+		s := s
+		log.Printf("Starting %s server on: %s\n", s.name, s.addr)
+		go func() {
+			if err := s.serve(); err != nil {
+				errs <- fmt.Errorf("got error from %s server; %v", s.name, err)
 			}
+		}()
+	}
 
-			if err := server.ListenAndServe(); err != nil {
-				log.Fatal(err)
+	var serveErr error
+	select {
+	case <-c:
+		log.Println("User cancelled, shutting down")
+	case serveErr = <-errs:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	shutdownErrs := make(chan error, len(servers))
+	for _, s := range servers {
+		s := s
+		go func() {
+			if err := s.shutdown(ctx); err != nil {
+				shutdownErrs <- fmt.Errorf("failed to shut down %s server; %v", s.name, err)
+				return
 			}
+			shutdownErrs <- nil
+		}()
+	}
+	for range servers {
+		if err := <-shutdownErrs; err != nil && serveErr == nil {
+			serveErr = err
 		}
-
-		return errors.New("http option not recognised")
-	},
+	}
+	return serveErr
 }
 
 func init() {
@@ -174,6 +298,10 @@ func init() {
 	runServerCmd.Flags().BoolVar(&fasthttp1, "fasthttp-1", false, "Fasthttp HTTP/1.1 server")
 	runServerCmd.Flags().BoolVar(&nethttp2, "netHTTP-2", false, "net/http HTTP/2 server")
 	runServerCmd.Flags().BoolVar(&httpv3, "http-3", false, "HTTP/3 server")
+	runServerCmd.Flags().IntVar(&fasthttp1Port, "fasthttp-1-port", 0, "Port for a Fasthttp HTTP/1.1 server, can be used with the other protocol ports to serve them all at once")
+	runServerCmd.Flags().IntVar(&nethttp2Port, "netHTTP-2-port", 0, "Port for a net/http HTTP/2 server, can be used with the other protocol ports to serve them all at once")
+	runServerCmd.Flags().IntVar(&httpv3Port, "http-3-port", 0, "Port for a HTTP/3 server, can be used with the other protocol ports to serve them all at once")
+	runServerCmd.Flags().BoolVar(&echo, "echo", false, "Respond with the received request's method, path, headers and body as JSON instead of --response-size bytes")
 	runServerCmd.Flags().BoolVarP(&debug, "verbose", "v", false, "print logs")
 	rootCmd.AddCommand(runServerCmd)
 }
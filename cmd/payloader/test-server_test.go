@@ -0,0 +1,298 @@
+package payloader
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"github.com/quic-go/quic-go/http3"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestServeUntilSignal_HTTP2(t *testing.T) {
+	useTestCerts()
+	addr := portAddr(freePort(t, "tcp"))
+
+	server := newHTTP2Server(addr, "hello")
+	// slow handler so a request is in-flight when the signal arrives
+	handler := server.Handler
+	server.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(500 * time.Millisecond)
+		handler.ServeHTTP(w, r)
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilSignal(testServer{
+			name: "net/http HTTP/2",
+			addr: addr,
+			serve: func() error {
+				return server.ListenAndServeTLS("", "")
+			},
+			shutdown: server.Shutdown,
+		})
+	}()
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	url := "https://" + addr
+
+	// wait for the server to be up, signals are being listened for by then
+	for i := 0; ; i++ {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+			if resp.ProtoMajor != 2 {
+				t.Fatalf("wanted HTTP/2 got %s", resp.Proto)
+			}
+			break
+		}
+		if i == 50 {
+			t.Fatalf("server didn't start; %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	inFlight := make(chan error, 1)
+	go func() {
+		resp, err := client.Get(url)
+		if err == nil {
+			resp.Body.Close()
+		}
+		inFlight <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	signalShutdown(t, served)
+
+	if err := <-inFlight; err != nil {
+		t.Errorf("wanted in-flight request to finish during shutdown got %v", err)
+	}
+	if _, err := net.DialTimeout("tcp", addr, time.Second); err == nil {
+		t.Error("wanted server to stop listening")
+	}
+}
+
+func TestServeUntilSignal_AllProtocols(t *testing.T) {
+	useTestCerts()
+	fasthttp1Port = freePort(t, "tcp")
+	nethttp2Port = freePort(t, "tcp")
+	httpv3Port = freePort(t, "udp")
+	defer func() {
+		fasthttp1Port, nethttp2Port, httpv3Port = 0, 0, 0
+	}()
+
+	servers, err := newTestServers("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(servers) != 3 {
+		t.Fatalf("wanted 3 servers got %d", len(servers))
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilSignal(servers...)
+	}()
+
+	clients, closeClients := protocolClients()
+	defer closeClients()
+
+	for _, c := range clients {
+		url := "http" + c.scheme + "://" + portAddr(c.port())
+		for i := 0; ; i++ {
+			resp, err := c.client.Get(url)
+			if err == nil {
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if resp.ProtoMajor != c.proto {
+					t.Errorf("%s wanted HTTP/%d got %s", url, c.proto, resp.Proto)
+				}
+				if string(body) != "hello" {
+					t.Errorf("%s wanted body hello got %s", url, body)
+				}
+				break
+			}
+			if i == 50 {
+				t.Fatalf("%s didn't respond; %v", url, err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	signalShutdown(t, served)
+
+	for _, p := range []int{fasthttp1Port, nethttp2Port} {
+		if _, err := net.DialTimeout("tcp", portAddr(p), time.Second); err == nil {
+			t.Errorf("wanted server on port %d to stop listening", p)
+		}
+	}
+}
+
+func TestNewTestServers_SharedTCPPort(t *testing.T) {
+	fasthttp1Port, nethttp2Port = 8080, 8080
+	defer func() {
+		fasthttp1Port, nethttp2Port = 0, 0
+	}()
+
+	if _, err := newTestServers("hello"); err == nil {
+		t.Fatal("wanted error for fasthttp-1 and netHTTP-2 on the same port")
+	}
+}
+
+func TestServeUntilSignal_Echo(t *testing.T) {
+	useTestCerts()
+	echo = true
+	fasthttp1Port = freePort(t, "tcp")
+	nethttp2Port = freePort(t, "tcp")
+	httpv3Port = freePort(t, "udp")
+	defer func() {
+		echo = false
+		fasthttp1Port, nethttp2Port, httpv3Port = 0, 0, 0
+	}()
+
+	servers, err := newTestServers("hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- serveUntilSignal(servers...)
+	}()
+	defer signalShutdown(t, served)
+
+	clients, closeClients := protocolClients()
+	defer closeClients()
+
+	for _, c := range clients {
+		addr := portAddr(c.port())
+		url := "http" + c.scheme + "://" + addr + "/echo/path?a=1"
+		var resp *http.Response
+		for i := 0; ; i++ {
+			req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(`{"key":"value"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer some-jwt")
+			req.Header.Add("X-Multi", "one")
+			req.Header.Add("X-Multi", "two")
+
+			resp, err = c.client.Do(req)
+			if err == nil {
+				break
+			}
+			if i == 50 {
+				t.Fatalf("%s didn't respond; %v", url, err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		var got echoedRequest
+		err := json.NewDecoder(resp.Body).Decode(&got)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("%s response isn't echoed JSON; %v", url, err)
+		}
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s wanted content type application/json got %s", url, ct)
+		}
+		if got.Method != http.MethodPost {
+			t.Errorf("%s wanted method POST got %s", url, got.Method)
+		}
+		if got.Path != "/echo/path?a=1" {
+			t.Errorf("%s wanted path /echo/path?a=1 got %s", url, got.Path)
+		}
+		if !strings.HasPrefix(got.Proto, fmt.Sprintf("HTTP/%d", c.proto)) {
+			t.Errorf("%s wanted HTTP/%d got %s", url, c.proto, got.Proto)
+		}
+		if got.Body != `{"key":"value"}` {
+			t.Errorf("%s wanted body {\"key\":\"value\"} got %s", url, got.Body)
+		}
+		if v := got.Headers["Authorization"]; len(v) != 1 || v[0] != "Bearer some-jwt" {
+			t.Errorf("%s wanted Authorization header echoed got %v", url, v)
+		}
+		if v := got.Headers["X-Multi"]; len(v) != 2 || v[0] != "one" || v[1] != "two" {
+			t.Errorf("%s wanted both X-Multi values echoed got %v", url, v)
+		}
+		if v := got.Headers["Host"]; len(v) != 1 || v[0] != addr {
+			t.Errorf("%s wanted Host %s got %v", url, addr, v)
+		}
+	}
+}
+
+type protocolClient struct {
+	// scheme is appended to http
+	scheme string
+	port   func() int
+	client *http.Client
+	proto  int
+}
+
+// protocolClients returns a client for each of the test server protocols, ports are read when used as they're
+// set by the test
+func protocolClients() ([]protocolClient, func()) {
+	insecure := &tls.Config{InsecureSkipVerify: true}
+	h3 := &http3.RoundTripper{TLSClientConfig: insecure}
+	return []protocolClient{
+		{port: func() int { return fasthttp1Port }, client: &http.Client{}, proto: 1},
+		{scheme: "s", port: func() int { return nethttp2Port }, client: &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   insecure,
+			ForceAttemptHTTP2: true,
+		}}, proto: 2},
+		{scheme: "s", port: func() int { return httpv3Port }, client: &http.Client{Transport: h3}, proto: 3},
+	}, func() { h3.Close() }
+}
+
+// signalShutdown sends SIGTERM and waits for serveUntilSignal to return cleanly
+func signalShutdown(t *testing.T, served chan error) {
+	t.Helper()
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Signal(syscall.SIGTERM); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatalf("wanted clean shutdown got %v", err)
+		}
+	case <-time.After(shutdownTimeout + time.Second):
+		t.Fatal("servers didn't shut down")
+	}
+}
+
+// useTestCerts points at the certs relative to the package, init sets them relative to the repo root
+func useTestCerts() {
+	serverCert = filepath.Join("cert", "server.crt")
+	privateKey = filepath.Join("cert", "server.key")
+}
+
+func freePort(t *testing.T, network string) int {
+	t.Helper()
+	if network == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		return conn.LocalAddr().(*net.UDPAddr).Port
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
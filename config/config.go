@@ -4,41 +4,185 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"math"
+	"net"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
 	"time"
 	"encoding/json"
+	"encoding/hex"
+	"github.com/domsolutions/gopayloader/pkgs/expr"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	jwt_claims "github.com/domsolutions/gopayloader/pkgs/jwt-claims"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"github.com/domsolutions/gopayloader/pkgs/protobody"
 )
 
 type Config struct {
 	Ctx                  context.Context
 	ReqURI               string
+	RawPath              bool
 	DisableKeepAlive     bool
+	HTTPVersion          string
 	ReqTarget            int64
 	Conns                uint
+	ConnsAuto            bool
 	Duration             time.Duration
+	MaxBytes             int64
+	MaxHeaderSize        int64
+	// MaxExpectedBody flags responses larger than it as oversized, without failing them. 0 doesn't
+	MaxExpectedBody      int64
+	RampDown             time.Duration
+	RunUntilStable       bool
+	StableMin            time.Duration
+	StableInterval       time.Duration
+	StableThreshold      float64
+	ConnBatchSize        uint
+	ConnBatchInterval    time.Duration
 	MTLSKey              string
 	MTLSCert             string
+	MTLSCertDir          string
+	// Identities is a JSON file of virtual users, each connection sends every request with one's cert, JWT and headers
+	Identities           string
 	SkipVerify           bool
+	CACert               string
+	TLSSessionResumption bool
+	TLSFingerprint       string
+	// TCPStats reports the kernel's TCP_INFO of the connections i.e. retransmits, Linux only
+	TCPStats             bool
+	CertInfo             bool
+	WaitForReady         bool
+	ReadyPath            string
+	ReadyTimeout         time.Duration
 	ReadTimeout          time.Duration
 	WriteTimeout         time.Duration
+	TimeoutJitter        time.Duration
+	MaxIdleConnDuration  time.Duration
+	MaxConnDuration      time.Duration
+	H2PingInterval       time.Duration
+	CloseRate            float64
+	ConnectRetries       int
+	RetryOnReset         bool
+	HonorRetryAfter      bool
+	// AdaptiveThinkTime is the base pause between a connection's requests, longer after errors and slow responses
+	AdaptiveThinkTime    time.Duration
 	Method               string
 	Verbose              bool
 	VerboseTicker        time.Duration
+	ProgressFD           int
+	ProgressPipe         string
+	Progress             bool
+	MinSamples           int64
+	Slowest              int
+	// ConnOutlierFactor flags connections whose median latency is this many times the run's, 0 to not check
+	ConnOutlierFactor    float64
+	// MaxSamples caps the latencies each connection keeps for ConnOutlierFactor, 0 for the default
+	MaxSamples           int
+	SLOSuccessRate       float64
+	SLOLatency           []string
+	// AbortOnSLOBreach stops the run once the SLOs are breached by the requests of the last SLOWindow
+	AbortOnSLOBreach     bool
+	SLOWindow            time.Duration
+	SuccessCodes         []int
+	LogSampleRate        float64
+	RequestLog           io.Writer
+	Seed                 int64
 	JwtKID               string
 	JwtKey               string
+	JwtKeyEnv            string
+	JwtKeyStdin          bool
+	// JwtKeyBlob is the key read from JwtKeyEnv or stdin, see LoadJwtKey
+	JwtKeyBlob           []byte
 	JwtSub               string
 	JwtCustomClaimsJSON  string
+	JwtClaimsTemplate    string
 	JwtIss               string
 	JwtAud               string
 	JwtHeader            string
+	// JwtsFilename is a comma separated list of files of JWTs, each can be a glob
 	JwtsFilename         string
+	// JwtsFilenames is the files in JwtsFilename, set by Validate
+	JwtsFilenames        []string
 	SendJWT              bool
+	// JwtRotateEvery is how many requests each connection sends with a JWT before taking the next, 0 is the same as 1
+	JwtRotateEvery       int64
 	Headers              []string
+	HeaderOrder          []string
 	Body                 string
+	BodyHex              string
+	BodyProtoDescriptor  string
+	BodyProtoMessage     string
 	BodyFile             string
+	BodyStream           bool
+	StreamRespBody       bool
+	DiscardBody          bool
+	TTFB                 bool
+	ExpectSHA256         string
+	BodyTemplateFile     string
+	QueryFuzz            string
+	DataCSV              string
+	ReplayHAR            string
+	ReplayMultiplier     int
+	Scenario             string
+	ExpectHeaders        []string
+	ExpectHeadersPresent []string
+	AssertJSONPath       string
+	AssertJSONEquals     string
+	ValidateEvery        int
+	SuccessExpr          string
+	Rate                 float64
+	RatePerConn          float64
+	RequireRate          bool
+	OpenModel            bool
+	// MaxInflight caps the open model's requests which have arrived but not completed, 0 doesn't. InflightOverflow is
+	// what happens to arrivals over it, drop or delay
+	MaxInflight          int
+	InflightOverflow     string
+	// FairRate splits the Rate evenly between the connections instead of them sharing its schedule
+	FairRate             bool
+	Interactive          bool
+	AdaptiveBackoff      bool
+	BackoffThreshold     float64
+	BackoffWindow        time.Duration
+	DoHURL               string
+	DNSServer            string
+	SRVRecord            string
+	SourcePortRange      string
+	MetricsAddr          string
+	StatsDAddr           string
+	TargetMetricsURL     string
+	TargetMetricsEvery   time.Duration
+	TraceHeader          string
+	HMACSecret           string
+	HMACHeader           string
+	HMACAlgo             string
+	ContentHashHeader    string
+	MethodConcurrency    string
+	ForceContentLength   string
+	SQLitePath           string
+	JTLPath              string
+	ConnsReportPath      string
+	// LatencyFlamegraph is the file the phases of every request are written to as folded stacks, nethttp only
+	LatencyFlamegraph    string
+	IntervalCSVPath      string
+	LatencyUnit          string
+	LatencyPrecision     int
+	CompareURI           string
+	CompareHeaders       []string
+	CompareProtocols     bool
+	// Repeat is how many times the whole run is repeated, 0 is the same as 1
+	Repeat               int
+	// Estimate sends EstimateProbes requests and prints the throughput projected for Conns instead of running
+	Estimate             bool
+	EstimateProbes       int
 	Client               string
 }
 
@@ -81,11 +225,99 @@ const regEx = `https?:\/\/(.)*(?::\d+)`
 
 var regExHostURI = regexp.MustCompile(regEx)
 
-var allowedMethods = [4]string{
+// regExSRV matches SRV record names i.e. _http._tcp.example.com
+var regExSRV = regexp.MustCompile(`^_[A-Za-z0-9-]+\._(tcp|udp)(\.[A-Za-z0-9-]+)+\.?$`)
+
+var allowedMethods = [5]string{
 	"GET",
 	"PUT",
 	"POST",
 	"DELETE",
+	"TRACE",
+}
+
+// DecodeBodyHex decodes a --body-hex string into the body bytes, whitespace between bytes is ignored so long bodies
+// can be grouped i.e. "de ad be ef"
+func DecodeBodyHex(bodyHex string) ([]byte, error) {
+	return hex.DecodeString(strings.Join(strings.Fields(bodyHex), ""))
+}
+
+// EncodeProtoBody encodes the JSON of -b or --body-file as the --body-proto-message, the body sent instead
+func (c *Config) EncodeProtoBody() ([]byte, error) {
+	body := []byte(c.Body)
+	if c.BodyFile != "" {
+		var err error
+		if body, err = os.ReadFile(c.BodyFile); err != nil {
+			return nil, err
+		}
+	}
+	return protobody.Marshal(c.BodyProtoDescriptor, c.BodyProtoMessage, body)
+}
+
+// LatencyFormat is the --latency-unit and --latency-precision latencies are shown and exported with
+func (c *Config) LatencyFormat() units.Latency {
+	return units.Latency{Unit: c.LatencyUnit, Precision: c.LatencyPrecision}
+}
+
+// ParseByteSize parses a --max-bytes, --max-header-size or --max-expected-body size like 1GB, 512MB, 64KB or 1000 for
+// bytes. Units are powers of 1024 like the MB in results and are case insensitive
+func ParseByteSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"TB", 1 << 40},
+		{"GB", 1 << 30},
+		{"MB", 1 << 20},
+		{"KB", 1 << 10},
+		{"B", 1},
+	} {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	bytes := n * float64(multiplier)
+	// inf, nan and sizes too big for an int64 parse as floats but aren't a number of bytes
+	if err != nil || n <= 0 || math.IsNaN(n) || bytes >= math.MaxInt64 {
+		return 0, fmt.Errorf("size %q needs to be a positive number of bytes optionally followed by KB, MB, GB or TB", size)
+	}
+	return int64(bytes), nil
+}
+
+// SplitSLOPath splits the path off a --slo-latency for one endpoint like /search:p99=250ms, path is empty for an SLO
+// on every request
+func SplitSLOPath(slo string) (path, spec string) {
+	if !strings.HasPrefix(slo, "/") {
+		return "", slo
+	}
+	i := strings.LastIndexByte(slo, ':')
+	if i < 0 {
+		return slo, ""
+	}
+	return slo[:i], slo[i+1:]
+}
+
+// ParseLatencySLO parses a --slo-latency like p99=250ms into the percentile and the latency it needs to be within
+func ParseLatencySLO(slo string) (float64, time.Duration, error) {
+	pc, latency, ok := strings.Cut(slo, "=")
+	if !ok || !strings.HasPrefix(pc, "p") {
+		return 0, 0, fmt.Errorf("latency SLO %q needs to be like p99=250ms", slo)
+	}
+	percentile, err := strconv.ParseFloat(strings.TrimPrefix(pc, "p"), 64)
+	if err != nil || percentile <= 0 || percentile >= 100 {
+		return 0, 0, fmt.Errorf("latency SLO %q needs a percentile between 0 and 100 i.e. p99", slo)
+	}
+	max, err := time.ParseDuration(latency)
+	if err != nil || max <= 0 {
+		return 0, 0, fmt.Errorf("latency SLO %q needs a latency above 0 i.e. 250ms", slo)
+	}
+	return percentile, max, nil
 }
 
 // Converts jwtCustomClaimsJSON from string to map[string]interface{}
@@ -104,11 +336,52 @@ func JwtCustomClaimsJSONStringToMap(jwtCustomClaimsJSON string) (map[string]inte
 	return jwtCustomClaimsMap, nil
 }
 
+// LoadJwtKey reads the jwt signing key from the JwtKeyEnv variable or stdin into JwtKeyBlob, so secrets don't have
+// to be written to disk. Stdin can only be read once so a loaded key is kept
+func (c *Config) LoadJwtKey() error {
+	if c.JwtKeyBlob != nil {
+		return nil
+	}
+
+	if c.JwtKeyEnv != "" {
+		key := os.Getenv(c.JwtKeyEnv)
+		if key == "" {
+			return fmt.Errorf("config: jwt key environment variable %s is empty", c.JwtKeyEnv)
+		}
+		c.JwtKeyBlob = []byte(key)
+		return nil
+	}
+
+	if c.JwtKeyStdin {
+		key, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("config: failed to read jwt key from stdin; %v", err)
+		}
+		if len(strings.TrimSpace(string(key))) == 0 {
+			return errors.New("config: jwt key from stdin is empty")
+		}
+		c.JwtKeyBlob = key
+	}
+	return nil
+}
+
 func (c *Config) Validate() error {
-	if _, err := url.ParseRequestURI(c.ReqURI); err != nil {
+	reqURI := c.ReqURI
+	if c.RawPath {
+		// the path is sent as is so only the host needs to be valid
+		reqURI, _ = http_clients.SplitRawURI(c.ReqURI)
+	}
+	if _, err := url.ParseRequestURI(reqURI); err != nil {
 		return fmt.Errorf("config: invalid request uri, got error %v", err)
 	}
-	if int64(c.Conns) > c.ReqTarget && c.Duration == 0 {
+	if c.ConnsAuto {
+		c.Conns = AutoConns()
+		if c.ReqTarget > 0 && int64(c.Conns) > c.ReqTarget {
+			// every connection needs at least one request
+			c.Conns = uint(c.ReqTarget)
+		}
+	}
+	if int64(c.Conns) > c.ReqTarget && c.Duration == 0 && c.MaxBytes == 0 && c.ReplayHAR == "" {
 		return errConnLimit
 	}
 	if int64(c.Conns) > c.ReqTarget && c.ReqTarget != 0 && c.Duration != 0 {
@@ -118,10 +391,41 @@ func (c *Config) Validate() error {
 		return errors.New("0 connections not allowed")
 	}
 
-	if !regExHostURI.MatchString(c.ReqURI) {
+	if !regExHostURI.MatchString(reqURI) {
 		return fmt.Errorf("url not in correct format %s needs to be like protocol://host:port/path i.e. https://localhost:443/some-path", c.ReqURI)
 	}
 
+	if c.WaitForReady && c.ReadyTimeout <= 0 {
+		return errors.New("config: ready timeout must be more than 0")
+	}
+	if c.ReadyPath != "" {
+		if !c.WaitForReady {
+			return errors.New("config: ready path requires wait for ready")
+		}
+		if !strings.HasPrefix(c.ReadyPath, "/") {
+			return errors.New("config: ready path must start with / i.e. /healthz")
+		}
+	}
+
+	if c.CertInfo && !strings.HasPrefix(reqURI, "https://") {
+		return errors.New("config: cert info requires an https url")
+	}
+
+	if c.TLSFingerprint != "" && c.TLSFingerprint != http_clients.TLSFingerprintGo {
+		if !http_clients.IsTLSFingerprint(c.TLSFingerprint) {
+			return fmt.Errorf("config: tls fingerprint %s needs to be one of chrome, firefox or go", c.TLSFingerprint)
+		}
+		if !strings.HasPrefix(reqURI, "https://") {
+			return errors.New("config: tls fingerprint requires an https url")
+		}
+	}
+
+	if c.CACert != "" {
+		if _, err := http_clients.LoadCertPool(c.CACert); err != nil {
+			return fmt.Errorf("config: invalid ca cert; %v", err)
+		}
+	}
+
 	if c.MTLSKey != "" {
 		_, err := os.OpenFile(c.MTLSKey, os.O_RDONLY, os.ModePerm)
 		if err != nil {
@@ -140,24 +444,47 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("config: mTLS cert error checking file exists; %v", err)
 		}
 	}
+	if c.MTLSCertDir != "" {
+		if c.MTLSCert != "" || c.MTLSKey != "" {
+			return errors.New("config: mTLS cert dir can't be used with an mTLS cert and key")
+		}
+		if _, err := http_clients.LoadCertDir(c.MTLSCertDir); err != nil {
+			return fmt.Errorf("config: invalid mTLS cert dir; %v", err)
+		}
+	}
+
+	jwtKeySources := 0
+	for _, set := range []bool{c.JwtKey != "", c.JwtKeyEnv != "", c.JwtKeyStdin} {
+		if set {
+			jwtKeySources++
+		}
+	}
+	if jwtKeySources > 1 {
+		return errors.New("config: only one of jwt key, jwt key env and jwt key stdin can be used")
+	}
+	hasJwtKey := jwtKeySources == 1
 
 	// Require JwtHeader if JwtKey or JwtsFilename is present
-  if (c.JwtsFilename != "" || c.JwtKey != "") && c.JwtHeader == "" {
+  if (c.JwtsFilename != "" || hasJwtKey) && c.JwtHeader == "" {
 		return errors.New("config: empty jwt header")
 	}
 
-	// Require JwtKey or JwtsFilename if JwtHeader is present
-	if c.JwtHeader != "" && c.JwtsFilename == "" && c.JwtKey == "" {
+	// Require JwtKey or JwtsFilename if JwtHeader is present, unless the identities have the JWTs
+	if c.JwtHeader != "" && c.JwtsFilename == "" && !hasJwtKey && c.Identities == "" {
 		return errors.New("config: empty jwt filename and jwt key, one of those is needed to send requests with JWTs")
 	}
 
-	if c.JwtKey != "" {
-		_, err := os.OpenFile(c.JwtKey, os.O_RDONLY, os.ModePerm)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return errors.New("config: jwt key does not exist")
+	if hasJwtKey {
+		if c.JwtKey != "" {
+			_, err := os.OpenFile(c.JwtKey, os.O_RDONLY, os.ModePerm)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return errors.New("config: jwt key does not exist")
+				}
+				return fmt.Errorf("config: jwt key error checking file exists; %v", err)
 			}
-			return fmt.Errorf("config: jwt key error checking file exists; %v", err)
+		} else if err := c.LoadJwtKey(); err != nil {
+			return err
 		}
 		if c.ReqTarget == 0 {
 			return errors.New("can only send jwts when request number is specified")
@@ -166,25 +493,122 @@ func (c *Config) Validate() error {
 	}
 
 	if c.JwtsFilename != "" {
-		_, err := os.OpenFile(c.JwtsFilename, os.O_RDONLY, os.ModePerm)
+		files, err := jwtsFilenames(c.JwtsFilename)
 		if err != nil {
-			if os.IsNotExist(err) {
-				return errors.New("config: jwt file does not exist: " + c.JwtsFilename)
+			return err
+		}
+		for _, file := range files {
+			_, err := os.OpenFile(file, os.O_RDONLY, os.ModePerm)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return errors.New("config: jwt file does not exist: " + file)
+				}
+				return fmt.Errorf("config: jwt file error checking file exists; %v", err)
 			}
-			return fmt.Errorf("config: jwt file error checking file exists; %v", err)
 		}
+		c.JwtsFilenames = files
 		if c.ReqTarget == 0 {
 			return errors.New("can only send jwts when request number is specified")
 		}
 		c.SendJWT = true
 	}
 
+	if c.Identities != "" {
+		if c.MTLSCert != "" || c.MTLSKey != "" || c.MTLSCertDir != "" {
+			return errors.New("config: identities can't be used with an mTLS cert or cert dir, each identity has its own cert")
+		}
+		if c.SendJWT {
+			return errors.New("config: identities can't be used with a jwt key or jwt file, each identity has its own jwt")
+		}
+		identities, err := http_clients.LoadIdentities(c.Identities)
+		if err != nil {
+			return fmt.Errorf("config: invalid identities; %v", err)
+		}
+		for _, id := range identities {
+			// either every connection sends a JWT or none do
+			if id.JWT != "" && c.JwtHeader == "" {
+				return fmt.Errorf("config: identity %s has a jwt but there's no jwt header to send it in", id.Name)
+			}
+			if id.JWT == "" && c.JwtHeader != "" {
+				return fmt.Errorf("config: identity %s has no jwt to send in the jwt header", id.Name)
+			}
+		}
+	}
+
 	if len(c.Headers) > 0 {
 		for _, h := range c.Headers {
 			if !strings.Contains(h, ":") {
 				return fmt.Errorf("header %s does not contain : ", h)
 			}
 		}
+		templates, err := http_clients.ParseHeaderTemplates(c.Headers)
+		if err != nil {
+			return fmt.Errorf("config: invalid header template; %v", err)
+		}
+		if len(templates) > 0 && len(c.HeaderOrder) > 0 {
+			// like body templates, the ordered headers are fixed when the request is built
+			return errors.New("config: header templates can't be used with header order")
+		}
+		if len(templates) > 0 && c.ReplayHAR != "" {
+			return errors.New("config: header templates can't be used with replay har")
+		}
+	}
+
+	if len(c.HeaderOrder) > 0 && c.Client != "fasthttp-1" {
+		return errors.New("config: header order is only supported by the fasthttp-1 client")
+	}
+
+	if c.ForceContentLength != "" {
+		if c.ForceContentLength != http_clients.ContentLengthNone {
+			if n, err := strconv.ParseInt(c.ForceContentLength, 10, 64); err != nil || n < 0 {
+				return fmt.Errorf("config: force content length %s should be none or a number of bytes", c.ForceContentLength)
+			}
+		}
+		if c.Client != "fasthttp-1" {
+			// the other clients won't send a request whose framing doesn't match its body
+			return errors.New("config: force content length is only supported by the fasthttp-1 client")
+		}
+		if c.Body == "" && c.BodyFile == "" && c.BodyHex == "" {
+			return errors.New("config: force content length requires a body, set -b, --body-file or --body-hex")
+		}
+		// like header order the headers are fixed when the request is built
+		if c.BodyStream || c.BodyTemplateFile != "" || c.HMACSecret != "" || c.ContentHashHeader != "" || c.CloseRate > 0 || c.ReplayHAR != "" {
+			return errors.New("config: force content length can't be used with body stream, a body template, header templates, hmac secret, content hash header, close rate or replay har")
+		}
+		for _, h := range c.Headers {
+			if http_clients.IsHeaderTemplate(h) {
+				return errors.New("config: force content length can't be used with body stream, a body template, header templates, hmac secret, content hash header, close rate or replay har")
+			}
+		}
+	}
+
+	for _, h := range c.ExpectHeaders {
+		if !strings.Contains(h, ":") {
+			return fmt.Errorf("expected header %s does not contain : ", h)
+		}
+	}
+
+	if c.AssertJSONPath != "" {
+		if _, err := jsonpath.Parse(c.AssertJSONPath); err != nil {
+			return fmt.Errorf("config: invalid assert json path; %v", err)
+		}
+	} else if c.AssertJSONEquals != "" {
+		return errors.New("config: assert json equals requires an assert json path")
+	}
+	if c.ValidateEvery < 0 {
+		return errors.New("config: validate every can't be negative")
+	}
+	if c.ValidateEvery > 1 && c.AssertJSONPath == "" {
+		return errors.New("config: validate every requires an assert json path to sample")
+	}
+	if c.SuccessExpr != "" {
+		x, err := expr.Parse(c.SuccessExpr)
+		if err != nil {
+			return fmt.Errorf("config: invalid success expression; %v", err)
+		}
+		if x.ReadsBody() && (c.StreamRespBody || c.DiscardBody) {
+			return errors.New("config: a success expression using body or json can't be used with streaming or discarding response bodies")
+		}
 	}
 
 	if len(c.BodyFile) > 0 {
@@ -197,13 +621,381 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	if c.BodyHex != "" {
+		if c.Body != "" || c.BodyFile != "" {
+			return errors.New("config: body hex can't be used with a body or body file")
+		}
+		if _, err := DecodeBodyHex(c.BodyHex); err != nil {
+			return fmt.Errorf("config: invalid body hex; %v", err)
+		}
+	}
+
+	if c.BodyProtoDescriptor != "" || c.BodyProtoMessage != "" {
+		if c.BodyProtoDescriptor == "" || c.BodyProtoMessage == "" {
+			return errors.New("config: body proto descriptor and body proto message need to be set together")
+		}
+		if c.Body == "" && c.BodyFile == "" {
+			return errors.New("config: body proto message requires the JSON to encode, set -b or --body-file")
+		}
+		if c.BodyHex != "" || c.BodyStream || c.BodyTemplateFile != "" {
+			return errors.New("config: body proto message can't be used with body hex, body stream or a body template")
+		}
+		if _, err := c.EncodeProtoBody(); err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+	}
+
+	if c.BodyStream && len(c.BodyFile) == 0 {
+		return errors.New("config: body stream requires a body file")
+	}
+
+	if c.StreamRespBody {
+		if c.Client == "fasthttp-2" {
+			return errors.New("config: streaming response bodies isn't supported by the fasthttp-2 client")
+		}
+		// the body is gone once it's been streamed
+		if c.CompareURI != "" || c.AssertJSONPath != "" {
+			return errors.New("config: streaming response bodies can't be used with a compare target or assert json path as both read the body")
+		}
+	}
+	if c.DiscardBody {
+		if c.Client == "fasthttp-2" {
+			return errors.New("config: discarding response bodies isn't supported by the fasthttp-2 client")
+		}
+		if c.StreamRespBody || c.CompareURI != "" || c.AssertJSONPath != "" {
+			return errors.New("config: discarding response bodies can't be used with streaming response bodies, a compare target or assert json path as they read the body")
+		}
+	}
+	if c.ConnsReportPath != "" && (c.Client == "fasthttp-2" || c.Client == "nethttp-3") {
+		// responses are only counted against a connection when each carries one request at a time
+		return errors.New("config: connections report is only supported by the fasthttp-1 and nethttp clients")
+	}
+	if c.LatencyFlamegraph != "" && c.Client != "nethttp" {
+		// the phases are timed with net/http's client trace, which HTTP/3 doesn't have
+		return errors.New("config: latency flamegraph is only supported by the nethttp client")
+	}
+	if c.TCPStats {
+		if !http_clients.TCPStatsSupported {
+			return errors.New("config: tcp stats are only supported on linux")
+		}
+		if c.Client == "fasthttp-2" || c.Client == "nethttp-3" {
+			return errors.New("config: tcp stats are only supported by the fasthttp-1 and nethttp clients")
+		}
+	}
+	if c.TTFB && c.Client == "fasthttp-2" {
+		return errors.New("config: ttfb isn't supported by the fasthttp-2 client")
+	}
+	if c.ExpectSHA256 != "" {
+		if !c.StreamRespBody {
+			return errors.New("config: expect sha256 requires streaming response bodies")
+		}
+		if sum, err := hex.DecodeString(c.ExpectSHA256); err != nil || len(sum) != 32 {
+			return fmt.Errorf("config: expect sha256 %s needs to be 64 hex characters", c.ExpectSHA256)
+		}
+	}
+
+	if c.BodyTemplateFile != "" {
+		if _, err := os.Stat(c.BodyTemplateFile); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: body template file does not exist")
+			}
+			return fmt.Errorf("config: body template file error checking file exists; %v", err)
+		}
+		if c.Body != "" || c.BodyFile != "" || c.BodyHex != "" {
+			return errors.New("config: body template file can't be used with a body, body file or body hex")
+		}
+		if len(c.HeaderOrder) > 0 {
+			// the ordered headers include a fixed content-length
+			return errors.New("config: body template file can't be used with header order")
+		}
+	}
+
+	if c.ReplayHAR != "" {
+		// the recording is only read once, for the run
+		if _, err := os.Stat(c.ReplayHAR); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: replay har does not exist")
+			}
+			return fmt.Errorf("config: replay har error checking file exists; %v", err)
+		}
+		// the recording decides how many requests are sent, when, and what they are
+		if c.ReqTarget != 0 || c.Duration != 0 || c.MaxBytes != 0 || c.Rate > 0 || c.RatePerConn > 0 {
+			return errors.New("config: replay har can't be used with requests, time, max bytes or a rate")
+		}
+		if c.Body != "" || c.BodyFile != "" || c.BodyHex != "" || c.BodyTemplateFile != "" || c.QueryFuzz != "" ||
+			c.HMACSecret != "" || c.CompareURI != "" || len(c.HeaderOrder) > 0 || c.RawPath {
+			return errors.New("config: replay har can't be used with a body, body template, query fuzz, hmac secret, compare target, header order or raw path")
+		}
+	}
+	if c.ReplayMultiplier < 0 {
+		return errors.New("config: replay multiplier can't be negative")
+	}
+	if c.ReplayMultiplier > 1 && c.ReplayHAR == "" {
+		return errors.New("config: replay multiplier requires replay har")
+	}
+	if c.ReplayMultiplier == 0 {
+		c.ReplayMultiplier = 1
+	}
+
+	if c.Scenario != "" {
+		// the steps are only read once, for the run, and checked with CheckScenario
+		if _, err := os.Stat(c.Scenario); err != nil {
+			if os.IsNotExist(err) {
+				return errors.New("config: scenario does not exist")
+			}
+			return fmt.Errorf("config: scenario error checking file exists; %v", err)
+		}
+		if c.ReplayHAR != "" {
+			return errors.New("config: scenario can't be used with replay har")
+		}
+		// steps are sent one after the other on each connection rather than spread over the time window
+		if c.ReqTarget != 0 && c.Duration != 0 {
+			return errors.New("config: scenario runs for either a number of requests or a time, not both")
+		}
+		// the steps have their own bodies, and their headers and paths are rendered on every request
+		if c.Body != "" || c.BodyFile != "" || c.BodyHex != "" || c.BodyTemplateFile != "" || c.QueryFuzz != "" ||
+			c.HMACSecret != "" || c.CompareURI != "" || c.ContentHashHeader != "" || c.ForceContentLength != "" ||
+			len(c.HeaderOrder) > 0 || c.RawPath {
+			return errors.New("config: scenario can't be used with a body, body template, query fuzz, hmac secret, compare target, content hash header, force content length, header order or raw path")
+		}
+		for _, h := range c.Headers {
+			if http_clients.IsHeaderTemplate(h) {
+				return errors.New("config: header templates can't be used with scenario, template the steps' headers instead")
+			}
+		}
+		if c.StreamRespBody || c.DiscardBody {
+			return errors.New("config: scenario can't be used with streaming or discarding response bodies as values are extracted from them")
+		}
+	}
+
+	if c.QueryFuzz != "" {
+		if c.RawPath {
+			return errors.New("config: query fuzz can't be used with raw path")
+		}
+		if _, err := http_clients.ParseQueryFuzz(c.QueryFuzz); err != nil {
+			return fmt.Errorf("config: invalid query fuzz; %v", err)
+		}
+	}
+
+	if c.MethodConcurrency != "" {
+		caps, err := limiter.ParseMethods(c.MethodConcurrency)
+		if err != nil {
+			return fmt.Errorf("config: invalid method concurrency; %v", err)
+		}
+		for method := range caps {
+			if !methodAllowed(method) {
+				return fmt.Errorf("config: method concurrency caps method %s which isn't supported", method)
+			}
+		}
+	}
+
+	if err := c.validateTemplates(); err != nil {
+		return err
+	}
+
+	if c.MetricsAddr != "" {
+		if _, _, err := net.SplitHostPort(c.MetricsAddr); err != nil {
+			return fmt.Errorf("config: metrics address needs to be like host:port i.e. :9100; %v", err)
+		}
+	}
+	if c.StatsDAddr != "" {
+		if host, _, err := net.SplitHostPort(c.StatsDAddr); err != nil || host == "" {
+			return errors.New("config: statsd address needs to be like host:port i.e. localhost:8125")
+		}
+	}
+	if c.TargetMetricsURL != "" {
+		u, err := url.ParseRequestURI(c.TargetMetricsURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("config: target metrics url needs to be an http or https url i.e. http://localhost:8080/metrics")
+		}
+		if c.TargetMetricsEvery < 0 {
+			return errors.New("config: target metrics interval can't be negative")
+		}
+		if c.TargetMetricsEvery == 0 {
+			c.TargetMetricsEvery = 5 * time.Second
+		}
+	}
+	if strings.ContainsAny(c.TraceHeader, ": ") {
+		return fmt.Errorf("config: trace header %s should be a header name only", c.TraceHeader)
+	}
+	if c.HMACSecret != "" {
+		if c.HMACHeader == "" || strings.ContainsAny(c.HMACHeader, ": ") {
+			return fmt.Errorf("config: hmac header %q should be a header name only", c.HMACHeader)
+		}
+		switch c.HMACAlgo {
+		case "", "sha256", "sha1", "sha512":
+		default:
+			return fmt.Errorf("config: hmac algo %s needs to be one of sha256, sha1 or sha512", c.HMACAlgo)
+		}
+		if c.BodyStream {
+			return errors.New("config: hmac secret can't be used with body stream as the body is never held in memory to sign")
+		}
+		if len(c.HeaderOrder) > 0 {
+			// the ordered headers are fixed once set
+			return errors.New("config: hmac secret can't be used with header order")
+		}
+	}
+
+	if c.ContentHashHeader != "" {
+		if strings.ContainsAny(c.ContentHashHeader, ": ") {
+			return fmt.Errorf("config: content hash header %s should be a header name only", c.ContentHashHeader)
+		}
+		if c.BodyStream {
+			return errors.New("config: content hash header can't be used with body stream as the body is never held in memory to hash")
+		}
+		if len(c.HeaderOrder) > 0 {
+			return errors.New("config: content hash header can't be used with header order")
+		}
+		if c.ReplayHAR != "" {
+			return errors.New("config: content hash header can't be used with replay har")
+		}
+	}
+
+	if c.DoHURL != "" {
+		u, err := url.ParseRequestURI(c.DoHURL)
+		if err != nil {
+			return fmt.Errorf("config: invalid DoH url, got error %v", err)
+		}
+		if u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("config: DoH url %s needs to be like https://host/dns-query", c.DoHURL)
+		}
+	}
+	if c.DNSServer != "" {
+		if c.DoHURL != "" {
+			return errors.New("config: dns server and DoH url can't be used together")
+		}
+		if _, err := http_clients.DNSServerAddr(c.DNSServer); err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+	}
+	if c.SRVRecord != "" && !regExSRV.MatchString(c.SRVRecord) {
+		return fmt.Errorf("config: SRV record %s needs to be like _service._proto.name i.e. _http._tcp.example.com", c.SRVRecord)
+	}
+	if c.SourcePortRange != "" {
+		first, last, err := http_clients.ParsePortRange(c.SourcePortRange)
+		if err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+		if c.Client == "nethttp-3" || c.CompareProtocols {
+			return errors.New("config: source port range isn't supported by HTTP/3")
+		}
+		conns := int(c.Conns)
+		if c.CompareURI != "" {
+			// every connection has one to the compare target too
+			conns *= 2
+		}
+		if ports := last - first + 1; conns > ports {
+			return fmt.Errorf("config: source port range %s has %d ports, fewer than the %d connections", c.SourcePortRange, ports, conns)
+		}
+	}
+
+	if c.CompareURI != "" {
+		if _, err := url.ParseRequestURI(c.CompareURI); err != nil {
+			return fmt.Errorf("config: invalid compare target uri, got error %v", err)
+		}
+		if !regExHostURI.MatchString(c.CompareURI) {
+			return fmt.Errorf("compare target url not in correct format %s needs to be like protocol://host:port/path i.e. https://localhost:443/some-path", c.CompareURI)
+		}
+	}
+	if len(c.CompareHeaders) > 0 && c.CompareURI == "" {
+		return errors.New("config: compare headers require a compare target")
+	}
+
+	if c.SQLitePath != "" {
+		if _, err := os.Stat(filepath.Dir(c.SQLitePath)); err != nil {
+			return fmt.Errorf("config: sqlite directory error; %v", err)
+		}
+	}
+
+	if c.LogSampleRate < 0 || c.LogSampleRate > 1 {
+		return errors.New("config: log sample rate must be between 0 and 1")
+	}
+	if c.LogSampleRate > 0 && !c.Verbose {
+		return errors.New("config: log sample rate requires verbose mode")
+	}
+
+	if c.MinSamples < 0 {
+		return errors.New("config: min samples can't be negative")
+	}
+	if c.Slowest < 0 {
+		return errors.New("config: slowest can't be negative")
+	}
+	if c.ConnOutlierFactor != 0 && c.ConnOutlierFactor <= 1 {
+		return errors.New("config: conn outlier factor must be above 1")
+	}
+	if c.MaxSamples < 0 {
+		return errors.New("config: max samples can't be negative")
+	}
+	if c.MaxSamples > 0 {
+		if c.ConnOutlierFactor == 0 {
+			// the run's percentiles come from a fixed size histogram, only connection medians are from samples
+			return errors.New("config: max samples requires conn outlier factor")
+		}
+		if c.MaxSamples < 10 {
+			// connections with fewer samples aren't compared
+			return errors.New("config: max samples must be at least 10")
+		}
+	}
+
+	if c.SLOSuccessRate < 0 || c.SLOSuccessRate > 100 {
+		return errors.New("config: slo success rate must be between 0 and 100")
+	}
+	for _, slo := range c.SLOLatency {
+		path, spec := SplitSLOPath(slo)
+		if _, _, err := ParseLatencySLO(spec); err != nil {
+			if path != "" {
+				return fmt.Errorf("config: latency SLO for %s; %v", path, err)
+			}
+			return fmt.Errorf("config: %v", err)
+		}
+		if strings.ContainsAny(path, "?#") {
+			return fmt.Errorf("config: latency SLO %q path can't have a query, it's matched against the path only", slo)
+		}
+	}
+
+	if c.AbortOnSLOBreach {
+		if c.SLOSuccessRate == 0 && len(c.SLOLatency) == 0 {
+			return errors.New("config: abort on slo breach needs an slo success rate or slo latency to check")
+		}
+		if c.SLOWindow < 0 {
+			return errors.New("config: slo window can't be negative")
+		}
+		if c.SLOWindow == 0 {
+			c.SLOWindow = 10 * time.Second
+		}
+	}
+
+	for _, code := range c.SuccessCodes {
+		if code < 100 || code > 599 {
+			return fmt.Errorf("config: success code %d isn't a HTTP status code", code)
+		}
+	}
+
 	if c.VerboseTicker == 0 {
 		return errors.New("ticker value can't be zero")
 	}
+	if c.ProgressFD < 0 || c.ProgressFD == 1 {
+		// stdout is kept for the results
+		return fmt.Errorf("config: progress fd %d needs to be 2 for stderr or an fd opened by the caller i.e. 3", c.ProgressFD)
+	}
+	if c.Progress {
+		if c.ReqTarget == 0 && c.ReplayHAR == "" {
+			return errors.New("config: progress bar requires a number of requests")
+		}
+		if c.Verbose {
+			return errors.New("config: progress bar can't be used with verbose mode, which has a progress bar of its own")
+		}
+	}
+	if c.ProgressFD > 0 && c.ProgressPipe != "" {
+		return errors.New("config: progress fd and progress pipe can't be used together")
+	}
 
 	if !methodAllowed(c.Method) {
 		return fmt.Errorf("method %s not allowed", c.Method)
 	}
+	if c.Method == "TRACE" && (c.Body != "" || c.BodyFile != "" || c.BodyHex != "" || c.BodyTemplateFile != "") {
+		return errors.New("config: TRACE requests can't have a body")
+	}
 
 	if c.WriteTimeout == 0 {
 		return errors.New("write timeout is zero")
@@ -212,9 +1004,263 @@ func (c *Config) Validate() error {
 		return errors.New("read timeout is zero")
 	}
 
-	if c.ReqTarget == 0 && c.Duration == 0 {
+	if c.ConnectRetries < 0 {
+		return errors.New("config: connect retries can't be negative")
+	}
+
+	if c.MaxIdleConnDuration < 0 {
+		return errors.New("config: max idle connection duration can't be negative")
+	}
+	if c.TimeoutJitter < 0 {
+		return errors.New("config: timeout jitter can't be negative")
+	}
+	if c.TimeoutJitter > 0 && (c.Client == "fasthttp-2" || c.Client == "nethttp-3") {
+		return errors.New("config: timeout jitter isn't supported by the fasthttp-2 and nethttp-3 clients")
+	}
+	if c.MaxConnDuration < 0 {
+		return errors.New("config: max connection duration can't be negative")
+	}
+	if c.MaxConnDuration > 0 && c.Client == "nethttp-3" {
+		return errors.New("config: max connection duration isn't supported by the nethttp-3 client")
+	}
+	if c.H2PingInterval < 0 {
+		return errors.New("config: h2 ping interval can't be negative")
+	}
+	if c.H2PingInterval > 0 && c.Client != "fasthttp-2" {
+		return errors.New("config: h2 ping interval is only supported by the fasthttp-2 client")
+	}
+	if c.CloseRate < 0 || c.CloseRate > 1 {
+		return errors.New("config: close rate must be between 0 and 1")
+	}
+	if c.CloseRate > 0 {
+		if c.Client == "fasthttp-2" || c.Client == "nethttp-3" {
+			return errors.New("config: close rate is only supported by HTTP/1.1 clients as HTTP/2 and HTTP/3 have no Connection header")
+		}
+		if c.DisableKeepAlive {
+			return errors.New("config: close rate can't be used when keep-alive is disabled as every request already closes the connection")
+		}
+		if len(c.HeaderOrder) > 0 {
+			return errors.New("config: close rate can't be used with header order")
+		}
+	}
+
+	if c.ReqTarget == 0 && c.Duration == 0 && c.MaxBytes == 0 && c.ReplayHAR == "" {
 		return errors.New("config: ReqTarget 0 and Duration 0")
 	}
+	if c.MaxBytes < 0 {
+		return errors.New("config: max bytes can't be negative")
+	}
+	if c.MaxHeaderSize < 0 {
+		return errors.New("config: max header size can't be negative")
+	}
+	if c.MaxExpectedBody < 0 {
+		return errors.New("config: max expected body can't be negative")
+	}
+	if c.MaxExpectedBody > 0 && c.DiscardBody {
+		// only the headers of a discarded response are measured
+		return errors.New("config: max expected body can't be used with discarding response bodies as they aren't measured")
+	}
+	if c.MaxHeaderSize > 0 && c.Client == "fasthttp-2" {
+		return errors.New("config: max header size isn't supported by the fasthttp-2 client")
+	}
+	switch c.HTTPVersion {
+	case "", "1.1":
+	case "1.0":
+		if c.Client != "fasthttp-1" {
+			return errors.New("config: HTTP version 1.0 is only supported by the fasthttp-1 client")
+		}
+	default:
+		return fmt.Errorf("config: HTTP version %q must be 1.0 or 1.1", c.HTTPVersion)
+	}
+	if c.RampDown < 0 {
+		return errors.New("config: ramp down can't be negative")
+	}
+	if c.ConnBatchSize > 0 && c.ConnBatchInterval <= 0 {
+		return errors.New("config: conn batch size requires a conn batch interval above 0")
+	}
+	if c.RunUntilStable {
+		if c.Duration == 0 {
+			return errors.New("config: run until stable requires a time window as the longest the run can take")
+		}
+		if c.StableMin < 0 || c.StableMin >= c.Duration {
+			return errors.New("config: stable min must be 0 or more and shorter than the time window")
+		}
+		if c.StableInterval <= 0 {
+			return errors.New("config: stable interval must be above 0")
+		}
+		if c.StableThreshold <= 0 {
+			return errors.New("config: stable threshold must be above 0")
+		}
+		if c.RampDown > 0 {
+			return errors.New("config: run until stable can't be used with ramp down as the run can stop before connections are retired")
+		}
+	}
+	if c.RampDown > 0 {
+		if c.Duration == 0 || c.ReqTarget != 0 {
+			return errors.New("config: ramp down requires a time window with unlimited requests")
+		}
+		if c.RampDown > c.Duration {
+			return errors.New("config: ramp down can't be longer than the time window")
+		}
+	}
+
+	if c.Rate < 0 {
+		return errors.New("config: rate can't be negative")
+	}
+	if c.Rate > 0 && c.ReqTarget != 0 && c.Duration != 0 {
+		return errors.New("config: rate can't be used when requests are spread over a time window")
+	}
+	if c.RatePerConn < 0 {
+		return errors.New("config: rate per connection can't be negative")
+	}
+	if c.RatePerConn > 0 && c.Rate > 0 {
+		return errors.New("config: rate and rate per connection are mutually exclusive")
+	}
+	if c.RatePerConn > 0 && c.ReqTarget != 0 && c.Duration != 0 {
+		return errors.New("config: rate per connection can't be used when requests are spread over a time window")
+	}
+	if c.RequireRate {
+		if c.Rate == 0 && c.RatePerConn == 0 {
+			return errors.New("config: require rate needs a rate or rate per connection to check")
+		}
+		if c.Interactive || c.AdaptiveBackoff {
+			return errors.New("config: require rate can't be used when the rate changes while running i.e. interactive mode or adaptive backoff")
+		}
+	}
+	if c.FairRate && c.Rate == 0 {
+		return errors.New("config: fair rate requires a rate to split between connections")
+	}
+	if c.OpenModel && c.Rate == 0 && c.RatePerConn == 0 {
+		return errors.New("config: open model requires a rate or rate per connection to schedule requests")
+	}
+	if c.MaxInflight < 0 {
+		return errors.New("config: max inflight can't be negative")
+	}
+	if c.MaxInflight > 0 {
+		if !c.OpenModel || c.Rate == 0 {
+			return errors.New("config: max inflight requires the open model with a rate")
+		}
+		if c.FairRate || c.Interactive || c.AdaptiveBackoff {
+			return errors.New("config: max inflight can't be used when the rate is split or changes while running i.e. fair rate, interactive mode or adaptive backoff")
+		}
+		switch c.InflightOverflow {
+		case "", "delay", "drop":
+		default:
+			return fmt.Errorf("config: inflight overflow %s needs to be one of delay or drop", c.InflightOverflow)
+		}
+	}
+	if c.AdaptiveThinkTime < 0 {
+		return errors.New("config: adaptive think time can't be negative")
+	}
+	if c.AdaptiveThinkTime > 0 {
+		if c.ReqTarget != 0 && c.Duration != 0 {
+			return errors.New("config: adaptive think time can't be used when requests are spread over a time window")
+		}
+		if c.OpenModel || c.ReplayHAR != "" {
+			return errors.New("config: adaptive think time can't be used when requests are sent on a schedule i.e. open model or replaying a HAR")
+		}
+	}
+
+	if c.Interactive {
+		if c.Rate == 0 {
+			return errors.New("config: interactive mode requires a rate to adjust")
+		}
+		if c.Duration == 0 || c.ReqTarget != 0 {
+			return errors.New("config: interactive mode requires a time window with unlimited requests")
+		}
+	}
+
+	if c.AdaptiveBackoff {
+		if c.Rate == 0 {
+			return errors.New("config: adaptive backoff requires a rate to back off from")
+		}
+		if c.Interactive {
+			return errors.New("config: adaptive backoff and interactive mode both change the rate so can't be used together")
+		}
+		if c.BackoffThreshold < 0 || c.BackoffThreshold >= 1 {
+			return errors.New("config: backoff threshold must be at least 0 and less than 1")
+		}
+		if c.BackoffWindow <= 0 {
+			return errors.New("config: backoff window must be more than 0")
+		}
+	}
+
+	if c.CompareProtocols {
+		// every protocol is run with its own client so client specific options can't be used
+		if len(c.HeaderOrder) > 0 || c.MaxConnDuration > 0 || c.TimeoutJitter > 0 || c.CloseRate > 0 || c.ForceContentLength != "" {
+			return errors.New("config: compare protocols can't be used with client specific options i.e. header order, max connection duration, timeout jitter, close rate or force content length")
+		}
+		if c.Interactive {
+			return errors.New("config: compare protocols can't be used with interactive mode")
+		}
+		if c.SQLitePath != "" {
+			return errors.New("config: compare protocols can't be used with sqlite output")
+		}
+		if c.JTLPath != "" {
+			return errors.New("config: compare protocols can't be used with jtl output")
+		}
+		if c.ConnsReportPath != "" {
+			return errors.New("config: compare protocols can't be used with a connections report")
+		}
+		if c.LatencyFlamegraph != "" {
+			return errors.New("config: compare protocols can't be used with a latency flamegraph")
+		}
+		if c.IntervalCSVPath != "" {
+			return errors.New("config: compare protocols can't be used with interval csv output")
+		}
+		if c.RequireRate {
+			return errors.New("config: compare protocols can't be used with require rate")
+		}
+		if c.TTFB {
+			// HTTP/2 is run with fasthttp-2
+			return errors.New("config: compare protocols can't be used with ttfb")
+		}
+	}
+
+	if c.LatencyUnit != "" && !units.IsUnit(c.LatencyUnit) {
+		return fmt.Errorf("config: latency unit %s needs to be one of ns, us, ms, s or auto", c.LatencyUnit)
+	}
+	if c.LatencyPrecision < units.AsNeeded || c.LatencyPrecision > 9 {
+		// nanoseconds in seconds are 9 places
+		return errors.New("config: latency precision needs to be between 0 and 9, or -1 for as many places as needed")
+	}
+
+	if c.Estimate {
+		if c.EstimateProbes < 0 {
+			return errors.New("config: estimate probes can't be negative")
+		}
+		if c.EstimateProbes == 0 {
+			c.EstimateProbes = 20
+		}
+		if c.CompareProtocols || c.Repeat > 1 {
+			return errors.New("config: estimate can't be used with compare protocols or repeat")
+		}
+	}
+
+	if c.Repeat < 0 {
+		return errors.New("config: repeat can't be negative")
+	}
+	if c.Repeat > 1 {
+		// every run would overwrite the output of the one before
+		if c.JTLPath != "" {
+			return errors.New("config: repeat can't be used with jtl output")
+		}
+		if c.IntervalCSVPath != "" {
+			return errors.New("config: repeat can't be used with interval csv output")
+		}
+		if c.ConnsReportPath != "" {
+			return errors.New("config: repeat can't be used with a connections report")
+		}
+		if c.LatencyFlamegraph != "" {
+			return errors.New("config: repeat can't be used with a latency flamegraph")
+		}
+		if c.Interactive {
+			return errors.New("config: repeat can't be used with interactive mode")
+		}
+		if c.CompareProtocols {
+			return errors.New("config: repeat can't be used with compare protocols")
+		}
+	}
 
 	if c.JwtCustomClaimsJSON != "" {
 		_, err := JwtCustomClaimsJSONStringToMap(c.JwtCustomClaimsJSON)
@@ -222,6 +1268,20 @@ func (c *Config) Validate() error {
 			return fmt.Errorf("config: failed to parse custom json in --jwt-claims, got error; %v", err)
 		}
 	}
+	if c.JwtClaimsTemplate != "" {
+		if !hasJwtKey {
+			return errors.New("config: jwt claims template requires a jwt key to sign the jwts with")
+		}
+		if _, err := jwt_claims.Parse(c.JwtClaimsTemplate); err != nil {
+			return fmt.Errorf("config: invalid --jwt-claims-template; %v", err)
+		}
+	}
+	if c.JwtRotateEvery < 0 {
+		return errors.New("config: jwt rotate every can't be negative")
+	}
+	if c.JwtRotateEvery > 1 && !c.SendJWT {
+		return errors.New("config: jwt rotate every requires jwts to be sent, set --jwt-key or --jwts-filename")
+	}
 
 	return nil
 }
@@ -234,3 +1294,89 @@ func methodAllowed(method string) bool {
 	}
 	return false
 }
+
+// jwtsFilenames splits the comma separated --jwts-filename, expanding globs. A file matched more than once is only
+// read once so its JWTs aren't sent more than the other files'
+func jwtsFilenames(spec string) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("config: empty jwt filename in %q", spec)
+		}
+		matches := []string{name}
+		if strings.ContainsAny(name, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(name); err != nil {
+				return nil, fmt.Errorf("config: invalid jwt file glob %s; %v", name, err)
+			}
+			if len(matches) == 0 {
+				return nil, errors.New("config: jwt file glob matched no files: " + name)
+			}
+		}
+		for _, file := range matches {
+			if !seen[file] {
+				seen[file] = true
+				files = append(files, file)
+			}
+		}
+	}
+	return files, nil
+}
+
+// validateTemplates checks the URL and header templates and the flags they can be used with, the columns of
+// --data-csv are checked against the templates once the files are read for the run with CheckDataFields
+func (c *Config) validateTemplates() error {
+	uriTemplate, err := http_clients.ParseURITemplate(c.ReqURI)
+	if err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	if uriTemplate != nil {
+		if c.RawPath || c.QueryFuzz != "" || c.CompareURI != "" || c.ReplayHAR != "" || c.Scenario != "" {
+			return errors.New("config: a templated url can't be used with raw path, query fuzz, compare target, replay har or scenario")
+		}
+	}
+	if _, err := http_clients.ParseHeaderTemplates(c.Headers); err != nil {
+		return fmt.Errorf("config: invalid header template; %v", err)
+	}
+
+	if c.DataCSV == "" {
+		return nil
+	}
+	if c.ReplayHAR != "" {
+		return errors.New("config: data csv can't be used with replay har")
+	}
+	if _, err := os.Stat(c.DataCSV); err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("config: data csv does not exist")
+		}
+		return fmt.Errorf("config: data csv error checking file exists; %v", err)
+	}
+	return nil
+}
+
+// CheckScenario checks the steps of the --scenario file once it's been read for the run
+func CheckScenario(steps []http_clients.ScenarioStep) error {
+	for _, s := range steps {
+		if !methodAllowed(s.Method) {
+			return fmt.Errorf("config: scenario step %s method %s isn't supported", s.Name, s.Method)
+		}
+	}
+	return nil
+}
+
+// CheckDataFields checks every {{.Data.<column>}} the templates use is in the --data-csv file once it's been read
+// for the run, data is nil without one
+func CheckDataFields(data *http_clients.DataCSV, templates ...*template.Template) error {
+	if data == nil {
+		if len(http_clients.DataFields(templates...)) > 0 {
+			return errors.New("config: templates use .Data which needs a data csv")
+		}
+		return nil
+	}
+	if err := data.CheckFields(templates...); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,935 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckScenario(t *testing.T) {
+	steps := []http_clients.ScenarioStep{{Name: "login", Method: "POST"}, {Name: "orders", Method: "GET"}}
+	if err := CheckScenario(steps); err != nil {
+		t.Errorf("wanted no error for supported methods got %v", err)
+	}
+
+	steps = append(steps, http_clients.ScenarioStep{Name: "connect", Method: "CONNECT"})
+	if err := CheckScenario(steps); err == nil || err.Error() != "config: scenario step connect method CONNECT isn't supported" {
+		t.Errorf("wanted error for an unsupported method got %v", err)
+	}
+}
+
+// validConfig is the least a run needs, for tests to change into an invalid one
+func validConfig() *Config {
+	return &Config{
+		Ctx:           context.Background(),
+		ReqURI:        "http://localhost:8080",
+		ReqTarget:     10,
+		Conns:         1,
+		ReadTimeout:   time.Second,
+		WriteTimeout:  time.Second,
+		Method:        "GET",
+		Client:        "fasthttp-1",
+		VerboseTicker: time.Second,
+	}
+}
+
+func TestConfig_ValidateInvalid(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string
+	}{
+		{
+			name:    "header order with nethttp",
+			change:  func(c *Config) { c.Client, c.HeaderOrder = "nethttp", []string{"host"} },
+			wantErr: "header order is only supported by the fasthttp-1 client",
+		},
+		{
+			name:    "adaptive backoff without rate",
+			change:  func(c *Config) { c.AdaptiveBackoff, c.BackoffWindow = true, time.Second },
+			wantErr: "adaptive backoff requires a rate to back off from",
+		},
+		{
+			name: "backoff threshold of 1",
+			change: func(c *Config) {
+				c.Rate, c.AdaptiveBackoff, c.BackoffThreshold, c.BackoffWindow = 10, true, 1, time.Second
+			},
+			wantErr: "backoff threshold must be at least 0 and less than 1",
+		},
+		{
+			name:    "log sample rate over 1",
+			change:  func(c *Config) { c.Verbose, c.LogSampleRate = true, 1.5 },
+			wantErr: "log sample rate must be between 0 and 1",
+		},
+		{
+			name:    "log sample rate without verbose",
+			change:  func(c *Config) { c.LogSampleRate = 0.1 },
+			wantErr: "log sample rate requires verbose mode",
+		},
+		{
+			name:    "negative max idle connection duration",
+			change:  func(c *Config) { c.MaxIdleConnDuration = -time.Second },
+			wantErr: "max idle connection duration can't be negative",
+		},
+		{
+			name:    "max connection duration with nethttp-3",
+			change:  func(c *Config) { c.Client, c.MaxConnDuration = "nethttp-3", time.Second },
+			wantErr: "max connection duration isn't supported by the nethttp-3 client",
+		},
+		{
+			name:    "compare protocols with a client specific option",
+			change:  func(c *Config) { c.CompareProtocols, c.MaxConnDuration = true, time.Second },
+			wantErr: "compare protocols can't be used with client specific options",
+		},
+		{
+			name:    "missing body template file",
+			change:  func(c *Config) { c.BodyTemplateFile = "does-not-exist.tmpl" },
+			wantErr: "body template file does not exist",
+		},
+		{
+			name:    "metrics address without port",
+			change:  func(c *Config) { c.MetricsAddr = "localhost" },
+			wantErr: "metrics address needs to be like host:port",
+		},
+		{
+			name:    "trace header with a value",
+			change:  func(c *Config) { c.TraceHeader = "X-Trace: 1" },
+			wantErr: "trace header X-Trace: 1 should be a header name only",
+		},
+		{
+			name:    "invalid escape without raw path",
+			change:  func(c *Config) { c.ReqURI = "http://localhost:8080/x%zz" },
+			wantErr: "invalid request uri",
+		},
+		{
+			name:    "negative min samples",
+			change:  func(c *Config) { c.MinSamples = -1 },
+			wantErr: "min samples can't be negative",
+		},
+		{
+			name:    "slo success rate over 100",
+			change:  func(c *Config) { c.SLOSuccessRate = 101 },
+			wantErr: "slo success rate must be between 0 and 100",
+		},
+		{
+			name: "jwt key from env and stdin",
+			change: func(c *Config) {
+				c.JwtHeader, c.JwtKeyEnv, c.JwtKeyStdin = "some-jwt", "GOPAYLOADER_TEST_JWT_KEY", true
+			},
+			wantErr: "only one of jwt key, jwt key env and jwt key stdin can be used",
+		},
+		{
+			name:    "negative connect retries",
+			change:  func(c *Config) { c.ConnectRetries = -1 },
+			wantErr: "connect retries can't be negative",
+		},
+		{
+			name:    "invalid body hex",
+			change:  func(c *Config) { c.Method, c.BodyHex = "POST", "0g" },
+			wantErr: "invalid body hex",
+		},
+		{
+			name:    "body hex with a body",
+			change:  func(c *Config) { c.Method, c.Body, c.BodyHex = "POST", "a", "61" },
+			wantErr: "body hex can't be used with a body or body file",
+		},
+		{
+			name:    "cert info with a http url",
+			change:  func(c *Config) { c.CertInfo = true },
+			wantErr: "cert info requires an https url",
+		},
+		{
+			name:    "ready path without wait for ready",
+			change:  func(c *Config) { c.ReadyPath = "/healthz" },
+			wantErr: "ready path requires wait for ready",
+		},
+		{
+			name:    "relative ready path",
+			change:  func(c *Config) { c.WaitForReady, c.ReadyTimeout, c.ReadyPath = true, time.Second, "healthz" },
+			wantErr: "ready path must start with /",
+		},
+		{
+			name:    "success code out of range",
+			change:  func(c *Config) { c.SuccessCodes = []int{200, 600} },
+			wantErr: "success code 600 isn't a HTTP status code",
+		},
+		{
+			name:    "missing ca cert",
+			change:  func(c *Config) { c.CACert = "does-not-exist.pem" },
+			wantErr: "invalid ca cert",
+		},
+		{
+			name:    "ramp down with a request target",
+			change:  func(c *Config) { c.Duration, c.RampDown = 2*time.Second, time.Second },
+			wantErr: "ramp down requires a time window with unlimited requests",
+		},
+		{
+			name:    "ramp down longer than the window",
+			change:  func(c *Config) { c.ReqTarget, c.Duration, c.RampDown = 0, time.Second, 2*time.Second },
+			wantErr: "ramp down can't be longer than the time window",
+		},
+		{
+			name:    "negative timeout jitter",
+			change:  func(c *Config) { c.TimeoutJitter = -time.Second },
+			wantErr: "timeout jitter can't be negative",
+		},
+		{
+			name:    "timeout jitter with nethttp-3",
+			change:  func(c *Config) { c.Client, c.TimeoutJitter = "nethttp-3", time.Second },
+			wantErr: "timeout jitter isn't supported by the fasthttp-2 and nethttp-3 clients",
+		},
+		{
+			name:    "invalid query fuzz",
+			change:  func(c *Config) { c.QueryFuzz = "page=randInt(5,1)" },
+			wantErr: "invalid query fuzz; query fuzz parameter page; randInt max 1 must be more than min 5",
+		},
+		{
+			name:    "query fuzz with raw path",
+			change:  func(c *Config) { c.QueryFuzz, c.RawPath = "page=randInt(1,5)", true },
+			wantErr: "query fuzz can't be used with raw path",
+		},
+		{
+			name:    "negative max bytes",
+			change:  func(c *Config) { c.MaxBytes = -1 },
+			wantErr: "max bytes can't be negative",
+		},
+		{
+			name:    "SRV record without a proto",
+			change:  func(c *Config) { c.SRVRecord = "_http.srv.test" },
+			wantErr: "SRV record _http.srv.test needs to be like _service._proto.name i.e. _http._tcp.example.com",
+		},
+		{
+			name:    "assert json path without $",
+			change:  func(c *Config) { c.AssertJSONPath = "status" },
+			wantErr: "invalid assert json path",
+		},
+		{
+			name:    "assert json equals without a path",
+			change:  func(c *Config) { c.AssertJSONEquals = "ok" },
+			wantErr: "assert json equals requires an assert json path",
+		},
+		{
+			name:    "close rate above 1",
+			change:  func(c *Config) { c.CloseRate = 1.5 },
+			wantErr: "close rate must be between 0 and 1",
+		},
+		{
+			name:    "close rate with fasthttp-2",
+			change:  func(c *Config) { c.Client, c.CloseRate = "fasthttp-2", 0.2 },
+			wantErr: "close rate is only supported by HTTP/1.1 clients",
+		},
+		{
+			name:    "close rate without keep-alive",
+			change:  func(c *Config) { c.DisableKeepAlive, c.CloseRate = true, 0.2 },
+			wantErr: "close rate can't be used when keep-alive is disabled",
+		},
+		{
+			name:    "stream response body with fasthttp-2",
+			change:  func(c *Config) { c.Client, c.StreamRespBody = "fasthttp-2", true },
+			wantErr: "streaming response bodies isn't supported by the fasthttp-2 client",
+		},
+		{
+			name:    "expect sha256 without streaming",
+			change:  func(c *Config) { c.ExpectSHA256 = strings.Repeat("ab", 32) },
+			wantErr: "expect sha256 requires streaming response bodies",
+		},
+		{
+			name:    "short expect sha256",
+			change:  func(c *Config) { c.StreamRespBody, c.ExpectSHA256 = true, "abc" },
+			wantErr: "expect sha256 abc needs to be 64 hex characters",
+		},
+		{
+			name:    "unsupported hmac algo",
+			change:  func(c *Config) { c.HMACSecret, c.HMACHeader, c.HMACAlgo = "shh", "X-Signature", "md5" },
+			wantErr: "hmac algo md5 needs to be one of sha256, sha1 or sha512",
+		},
+		{
+			name:    "hmac header with a value",
+			change:  func(c *Config) { c.HMACSecret, c.HMACHeader, c.HMACAlgo = "shh", "X-Signature: v1", "sha256" },
+			wantErr: `hmac header "X-Signature: v1" should be a header name only`,
+		},
+		{
+			name:    "negative max header size",
+			change:  func(c *Config) { c.MaxHeaderSize = -1 },
+			wantErr: "max header size can't be negative",
+		},
+		{
+			name:    "max header size with fasthttp-2",
+			change:  func(c *Config) { c.Client, c.MaxHeaderSize = "fasthttp-2", 64<<10 },
+			wantErr: "max header size isn't supported by the fasthttp-2 client",
+		},
+		{
+			name:    "conn batch size without an interval",
+			change:  func(c *Config) { c.ConnBatchSize = 1 },
+			wantErr: "conn batch size requires a conn batch interval above 0",
+		},
+		{
+			name:    "latency SLO without a percentile",
+			change:  func(c *Config) { c.SLOLatency = []string{"99=250ms"} },
+			wantErr: `latency SLO "99=250ms" needs to be like p99=250ms`,
+		},
+		{
+			name:    "HTTP version 1.0 with nethttp",
+			change:  func(c *Config) { c.Client, c.HTTPVersion = "nethttp", "1.0" },
+			wantErr: "HTTP version 1.0 is only supported by the fasthttp-1 client",
+		},
+		{
+			name:    "unknown HTTP version",
+			change:  func(c *Config) { c.HTTPVersion = "2" },
+			wantErr: `HTTP version "2" must be 1.0 or 1.1`,
+		},
+		{
+			name:    "run until stable without a time window",
+			change:  func(c *Config) { c.RunUntilStable, c.StableInterval, c.StableThreshold = true, time.Second, 0.1 },
+			wantErr: "run until stable requires a time window",
+		},
+		{
+			name: "stable min as long as the window",
+			change: func(c *Config) {
+				c.ReqTarget, c.Duration, c.RunUntilStable, c.StableMin, c.StableInterval, c.StableThreshold = 0, time.Minute, true, time.Minute, time.Second, 0.1
+			},
+			wantErr: "stable min must be 0 or more and shorter than the time window",
+		},
+		{
+			name: "stable interval of 0",
+			change: func(c *Config) {
+				c.ReqTarget, c.Duration, c.RunUntilStable, c.StableThreshold = 0, time.Minute, true, 0.1
+			},
+			wantErr: "stable interval must be above 0",
+		},
+		{
+			name: "stable threshold of 0",
+			change: func(c *Config) {
+				c.ReqTarget, c.Duration, c.RunUntilStable, c.StableInterval = 0, time.Minute, true, time.Second
+			},
+			wantErr: "stable threshold must be above 0",
+		},
+		{
+			name: "run until stable with ramp down",
+			change: func(c *Config) {
+				c.ReqTarget, c.Duration, c.RunUntilStable, c.StableInterval, c.StableThreshold, c.RampDown = 0, time.Minute, true, time.Second, 0.1, time.Second
+			},
+			wantErr: "run until stable can't be used with ramp down",
+		},
+		{
+			name:    "discard body with fasthttp-2",
+			change:  func(c *Config) { c.Client, c.DiscardBody = "fasthttp-2", true },
+			wantErr: "discarding response bodies isn't supported by the fasthttp-2 client",
+		},
+		{
+			name:    "discard body with assert json path",
+			change:  func(c *Config) { c.DiscardBody, c.AssertJSONPath = true, "$.status" },
+			wantErr: "discarding response bodies can't be used with streaming response bodies, a compare target or assert json path",
+		},
+		{
+			name:    "missing mtls cert dir",
+			change:  func(c *Config) { c.MTLSCertDir = "does-not-exist" },
+			wantErr: "invalid mTLS cert dir",
+		},
+		{
+			name:    "unparseable header template",
+			change:  func(c *Config) { c.Headers = []string{"X-Nonce: {{.UUID"} },
+			wantErr: "header X-Nonce template",
+		},
+		{
+			name: "header templates with header order",
+			change: func(c *Config) {
+				c.Headers = []string{"X-Nonce: {{.UUID}}"}
+				c.HeaderOrder = []string{"X-Nonce"}
+			},
+			wantErr: "header templates can't be used with header order",
+		},
+		{
+			name: "header templates with replay har",
+			change: func(c *Config) {
+				c.Headers = []string{"X-Nonce: {{.UUID}}"}
+				c.ReplayHAR = "requests.har"
+			},
+			wantErr: "header templates can't be used with replay har",
+		},
+		{
+			name:    "ttfb with fasthttp-2",
+			change:  func(c *Config) { c.TTFB, c.Client = true, "fasthttp-2" },
+			wantErr: "ttfb isn't supported by the fasthttp-2 client",
+		},
+		{
+			name:    "compare protocols with ttfb",
+			change:  func(c *Config) { c.CompareProtocols, c.TTFB = true, true },
+			wantErr: "compare protocols can't be used with ttfb",
+		},
+		{
+			name:    "negative jwt rotate every",
+			change:  func(c *Config) { c.JwtRotateEvery = -1 },
+			wantErr: "jwt rotate every can't be negative",
+		},
+		{
+			name:    "jwt rotate every without jwts",
+			change:  func(c *Config) { c.JwtRotateEvery = 4 },
+			wantErr: "jwt rotate every requires jwts to be sent",
+		},
+		{
+			name:    "conn outlier factor of 1",
+			change:  func(c *Config) { c.ConnOutlierFactor = 1 },
+			wantErr: "conn outlier factor must be above 1",
+		},
+		{
+			name:    "invalid source port range",
+			change:  func(c *Config) { c.SourcePortRange = "30000-20000" },
+			wantErr: "invalid port range 30000-20000",
+		},
+		{
+			name:    "source port range with http3",
+			change:  func(c *Config) { c.SourcePortRange, c.Client = "20000-20001", "nethttp-3" },
+			wantErr: "source port range isn't supported by HTTP/3",
+		},
+		{
+			name:    "fewer source ports than connections",
+			change:  func(c *Config) { c.SourcePortRange, c.Conns = "20000-20001", 3 },
+			wantErr: "has 2 ports, fewer than the 3 connections",
+		},
+		{
+			name:    "dns server with doh url",
+			change:  func(c *Config) { c.DNSServer, c.DoHURL = "10.0.0.53", "https://dns.example.com/dns-query" },
+			wantErr: "dns server and DoH url can't be used together",
+		},
+		{
+			name:    "dns server name",
+			change:  func(c *Config) { c.DNSServer = "dns.example.com:53" },
+			wantErr: "DNS server dns.example.com:53 needs to be an IP",
+		},
+		{
+			name:    "progress bar without a number of requests",
+			change:  func(c *Config) { c.Progress, c.ReqTarget, c.Duration = true, 0, time.Second },
+			wantErr: "progress bar requires a number of requests",
+		},
+		{
+			name:    "progress bar with verbose",
+			change:  func(c *Config) { c.Progress, c.Verbose = true, true },
+			wantErr: "progress bar can't be used with verbose mode",
+		},
+		{
+			name:    "trace with a body",
+			change:  func(c *Config) { c.Method, c.Body = "TRACE", "reflect me" },
+			wantErr: "TRACE requests can't have a body",
+		},
+		{
+			name:    "negative max samples",
+			change:  func(c *Config) { c.MaxSamples = -1 },
+			wantErr: "max samples can't be negative",
+		},
+		{
+			name:    "max samples without conn outlier factor",
+			change:  func(c *Config) { c.MaxSamples = 16 },
+			wantErr: "max samples requires conn outlier factor",
+		},
+		{
+			name:    "too few max samples",
+			change:  func(c *Config) { c.MaxSamples, c.ConnOutlierFactor = 5, 3 },
+			wantErr: "max samples must be at least 10",
+		},
+		{
+			name:    "content hash header with a value",
+			change:  func(c *Config) { c.ContentHashHeader = "X-Content-Hash: v1" },
+			wantErr: "content hash header X-Content-Hash: v1 should be a header name only",
+		},
+		{
+			name: "content hash header with body stream",
+			change: func(c *Config) {
+				// any file that exists will do as the body
+				c.ContentHashHeader, c.BodyStream, c.BodyFile = "X-Content-Hash", true, "config_test.go"
+			},
+			wantErr: "content hash header can't be used with body stream",
+		},
+		{
+			name:    "content hash header with header order",
+			change:  func(c *Config) { c.ContentHashHeader, c.HeaderOrder = "X-Content-Hash", []string{"Host"} },
+			wantErr: "content hash header can't be used with header order",
+		},
+		{
+			name:    "invalid method concurrency",
+			change:  func(c *Config) { c.MethodConcurrency = "POST=0" },
+			wantErr: "invalid method concurrency",
+		},
+		{
+			name:    "method concurrency of an unsupported method",
+			change:  func(c *Config) { c.MethodConcurrency = "PATCH=1" },
+			wantErr: "method concurrency caps method PATCH which isn't supported",
+		},
+		{
+			name:    "invalid force content length",
+			change:  func(c *Config) { c.ForceContentLength, c.Body = "-1", "{}" },
+			wantErr: "force content length -1 should be none or a number of bytes",
+		},
+		{
+			name:    "force content length with nethttp",
+			change:  func(c *Config) { c.ForceContentLength, c.Body, c.Client = "0", "{}", "nethttp" },
+			wantErr: "force content length is only supported by the fasthttp-1 client",
+		},
+		{
+			name:    "force content length without a body",
+			change:  func(c *Config) { c.ForceContentLength = "0" },
+			wantErr: "force content length requires a body",
+		},
+		{
+			name:    "force content length with header templates",
+			change:  func(c *Config) { c.ForceContentLength, c.Body, c.Headers = "0", "{}", []string{"X-Seq: {{.Index}}"} },
+			wantErr: "force content length can't be used with body stream, a body template, header templates",
+		},
+		{
+			name:    "statsd address without a host",
+			change:  func(c *Config) { c.StatsDAddr = ":8125" },
+			wantErr: "statsd address needs to be like host:port",
+		},
+		{
+			name:    "unknown tls fingerprint",
+			change:  func(c *Config) { c.TLSFingerprint, c.ReqURI = "safari", "https://localhost:8080" },
+			wantErr: "tls fingerprint safari needs to be one of chrome, firefox or go",
+		},
+		{
+			name:    "tls fingerprint without https",
+			change:  func(c *Config) { c.TLSFingerprint = "chrome" },
+			wantErr: "tls fingerprint requires an https url",
+		},
+		{
+			name:    "negative h2 ping interval",
+			change:  func(c *Config) { c.Client, c.H2PingInterval = "fasthttp-2", -time.Second },
+			wantErr: "h2 ping interval can't be negative",
+		},
+		{
+			name:    "h2 ping interval with an HTTP/1.1 client",
+			change:  func(c *Config) { c.H2PingInterval = time.Second },
+			wantErr: "h2 ping interval is only supported by the fasthttp-2 client",
+		},
+		{
+			name:    "latency SLO on a path without a spec",
+			change:  func(c *Config) { c.SLOLatency = []string{"/search"} },
+			wantErr: `latency SLO for /search; latency SLO "" needs to be like p99=250ms`,
+		},
+		{
+			name:    "latency SLO on a path without a target",
+			change:  func(c *Config) { c.SLOLatency = []string{"/search:p99"} },
+			wantErr: `latency SLO for /search; latency SLO "p99" needs to be like p99=250ms`,
+		},
+		{
+			name:    "latency SLO on a path with a query",
+			change:  func(c *Config) { c.SLOLatency = []string{"/search?q=1:p99=1s"} },
+			wantErr: `latency SLO "/search?q=1:p99=1s" path can't have a query`,
+		},
+		{
+			name:    "body proto descriptor without a message",
+			change:  func(c *Config) { c.BodyProtoDescriptor = "shop.pb" },
+			wantErr: "body proto descriptor and body proto message need to be set together",
+		},
+		{
+			name:    "body proto message without a body",
+			change:  func(c *Config) { c.BodyProtoDescriptor, c.BodyProtoMessage = "shop.pb", "shop.v1.Order" },
+			wantErr: "body proto message requires the JSON to encode",
+		},
+		{
+			name: "body proto message with body stream",
+			change: func(c *Config) {
+				// an existing file so it isn't the missing body file which fails
+				c.BodyProtoDescriptor, c.BodyProtoMessage, c.BodyFile, c.BodyStream = "shop.pb", "shop.v1.Order", "config_test.go", true
+			},
+			wantErr: "body proto message can't be used with body hex, body stream or a body template",
+		},
+		{
+			name:    "unknown latency unit",
+			change:  func(c *Config) { c.LatencyUnit = "m" },
+			wantErr: "latency unit m needs to be one of ns, us, ms, s or auto",
+		},
+		{
+			name:    "latency unit with the micro sign",
+			change:  func(c *Config) { c.LatencyUnit = "µs" },
+			wantErr: "latency unit µs needs to be one of ns, us, ms, s or auto",
+		},
+		{
+			name:    "latency precision below as needed",
+			change:  func(c *Config) { c.LatencyUnit, c.LatencyPrecision = "ms", -2 },
+			wantErr: "latency precision needs to be between 0 and 9",
+		},
+		{
+			name:    "latency precision past nanoseconds",
+			change:  func(c *Config) { c.LatencyUnit, c.LatencyPrecision = "ms", 10 },
+			wantErr: "latency precision needs to be between 0 and 9",
+		},
+		{
+			name:    "target metrics url without a scheme",
+			change:  func(c *Config) { c.TargetMetricsURL = "localhost:9100/metrics" },
+			wantErr: "target metrics url needs to be an http or https url",
+		},
+		{
+			name: "negative target metrics interval",
+			change: func(c *Config) {
+				c.TargetMetricsURL, c.TargetMetricsEvery = "http://localhost:9100/metrics", -time.Second
+			},
+			wantErr: "target metrics interval can't be negative",
+		},
+		{
+			name:    "invalid success expression",
+			change:  func(c *Config) { c.SuccessExpr = `status = 200` },
+			wantErr: "invalid success expression",
+		},
+		{
+			name:    "success expression reading discarded bodies",
+			change:  func(c *Config) { c.SuccessExpr, c.DiscardBody = `json("$.order.state") == "paid"`, true },
+			wantErr: "a success expression using body or json can't be used with streaming or discarding response bodies",
+		},
+		{
+			name:    "replay multiplier without replay har",
+			change:  func(c *Config) { c.ReplayMultiplier = 2 },
+			wantErr: "replay multiplier requires replay har",
+		},
+		{
+			name:    "negative replay multiplier",
+			change:  func(c *Config) { c.ReplayMultiplier = -1 },
+			wantErr: "replay multiplier can't be negative",
+		},
+		{
+			name:    "negative estimate probes",
+			change:  func(c *Config) { c.Estimate, c.EstimateProbes = true, -1 },
+			wantErr: "estimate probes can't be negative",
+		},
+		{
+			name:    "estimate with compare protocols",
+			change:  func(c *Config) { c.Estimate, c.CompareProtocols = true, true },
+			wantErr: "estimate can't be used with compare protocols or repeat",
+		},
+		{
+			name:    "negative max expected body",
+			change:  func(c *Config) { c.MaxExpectedBody = -1 },
+			wantErr: "max expected body can't be negative",
+		},
+		{
+			name:    "max expected body with discarded bodies",
+			change:  func(c *Config) { c.MaxExpectedBody, c.DiscardBody = 1024, true },
+			wantErr: "max expected body can't be used with discarding response bodies",
+		},
+		{
+			name:    "latency flamegraph with fasthttp-1",
+			change:  func(c *Config) { c.LatencyFlamegraph = "latency.folded" },
+			wantErr: "latency flamegraph is only supported by the nethttp client",
+		},
+		{
+			name:    "fair rate without a rate",
+			change:  func(c *Config) { c.FairRate = true },
+			wantErr: "fair rate requires a rate to split between connections",
+		},
+		{
+			name: "identities with an mTLS cert",
+			change: func(c *Config) {
+				// existing files so it isn't a missing cert which fails
+				c.Identities, c.MTLSCert, c.MTLSKey = "identities.json", "config_test.go", "config_test.go"
+			},
+			wantErr: "identities can't be used with an mTLS cert or cert dir",
+		},
+		{
+			name:    "missing identities file",
+			change:  func(c *Config) { c.Identities = "identities.json" },
+			wantErr: "invalid identities; failed to read identities file",
+		},
+		{
+			name:    "negative adaptive think time",
+			change:  func(c *Config) { c.AdaptiveThinkTime = -time.Second },
+			wantErr: "adaptive think time can't be negative",
+		},
+		{
+			name:    "adaptive think time over a time window",
+			change:  func(c *Config) { c.AdaptiveThinkTime, c.Duration = time.Second, time.Second },
+			wantErr: "adaptive think time can't be used when requests are spread over a time window",
+		},
+		{
+			name:    "adaptive think time with an open model",
+			change:  func(c *Config) { c.AdaptiveThinkTime, c.Rate, c.OpenModel = time.Second, 10, true },
+			wantErr: "adaptive think time can't be used when requests are sent on a schedule",
+		},
+		{
+			name: "replay har with a request target",
+			// any existing file, the recording is only read for the run
+			change:  func(c *Config) { c.ReplayHAR = "config_test.go" },
+			wantErr: "replay har can't be used with requests, time, max bytes or a rate",
+		},
+		{
+			name: "replay har which doesn't exist",
+			change: func(c *Config) {
+				c.ReqTarget = 0
+				c.ReplayHAR = "missing.har"
+			},
+			wantErr: "replay har does not exist",
+		},
+		{
+			name: "repeat with jtl",
+			change: func(c *Config) {
+				c.Repeat = 3
+				c.JTLPath = "results.jtl"
+			},
+			wantErr: "repeat can't be used with jtl output",
+		},
+		{
+			name:    "abort on slo breach without an slo",
+			change:  func(c *Config) { c.AbortOnSLOBreach = true },
+			wantErr: "abort on slo breach needs an slo success rate or slo latency to check",
+		},
+		{
+			name: "connections report with fasthttp-2",
+			change: func(c *Config) {
+				c.Client = "fasthttp-2"
+				c.ConnsReportPath = "conns.csv"
+			},
+			wantErr: "connections report is only supported by the fasthttp-1 and nethttp clients",
+		},
+		{
+			name: "compare protocols with force content length",
+			change: func(c *Config) {
+				c.CompareProtocols = true
+				c.Body = "body"
+				c.ForceContentLength = "10"
+			},
+			wantErr: "compare protocols can't be used with client specific options i.e. header order, max connection duration, timeout jitter, close rate or force content length",
+		},
+		{
+			name:    "negative max inflight",
+			change:  func(c *Config) { c.Rate, c.OpenModel, c.MaxInflight = 10, true, -1 },
+			wantErr: "max inflight can't be negative",
+		},
+		{
+			name:    "max inflight without open model",
+			change:  func(c *Config) { c.Rate, c.MaxInflight = 10, 10 },
+			wantErr: "max inflight requires the open model with a rate",
+		},
+		{
+			name:    "unknown inflight overflow",
+			change:  func(c *Config) { c.Rate, c.OpenModel, c.MaxInflight, c.InflightOverflow = 10, true, 10, "queue" },
+			wantErr: "inflight overflow queue needs to be one of delay or drop",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			c := validConfig()
+			tt.change(c)
+			if err := c.Validate(); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wanted error %q got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateRawPath(t *testing.T) {
+	// only the host has to be valid when the path is sent as is
+	c := validConfig()
+	c.ReqURI, c.RawPath = "http://localhost:8080/files/a%2Fb/x%zz?q=%20", true
+	if err := c.Validate(); err != nil {
+		t.Errorf("wanted the raw path accepted got %v", err)
+	}
+}
+
+func TestConfig_LoadJwtKey(t *testing.T) {
+	t.Setenv("GOPAYLOADER_TEST_JWT_KEY", "key-from-env")
+	c := &Config{JwtKeyEnv: "GOPAYLOADER_TEST_JWT_KEY"}
+	if err := c.LoadJwtKey(); err != nil || string(c.JwtKeyBlob) != "key-from-env" {
+		t.Errorf("wanted the key from the environment got %q; %v", c.JwtKeyBlob, err)
+	}
+	c = &Config{JwtKeyEnv: "GOPAYLOADER_TEST_JWT_KEY_UNSET"}
+	if err := c.LoadJwtKey(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("wanted error for an unset environment variable got %v", err)
+	}
+
+	stdin := func(key string) {
+		t.Helper()
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(key)); err != nil {
+			t.Fatal(err)
+		}
+		w.Close()
+		orig := os.Stdin
+		os.Stdin = r
+		t.Cleanup(func() {
+			os.Stdin = orig
+			r.Close()
+		})
+	}
+	stdin("key-from-stdin")
+	c = &Config{JwtKeyStdin: true}
+	if err := c.LoadJwtKey(); err != nil || string(c.JwtKeyBlob) != "key-from-stdin" {
+		t.Errorf("wanted the key from stdin got %q; %v", c.JwtKeyBlob, err)
+	}
+	// stdin is already read, the loaded key is kept
+	if err := c.LoadJwtKey(); err != nil || string(c.JwtKeyBlob) != "key-from-stdin" {
+		t.Errorf("wanted the loaded key kept got %q; %v", c.JwtKeyBlob, err)
+	}
+
+	stdin(" \n")
+	c = &Config{JwtKeyStdin: true}
+	if err := c.LoadJwtKey(); err == nil || !strings.Contains(err.Error(), "jwt key from stdin is empty") {
+		t.Errorf("wanted error for an empty key on stdin got %v", err)
+	}
+}
+
+func TestDecodeBodyHex(t *testing.T) {
+	got, err := DecodeBodyHex("0001ff 0d0a\n7F80 61")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte{0x00, 0x01, 0xff, 0x0d, 0x0a, 0x7f, 0x80, 'a'}; !bytes.Equal(got, want) {
+		t.Errorf("wanted %x got %x", want, got)
+	}
+	for _, invalid := range []string{"0g", "abc", "a bc"} {
+		if _, err := DecodeBodyHex(invalid); err == nil {
+			t.Errorf("wanted error decoding %q", invalid)
+		}
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	for size, want := range map[string]int64{"1GB": 1 << 30, "512mb": 512 << 20, "1.5KB": 1536, "1000": 1000, "64 B": 64} {
+		got, err := ParseByteSize(size)
+		if err != nil || got != want {
+			t.Errorf("wanted %s to be %d bytes got %d; %v", size, want, got, err)
+		}
+	}
+	// 8388608TB is one byte past the largest int64
+	for _, size := range []string{"", "GB", "-1MB", "1PB", "inf", "nan", "1e30TB", "8388608TB"} {
+		if _, err := ParseByteSize(size); err == nil || !strings.Contains(err.Error(), "needs to be a positive number of bytes") {
+			t.Errorf("wanted size error parsing %q got %v", size, err)
+		}
+	}
+}
+
+func TestParseLatencySLO(t *testing.T) {
+	percentile, latency, err := ParseLatencySLO("p99.9=250ms")
+	if err != nil || percentile != 99.9 || latency != 250*time.Millisecond {
+		t.Errorf("wanted p99.9 within 250ms got p%g within %s; %v", percentile, latency, err)
+	}
+	for _, slo := range []string{"99=250ms", "p0=250ms", "p100=250ms", "p99", "p99=0s", "p99=fast"} {
+		if _, _, err := ParseLatencySLO(slo); err == nil {
+			t.Errorf("wanted error for latency SLO %q", slo)
+		}
+	}
+}
+
+func TestConfig_EncodeProtoBody(t *testing.T) {
+	set, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("shop.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				{Name: proto.String("id"), Number: proto.Int32(1), Type: descriptorpb.FieldDescriptorProto_TYPE_INT64.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+				{Name: proto.String("customer_name"), Number: proto.Int32(2), Type: descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(), Label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()},
+			},
+		}},
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	descriptor := filepath.Join(dir, "shop.pb")
+	if err := os.WriteFile(descriptor, set, 0644); err != nil {
+		t.Fatal(err)
+	}
+	bodyFile := filepath.Join(dir, "order.json")
+	if err := os.WriteFile(bodyFile, []byte(`{"id": 7, "customerName": "bob"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := validConfig()
+	c.Method, c.BodyProtoDescriptor, c.BodyProtoMessage = "POST", descriptor, "shop.v1.Order"
+	// field 1 varint, field 2 length delimited string
+	c.Body = `{"id": "42", "customerName": "ann"}`
+	if err := c.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := c.EncodeProtoBody(); err != nil || !bytes.Equal(b, []byte{0x08, 42, 0x12, 3, 'a', 'n', 'n'}) {
+		t.Errorf("wanted the body encoded got %x; %v", b, err)
+	}
+	c.Body, c.BodyFile = "", bodyFile
+	if b, err := c.EncodeProtoBody(); err != nil || !bytes.Equal(b, []byte{0x08, 7, 0x12, 3, 'b', 'o', 'b'}) {
+		t.Errorf("wanted the body file encoded got %x; %v", b, err)
+	}
+
+	c.BodyFile = ""
+	for _, body := range []string{`{"id": 1, "total": 5}`, `{"id": "one"}`} {
+		c.Body = body
+		if err := c.Validate(); err == nil {
+			t.Errorf("wanted error for body %q", body)
+		}
+	}
+	c.Body, c.BodyProtoMessage = `{"id": 1}`, "shop.v1.Refund"
+	if err := c.Validate(); err == nil {
+		t.Error("wanted error for a message not in the descriptor set")
+	}
+}
+
+func TestConfig_ValidateJwtsFilename(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"tenant-a.txt", "tenant-b.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("jwt\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tenantA, tenantB := filepath.Join(dir, "tenant-a.txt"), filepath.Join(dir, "tenant-b.txt")
+
+	tests := []struct {
+		files   string
+		want    []string
+		wantErr string
+	}{
+		{files: tenantA + "," + tenantB, want: []string{tenantA, tenantB}},
+		{files: filepath.Join(dir, "tenant-*.txt"), want: []string{tenantA, tenantB}},
+		// a file matched twice is only used once
+		{files: tenantB + " , " + filepath.Join(dir, "tenant-*.txt"), want: []string{tenantB, tenantA}},
+		{files: tenantA + "," + filepath.Join(dir, "tenant-c.txt"), wantErr: "jwt file does not exist"},
+		{files: filepath.Join(dir, "tenant-c*.txt"), wantErr: "jwt file glob matched no files"},
+		{files: tenantA + ",", wantErr: "empty jwt filename"},
+	}
+	for _, tt := range tests {
+		c := validConfig()
+		c.JwtHeader, c.JwtsFilename = "some-jwt", tt.files
+		err := c.Validate()
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: wanted error %q got %v", tt.files, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.files, err)
+		}
+		if strings.Join(c.JwtsFilenames, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: wanted files %v got %v", tt.files, tt.want, c.JwtsFilenames)
+		}
+	}
+}
+
+func TestConfig_ValidateIdentities(t *testing.T) {
+	identities := filepath.Join(t.TempDir(), "identities.json")
+	if err := os.WriteFile(identities, []byte(`{"identities": [{"name": "a", "jwt": "jwt-a"}, {"name": "b", "headers": ["X-Tenant: b"]}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// either every connection sends a JWT or none do
+	c := validConfig()
+	c.Identities = identities
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "identity a has a jwt but there's no jwt header to send it in") {
+		t.Errorf("wanted error for an identity's jwt without a jwt header got %v", err)
+	}
+	c = validConfig()
+	c.Identities, c.JwtHeader = identities, "some-jwt"
+	if err := c.Validate(); err == nil || !strings.Contains(err.Error(), "identity b has no jwt to send in the jwt header") {
+		t.Errorf("wanted error for an identity without a jwt got %v", err)
+	}
+}
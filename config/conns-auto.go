@@ -0,0 +1,29 @@
+package config
+
+import "runtime"
+
+const (
+	// autoConnsPerProc is how many connections --connections auto opens for each GOMAXPROCS, enough to keep the
+	// CPUs busy while connections wait on responses
+	autoConnsPerProc = 32
+	// autoConnsFDReserve is the file descriptors left for everything but connections i.e. stdio, DNS, output files
+	autoConnsFDReserve = 64
+)
+
+// AutoConns is the number of connections for --connections auto, autoConnsPerProc for each GOMAXPROCS capped to half
+// of the file descriptors left after autoConnsFDReserve. Half leaves room for reconnects, where the new connection
+// opens before the old one's closed, and the extra connection to --compare-target, so the run doesn't fail with too
+// many open files
+func AutoConns() uint {
+	conns := uint64(runtime.GOMAXPROCS(0)) * autoConnsPerProc
+	if limit, ok := fdLimit(); ok {
+		max := uint64(1)
+		if limit > autoConnsFDReserve+2 {
+			max = (limit - autoConnsFDReserve) / 2
+		}
+		if conns > max {
+			conns = max
+		}
+	}
+	return uint(conns)
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestAutoConns(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	orig := fdLimit
+	defer func() { fdLimit = orig }()
+
+	for _, tt := range []struct {
+		limit uint64
+		ok    bool
+		want  uint
+	}{
+		// 32 for each of the 4 procs
+		{limit: 1 << 20, ok: true, want: 128},
+		{ok: false, want: 128},
+		// half of what's left after the reserve
+		{limit: 256, ok: true, want: 96},
+		{limit: 64, ok: true, want: 1},
+	} {
+		fdLimit = func() (uint64, bool) { return tt.limit, tt.ok }
+		if got := AutoConns(); got != tt.want {
+			t.Errorf("wanted %d connections with a limit of %d got %d", tt.want, tt.limit, got)
+		}
+	}
+
+	fdLimit = func() (uint64, bool) { return 256, true }
+	conf := &Config{
+		Ctx:           context.Background(),
+		ReqURI:        "http://localhost:8080",
+		ReqTarget:     10,
+		ConnsAuto:     true,
+		ReadTimeout:   time.Second,
+		WriteTimeout:  time.Second,
+		Method:        "GET",
+		Client:        "fasthttp-1",
+		VerboseTicker: time.Second,
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Conns != 10 {
+		t.Errorf("wanted connections capped to the 10 requests got %d", conf.Conns)
+	}
+	conf.ReqTarget, conf.Duration = 0, time.Second
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Conns != 96 {
+		t.Errorf("wanted 96 connections under the open file limit got %d", conf.Conns)
+	}
+}
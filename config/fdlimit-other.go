@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package config
+
+// there's no rlimit to check, connections are only limited by the CPUs
+var fdLimit = func() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,551 @@
+package expr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Response is what an expression is evaluated against, the body is only read if the expression uses it
+type Response interface {
+	Status() int
+	Latency() time.Duration
+	Header(name string) (string, bool)
+	Body() ([]byte, error)
+}
+
+// Expr is a boolean expression over a response i.e.
+// status == 200 && latency < 100ms && header("X-Cache") == "HIT". It has
+//
+//   - status, the response code, latency, a duration like 100ms or 1.5s, and body, the response body as a string
+//   - header(name), a response header, "" when it isn't set
+//   - json(path), the value at a JSONPath in the body, strings as is and anything else as its JSON i.e. 42 or true,
+//     "" when it's missing
+//   - contains(s, substr) and matches(s, regexp)
+//   - ==, !=, <, <=, >, >=, &&, ||, ! and brackets
+//
+// Types are checked when it's parsed, so i.e. comparing latency to a number without a unit is an error up front
+// rather than when the first response comes back
+type Expr struct {
+	src       string
+	root      node
+	readsBody bool
+}
+
+type valueType int
+
+const (
+	typeBool valueType = iota
+	typeNumber
+	typeDuration
+	typeString
+)
+
+func (t valueType) String() string {
+	switch t {
+	case typeNumber:
+		return "number"
+	case typeDuration:
+		return "duration"
+	case typeString:
+		return "string"
+	}
+	return "bool"
+}
+
+// node is a parsed expression, values are bool, float64, time.Duration or string as given by its type
+type node interface {
+	typ() valueType
+	eval(e *env) (any, error)
+}
+
+// env is a response being evaluated, the body is read and decoded at most once
+type env struct {
+	resp    Response
+	body    []byte
+	read    bool
+	doc     any
+	decoded bool
+	docErr  error
+}
+
+func (e *env) readBody() ([]byte, error) {
+	if !e.read {
+		b, err := e.resp.Body()
+		if err != nil {
+			return nil, err
+		}
+		e.body, e.read = b, true
+	}
+	return e.body, nil
+}
+
+func Parse(src string) (*Expr, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{src: src, tokens: tokens}
+	root, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, p.unexpected(t)
+	}
+	if root.typ() != typeBool {
+		return nil, fmt.Errorf("expr: %s is a %s, it needs to be true or false", src, root.typ())
+	}
+	return &Expr{src: src, root: root, readsBody: p.readsBody}, nil
+}
+
+// Eval reports whether the expression is true for the response, an error is a body which couldn't be read
+func (x *Expr) Eval(resp Response) (bool, error) {
+	v, err := x.root.eval(&env{resp: resp})
+	if err != nil {
+		return false, err
+	}
+	return v.(bool), nil
+}
+
+// ReadsBody is whether the expression uses the response body, with body or json
+func (x *Expr) ReadsBody() bool {
+	return x.readsBody
+}
+
+func (x *Expr) String() string {
+	return x.src
+}
+
+type parser struct {
+	src       string
+	tokens    []token
+	pos       int
+	readsBody bool
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tokenOp && t.text == op
+}
+
+func (p *parser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.unexpected(p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *parser) unexpected(t token) error {
+	if t.kind == tokenEOF {
+		return fmt.Errorf("expr: unexpected end of %s", p.src)
+	}
+	return fmt.Errorf("expr: unexpected %s at position %d in %s", t.text, t.pos, p.src)
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		t := p.next()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.logical(t, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		t := p.next()
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		if left, err = p.logical(t, left, right); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+func (p *parser) logical(t token, left, right node) (node, error) {
+	if left.typ() != typeBool || right.typ() != typeBool {
+		return nil, fmt.Errorf("expr: %s at position %d in %s needs true or false either side, got %s and %s", t.text, t.pos, p.src, left.typ(), right.typ())
+	}
+	return &logicalNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) not() (node, error) {
+	if !p.isOp("!") {
+		return p.comparison()
+	}
+	t := p.next()
+	operand, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	if operand.typ() != typeBool {
+		return nil, fmt.Errorf("expr: ! at position %d in %s needs true or false, got %s", t.pos, p.src, operand.typ())
+	}
+	return &notNode{operand: operand}, nil
+}
+
+func (p *parser) comparison() (node, error) {
+	left, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tokenOp {
+		return left, nil
+	}
+	switch t.text {
+	case "==", "!=", "<", "<=", ">", ">=":
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ() != right.typ() {
+		hint := ""
+		if left.typ() == typeDuration || right.typ() == typeDuration {
+			hint = ", give durations a unit i.e. 100ms"
+		}
+		return nil, fmt.Errorf("expr: can't compare %s with %s at position %d in %s%s", left.typ(), right.typ(), t.pos, p.src, hint)
+	}
+	if left.typ() == typeBool && t.text != "==" && t.text != "!=" {
+		return nil, fmt.Errorf("expr: %s at position %d in %s can't order true and false", t.text, t.pos, p.src)
+	}
+	return &compareNode{op: t.text, left: left, right: right}, nil
+}
+
+func (p *parser) primary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		return &literal{v: t.num, t: typeNumber}, nil
+	case tokenDuration:
+		return &literal{v: t.dur, t: typeDuration}, nil
+	case tokenString:
+		return &literal{v: t.str, t: typeString}, nil
+	case tokenOp:
+		if t.text != "(" {
+			return nil, p.unexpected(t)
+		}
+		n, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return n, nil
+	case tokenIdent:
+		if p.isOp("(") {
+			return p.call(t)
+		}
+		switch t.text {
+		case "true", "false":
+			return &literal{v: t.text == "true", t: typeBool}, nil
+		case "status":
+			return &statusNode{}, nil
+		case "latency":
+			return &latencyNode{}, nil
+		case "body":
+			p.readsBody = true
+			return &bodyNode{}, nil
+		}
+		return nil, fmt.Errorf("expr: unknown name %s at position %d in %s, there's status, latency and body", t.text, t.pos, p.src)
+	}
+	return nil, p.unexpected(t)
+}
+
+// funcArgs is how many arguments each function takes
+var funcArgs = map[string]int{"header": 1, "json": 1, "contains": 2, "matches": 2}
+
+// call parses a function call, the arguments which are parsed up front i.e. a JSONPath need to be strings
+func (p *parser) call(name token) (node, error) {
+	p.next()
+	var args []node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	n, ok := funcArgs[name.text]
+	if !ok {
+		return nil, fmt.Errorf("expr: unknown function %s at position %d in %s, there's header, json, contains and matches", name.text, name.pos, p.src)
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("expr: %s at position %d in %s takes %d arguments, got %d", name.text, name.pos, p.src, n, len(args))
+	}
+	for _, a := range args {
+		if a.typ() != typeString {
+			return nil, fmt.Errorf("expr: %s at position %d in %s takes strings, got a %s", name.text, name.pos, p.src, a.typ())
+		}
+	}
+
+	switch name.text {
+	case "header":
+		return &headerNode{name: args[0]}, nil
+	case "contains":
+		return &containsNode{s: args[0], substr: args[1]}, nil
+	case "json":
+		l, ok := args[0].(*literal)
+		if !ok {
+			return nil, fmt.Errorf("expr: json at position %d in %s needs its path as a string", name.pos, p.src)
+		}
+		path, err := jsonpath.Parse(l.v.(string))
+		if err != nil {
+			return nil, fmt.Errorf("expr: %v", err)
+		}
+		p.readsBody = true
+		return &jsonNode{path: path}, nil
+	}
+	l, ok := args[1].(*literal)
+	if !ok {
+		return nil, fmt.Errorf("expr: matches at position %d in %s needs its regexp as a string", name.pos, p.src)
+	}
+	re, err := regexp.Compile(l.v.(string))
+	if err != nil {
+		return nil, fmt.Errorf("expr: invalid regexp at position %d in %s; %v", name.pos, p.src, err)
+	}
+	return &matchesNode{s: args[0], re: re}, nil
+}
+
+type literal struct {
+	v any
+	t valueType
+}
+
+func (n *literal) typ() valueType         { return n.t }
+func (n *literal) eval(*env) (any, error) { return n.v, nil }
+
+type statusNode struct{}
+
+func (n *statusNode) typ() valueType { return typeNumber }
+func (n *statusNode) eval(e *env) (any, error) {
+	return float64(e.resp.Status()), nil
+}
+
+type latencyNode struct{}
+
+func (n *latencyNode) typ() valueType { return typeDuration }
+func (n *latencyNode) eval(e *env) (any, error) {
+	return e.resp.Latency(), nil
+}
+
+type bodyNode struct{}
+
+func (n *bodyNode) typ() valueType { return typeString }
+func (n *bodyNode) eval(e *env) (any, error) {
+	b, err := e.readBody()
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+type headerNode struct {
+	name node
+}
+
+func (n *headerNode) typ() valueType { return typeString }
+func (n *headerNode) eval(e *env) (any, error) {
+	name, err := n.name.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	v, _ := e.resp.Header(name.(string))
+	return v, nil
+}
+
+type jsonNode struct {
+	path *jsonpath.Path
+}
+
+func (n *jsonNode) typ() valueType { return typeString }
+
+// eval is "" for a body which isn't JSON as well as a missing path, so json("$.ok") == "true" is false for either
+func (n *jsonNode) eval(e *env) (any, error) {
+	if !e.decoded {
+		b, err := e.readBody()
+		if err != nil {
+			return nil, err
+		}
+		d := json.NewDecoder(bytes.NewReader(b))
+		// numbers are kept as written rather than after a round trip through float64
+		d.UseNumber()
+		e.docErr = d.Decode(&e.doc)
+		e.decoded = true
+	}
+	if e.docErr != nil {
+		return "", nil
+	}
+	v, ok := n.path.Lookup(e.doc)
+	if !ok {
+		return "", nil
+	}
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", nil
+	}
+	return string(b), nil
+}
+
+type containsNode struct {
+	s, substr node
+}
+
+func (n *containsNode) typ() valueType { return typeBool }
+func (n *containsNode) eval(e *env) (any, error) {
+	s, err := n.s.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	substr, err := n.substr.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Contains(s.(string), substr.(string)), nil
+}
+
+type matchesNode struct {
+	s  node
+	re *regexp.Regexp
+}
+
+func (n *matchesNode) typ() valueType { return typeBool }
+func (n *matchesNode) eval(e *env) (any, error) {
+	s, err := n.s.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return n.re.MatchString(s.(string)), nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) typ() valueType { return typeBool }
+func (n *notNode) eval(e *env) (any, error) {
+	v, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return !v.(bool), nil
+}
+
+// logicalNode short circuits so i.e. status == 200 && json("$.ok") == "true" only reads the body of 200s
+type logicalNode struct {
+	op          string
+	left, right node
+}
+
+func (n *logicalNode) typ() valueType { return typeBool }
+func (n *logicalNode) eval(e *env) (any, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "&&" && !left.(bool) || n.op == "||" && left.(bool) {
+		return left, nil
+	}
+	return n.right.eval(e)
+}
+
+type compareNode struct {
+	op          string
+	left, right node
+}
+
+func (n *compareNode) typ() valueType { return typeBool }
+func (n *compareNode) eval(e *env) (any, error) {
+	left, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "==":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	}
+
+	var cmp int
+	switch l := left.(type) {
+	case float64:
+		cmp = compare(l, right.(float64))
+	case time.Duration:
+		cmp = compare(float64(l), float64(right.(time.Duration)))
+	case string:
+		cmp = strings.Compare(l, right.(string))
+	}
+	switch n.op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+func compare(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
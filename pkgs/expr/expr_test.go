@@ -0,0 +1,146 @@
+package expr
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type response struct {
+	status  int
+	latency time.Duration
+	headers map[string]string
+	body    string
+	reads   int
+}
+
+func (r *response) Status() int            { return r.status }
+func (r *response) Latency() time.Duration { return r.latency }
+func (r *response) Header(name string) (string, bool) {
+	v, ok := r.headers[name]
+	return v, ok
+}
+func (r *response) Body() ([]byte, error) {
+	r.reads++
+	return []byte(r.body), nil
+}
+
+func TestExpr_Eval(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		want bool
+	}{
+		{expr: `status == 200 && latency < 100ms && header("X-Cache") == "HIT"`, want: true},
+		{expr: `status == 200 && latency < 40ms && header("X-Cache") == "HIT"`},
+		{expr: `status == 200 && latency < 100ms && header("X-Cache") == "MISS"`},
+		{expr: `status >= 500 || latency > 1s`},
+		{expr: `status < 300 && !(latency >= 50ms)`, want: true},
+		{expr: `(status == 201 || status == 200) && json("$.order.state") == "paid"`, want: true},
+		{expr: `json("$.order.total") == "42.50" && json("$.order.items[0].sku") == "A1"`, want: true},
+		{expr: `json("$.order.gift") == "true"`},
+		{expr: `json("$.missing") == ""`, want: true},
+		{expr: `contains(body, "paid") && matches(header("Content-Type"), "^application/json")`, want: true},
+		{expr: `header("X-Missing") != "" || contains(body, "refunded")`},
+		{expr: `latency <= 45.5ms && latency >= 45500µs`, want: true},
+		{expr: `header("X-Cache") > "HAT" && true`, want: true},
+		{expr: `!false == true`, want: true},
+	} {
+		x, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		got, err := x.Eval(&response{
+			status:  200,
+			latency: 45500 * time.Microsecond,
+			headers: map[string]string{"X-Cache": "HIT", "Content-Type": "application/json; charset=utf-8"},
+			body:    `{"order": {"state": "paid", "total": 42.50, "gift": false, "items": [{"sku": "A1"}]}}`,
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: wanted %t got %t", tt.expr, tt.want, got)
+		}
+	}
+}
+
+func TestExpr_EvalBody(t *testing.T) {
+	x, err := Parse(`status == 200 && header("X-Cache") == "HIT"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x.ReadsBody() {
+		t.Error("wanted an expression without body or json not to read the body")
+	}
+
+	x, err = Parse(`status == 200 && json("$.ok") == "true" && contains(body, "ok")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the body isn't read when the status already decides it, and only once when it's used twice
+	r := &response{status: 500, body: `{"ok": true}`}
+	if ok, err := x.Eval(r); ok || err != nil || r.reads != 0 {
+		t.Errorf("wanted false without reading the body got %t, %v after %d reads", ok, err, r.reads)
+	}
+	r.status = 200
+	if ok, err := x.Eval(r); !ok || err != nil || r.reads != 1 {
+		t.Errorf("wanted true reading the body once got %t, %v after %d reads", ok, err, r.reads)
+	}
+	// not JSON is the same as a missing path
+	r.body = "ok"
+	if ok, err := x.Eval(r); ok || err != nil {
+		t.Errorf("wanted false for a body which isn't JSON got %t, %v", ok, err)
+	}
+
+	x, err = Parse(`contains(body, "ok")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !x.ReadsBody() {
+		t.Error("wanted an expression using body to read it")
+	}
+	if _, err := x.Eval(&failingBody{}); err == nil {
+		t.Error("wanted an error for a body which can't be read")
+	}
+}
+
+type failingBody struct {
+	response
+}
+
+func (r *failingBody) Body() ([]byte, error) {
+	return nil, errors.New("connection closed")
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, tt := range []struct {
+		expr string
+		want string
+	}{
+		{expr: ``, want: "unexpected end"},
+		{expr: `status`, want: "is a number, it needs to be true or false"},
+		{expr: `latency < 100`, want: "can't compare duration with number"},
+		{expr: `status == "200"`, want: "can't compare number with string"},
+		{expr: `status == 200 &&`, want: "unexpected end"},
+		{expr: `status == 200 && latency`, want: "&& at position 14"},
+		{expr: `status = 200`, want: "unexpected = at position 7"},
+		{expr: `(status == 200`, want: "unexpected end"},
+		{expr: `code == 200`, want: "unknown name code"},
+		{expr: `cache("X") == "HIT"`, want: "unknown function cache"},
+		{expr: `header("X", "Y") == ""`, want: "takes 1 arguments, got 2"},
+		{expr: `contains(status, "2")`, want: "takes strings, got a number"},
+		{expr: `json(header("X")) == ""`, want: "needs its path as a string"},
+		{expr: `json("status") == ""`, want: "must start with $"},
+		{expr: `matches(body, "[")`, want: "invalid regexp"},
+		{expr: `latency < 10xs`, want: "invalid duration 10xs"},
+		{expr: `header("X-Cache) == "HIT"`, want: "unterminated string"},
+		{expr: `true < false`, want: "can't order true and false"},
+		{expr: `!status`, want: "needs true or false, got number"},
+	} {
+		_, err := Parse(tt.expr)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: wanted error containing %q got %v", tt.expr, tt.want, err)
+		}
+	}
+}
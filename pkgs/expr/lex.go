@@ -0,0 +1,113 @@
+package expr
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenDuration
+	tokenString
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+	num  float64
+	dur  time.Duration
+	str  string
+}
+
+// ops are the operators and punctuation, longest first so <= isn't read as <
+var ops = []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", ","}
+
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '`':
+			end := i + 1
+			for end < len(src) && src[end] != src[i] {
+				if c == '"' && src[end] == '\\' {
+					// an escaped quote isn't the end of the string
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return nil, fmt.Errorf("expr: unterminated string at position %d in %s", i, src)
+			}
+			text := src[i : end+1]
+			s, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, fmt.Errorf("expr: invalid string %s at position %d in %s", text, i, src)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text, pos: i, str: s})
+			i += len(text)
+		case unicode.IsDigit(c):
+			end := i
+			for end < len(src) && (unicode.IsDigit(rune(src[end])) || src[end] == '.') {
+				end++
+			}
+			number := end
+			// a unit straight after the number makes it a duration i.e. 100ms or 1.5s
+			for end < len(src) {
+				r, size := utf8.DecodeRuneInString(src[end:])
+				if !unicode.IsLetter(r) {
+					break
+				}
+				end += size
+			}
+			text := src[i:end]
+			if end > number {
+				d, err := time.ParseDuration(text)
+				if err != nil {
+					return nil, fmt.Errorf("expr: invalid duration %s at position %d in %s", text, i, src)
+				}
+				tokens = append(tokens, token{kind: tokenDuration, text: text, pos: i, dur: d})
+			} else {
+				n, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					return nil, fmt.Errorf("expr: invalid number %s at position %d in %s", text, i, src)
+				}
+				tokens = append(tokens, token{kind: tokenNumber, text: text, pos: i, num: n})
+			}
+			i = end
+		case unicode.IsLetter(c) || c == '_':
+			end := i
+			for end < len(src) && (unicode.IsLetter(rune(src[end])) || unicode.IsDigit(rune(src[end])) || src[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: src[i:end], pos: i})
+			i = end
+		default:
+			op := ""
+			for _, o := range ops {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("expr: unexpected %c at position %d in %s", src[i], i, src)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(src)}), nil
+}
@@ -0,0 +1,84 @@
+package har
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is a request recorded in a HAR file, Offset is when it was sent relative to the first request
+type Entry struct {
+	Offset  time.Duration
+	Method  string
+	URL     string
+	Headers []Header
+	Body    []byte
+}
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// file is the part of the HAR 1.2 format needed to replay requests, responses are ignored
+type file struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Request         struct {
+				Method   string   `json:"method"`
+				URL      string   `json:"url"`
+				Headers  []Header `json:"headers"`
+				PostData *struct {
+					Text string `json:"text"`
+				} `json:"postData"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// Load reads the requests of a HAR file in the order they were sent. Headers the client sets itself, Host,
+// Content-Length and HTTP/2 pseudo headers, are left out
+func Load(path string) ([]Entry, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read HAR file %v", err)
+	}
+	var f file
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse HAR file %s; %v", path, err)
+	}
+	if len(f.Log.Entries) == 0 {
+		return nil, fmt.Errorf("HAR file %s has no entries", path)
+	}
+
+	sort.SliceStable(f.Log.Entries, func(i, j int) bool {
+		return f.Log.Entries[i].StartedDateTime.Before(f.Log.Entries[j].StartedDateTime)
+	})
+	first := f.Log.Entries[0].StartedDateTime
+	entries := make([]Entry, 0, len(f.Log.Entries))
+	for i, e := range f.Log.Entries {
+		if e.Request.Method == "" || e.Request.URL == "" {
+			return nil, fmt.Errorf("HAR file %s entry %d is missing a request method or url", path, i)
+		}
+		entry := Entry{
+			Offset: e.StartedDateTime.Sub(first),
+			Method: e.Request.Method,
+			URL:    e.Request.URL,
+		}
+		for _, h := range e.Request.Headers {
+			if strings.HasPrefix(h.Name, ":") || strings.EqualFold(h.Name, "host") || strings.EqualFold(h.Name, "content-length") {
+				continue
+			}
+			entry.Headers = append(entry.Headers, h)
+		}
+		if e.Request.PostData != nil {
+			entry.Body = []byte(e.Request.PostData.Text)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,47 @@
+package har
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.har")
+	if err := os.WriteFile(path, []byte(`{"log": {"version": "1.2", "entries": [
+		{"startedDateTime": "2024-05-01T10:00:01.500Z", "request": {"method": "POST", "url": "https://example.com/orders?ref=1",
+			"headers": [{"name": ":authority", "value": "example.com"}, {"name": "Content-Type", "value": "application/json"},
+				{"name": "Content-Length", "value": "2"}], "postData": {"mimeType": "application/json", "text": "{}"}}},
+		{"startedDateTime": "2024-05-01T10:00:01Z", "request": {"method": "GET", "url": "https://example.com/",
+			"headers": [{"name": "Host", "value": "example.com"}, {"name": "Cookie", "value": "a=b"}]}}
+	]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("wanted 2 entries got %d", len(entries))
+	}
+	// sorted by when they were sent
+	get, post := entries[0], entries[1]
+	if get.Method != "GET" || get.Offset != 0 || len(get.Headers) != 1 || get.Headers[0] != (Header{Name: "Cookie", Value: "a=b"}) || get.Body != nil {
+		t.Errorf("unexpected first entry %+v", get)
+	}
+	if post.Method != "POST" || post.URL != "https://example.com/orders?ref=1" || post.Offset != 500*time.Millisecond ||
+		len(post.Headers) != 1 || post.Headers[0].Name != "Content-Type" || string(post.Body) != "{}" {
+		t.Errorf("unexpected second entry %+v", post)
+	}
+
+	for _, contents := range []string{`{"log": {"entries": []}}`, `not json`, `{"log": {"entries": [{"request": {"url": "https://example.com"}}]}}`} {
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(path); err == nil {
+			t.Errorf("wanted error loading %s", contents)
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package http_clients
+
+import (
+	"context"
+	"errors"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/connreport"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ConnTracker follows the lifecycle of a worker's connections for --connections-report. The clients wrap their dial
+// with it to see when connections open, fail and close, and the worker counts each response against the connection
+// it was read from with Served. A closed connection is only written once the next one opens or the run finishes, as
+// the client can close it before the worker has counted its last response
+type ConnTracker struct {
+	mu              sync.Mutex
+	report          *connreport.Writer
+	workerID        int
+	maxConnDuration time.Duration
+	maxIdle         time.Duration
+	open            map[*trackedConn]struct{}
+	closed          []*trackedConn
+	last            *trackedConn
+	ending          bool
+}
+
+// NewConnTracker returns a tracker writing the connections of the worker to report
+func NewConnTracker(report *connreport.Writer, workerID int) *ConnTracker {
+	return &ConnTracker{
+		report:   report,
+		workerID: workerID,
+		open:     make(map[*trackedConn]struct{}),
+	}
+}
+
+// SetLimits is given the client's connection lifetime and idle timeout, after any defaults, so connections it closes
+// for reaching them can be told apart. 0 is no limit
+func (t *ConnTracker) SetLimits(maxConnDuration, maxIdle time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.maxConnDuration = maxConnDuration
+	t.maxIdle = maxIdle
+}
+
+// Dial wraps the connections dialed by dial to track them
+func (t *ConnTracker) Dial(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.track(conn), nil
+	}
+}
+
+// DialContext is Dial for net/http's dial func
+func (t *ConnTracker) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.track(conn), nil
+	}
+}
+
+func (t *ConnTracker) track(conn net.Conn) net.Conn {
+	now := time.Now()
+	c := &trackedConn{Conn: conn, tracker: t, opened: now, used: now}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.flush()
+	t.open[c] = struct{}{}
+	return c
+}
+
+// Served counts a response against the connection it was last read from
+func (t *ConnTracker) Served() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.last != nil {
+		t.last.requests++
+	}
+}
+
+// Ending is called before the worker closes its connections at the end of the run
+func (t *ConnTracker) Ending() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ending = true
+}
+
+// Finish writes the closed connections which haven't been yet and those the client never closed
+func (t *ConnTracker) Finish() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := time.Now()
+	for c := range t.open {
+		c.closed = now
+		c.reason = connreport.ReasonOpenAtEnd
+		t.closed = append(t.closed, c)
+	}
+	t.open = make(map[*trackedConn]struct{})
+	t.flush()
+}
+
+// flush writes the closed connections, t.mu must be held
+func (t *ConnTracker) flush() {
+	for _, c := range t.closed {
+		var err error
+		if c.reason == connreport.ReasonError || c.reason == connreport.ReasonTimeout {
+			err = c.err
+		}
+		t.report.Write(connreport.Connection{
+			WorkerID:   t.workerID,
+			Opened:     c.opened,
+			Closed:     c.closed,
+			Requests:   c.requests,
+			Reason:     c.reason,
+			Err:        err,
+			LocalAddr:  c.LocalAddr().String(),
+			RemoteAddr: c.RemoteAddr().String(),
+		})
+	}
+	t.closed = t.closed[:0]
+}
+
+// used records reading or writing on c, the first error is kept as it's what ended the connection
+func (t *ConnTracker) used(c *trackedConn, read bool, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !c.closed.IsZero() {
+		// a read blocked on a connection the client closed
+		return
+	}
+	c.used = time.Now()
+	if read {
+		t.last = c
+	}
+	if err != nil && c.err == nil {
+		c.err = err
+	}
+}
+
+func (t *ConnTracker) close(c *trackedConn) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !c.closed.IsZero() {
+		return
+	}
+	c.closed = time.Now()
+	c.reason = t.closeReason(c)
+	delete(t.open, c)
+	t.closed = append(t.closed, c)
+}
+
+// closeReason works out why c is being closed. An HTTP/1.1 client which closes a connection straight after a
+// response, without it failing or reaching a limit, does so because the request or response had Connection: close
+func (t *ConnTracker) closeReason(c *trackedConn) string {
+	var netErr net.Error
+	switch {
+	case c.err != nil && (errors.Is(c.err, io.EOF) || errors.Is(c.err, syscall.ECONNRESET) || errors.Is(c.err, syscall.EPIPE)):
+		return connreport.ReasonServerClose
+	case c.err != nil && errors.As(c.err, &netErr) && netErr.Timeout():
+		return connreport.ReasonTimeout
+	case c.err != nil:
+		return connreport.ReasonError
+	case t.maxConnDuration > 0 && c.closed.Sub(c.opened) >= t.maxConnDuration:
+		return connreport.ReasonMaxLifetime
+	case t.maxIdle > 0 && c.closed.Sub(c.used) >= t.maxIdle:
+		return connreport.ReasonKeepAliveExpiry
+	case t.ending:
+		return connreport.ReasonEndOfRun
+	default:
+		return connreport.ReasonConnectionClose
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	tracker *ConnTracker
+	// guarded by tracker.mu
+	opened   time.Time
+	used     time.Time
+	closed   time.Time
+	requests int64
+	reason   string
+	err      error
+}
+
+func (c *trackedConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.tracker.used(c, true, err)
+	return n, err
+}
+
+func (c *trackedConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.tracker.used(c, false, err)
+	return n, err
+}
+
+func (c *trackedConn) Close() error {
+	c.tracker.close(c)
+	return c.Conn.Close()
+}
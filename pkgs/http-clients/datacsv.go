@@ -0,0 +1,174 @@
+package http_clients
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// DataMethodColumn is the --data-csv column which, when there is one, sets the method of each row's request
+const DataMethodColumn = "method"
+
+// DataCSV is the rows of a --data-csv file, each request gets the next row as {{.Data.<column>}} in its templates
+// like JMeter's CSV data set config. Rows are shared by all workers and only read
+type DataCSV struct {
+	Columns []string
+	Rows    []map[string]string
+}
+
+// LoadDataCSV reads a CSV file with a header row naming the columns, there has to be at least one row after it
+func LoadDataCSV(path string) (*DataCSV, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open data csv; %v", err)
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data csv %s; %v", path, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("data csv %s needs a header row and at least one row of data", path)
+	}
+
+	d := &DataCSV{Columns: records[0]}
+	seen := make(map[string]bool, len(d.Columns))
+	for _, c := range d.Columns {
+		if c == "" {
+			return nil, fmt.Errorf("data csv %s has a column without a name", path)
+		}
+		if seen[c] {
+			return nil, fmt.Errorf("data csv %s has more than one %s column", path, c)
+		}
+		seen[c] = true
+	}
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(d.Columns))
+		for i, c := range d.Columns {
+			row[c] = record[i]
+		}
+		if m, ok := row[DataMethodColumn]; ok && m == "" {
+			return nil, fmt.Errorf("data csv %s has a row with an empty method", path)
+		}
+		d.Rows = append(d.Rows, row)
+	}
+	return d, nil
+}
+
+// Row is the row for the index'th request, rows are cycled through once they've all been used
+func (d *DataCSV) Row(index int64) map[string]string {
+	return d.Rows[index%int64(len(d.Rows))]
+}
+
+// CheckFields returns an error naming any {{.Data.<column>}} used by the templates which the CSV doesn't have
+func (d *DataCSV) CheckFields(templates ...*template.Template) error {
+	has := make(map[string]bool, len(d.Columns))
+	for _, c := range d.Columns {
+		has[c] = true
+	}
+	var missing []string
+	for name := range DataFields(templates...) {
+		if !has[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("data csv has no column for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// DataFields returns the names of the {{.Data.<column>}} fields used by the templates. Fields looked up another way
+// i.e. with index aren't found, they fail when the template is executed instead
+func DataFields(templates ...*template.Template) map[string]bool {
+	return templateFields("Data", templates...)
+}
+
+// templateFields returns the names of the {{.<root>.<name>}} fields used by the templates
+func templateFields(root string, templates ...*template.Template) map[string]bool {
+	fields := make(map[string]bool)
+	for _, t := range templates {
+		if t == nil {
+			continue
+		}
+		for _, tmpl := range t.Templates() {
+			if tmpl.Tree != nil {
+				fieldsIn(tmpl.Tree.Root, root, fields)
+			}
+		}
+	}
+	return fields
+}
+
+func fieldsIn(node parse.Node, root string, fields map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			fieldsIn(c, root, fields)
+		}
+	case *parse.ActionNode:
+		fieldsIn(n.Pipe, root, fields)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			fieldsIn(c, root, fields)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			fieldsIn(a, root, fields)
+		}
+	case *parse.IfNode:
+		fieldsIn(n.Pipe, root, fields)
+		fieldsIn(n.List, root, fields)
+		fieldsIn(n.ElseList, root, fields)
+	case *parse.RangeNode:
+		fieldsIn(n.Pipe, root, fields)
+		fieldsIn(n.List, root, fields)
+		fieldsIn(n.ElseList, root, fields)
+	case *parse.WithNode:
+		fieldsIn(n.Pipe, root, fields)
+		fieldsIn(n.List, root, fields)
+		fieldsIn(n.ElseList, root, fields)
+	case *parse.TemplateNode:
+		fieldsIn(n.Pipe, root, fields)
+	case *parse.FieldNode:
+		if len(n.Ident) >= 2 && n.Ident[0] == root {
+			fields[n.Ident[1]] = true
+		}
+	case *parse.VariableNode:
+		// $.<root>.<name>
+		if len(n.Ident) >= 3 && n.Ident[0] == "$" && n.Ident[1] == root {
+			fields[n.Ident[2]] = true
+		}
+	}
+}
+
+// ParseURITemplate parses the request URI as a template when it has one, nil if it doesn't. Only the path and query
+// can be templated, connections are opened to the host before any request is rendered
+func ParseURITemplate(uri string) (*template.Template, error) {
+	if !strings.Contains(uri, "{{") {
+		return nil, nil
+	}
+	scheme, rest, _ := strings.Cut(uri, "://")
+	host, _, _ := strings.Cut(rest, "/")
+	if strings.Contains(scheme, "{{") || strings.Contains(host, "{{") {
+		return nil, errors.New("only the path and query of the request uri can be templated")
+	}
+	t, err := template.New("uri").Option("missingkey=error").Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("request uri template; %v", err)
+	}
+	return t, nil
+}
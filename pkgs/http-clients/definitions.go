@@ -2,19 +2,61 @@ package http_clients
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/domsolutions/gopayloader/pkgs/expr"
+	"github.com/domsolutions/gopayloader/pkgs/har"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/flamegraph"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/jtl"
+	"io"
+	"log"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
+// ContentLengthNone is given to Request.SetContentLength to send a request without a Content-Length
+const ContentLengthNone = "none"
+
 type Request interface {
 	SetHeader(key, val string)
+	SetMethod(method string)
 	SetBody(body []byte)
+	// SetBodyStream sets a body which is re-opened and streamed on every request, so it's never held in memory
+	SetBodyStream(open func() (io.ReadCloser, error), size int64)
+	// SetHeaderOrder sends headers in the given order and casing, it must be called once all headers and the body
+	// are set
+	SetHeaderOrder(order []string)
+	// SetQuery replaces the query string of the request URL, query is already escaped
+	SetQuery(query []byte)
+	// SetURI replaces the request URL with another on the same host i.e. rendered from the URL template
+	SetURI(uri string) error
+	// SetConnectionClose asks for the connection to be closed once the response is received, HTTP/1.1 only
+	SetConnectionClose(close bool)
+	// SetContentLength sends length as the Content-Length whatever the size of the body, or no Content-Length with
+	// ContentLengthNone, making a malformed request. It must be called before SetHeaderOrder, which sends it
+	SetContentLength(length string)
 	Size() int64
 }
 
 type Response interface {
 	StatusCode() int
+	// Header looks up a response header case-insensitively, returning false if it isn't present
+	Header(key string) (string, bool)
+	// Body reads the response body, it's only read once so later calls return the same body
+	Body() ([]byte, error)
+	// StreamBody copies the body to w as it's read without holding it in memory, returning how many bytes were
+	// copied. Clients only leave the body unread for streaming with Config.StreamBody
+	StreamBody(w io.Writer) (int64, error)
 	Size() int64
+	// FirstByte is when the first byte of the response was read in unix nanoseconds, 0 without Config.TTFB
+	FirstByte() int64
+	// Phases is when each phase of the request happened, zero without Config.Phases
+	Phases() Phases
 	Close()
 }
 
@@ -25,30 +67,135 @@ type GoPayLoaderClient interface {
 	CloseConns()
 }
 
+// ReqTiming is when a completed request was scheduled to be sent, when it was sent and when it completed in unix
+// nanoseconds. Scheduled is only earlier than Sent when an open rate has fallen behind and the request queued. Bytes
+// is the size of the request and response, only measured with Config.ReqBytes. FirstByte is when the response
+// started, 0 unless measured with Config.TTFB. Status is the response's status code. Path is the URL path without
+// the query, only set with Config.ReqPath
+type ReqTiming struct {
+	Scheduled int64
+	Sent      int64
+	FirstByte int64
+	Done      int64
+	Bytes     int64
+	Status    int
+	Path      string
+}
+
+// Phases is when each phase of a request happened in unix nanoseconds, from sending it to the first byte of the
+// response. Resolving, connecting and the TLS handshake are 0 when an idle connection was reused, as is anything the
+// client doesn't trace
+type Phases struct {
+	Start        int64
+	DNSStart     int64
+	DNSDone      int64
+	ConnectStart int64
+	ConnectDone  int64
+	TLSStart     int64
+	TLSDone      int64
+	GotConn      int64
+	FirstByte    int64
+}
+
 type Config struct {
-	ReqURI            string
-	DisableKeepAlive  bool
-	SkipVerify        bool
-	MTLSKey           string
-	MTLSCert          string
-	ReqTarget         int64
-	Ctx               context.Context
-	StartTrigger      *sync.WaitGroup
-	Until             time.Duration
-	ReqEvery          time.Duration
-	ReadTimeout       time.Duration
-	WriteTimeout      time.Duration
-	Method            string
-	Verbose           bool
-	JwtStreamReceiver <-chan string
-	JWTHeader         string
-	Headers           []string
-	Body              string
-	BodyFile          string
-	NetHTTP           bool
-	HTTPV3            bool
-	ReqStats          chan<- time.Duration
-	Client            string
+	ReqURI              string
+	RawPath             bool
+	DisableKeepAlive    bool
+	HTTP10              bool // HTTP/1.0 request lines, fasthttp-1 only
+	SkipVerify          bool
+	MTLSKey             string
+	MTLSCert            string
+	ReqTarget           int64
+	Ctx                 context.Context
+	StartTrigger        *sync.WaitGroup
+	Until               time.Duration
+	StartDelay          time.Duration // before the first request, so the connection opens in its --conn-batch-size batch
+	ReqEvery            time.Duration
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	TimeoutJitter       time.Duration
+	MaxIdleConnDuration time.Duration
+	MaxConnDuration     time.Duration
+	H2PingInterval      time.Duration // between PINGs on fasthttp-2 connections, 0 for the library's default
+	CloseRate           float64
+	MaxHeaderSize       int   // 0 for the client's default
+	MaxExpectedBody     int64 // responses larger than it are counted as oversized, 0 doesn't
+	StreamBody          bool
+	DiscardBody         bool // response bodies aren't kept, only drained so the connection can be reused
+	TTFB                bool // measure when responses start, not supported by fasthttp-2
+	Phases              bool // time the phases of every request, nethttp only
+	ExpectSHA256        []byte
+	Method              string
+	Verbose             bool
+	LogSampleRate       float64
+	RequestLog          *log.Logger
+	Seed                int64
+	JwtStreamReceiver   <-chan string
+	JWTHeader           string
+	JwtRotateEvery      int64 // requests sent with each JWT before taking the next, 0 or 1 a new one every request
+	Headers             []string
+	HeaderTemplates     []HeaderTemplate
+	Body                string
+	BodyFile            string
+	BodyStream          bool
+	BodyTemplate        *template.Template
+	URITemplate         *template.Template // the request URL rendered for every request, the host isn't templated
+	DataCSV             *DataCSV
+	QueryFuzz           *QueryFuzz
+	ReqIndex            *atomic.Int64
+	Replay              []har.Entry // this connection's requests of a --replay-har recording
+	Scenario            []ScenarioStep
+	ExpectHeaders       []string
+	ExpectPresent       []string
+	AssertJSONPath      *jsonpath.Path
+	AssertJSONEquals    string
+	SuccessExpr         *expr.Expr // a request is only successful when it's true, --success-expr
+	ValidateEvery       int
+	NetHTTP             bool
+	HeaderOrder         []string
+	ContentLength       string // sent instead of the body's size, --force-content-length fasthttp-1 only
+	HTTPV3              bool
+	ReqStats            chan<- ReqTiming
+	ReqBytes            bool // measure the size of every request for its ReqTiming, for --output-interval-csv
+	ReqPath             bool // set the path of every request in its ReqTiming, for --slo-latency on an endpoint
+	LatencySamples      int  // keep a sample of up to this many of the worker's latencies in its Stats, for --conn-outlier-factor
+	Client              string
+	WorkerID            int
+	RateLimiter         *limiter.Rate
+	ConnLimiter         *limiter.Conns
+	ErrorWindow         *limiter.Window
+	Pause               *limiter.Pause
+	ByteLimit           *limiter.Bytes
+	MethodLimit         *limiter.Methods // caps the requests of each method in flight across workers, --method-concurrency
+	ConnectRetries      int
+	RetryOnReset        bool
+	HonorRetryAfter     bool          // wait as long as 429 and 503 responses ask before the next request
+	AdaptiveThinkTime   time.Duration // base pause between a connection's requests, adapted to the last response
+	Resolver            Resolver
+	DialAddr            string // dialed instead of the target host when set i.e. the connection's SRV target
+	SourcePorts         *SourcePorts
+	TLSSessionCache     tls.ClientSessionCache
+	TLSFingerprint      string // the browser the ClientHello offers the same ciphers and key exchanges as, see --tls-fingerprint
+	RootCAs             *x509.CertPool
+	TLSStats            *TLSStats
+	TCPStats            *TCPStats // samples the TCP_INFO of every connection for --tcp-stats
+	TraceHeader         string
+	HMACSecret          string
+	HMACHeader          string
+	HMACAlgo            string
+	ContentHashHeader   string
+	Metrics             *metrics.Recorder
+	ConnTracker         *ConnTracker // follows the connections for --connections-report
+	JTL                 *jtl.Writer
+	Flamegraph          *flamegraph.Writer // sums the phases of every request for --latency-flamegraph
+	Slowest             int
+	CompareURI          string
+	CompareHeaders      []string
+}
+
+// CustomDial reports whether connections need to be opened with a Dialer rather than the client's own
+func (c *Config) CustomDial() bool {
+	return c.Resolver != nil || c.DialAddr != "" || c.SourcePorts != nil
 }
 
 func (c *Config) ReqLimitedOnly() bool {
@@ -56,5 +203,5 @@ func (c *Config) ReqLimitedOnly() bool {
 }
 
 func (c *Config) UnlimitedReqs() bool {
-	return c.Until != 0 && c.ReqTarget == 0
+	return (c.Until != 0 || c.ByteLimit != nil) && c.ReqTarget == 0
 }
@@ -0,0 +1,135 @@
+package http_clients
+
+import (
+	"context"
+	"errors"
+	"github.com/quic-go/quic-go"
+	"github.com/valyala/fasthttp"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Resolver looks up the addresses of a host, *net.Resolver satisfies it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Dialer is used by all clients to open connections when the target host needs to be resolved by something other
+// than the system resolver
+type Dialer struct {
+	Timeout  time.Duration
+	Resolver Resolver
+	// Addr replaces the address of every connection when set, TLS still verifies the target host
+	Addr string
+	// SourcePorts are the local ports connections are opened from, any port when nil
+	SourcePorts *SourcePorts
+}
+
+func NewDialer(config *Config) *Dialer {
+	return &Dialer{
+		Timeout:     config.ReadTimeout,
+		Resolver:    config.Resolver,
+		Addr:        config.DialAddr,
+		SourcePorts: config.SourcePorts,
+	}
+}
+
+// Resolve returns the addresses to dial for addr, hosts which are already an IP aren't looked up
+func (d *Dialer) Resolve(ctx context.Context, addr string) ([]string, error) {
+	if d.Addr != "" {
+		addr = d.Addr
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if d.Resolver == nil || net.ParseIP(host) != nil {
+		return []string{addr}, nil
+	}
+
+	ips, err := d.Resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("no addresses found for host " + host)
+	}
+
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip, port)
+	}
+	return addrs, nil
+}
+
+// DialContext resolves addr and tries each address in turn until one connects
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+
+	addrs, err := d.Resolve(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	for _, a := range addrs {
+		var conn net.Conn
+		if d.SourcePorts != nil {
+			conn, err = d.SourcePorts.DialContext(ctx, dialer, network, a)
+		} else {
+			conn, err = dialer.DialContext(ctx, network, a)
+		}
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// Dial has the signature fasthttp expects for its dial func
+func (d *Dialer) Dial(addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), "tcp", addr)
+}
+
+// IsDialError reports whether err is from opening a connection rather than sending a request on one, so the request
+// was never sent and can safely be tried again
+func IsDialError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	if errors.Is(err, fasthttp.ErrDialTimeout) {
+		return true
+	}
+	// quic has no dial op, nothing answering shows up as the handshake timing out
+	var handshakeErr *quic.HandshakeTimeoutError
+	return errors.As(err, &handshakeErr)
+}
+
+// IsResetError reports whether the connection was reset by the target while sending a request or waiting for the
+// response
+func IsResetError(err error) bool {
+	if errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	// windows reports its own errno which doesn't match ECONNRESET
+	return err != nil && strings.Contains(err.Error(), "connection reset")
+}
+
+// IsHeaderTooLargeError reports whether the response headers were larger than the client allows, see
+// Config.MaxHeaderSize
+func IsHeaderTooLargeError(err error) bool {
+	var smallBuffer *fasthttp.ErrSmallBuffer
+	if errors.As(err, &smallBuffer) {
+		return true
+	}
+	// net/http and http3 only return plain errors
+	return err != nil && (strings.Contains(err.Error(), "server response headers exceeded") ||
+		strings.Contains(err.Error(), "HEADERS frame too large"))
+}
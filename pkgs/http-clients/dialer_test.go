@@ -0,0 +1,91 @@
+package http_clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"syscall"
+	"testing"
+)
+
+type testResolver map[string][]string
+
+func (r testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host " + host)
+	}
+	return addrs, nil
+}
+
+func TestDialer_Resolve(t *testing.T) {
+	d := &Dialer{Resolver: testResolver{"target.test": {"127.0.0.1", "::1"}, "empty.test": {}}}
+	ctx := context.Background()
+
+	got, err := d.Resolve(ctx, "target.test:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.1:8080", "[::1]:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v got %v", want, got)
+	}
+	// IPs aren't looked up
+	if got, err := d.Resolve(ctx, "10.0.0.1:80"); err != nil || !reflect.DeepEqual(got, []string{"10.0.0.1:80"}) {
+		t.Errorf("wanted the IP as is got %v; %v", got, err)
+	}
+	if _, err := d.Resolve(ctx, "empty.test:80"); err == nil {
+		t.Error("wanted error for a host without addresses")
+	}
+	if _, err := d.Resolve(ctx, "missing.test:80"); err == nil {
+		t.Error("wanted the resolver's error")
+	}
+
+	// Addr replaces the address dialed
+	d.Addr = "target.test:9090"
+	if got, _ := d.Resolve(ctx, "other.test:80"); !reflect.DeepEqual(got, []string{"127.0.0.1:9090", "[::1]:9090"}) {
+		t.Errorf("wanted the addresses of Addr got %v", got)
+	}
+}
+
+func TestDialer_DialContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing listens on the first address so the next is tried
+	d := &Dialer{Resolver: testResolver{"target.test": {"127.0.0.2", "127.0.0.1"}}}
+	conn, err := d.DialContext(context.Background(), "tcp", "target.test:"+port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr().String() != server.Listener.Addr().String() {
+		t.Errorf("wanted a connection to %s got %s", server.Listener.Addr(), conn.RemoteAddr())
+	}
+}
+
+func TestIsResetError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want bool
+	}{
+		{err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, want: true},
+		{err: fmt.Errorf("failed to read response; %w", syscall.ECONNRESET), want: true},
+		// without the errno
+		{err: errors.New("read tcp 127.0.0.1:1234: connection reset by peer"), want: true},
+		{err: syscall.ECONNREFUSED},
+		{err: errors.New("timeout")},
+		{},
+	} {
+		if got := IsResetError(tt.err); got != tt.want {
+			t.Errorf("wanted IsResetError(%v) to be %t", tt.err, tt.want)
+		}
+	}
+}
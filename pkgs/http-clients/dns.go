@@ -0,0 +1,34 @@
+package http_clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+)
+
+// DNSServerAddr returns the address to query the DNS server at, port 53 is used if server doesn't have one. It has
+// to be an IP as there'd be nothing to resolve its name with
+func DNSServerAddr(server string) (string, error) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		// no port, or an IPv6 address without brackets
+		host, port = server, "53"
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("DNS server %s needs to be an IP with an optional port i.e. 10.0.0.53:53", server)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// NewDNSResolver resolves hosts by querying the DNS server at addr rather than those the system is configured with.
+// Each lookup is a query, unlike the DoHResolver answers aren't cached
+func NewDNSResolver(addr string) *net.Resolver {
+	return &net.Resolver{
+		// the cgo resolver can't be pointed at another server
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, addr)
+		},
+	}
+}
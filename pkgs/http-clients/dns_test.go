@@ -0,0 +1,89 @@
+package http_clients
+
+import (
+	"context"
+	"golang.org/x/net/dns/dnsmessage"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDNSServerAddr(t *testing.T) {
+	tests := []struct {
+		server  string
+		want    string
+		wantErr bool
+	}{
+		{server: "10.0.0.53:5353", want: "10.0.0.53:5353"},
+		{server: "10.0.0.53", want: "10.0.0.53:53"},
+		{server: "::1", want: "[::1]:53"},
+		{server: "[::1]:5353", want: "[::1]:5353"},
+		// there'd be nothing to resolve it with
+		{server: "dns.example.com:53", wantErr: true},
+		{server: "10.0.0.300", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.server, func(t *testing.T) {
+			got, err := DNSServerAddr(tt.server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %v got %v", tt.wantErr, err)
+			}
+			if got != tt.want {
+				t.Errorf("wanted %s got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDNSResolver(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	var queries atomic.Int32
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var msg dnsmessage.Message
+			if err := msg.Unpack(buf[:n]); err != nil || len(msg.Questions) != 1 {
+				continue
+			}
+			q := msg.Questions[0]
+			if q.Name.String() == "dns.test." {
+				queries.Add(1)
+			}
+			msg.Response = true
+			msg.Authoritative = true
+			if q.Type == dnsmessage.TypeA && q.Name.String() == "dns.test." {
+				msg.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 60},
+					Body:   &dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}},
+				}}
+			}
+			resp, err := msg.Pack()
+			if err != nil {
+				continue
+			}
+			_, _ = pc.WriteTo(resp, addr)
+		}
+	}()
+
+	d := &Dialer{Resolver: NewDNSResolver(pc.LocalAddr().String())}
+	got, err := d.Resolve(context.Background(), "dns.test:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.1:8080"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted %v got %v", want, got)
+	}
+	if queries.Load() == 0 {
+		t.Error("wanted the host resolved by the DNS server")
+	}
+}
@@ -0,0 +1,186 @@
+package http_clients
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/net/dns/dnsmessage"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dohContentType = "application/dns-message"
+
+// DoHResolver resolves hosts via a DNS-over-HTTPS endpoint (RFC 8484), answers are cached for their TTL so a
+// load test only hits the endpoint when records expire
+type DoHResolver struct {
+	url    string
+	client *http.Client
+
+	mu    sync.Mutex
+	cache map[string]*dohEntry
+}
+
+type dohEntry struct {
+	// ready is closed once the lookup completes, so concurrent workers wait on a single query
+	ready   chan struct{}
+	addrs   []string
+	err     error
+	expires time.Time
+}
+
+func NewDoHResolver(dohURL string, timeout time.Duration, skipVerify bool) *DoHResolver {
+	return &DoHResolver{
+		url: dohURL,
+		client: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: skipVerify},
+			},
+			Timeout: timeout,
+		},
+		cache: make(map[string]*dohEntry),
+	}
+}
+
+func (r *DoHResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	e, ok := r.cache[host]
+	if ok && e.expired() {
+		ok = false
+	}
+	if !ok {
+		e = &dohEntry{ready: make(chan struct{})}
+		r.cache[host] = e
+		r.mu.Unlock()
+
+		e.addrs, e.expires, e.err = r.lookup(ctx, host)
+		close(e.ready)
+
+		if e.err != nil {
+			// don't cache failures, the next lookup should retry
+			r.mu.Lock()
+			if r.cache[host] == e {
+				delete(r.cache, host)
+			}
+			r.mu.Unlock()
+		}
+		return e.addrs, e.err
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-e.ready:
+		return e.addrs, e.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (e *dohEntry) expired() bool {
+	select {
+	case <-e.ready:
+		return time.Now().After(e.expires)
+	default:
+		return false
+	}
+}
+
+// lookup queries A records first, falling back to AAAA if the host has none
+func (r *DoHResolver) lookup(ctx context.Context, host string) ([]string, time.Time, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("doh: invalid host %s; %v", host, err)
+	}
+
+	var addrs []string
+	var ttl uint32
+	for _, t := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		addrs, ttl, err = r.query(ctx, name, t)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		if len(addrs) > 0 {
+			return addrs, time.Now().Add(time.Duration(ttl) * time.Second), nil
+		}
+	}
+	return nil, time.Time{}, fmt.Errorf("doh: no addresses found for host %s", host)
+}
+
+func (r *DoHResolver) query(ctx context.Context, name dnsmessage.Name, t dnsmessage.Type) ([]string, uint32, error) {
+	msg := dnsmessage.Message{
+		// ID 0 as recommended by RFC 8484 so responses are cache friendly
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  t,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return nil, 0, err
+	}
+	q := u.Query()
+	q.Set("dns", base64.RawURLEncoding.EncodeToString(packed))
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: query failed; %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, 0, fmt.Errorf("doh: query failed with status code %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, 0, fmt.Errorf("doh: failed reading response; %v", err)
+	}
+
+	var answer dnsmessage.Message
+	if err := answer.Unpack(body); err != nil {
+		return nil, 0, fmt.Errorf("doh: invalid response; %v", err)
+	}
+	if answer.RCode != dnsmessage.RCodeSuccess {
+		return nil, 0, errors.New("doh: query failed with rcode " + answer.RCode.String())
+	}
+
+	var addrs []string
+	var ttl uint32
+	for _, a := range answer.Answers {
+		var ip net.IP
+		switch b := a.Body.(type) {
+		case *dnsmessage.AResource:
+			ip = b.A[:]
+		case *dnsmessage.AAAAResource:
+			ip = b.AAAA[:]
+		default:
+			// e.g. CNAME records, the resolver includes the final addresses in the answer
+			continue
+		}
+		if len(addrs) == 0 || a.Header.TTL < ttl {
+			ttl = a.Header.TTL
+		}
+		addrs = append(addrs, ip.String())
+	}
+	return addrs, ttl, nil
+}
@@ -0,0 +1,147 @@
+package http_clients
+
+import (
+	"context"
+	"encoding/base64"
+	"golang.org/x/net/dns/dnsmessage"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDoHServer answers A and AAAA queries from records, keyed by name and type, counting the queries for each name
+func testDoHServer(t *testing.T, ttl uint32, records map[string][]dnsmessage.ResourceBody) (*httptest.Server, *sync.Map) {
+	var queries sync.Map
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		packed, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("dns"))
+		if err != nil || r.Header.Get("Accept") != dohContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(packed); err != nil || len(msg.Questions) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		q := msg.Questions[0]
+		n, _ := queries.LoadOrStore(q.Name.String(), &atomic.Int32{})
+		n.(*atomic.Int32).Add(1)
+		if strings.HasPrefix(q.Name.String(), "slow.") {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		msg.Response = true
+		for _, body := range records[q.Name.String()+q.Type.String()] {
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: ttl},
+				Body:   body,
+			})
+		}
+		resp, err := msg.Pack()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server, &queries
+}
+
+func queriesFor(queries *sync.Map, name string) int32 {
+	n, ok := queries.Load(name)
+	if !ok {
+		return 0
+	}
+	return n.(*atomic.Int32).Load()
+}
+
+func TestDoHResolver_LookupHost(t *testing.T) {
+	server, queries := testDoHServer(t, 60, map[string][]dnsmessage.ResourceBody{
+		"doh.test.TypeA":     {&dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}, &dnsmessage.AResource{A: [4]byte{127, 0, 0, 2}}},
+		"slow.test.TypeA":    {&dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}},
+		"v6.test.TypeAAAA":   {&dnsmessage.AAAAResource{AAAA: [16]byte{15: 1}}},
+		"cname.test.TypeA":   {&dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("doh.test.")}, &dnsmessage.AResource{A: [4]byte{127, 0, 0, 3}}},
+		"missing.test.TypeA": nil,
+	})
+	r := NewDoHResolver(server.URL+"/dns-query", time.Second, true)
+	ctx := context.Background()
+
+	for _, tt := range []struct {
+		host string
+		want []string
+	}{
+		{host: "doh.test", want: []string{"127.0.0.1", "127.0.0.2"}},
+		{host: "v6.test", want: []string{"::1"}},
+		{host: "cname.test", want: []string{"127.0.0.3"}},
+	} {
+		got, err := r.LookupHost(ctx, tt.host)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: wanted %v got %v", tt.host, tt.want, got)
+		}
+	}
+
+	// answers are cached for their TTL
+	if _, err := r.LookupHost(ctx, "doh.test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queriesFor(queries, "doh.test."); n != 1 {
+		t.Errorf("wanted 1 query for a cached answer got %d", n)
+	}
+	// AAAA is only asked for when there are no A records
+	if n := queriesFor(queries, "v6.test."); n != 2 {
+		t.Errorf("wanted A and AAAA queried got %d queries", n)
+	}
+
+	// concurrent lookups wait on a single query
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := r.LookupHost(ctx, "slow.test"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := queriesFor(queries, "slow.test."); n != 1 {
+		t.Errorf("wanted concurrent lookups to share 1 query got %d", n)
+	}
+
+	// failures aren't cached so the next lookup asks again
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupHost(ctx, "missing.test"); err == nil || err.Error() != "doh: no addresses found for host missing.test" {
+			t.Errorf("wanted no addresses error got %v", err)
+		}
+	}
+	if n := queriesFor(queries, "missing.test."); n != 4 {
+		t.Errorf("wanted A and AAAA queried for both lookups got %d queries", n)
+	}
+}
+
+func TestDoHResolver_LookupHostExpired(t *testing.T) {
+	server, queries := testDoHServer(t, 0, map[string][]dnsmessage.ResourceBody{
+		"doh.test.TypeA": {&dnsmessage.AResource{A: [4]byte{127, 0, 0, 1}}},
+	})
+	r := NewDoHResolver(server.URL, time.Second, true)
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.LookupHost(context.Background(), "doh.test"); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := queriesFor(queries, "doh.test."); n != 2 {
+		t.Errorf("wanted an answer with a TTL of 0 queried again got %d queries", n)
+	}
+}
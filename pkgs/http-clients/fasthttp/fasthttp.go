@@ -1,36 +1,104 @@
 package fasthttp
 
 import (
+	"bytes"
 	"crypto/tls"
+	"errors"
 	"github.com/dgrr/http2"
 	"github.com/domsolutions/gopayloader/pkgs/http-clients"
 	"github.com/valyala/fasthttp"
+	"io"
 	"net"
 	"net/url"
+	"strconv"
+	"strings"
 )
 
 type Client struct {
-	client *fasthttp.HostClient
+	client        *fasthttp.HostClient
+	timeoutJitter *http_clients.TimeoutJitter
+	http10        bool
+	keepAlive     bool
+	discardBody   bool
+	// http1 is set for fasthttp-1, fasthttp-2 frames requests itself
+	http1 bool
+	// firstByte is set with Config.TTFB
+	firstByte *firstByte
 }
 
 type Req struct {
-	req *fasthttp.Request
+	req        *fasthttp.Request
+	openStream func() (io.ReadCloser, error)
+	streamSize int64
+	ordered    bool
+	// keepAlive is asked for by HTTP/1.0 requests, which otherwise close the connection after the response
+	keepAlive bool
+	// contentLength is sent by SetHeaderOrder instead of the body's size when set, see SetContentLength
+	contentLength string
 }
 
 type Resp struct {
 	resp *fasthttp.Response
+	// streamed is the size of the body read by StreamBody, which is gone from resp once read
+	streamed int64
+	// skipBody is set when the body is discarded without being read, see Client.Do
+	skipBody bool
+	// firstByte is when the response started in unix nanoseconds, 0 without Config.TTFB
+	firstByte int64
 }
 
 func (r *Resp) StatusCode() int {
 	return r.resp.StatusCode()
 }
 
+func (r *Resp) Header(key string) (string, bool) {
+	var val []byte
+	found := false
+	k := []byte(key)
+	// header names aren't normalized so can't use Peek
+	r.resp.Header.VisitAll(func(hk, hv []byte) {
+		if !found && bytes.EqualFold(hk, k) {
+			val = hv
+			found = true
+		}
+	})
+	return string(val), found
+}
+
+func (r *Resp) Body() ([]byte, error) {
+	return r.resp.Body(), nil
+}
+
+func (r *Resp) StreamBody(w io.Writer) (int64, error) {
+	stream := r.resp.BodyStream()
+	if stream == nil {
+		// small bodies are read with the headers even when streaming, they're still in resp so aren't counted again
+		n, err := w.Write(r.resp.Body())
+		return int64(n), err
+	}
+	n, err := io.Copy(w, stream)
+	r.streamed = n
+	return n, err
+}
+
 func (r *Resp) Size() int64 {
-	var size = int64(len(r.resp.Body()))
+	if r.skipBody {
+		// reading the body to measure it would defeat skipping it
+		return int64(len(r.resp.Header.Header()))
+	}
+	var size = int64(len(r.resp.Body())) + r.streamed
 	size += int64(len(r.resp.Header.Header()))
 	return size
 }
 
+func (r *Resp) FirstByte() int64 {
+	return r.firstByte
+}
+
+func (r *Resp) Phases() http_clients.Phases {
+	return http_clients.Phases{}
+}
+
 func (r *Resp) Close() {
 	r.resp.CloseBodyStream()
 }
@@ -40,6 +108,25 @@ func (fh *Req) SetHeader(key, val string) {
 }
 
 func (fh *Req) Size() int64 {
+	if fh.ordered {
+		// VisitAll double counts headers which are normally special once they're ordered, so measure the
+		// raw headers instead
+		size := int64(len(fh.req.Header.Header()))
+		if fh.openStream != nil {
+			return size + fh.streamSize
+		}
+		return size + int64(len(fh.req.Body()))
+	}
+
+	if fh.openStream != nil {
+		// body stream is closed after each request so can't be measured, use the size it was set with
+		size := fh.streamSize + 2
+		fh.req.Header.VisitAll(func(key, value []byte) {
+			size += int64(len(key) + len(value) + 2)
+		})
+		return size
+	}
+
 	size := len(fh.req.Body()) + 2 // 2 for the \r\n that separates the headers and body.
 	fh.req.Header.VisitAll(func(key, value []byte) {
 		size += len(key) + len(value) + 2 // 2 for the \r\n that separates the headers.
@@ -55,8 +142,146 @@ func (fh *Req) SetBody(body []byte) {
 	fh.req.SetBody(body)
 }
 
+func (fh *Req) SetQuery(query []byte) {
+	fh.req.URI().SetQueryStringBytes(query)
+}
+
+func (fh *Req) SetURI(uri string) error {
+	fh.req.SetRequestURI(uri)
+	return nil
+}
+
+func (fh *Req) SetConnectionClose(close bool) {
+	if close {
+		if fh.keepAlive {
+			fh.req.Header.Del(fasthttp.HeaderConnection)
+		}
+		fh.req.Header.SetConnectionClose()
+	} else if fh.keepAlive {
+		fh.req.Header.Set(fasthttp.HeaderConnection, "keep-alive")
+	} else {
+		fh.req.Header.ResetConnectionClose()
+	}
+}
+
+func (fh *Req) SetBodyStream(open func() (io.ReadCloser, error), size int64) {
+	fh.openStream = open
+	fh.streamSize = size
+}
+
+// SetContentLength only takes effect once the headers are ordered, otherwise fasthttp always sends the body's size
+func (fh *Req) SetContentLength(length string) {
+	fh.contentLength = length
+}
+
+// SetHeaderOrder rewrites the headers with special header handling disabled, so Host, Content-Length etc. are sent
+// like any other header in the position and casing given by order. Headers not in order follow in the order they
+// were set, as do headers set afterwards i.e. JWTs
+func (fh *Req) SetHeaderOrder(order []string) {
+	type header struct {
+		key, val string
+	}
+	headers := make([]header, 0)
+	fh.req.Header.VisitAll(func(key, val []byte) {
+		headers = append(headers, header{string(key), string(val)})
+	})
+
+	// these are only added by fasthttp when the request is written, which won't happen once special headers are
+	// disabled
+	has := func(key string) bool {
+		for _, h := range headers {
+			if strings.EqualFold(h.key, key) {
+				return true
+			}
+		}
+		return false
+	}
+	if !has(fasthttp.HeaderHost) {
+		headers = append(headers, header{fasthttp.HeaderHost, string(fh.req.URI().Host())})
+	}
+	size := int64(len(fh.req.Body()))
+	if fh.openStream != nil {
+		size = fh.streamSize
+	}
+	if fh.contentLength != "" {
+		kept := headers[:0]
+		for _, h := range headers {
+			if !strings.EqualFold(h.key, fasthttp.HeaderContentLength) {
+				kept = append(kept, h)
+			}
+		}
+		headers = kept
+		if fh.contentLength != http_clients.ContentLengthNone {
+			headers = append(headers, header{fasthttp.HeaderContentLength, fh.contentLength})
+		}
+	} else if !has(fasthttp.HeaderContentLength) && (size > 0 || !(fh.req.Header.IsGet() || fh.req.Header.IsHead() || fh.req.Header.IsTrace())) {
+		headers = append(headers, header{fasthttp.HeaderContentLength, strconv.FormatInt(size, 10)})
+	}
+
+	method := string(fh.req.Header.Method())
+	proto := string(fh.req.Header.Protocol())
+	fh.req.Header.Reset()
+	fh.req.Header.SetMethod(method)
+	fh.req.Header.SetProtocol(proto)
+	fh.req.Header.DisableNormalizing()
+	fh.req.Header.DisableSpecialHeader()
+	fh.ordered = true
+
+	added := make([]bool, len(headers))
+	for _, key := range order {
+		for i, h := range headers {
+			if !added[i] && strings.EqualFold(h.key, key) {
+				fh.req.Header.Add(key, h.val)
+				added[i] = true
+			}
+		}
+	}
+	for i, h := range headers {
+		if !added[i] {
+			fh.req.Header.Add(h.key, h.val)
+		}
+	}
+}
+
 func (fh *Client) Do(req http_clients.Request, resp http_clients.Response) error {
-	return fh.client.Do(req.(*Req).req, resp.(*Resp).resp)
+	r := req.(*Req)
+	resp.(*Resp).streamed = 0
+	// an unread body would be taken as the start of the next response, so it's only skipped when the connection is
+	// closed after this request. It's left as an unread stream, SkipBody would still read the trailers of a chunked
+	// body
+	resp.(*Resp).skipBody = fh.discardBody && r.req.Header.ConnectionClose()
+	resp.(*Resp).resp.StreamBody = resp.(*Resp).skipBody
+	if fh.firstByte != nil {
+		fh.firstByte.armed, fh.firstByte.at = false, 0
+		defer func() {
+			resp.(*Resp).firstByte = fh.firstByte.at
+		}()
+	}
+	if r.openStream == nil {
+		return fh.do(r.req, resp.(*Resp).resp)
+	}
+
+	// fasthttp won't retry requests with a body stream as it's already been consumed, so re-open the stream and
+	// retry once if the server closed an idle keep-alive connection
+	for attempt := 0; ; attempt++ {
+		body, err := r.openStream()
+		if err != nil {
+			return err
+		}
+		r.req.SetBodyStream(body, int(r.streamSize))
+
+		err = fh.do(r.req, resp.(*Resp).resp)
+		if err == nil || attempt > 0 || !errors.Is(err, fasthttp.ErrConnectionClosed) {
+			return err
+		}
+	}
+}
+
+func (fh *Client) do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	if fh.timeoutJitter != nil {
+		return fh.client.DoTimeout(req, resp, fh.timeoutJitter.Next())
+	}
+	return fh.client.Do(req, resp)
 }
 
 func (c *Client) CloseConns() {
@@ -71,9 +296,24 @@ func (fh *Client) NewReq(method, url string) (http_clients.Request, error) {
 	r := &fasthttp.Request{}
 	r.SetRequestURI(url)
 	r.Header.SetMethodBytes([]byte(method))
-	return &Req{
+	req := &Req{
 		req: r,
-	}, nil
+	}
+	if fh.http10 {
+		r.Header.SetProtocol("HTTP/1.0")
+		// HTTP/1.0 connections close after each response unless keep-alive is asked for
+		if fh.keepAlive {
+			req.keepAlive = true
+			r.Header.Set(fasthttp.HeaderConnection, "keep-alive")
+		}
+	}
+	if fh.http1 && method == fasthttp.MethodTrace {
+		// fasthttp sends a Content-Type and Content-Length: 0 with every method other than GET and HEAD, TRACE
+		// requests can't have content so the headers are written as they're set instead
+		r.Header.Set(fasthttp.HeaderUserAgent, "fasthttp")
+		req.SetHeaderOrder(nil)
+	}
+	return req, nil
 }
 
 func GetFastHTTPClient1(config *http_clients.Config) (http_clients.GoPayLoaderClient, error) {
@@ -89,25 +329,80 @@ func GetFastHTTPClient1(config *http_clients.Config) (http_clients.GoPayLoaderCl
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
-	u, err := url.ParseRequestURI(config.ReqURI)
+	http_clients.ConfigureTLS(tlsConfig, config)
+
+	reqURI := config.ReqURI
+	if config.RawPath {
+		// only the host is needed, the path may not parse
+		reqURI, _ = http_clients.SplitRawURI(reqURI)
+	}
+	u, err := url.ParseRequestURI(reqURI)
 	if err != nil {
 		return nil, err
 	}
 
+	readTimeout := config.ReadTimeout
+	if config.TimeoutJitter > 0 {
+		// the jittered timeout of each request is used instead, the read deadline is whichever is sooner
+		readTimeout += config.TimeoutJitter
+	}
 	client := &fasthttp.HostClient{
 		Addr:                          u.Host,
 		IsTLS:                         u.Scheme == "https",
 		MaxConns:                      1,
-		ReadTimeout:                   config.ReadTimeout,
+		ReadTimeout:                   readTimeout,
 		WriteTimeout:                  config.WriteTimeout,
 		DisableHeaderNamesNormalizing: true,
 		TLSConfig:                     tlsConfig,
+		MaxIdleConnDuration:           config.MaxIdleConnDuration,
+		MaxConnDuration:               config.MaxConnDuration,
+		DisablePathNormalizing:        config.RawPath,
+		StreamResponseBody:            config.StreamBody,
+		// response headers have to fit in the read buffer
+		ReadBufferSize: config.MaxHeaderSize,
 		Dial: func(addr string) (net.Conn, error) {
 			return fasthttp.DialTimeout(addr, config.ReadTimeout)
 		},
 	}
+	if config.CustomDial() {
+		client.Dial = http_clients.NewDialer(config).Dial
+	}
+	if config.TCPStats != nil {
+		// given the socket before anything wraps it
+		client.Dial = config.TCPStats.Dial(client.Dial)
+	}
+	// under TLS so it sees the writes to the socket
+	client.Dial = http_clients.PartialWriteDial(client.Dial)
 
-	return &Client{client: client}, nil
+	c := &Client{
+		client:        client,
+		timeoutJitter: http_clients.NewTimeoutJitter(config, config.ReadTimeout),
+		http10:        config.HTTP10,
+		http1:         true,
+		keepAlive:     !config.DisableKeepAlive,
+		discardBody:   config.DiscardBody,
+	}
+	if config.TTFB {
+		c.firstByte = &firstByte{}
+		var handshake *tls.Config
+		if client.IsTLS {
+			// like fasthttp, certs are verified against the target host whichever address is dialed
+			handshake = tlsConfig.Clone()
+			if handshake.ServerName == "" {
+				handshake.ServerName = u.Hostname()
+			}
+		}
+		client.Dial = firstByteDial(client.Dial, c.firstByte, handshake, config.ReadTimeout)
+	}
+	if config.ConnTracker != nil {
+		idle := client.MaxIdleConnDuration
+		if idle == 0 {
+			idle = fasthttp.DefaultMaxIdleConnDuration
+		}
+		config.ConnTracker.SetLimits(client.MaxConnDuration, idle)
+		client.Dial = config.ConnTracker.Dial(client.Dial)
+	}
+	return c, nil
 }
 
 func GetFastHTTPClient2(config *http_clients.Config) (http_clients.GoPayLoaderClient, error) {
@@ -116,6 +411,13 @@ func GetFastHTTPClient2(config *http_clients.Config) (http_clients.GoPayLoaderCl
 		return nil, err
 	}
 
+	if config.H2PingInterval > 0 {
+		if err := configureH2Pings(client.(*Client).client, config.H2PingInterval); err != nil {
+			return nil, err
+		}
+		return &Client{client: client.(*Client).client}, nil
+	}
+
 	if err := http2.ConfigureClient(client.(*Client).client, http2.ClientOpts{
 		//MaxResponseTime: config.ReadTimeout + config.WriteTimeout,
 	}); err != nil {
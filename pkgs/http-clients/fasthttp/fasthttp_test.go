@@ -0,0 +1,97 @@
+package fasthttp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sentHeaders returns the header names req is written with, in order
+func sentHeaders(req *Req) []string {
+	var keys []string
+	lines := strings.Split(string(req.req.Header.Header()), "\r\n")
+	for _, line := range lines[1:] {
+		if i := strings.Index(line, ":"); i > 0 {
+			keys = append(keys, line[:i])
+		}
+	}
+	return keys
+}
+
+func TestReq_SetHeaderOrder(t *testing.T) {
+	r, err := (&Client{}).NewReq("POST", "http://localhost:8080/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := r.(*Req)
+	req.SetBody([]byte(`{"hello":"world"}`))
+	for _, h := range [][2]string{{"x-first", "1"}, {"Accept", "*/*"}, {"Content-Type", "application/json"}, {"x-last", "2"}} {
+		req.SetHeader(h[0], h[1])
+	}
+	req.SetHeaderOrder([]string{"accept", "X-LAST", "content-length", "Host", "Content-Type"})
+
+	// listed headers first in the given casing, then the rest in the order they were set with their usual casing
+	want := []string{"accept", "X-LAST", "content-length", "Host", "Content-Type", "X-First"}
+	if got := sentHeaders(req); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted header order %v got %v", want, got)
+	}
+	if got := string(req.req.Header.Peek("content-length")); got != "17" {
+		t.Errorf("wanted the body's size as content-length got %q", got)
+	}
+}
+
+func TestClient_NewReqHTTP10(t *testing.T) {
+	for _, keepAlive := range []bool{true, false} {
+		r, err := (&Client{http10: true, keepAlive: keepAlive}).NewReq("GET", "http://localhost:8080/legacy?a=1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := r.(*Req)
+		if proto := string(req.req.Header.Protocol()); proto != "HTTP/1.0" {
+			t.Errorf("wanted an HTTP/1.0 request line got %s", proto)
+		}
+		// HTTP/1.0 connections close after each response unless keep-alive is asked for
+		if got := string(req.req.Header.Peek("Connection")); (got == "keep-alive") != keepAlive {
+			t.Errorf("keep-alive %v: got Connection %q", keepAlive, got)
+		}
+	}
+}
+
+func TestReq_SetContentLength(t *testing.T) {
+	for _, tt := range []struct {
+		length string
+		// want is the Content-Length header sent, empty for none
+		want string
+	}{
+		{length: "none"},
+		{length: "0", want: "0"},
+		{length: "3", want: "3"},
+		{length: "100", want: "100"},
+	} {
+		r, err := (&Client{}).NewReq("POST", "http://localhost:8080/orders")
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := r.(*Req)
+		req.SetBody([]byte(`{"id":1}`))
+		req.SetContentLength(tt.length)
+		req.SetHeaderOrder(nil)
+
+		var got []string
+		for _, line := range strings.Split(string(req.req.Header.Header()), "\r\n") {
+			if name, val, _ := strings.Cut(line, ":"); strings.EqualFold(name, "Content-Length") {
+				got = append(got, strings.TrimSpace(val))
+			}
+		}
+		if tt.want == "" && len(got) != 0 {
+			t.Errorf("%s: wanted no content length got %v", tt.length, got)
+		}
+		if tt.want != "" && (len(got) != 1 || got[0] != tt.want) {
+			t.Errorf("%s: wanted content length %s got %v", tt.length, tt.want, got)
+		}
+		// the whole body is still sent
+		if string(req.req.Body()) != `{"id":1}` {
+			t.Errorf("%s: wanted the body kept got %s", tt.length, req.req.Body())
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package fasthttp
+
+import (
+	"crypto/tls"
+	"github.com/valyala/fasthttp"
+	"net"
+	"time"
+)
+
+// firstByte is when the first byte of the response to the request being sent was read, fasthttp has no hooks for it
+// so it's recorded by the connection. Each client has one connection used by one worker so it isn't synchronised
+type firstByte struct {
+	// armed is set once the request is written, so a read after it is the start of the response
+	armed bool
+	at    int64
+}
+
+type firstByteConn struct {
+	net.Conn
+	rec *firstByte
+}
+
+func (c *firstByteConn) Write(b []byte) (int, error) {
+	c.rec.armed = true
+	return c.Conn.Write(b)
+}
+
+func (c *firstByteConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.rec.armed {
+		c.rec.at = time.Now().UnixNano()
+		c.rec.armed = false
+	}
+	return n, err
+}
+
+// Handshake makes fasthttp take the conn as already being TLS, see firstByteDial
+func (c *firstByteConn) Handshake() error {
+	if h, ok := c.Conn.(interface{ Handshake() error }); ok {
+		return h.Handshake()
+	}
+	return nil
+}
+
+// firstByteDial wraps connections to record when responses start. For TLS the handshake is done here, the conn is
+// wrapped around the TLS conn rather than the TCP one otherwise the handshake and post-handshake messages would be
+// taken as the response
+func firstByteDial(dial fasthttp.DialFunc, rec *firstByte, tlsConfig *tls.Config, timeout time.Duration) fasthttp.DialFunc {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		if tlsConfig == nil {
+			return &firstByteConn{Conn: conn, rec: rec}, nil
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if timeout > 0 {
+			conn.SetDeadline(time.Now().Add(timeout))
+		}
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn.SetDeadline(time.Time{})
+		return &firstByteConn{Conn: tlsConn, rec: rec}, nil
+	}
+}
@@ -0,0 +1,70 @@
+package fasthttp
+
+import (
+	"github.com/dgrr/http2"
+	"github.com/valyala/fasthttp"
+	"sync"
+	"time"
+)
+
+// h2PingTransport sends requests over an HTTP/2 connection which pings at its own interval. http2.ConfigureClient
+// doesn't pass ClientOpts.PingInterval on to the connections it dials, so they'd always ping every
+// http2.DefaultPingInterval. Each client has one connection used by one worker, so requests aren't spread over
+// connections like http2.Client does
+type h2PingTransport struct {
+	dialer *http2.Dialer
+	mu     sync.Mutex
+	conn   *http2.Conn
+}
+
+// configureH2Pings is http2.ConfigureClient with the connection pinged every interval
+func configureH2Pings(c *fasthttp.HostClient, interval time.Duration) error {
+	t := &h2PingTransport{dialer: &http2.Dialer{
+		Addr:         c.Addr,
+		TLSConfig:    c.TLSConfig,
+		PingInterval: interval,
+		NetDial:      c.Dial,
+	}}
+	// dialed straight away like http2.ConfigureClient so a server without HTTP/2 fails the client
+	if _, err := t.getConn(); err != nil {
+		return err
+	}
+	c.IsTLS = true
+	c.TLSConfig = t.dialer.TLSConfig
+	c.Transport = t.do
+	return nil
+}
+
+func (t *h2PingTransport) getConn() (*http2.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil || t.conn.Closed() {
+		conn, err := t.dialer.Dial(http2.ConnOpts{PingInterval: t.dialer.PingInterval})
+		if err != nil {
+			return nil, err
+		}
+		t.conn = conn
+	}
+	return t.conn, nil
+}
+
+func (t *h2PingTransport) do(req *fasthttp.Request, resp *fasthttp.Response) error {
+	conn, err := t.getConn()
+	if err != nil {
+		return err
+	}
+	errs := make(chan error, 1)
+	ctx := &http2.Ctx{Request: req, Response: resp, Err: errs}
+	// the same limit http2.Client has on waiting for a response
+	timer := time.AfterFunc(http2.DefaultMaxResponseTime, func() {
+		select {
+		case errs <- http2.ErrRequestCanceled:
+		default:
+		}
+		conn.Cancel(ctx)
+	})
+	defer timer.Stop()
+
+	conn.Write(ctx)
+	return <-errs
+}
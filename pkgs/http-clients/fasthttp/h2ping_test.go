@@ -0,0 +1,120 @@
+package fasthttp
+
+import (
+	"crypto/tls"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/valyala/fasthttp"
+	"golang.org/x/net/http2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pingConn records when the client sends HTTP/2 PING frames, frames are read as the server reads them
+type pingConn struct {
+	net.Conn
+	mu    sync.Mutex
+	buf   []byte
+	pings []time.Time
+	// skip is how much of the connection preface or the current frame's payload is still to be read
+	skip int
+}
+
+func (c *pingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = append(c.buf, b[:n]...)
+	for {
+		if c.skip > 0 {
+			skip := c.skip
+			if skip > len(c.buf) {
+				skip = len(c.buf)
+			}
+			c.buf, c.skip = c.buf[skip:], c.skip-skip
+			if c.skip > 0 {
+				break
+			}
+		}
+		if len(c.buf) < 9 {
+			break
+		}
+		// PING is type 6, acks have the first flag set
+		length := int(c.buf[0])<<16 | int(c.buf[1])<<8 | int(c.buf[2])
+		if c.buf[3] == 6 && c.buf[4]&1 == 0 {
+			c.pings = append(c.pings, time.Now())
+		}
+		c.buf, c.skip = c.buf[9:], length
+	}
+	return n, err
+}
+
+func (c *pingConn) times() []time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]time.Time(nil), c.pings...)
+}
+
+func TestGetFastHTTPClient2_H2PingInterval(t *testing.T) {
+	// only used for its certificate
+	certs := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	conf := certs.TLS.Clone()
+	certs.Close()
+	conf.NextProtos = []string{"h2"}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	conns := make(chan *pingConn, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &pingConn{Conn: conn, skip: len(http2.ClientPreface)}
+		conns <- c
+		(&http2.Server{}).ServeConn(c, &http2.ServeConnOpts{
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		})
+	}()
+
+	client, err := GetFastHTTPClient2(&http_clients.Config{
+		ReqURI:         "https://" + ln.Addr().String(),
+		ReadTimeout:    5 * time.Second,
+		WriteTimeout:   5 * time.Second,
+		SkipVerify:     true,
+		H2PingInterval: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := fasthttp.AcquireRequest()
+	defer fasthttp.ReleaseRequest(req)
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI("https://" + ln.Addr().String() + "/")
+	if err := client.(*Client).client.Do(req, resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		t.Fatalf("wanted 200 over the pinged connection got %d", resp.StatusCode())
+	}
+
+	// the connection is left idle so only pings are sent
+	c := <-conns
+	time.Sleep(time.Second)
+	pings := c.times()
+	if len(pings) < 5 {
+		t.Fatalf("wanted a ping every 100ms while idle got %d", len(pings))
+	}
+	for i := 1; i < len(pings); i++ {
+		if gap := pings[i].Sub(pings[i-1]); gap < 50*time.Millisecond || gap > 500*time.Millisecond {
+			t.Errorf("wanted pings 100ms apart got %s between ping %d and %d", gap, i-1, i)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package http_clients
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// HeaderTemplate is a -H header whose value is rendered for every request with the same data as
+// --body-template-file i.e. 'X-Nonce: {{.UUID}}' or 'X-Seq: {{.Index}}'
+type HeaderTemplate struct {
+	Name  string
+	Value *template.Template
+}
+
+// IsHeaderTemplate is whether a -H header's value is a template rather than sent as is
+func IsHeaderTemplate(header string) bool {
+	_, value, _ := strings.Cut(header, ":")
+	return strings.Contains(value, "{{")
+}
+
+// ParseHeaderTemplates parses the templated headers, others are left to be set once on the request. They're parsed
+// once and shared between workers
+func ParseHeaderTemplates(headers []string) ([]HeaderTemplate, error) {
+	var templates []HeaderTemplate
+	for _, h := range headers {
+		if !IsHeaderTemplate(h) {
+			continue
+		}
+		// unlike static headers the value can contain : i.e. {{.Time.Format "15:04:05"}}
+		name, value, _ := strings.Cut(h, ":")
+		t, err := template.New(name).Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("header %s template; %v", name, err)
+		}
+		templates = append(templates, HeaderTemplate{Name: name, Value: t})
+	}
+	return templates, nil
+}
+
+// ParseBodyTemplate parses --body-template-file, it's parsed once and shared between workers as executing a template
+// is safe from multiple goroutines
+func ParseBodyTemplate(file string) (*template.Template, error) {
+	t, err := template.New(filepath.Base(file)).Option("missingkey=error").ParseFiles(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse body template; %v", err)
+	}
+	return t, nil
+}
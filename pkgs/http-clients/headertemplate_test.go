@@ -0,0 +1,52 @@
+package http_clients
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseHeaderTemplates(t *testing.T) {
+	templates, err := ParseHeaderTemplates([]string{"X-Static: fixed", "X-Seq: {{.Index}}", `X-Time: {{.Time.Format "15:04:05"}}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// static headers are set once on the request instead
+	if len(templates) != 2 || templates[0].Name != "X-Seq" || templates[1].Name != "X-Time" {
+		t.Fatalf("wanted the X-Seq and X-Time templates got %+v", templates)
+	}
+	out := &strings.Builder{}
+	if err := templates[0].Value.Execute(out, struct{ Index int }{3}); err != nil || out.String() != " 3" {
+		t.Errorf("wanted the value rendered got %q; %v", out, err)
+	}
+
+	if _, err := ParseHeaderTemplates([]string{"X-Nonce: {{.UUID"}); err == nil || !strings.Contains(err.Error(), "header X-Nonce template") {
+		t.Errorf("wanted template parse error got %v", err)
+	}
+}
+
+func TestParseBodyTemplate(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.tmpl")
+	if err := os.WriteFile(valid, []byte(`{"index": {{.Index}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	invalid := filepath.Join(dir, "invalid.tmpl")
+	if err := os.WriteFile(invalid, []byte(`{"index": {{.Index}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl, err := ParseBodyTemplate(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &strings.Builder{}
+	if err := tmpl.Execute(out, struct{ Index int }{3}); err != nil || out.String() != `{"index": 3}` {
+		t.Errorf("wanted the file rendered got %q; %v", out, err)
+	}
+
+	if _, err := ParseBodyTemplate(invalid); err == nil || !strings.Contains(err.Error(), "failed to parse body template") {
+		t.Errorf("wanted template parse error got %v", err)
+	}
+}
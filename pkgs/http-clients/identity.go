@@ -0,0 +1,87 @@
+package http_clients
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Identity is a virtual user of --identities, a connection sends all of its requests as the same identity with its
+// client cert, JWT and headers, connection N being the Nth identity and wrapping around when there are more
+// connections than identities
+type Identity struct {
+	Name string
+	// Cert is empty for an identity without a client cert
+	Cert    CertPair
+	JWT     string
+	Headers []string
+}
+
+// identitiesFile is the --identities JSON format
+type identitiesFile struct {
+	Identities []struct {
+		Name    string   `json:"name"`
+		Cert    string   `json:"cert"`
+		Key     string   `json:"key"`
+		JWT     string   `json:"jwt"`
+		Headers []string `json:"headers"`
+	} `json:"identities"`
+}
+
+// LoadIdentities reads and checks an --identities file. Cert and key paths are relative to the file, each pair is
+// loaded to check the key matches the cert
+func LoadIdentities(path string) ([]Identity, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read identities file %v", err)
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	// a misspelt field would otherwise leave an identity without a credential without saying
+	d.DisallowUnknownFields()
+	var f identitiesFile
+	if err := d.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse identities file %s; %v", path, err)
+	}
+	if len(f.Identities) == 0 {
+		return nil, fmt.Errorf("identities file %s has no identities", path)
+	}
+
+	dir := filepath.Dir(path)
+	identities := make([]Identity, 0, len(f.Identities))
+	for i, id := range f.Identities {
+		identity := Identity{Name: id.Name, JWT: id.JWT, Headers: id.Headers}
+		if identity.Name == "" {
+			identity.Name = fmt.Sprintf("%d", i+1)
+		}
+		if (id.Cert == "") != (id.Key == "") {
+			return nil, fmt.Errorf("identity %s needs both a cert and key or neither", identity.Name)
+		}
+		if id.Cert != "" {
+			identity.Cert = CertPair{Cert: resolvePath(dir, id.Cert), Key: resolvePath(dir, id.Key)}
+			if _, err := tls.LoadX509KeyPair(identity.Cert.Cert, identity.Cert.Key); err != nil {
+				return nil, fmt.Errorf("identity %s cert and key; %v", identity.Name, err)
+			}
+		}
+		for _, h := range id.Headers {
+			if !strings.Contains(h, ":") {
+				return nil, fmt.Errorf("identity %s header %s does not contain :", identity.Name, h)
+			}
+		}
+		if id.Cert == "" && id.JWT == "" && len(id.Headers) == 0 {
+			return nil, fmt.Errorf("identity %s has no cert, jwt or headers", identity.Name)
+		}
+		identities = append(identities, identity)
+	}
+	return identities, nil
+}
+
+func resolvePath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,75 @@
+package http_clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadIdentities(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCA(t)
+	cert := newTestCert(t, ca, caKey)
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tenant-a.crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tenant-a.key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, identities string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(identities), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// cert paths are relative to the file, an identity without a name is numbered
+	got, err := LoadIdentities(write("identities.json", `{"identities": [
+		{"name": "a", "cert": "tenant-a.crt", "key": "tenant-a.key", "jwt": "jwt-a", "headers": ["X-Tenant: a"]},
+		{"jwt": "jwt-b"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Identity{
+		{Name: "a", Cert: CertPair{Cert: filepath.Join(dir, "tenant-a.crt"), Key: filepath.Join(dir, "tenant-a.key")}, JWT: "jwt-a", Headers: []string{"X-Tenant: a"}},
+		{Name: "2", JWT: "jwt-b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted identities %+v got %+v", want, got)
+	}
+
+	tests := []struct {
+		name       string
+		identities string
+		wantErr    string
+	}{
+		{name: "none", identities: `{"identities": []}`, wantErr: "has no identities"},
+		{name: "misspelt field", identities: `{"identities": [{"jwts": "jwt-a"}]}`, wantErr: "failed to parse identities file"},
+		{name: "cert without a key", identities: `{"identities": [{"name": "a", "cert": "tenant-a.crt"}]}`, wantErr: "identity a needs both a cert and key or neither"},
+		{name: "key not matching", identities: `{"identities": [{"name": "a", "cert": "tenant-a.crt", "key": "tenant-a.crt"}]}`, wantErr: "identity a cert and key"},
+		{name: "header without a colon", identities: `{"identities": [{"name": "a", "headers": ["X-Tenant"]}]}`, wantErr: "identity a header X-Tenant does not contain :"},
+		{name: "no credentials", identities: `{"identities": [{"name": "a"}]}`, wantErr: "identity a has no cert, jwt or headers"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadIdentities(write("invalid.json", tt.identities))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wanted error %q got %v", tt.wantErr, err)
+			}
+		})
+	}
+	if _, err := LoadIdentities(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("wanted error for a missing identities file")
+	}
+}
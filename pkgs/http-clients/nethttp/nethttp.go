@@ -2,30 +2,135 @@ package nethttp
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"errors"
 	"github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/quic-go/quic-go"
 	"github.com/quic-go/quic-go/http3"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync/atomic"
+	"time"
 )
 
+// drainLimit is the most of a --discard-body response body read to reuse the connection, net/http only drains small
+// bodies itself and closes the connection otherwise. Past this it's cheaper to open another
+const drainLimit = 4 << 20
+
 type Client struct {
-	client *http.Client
+	client          *http.Client
+	rawPath         bool
+	discardBody     bool
+	maxConnDuration time.Duration
+	timeoutJitter   *http_clients.TimeoutJitter
+	// dialed is when the connection was opened in unix nanoseconds, each client only has one connection
+	dialed atomic.Int64
+	// ttfb is set with Config.TTFB, firstByte is set by trace except over HTTP/3 which has no trace hooks
+	ttfb      bool
+	trace     *httptrace.ClientTrace
+	firstByte atomic.Int64
+	// phases is set with Config.Phases, the trace stores when each phase happened in the times
+	phases bool
+	times  phaseTimes
+	// wrote stores the request's PartialWriteError in partial, net/http reads the response while the request is
+	// written so the failed read of a target's reset can be returned rather than the failed write
+	wrote   *httptrace.ClientTrace
+	partial atomic.Pointer[http_clients.PartialWriteError]
+}
+
+// phaseTimes are when each phase of the request being sent happened in unix nanoseconds, they're atomic as the
+// connection is dialed on another goroutine
+type phaseTimes struct {
+	dnsStart     atomic.Int64
+	dnsDone      atomic.Int64
+	connectStart atomic.Int64
+	connectDone  atomic.Int64
+	tlsStart     atomic.Int64
+	tlsDone      atomic.Int64
+	gotConn      atomic.Int64
+}
+
+func (t *phaseTimes) reset() {
+	for _, v := range []*atomic.Int64{&t.dnsStart, &t.dnsDone, &t.connectStart, &t.connectDone, &t.tlsStart, &t.tlsDone, &t.gotConn} {
+		v.Store(0)
+	}
+}
+
+// stamp stores now in v, only the first time so a dial which falls back to another address is timed from the start
+func stamp(v *atomic.Int64) {
+	v.CompareAndSwap(0, time.Now().UnixNano())
 }
 
 type Req struct {
 	req *http.Request
+	// close is set for requests which should close the connection once they're done
+	close bool
 }
 
 type Resp struct {
 	resp *http.Response
+	// body is kept once read so the response can be checked more than once i.e. asserted and compared
+	body    []byte
+	bodyErr error
+	read    bool
+	// cancel ends the request's jittered timeout, it can't be cancelled until the body's been read
+	cancel context.CancelFunc
+	// drain is set with --discard-body, the body is read to the end before closing so the connection is reused
+	drain bool
+	// firstByte is when the response started in unix nanoseconds, 0 without Config.TTFB
+	firstByte int64
+	// phases is zero without Config.Phases
+	phases http_clients.Phases
 }
 
 func (r *Resp) StatusCode() int {
 	return r.resp.StatusCode
 }
 
+func (r *Resp) Header(key string) (string, bool) {
+	vals := r.resp.Header.Values(key)
+	if len(vals) == 0 {
+		return "", false
+	}
+	return vals[0], true
+}
+
+func (r *Resp) Body() ([]byte, error) {
+	if !r.read {
+		r.body, r.bodyErr = io.ReadAll(r.resp.Body)
+		r.read = true
+	}
+	return r.body, r.bodyErr
+}
+
+func (r *Resp) StreamBody(w io.Writer) (int64, error) {
+	return io.Copy(w, r.resp.Body)
+}
+
+func (r *Resp) FirstByte() int64 {
+	return r.firstByte
+}
+
+func (r *Resp) Phases() http_clients.Phases {
+	return r.phases
+}
+
 func (r *Resp) Close() {
+	if r.cancel != nil {
+		r.cancel()
+		r.cancel = nil
+	}
+	if r.resp == nil {
+		// request failed so there's no response
+		return
+	}
+	if r.drain && !r.read {
+		io.CopyN(io.Discard, r.resp.Body, drainLimit)
+	}
 	r.resp.Body.Close()
 }
 
@@ -34,6 +139,15 @@ func (r *Resp) Size() int64 {
 		return 0
 	}
 	var size = r.resp.ContentLength
+	if size < 0 {
+		// unknown i.e. chunked so the body is measured, unless it's being discarded
+		size = 0
+		if !r.drain {
+			if body, err := r.Body(); err == nil {
+				size = int64(len(body))
+			}
+		}
+	}
 	for key, header := range r.resp.Header {
 		size += int64(len(key))
 		for _, val := range header {
@@ -56,8 +170,43 @@ func (r *Req) SetBody(body []byte) {
 		r := bytes.NewReader(body)
 		return io.NopCloser(r), nil
 	}
+	r.req.ContentLength = int64(len(body))
+}
+
+func (r *Req) SetQuery(query []byte) {
+	r.req.URL.RawQuery = string(query)
+}
+
+// SetURI keeps the Host the request was created with, only the host of the URL it was created with is dialed
+func (r *Req) SetURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	r.req.URL = u
+	return nil
+}
+
+// SetConnectionClose is applied to the request as it's sent so it doesn't carry over to the next request
+func (r *Req) SetConnectionClose(close bool) {
+	r.close = close
 }
 
+// SetBodyStream uses GetBody to re-open the stream for every request, the transport also uses it to re-stream
+// the body from the start on retries and redirects
+func (r *Req) SetBodyStream(open func() (io.ReadCloser, error), size int64) {
+	r.req.GetBody = open
+	r.req.ContentLength = size
+}
+
+// SetContentLength isn't supported, net/http refuses to send a Content-Length which doesn't match the body. Config
+// validation prevents it being used with this client
+func (r *Req) SetContentLength(length string) {}
+
+// SetHeaderOrder isn't supported, net/http always writes headers sorted by name. Config validation prevents it
+// being used with this client
+func (r *Req) SetHeaderOrder(order []string) {}
+
 func (r *Req) Size() int64 {
 	var size = r.req.ContentLength
 	for key, header := range r.req.Header {
@@ -71,8 +220,88 @@ func (r *Req) Size() int64 {
 }
 
 func (c *Client) Do(req http_clients.Request, resp http_clients.Response) error {
-	resptemp, err := c.client.Do(req.(*Req).req)
+	r := req.(*Req).req
+	r.Close = req.(*Req).close
+	if r.GetBody != nil {
+		// request is reused so the body needs to be set again each time as it's consumed on send
+		body, err := r.GetBody()
+		if err != nil {
+			return err
+		}
+		r.Body = body
+	}
+
+	if c.maxConnDuration > 0 {
+		// like fasthttp, once the connection is too old the request asks for it to be closed so the next one is
+		// sent on a new connection
+		dialed := c.dialed.Load()
+		r.Close = r.Close || (dialed != 0 && time.Since(time.Unix(0, dialed)) > c.maxConnDuration)
+	}
+
+	var ctx context.Context
+	if c.timeoutJitter != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), c.timeoutJitter.Next())
+		resp.(*Resp).cancel = cancel
+	}
+	if c.trace != nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx = httptrace.WithClientTrace(ctx, c.trace)
+		c.firstByte.Store(0)
+		c.times.reset()
+	}
+	if c.wrote != nil {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx = httptrace.WithClientTrace(ctx, c.wrote)
+		c.partial.Store(nil)
+	}
+	if ctx != nil {
+		r = r.WithContext(ctx)
+	}
+
+	var start int64
+	if c.phases {
+		start = time.Now().UnixNano()
+	}
+	resptemp, err := c.client.Do(r)
+	if err != nil {
+		// the write has finished by the time Do fails, so partial is set if it was cut off part way
+		if partial := c.partial.Load(); partial != nil {
+			err = partial
+		}
+	}
 	resp.(*Resp).resp = resptemp
+	resp.(*Resp).body = nil
+	resp.(*Resp).bodyErr = nil
+	resp.(*Resp).read = false
+	resp.(*Resp).drain = c.discardBody
+	resp.(*Resp).firstByte = 0
+	if c.ttfb && err == nil {
+		if c.trace != nil {
+			resp.(*Resp).firstByte = c.firstByte.Load()
+		} else {
+			// the response headers being received is the closest there is
+			resp.(*Resp).firstByte = time.Now().UnixNano()
+		}
+	}
+	resp.(*Resp).phases = http_clients.Phases{}
+	if c.phases && err == nil {
+		resp.(*Resp).phases = http_clients.Phases{
+			Start:        start,
+			DNSStart:     c.times.dnsStart.Load(),
+			DNSDone:      c.times.dnsDone.Load(),
+			ConnectStart: c.times.connectStart.Load(),
+			ConnectDone:  c.times.connectDone.Load(),
+			TLSStart:     c.times.tlsStart.Load(),
+			TLSDone:      c.times.tlsDone.Load(),
+			GotConn:      c.times.gotConn.Load(),
+			FirstByte:    c.firstByte.Load(),
+		}
+	}
 	return err
 }
 
@@ -87,6 +316,17 @@ func (c *Client) NewResponse() http_clients.Response {
 }
 
 func (c *Client) NewReq(method, url string) (http_clients.Request, error) {
+	if c.rawPath {
+		// only the host is parsed, the opaque path is written as the request target without being re-encoded
+		base, path := http_clients.SplitRawURI(url)
+		req, err := http.NewRequest(method, base, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.URL.Opaque = path
+		return &Req{req: req}, nil
+	}
+
 	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, err
@@ -110,14 +350,92 @@ func GetNetHTTPClient(config *http_clients.Config) (http_clients.GoPayLoaderClie
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
-	return &Client{client: &http.Client{
-		Transport: &http.Transport{
-			TLSClientConfig: tlsConfig,
-			MaxConnsPerHost: 1,
-			MaxIdleConns:    1,
+	http_clients.ConfigureTLS(tlsConfig, config)
+
+	transport := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		MaxConnsPerHost: 1,
+		MaxIdleConns:    1,
+		IdleConnTimeout: config.MaxIdleConnDuration,
+		// 0 is the default of 10MB
+		MaxResponseHeaderBytes: int64(config.MaxHeaderSize),
+	}
+	if config.CustomDial() {
+		transport.DialContext = http_clients.NewDialer(config).DialContext
+	}
+
+	client := &Client{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   config.ReadTimeout + config.WriteTimeout,
+		},
+		rawPath:         config.RawPath,
+		discardBody:     config.DiscardBody,
+		maxConnDuration: config.MaxConnDuration,
+		timeoutJitter:   http_clients.NewTimeoutJitter(config, config.ReadTimeout+config.WriteTimeout),
+	}
+	if client.timeoutJitter != nil {
+		// each request has its own jittered timeout
+		client.client.Timeout = 0
+	}
+	if config.TTFB || config.Phases {
+		client.ttfb = config.TTFB
+		client.trace = &httptrace.ClientTrace{
+			GotFirstResponseByte: func() {
+				client.firstByte.Store(time.Now().UnixNano())
+			},
+		}
+	}
+	if config.Phases {
+		client.phases = true
+		t := &client.times
+		client.trace.DNSStart = func(httptrace.DNSStartInfo) { stamp(&t.dnsStart) }
+		client.trace.DNSDone = func(httptrace.DNSDoneInfo) { stamp(&t.dnsDone) }
+		client.trace.ConnectStart = func(string, string) { stamp(&t.connectStart) }
+		client.trace.ConnectDone = func(string, string, error) { t.connectDone.Store(time.Now().UnixNano()) }
+		client.trace.TLSHandshakeStart = func() { stamp(&t.tlsStart) }
+		client.trace.TLSHandshakeDone = func(tls.ConnectionState, error) { stamp(&t.tlsDone) }
+		client.trace.GotConn = func(httptrace.GotConnInfo) { stamp(&t.gotConn) }
+	}
+
+	if config.MaxConnDuration > 0 {
+		dial := transport.DialContext
+		if dial == nil {
+			// same as http.DefaultTransport
+			dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+		}
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			conn, err := dial(ctx, network, addr)
+			if err == nil {
+				client.dialed.Store(time.Now().UnixNano())
+			}
+			return conn, err
+		}
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		// same as the transport's own
+		dial = (&net.Dialer{}).DialContext
+	}
+	if config.TCPStats != nil {
+		// given the socket before anything wraps it
+		dial = config.TCPStats.DialContext(dial)
+	}
+	transport.DialContext = http_clients.PartialWriteDialContext(dial)
+	client.wrote = &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			var partial *http_clients.PartialWriteError
+			if errors.As(info.Err, &partial) {
+				client.partial.Store(partial)
+			}
 		},
-		Timeout: config.ReadTimeout + config.WriteTimeout,
-	}}, nil
+	}
+	if config.ConnTracker != nil {
+		config.ConnTracker.SetLimits(config.MaxConnDuration, config.MaxIdleConnDuration)
+		transport.DialContext = config.ConnTracker.DialContext(transport.DialContext)
+	}
+
+	return client, nil
 }
 
 func GetNetHTTP3Client(config *http_clients.Config) (http_clients.GoPayLoaderClient, error) {
@@ -133,16 +451,36 @@ func GetNetHTTP3Client(config *http_clients.Config) (http_clients.GoPayLoaderCli
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
+	http_clients.ConfigureTLS(tlsConfig, config)
+
 	// todo timeout configs
 
 	roundTripper := &http3.RoundTripper{
-		TLSClientConfig: tlsConfig,
-		EnableDatagrams: true,
+		TLSClientConfig:        tlsConfig,
+		EnableDatagrams:        true,
+		MaxResponseHeaderBytes: int64(config.MaxHeaderSize),
+	}
+	if config.MaxIdleConnDuration > 0 {
+		roundTripper.QuicConfig = &quic.Config{MaxIdleTimeout: config.MaxIdleConnDuration}
+	}
+	if config.CustomDial() {
+		dialer := http_clients.NewDialer(config)
+		roundTripper.Dial = func(ctx context.Context, addr string, tlsCfg *tls.Config, cfg *quic.Config) (quic.EarlyConnection, error) {
+			addrs, err := dialer.Resolve(ctx, addr)
+			if err != nil {
+				return nil, err
+			}
+			// SNI is already set from the hostname so dialing the resolved address doesn't affect verification
+			return quic.DialAddrEarly(ctx, addrs[0], tlsCfg, cfg)
+		}
 	}
 
 	return &Client{
 		client: &http.Client{
 			Transport: roundTripper,
 		},
+		rawPath:     config.RawPath,
+		discardBody: config.DiscardBody,
+		ttfb:        config.TTFB,
 	}, nil
 }
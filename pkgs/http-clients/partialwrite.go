@@ -0,0 +1,112 @@
+package http_clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// PartialWriteError is a request which failed after some but not all of its bytes were sent. What the target received
+// is cut off part way through a request so the connection is closed rather than reused, anything sent on it after
+// would be read as the rest of that request
+type PartialWriteError struct {
+	// Written is how many bytes of the request were sent, over however many writes it took
+	Written int
+	Err     error
+}
+
+// Error includes the underlying error. It isn't unwrapped as fasthttp keeps a connection to read the response after a
+// reset, which would report the failed read instead
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("partially written request, %d bytes sent; %v", e.Written, e.Err)
+}
+
+// IsPartialWriteError reports whether the request failed after being partly written, see PartialWriteError
+func IsPartialWriteError(err error) bool {
+	var partial *PartialWriteError
+	return errors.As(err, &partial)
+}
+
+// partialWriteConn closes the connection on a partial write, reads and writes after return the same error so the
+// client discards it. Clients write a request over several writes, so written counts the bytes of the request being
+// sent until its response starts to be read
+type partialWriteConn struct {
+	net.Conn
+	mu      sync.Mutex
+	written int
+	err     *PartialWriteError
+}
+
+func (c *partialWriteConn) Write(b []byte) (int, error) {
+	if err := c.broken(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Write(b)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.written += n
+	if n == len(b) || c.written == 0 {
+		// nothing of the request was sent so it's left for the client to retry
+		return n, err
+	}
+	if err == nil {
+		err = io.ErrShortWrite
+	}
+	c.err = &PartialWriteError{Written: c.written, Err: err}
+	c.Conn.Close()
+	return n, c.err
+}
+
+func (c *partialWriteConn) Read(b []byte) (int, error) {
+	if err := c.broken(); err != nil {
+		return 0, err
+	}
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		// the response has started so the next write is of the next request
+		c.mu.Lock()
+		c.written = 0
+		c.mu.Unlock()
+	}
+	if err != nil {
+		// a read blocked while the write failed sees the connection closed under it
+		if partial := c.broken(); partial != nil {
+			return n, partial
+		}
+	}
+	return n, err
+}
+
+func (c *partialWriteConn) broken() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		return nil
+	}
+	return c.err
+}
+
+// PartialWriteDial wraps the connections dialed by dial to detect partial writes, see PartialWriteError
+func PartialWriteDial(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		return &partialWriteConn{Conn: conn}, nil
+	}
+}
+
+// PartialWriteDialContext is PartialWriteDial for net/http's dial func
+func PartialWriteDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &partialWriteConn{Conn: conn}, nil
+	}
+}
@@ -0,0 +1,123 @@
+package http_clients
+
+import (
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+)
+
+// shortConn writes limit bytes in all, failing with err when it cuts a write short. Each read returns response
+type shortConn struct {
+	net.Conn
+	limit    int
+	err      error
+	response string
+	closed   bool
+}
+
+func (c *shortConn) Write(b []byte) (int, error) {
+	if len(b) > c.limit {
+		n := c.limit
+		c.limit = 0
+		return n, c.err
+	}
+	c.limit -= len(b)
+	return len(b), nil
+}
+
+func (c *shortConn) Read(b []byte) (int, error) {
+	if c.response == "" {
+		return 0, io.EOF
+	}
+	return copy(b, c.response), nil
+}
+
+func (c *shortConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestPartialWriteDial(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		err   error
+		// wantErr is the error the write fails with, nil when it's left as it is
+		wantErr error
+	}{
+		{name: "reset part way through", limit: 4, err: syscall.ECONNRESET, wantErr: syscall.ECONNRESET},
+		{name: "short write without an error", limit: 4, wantErr: io.ErrShortWrite},
+		{name: "nothing written", limit: 0, err: syscall.ECONNRESET},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			short := &shortConn{limit: tt.limit, err: tt.err}
+			conn, err := PartialWriteDial(func(addr string) (net.Conn, error) { return short, nil })("localhost:80")
+			if err != nil {
+				t.Fatal(err)
+			}
+			n, err := conn.Write([]byte("world!"))
+			if n != tt.limit {
+				t.Errorf("wanted %d bytes written got %d", tt.limit, n)
+			}
+			if tt.wantErr == nil {
+				if IsPartialWriteError(err) || !errors.Is(err, tt.err) || short.closed {
+					t.Errorf("wanted %v left as it is and the connection open got %v", tt.err, err)
+				}
+				return
+			}
+			var partial *PartialWriteError
+			if !errors.As(err, &partial) || partial.Written != tt.limit || partial.Err != tt.wantErr {
+				t.Fatalf("wanted a partial write of %d bytes with %v got %v", tt.limit, tt.wantErr, err)
+			}
+			if !short.closed {
+				t.Error("wanted the connection closed after a partial write")
+			}
+			// the connection is broken for whatever the client does with it next
+			if _, err := conn.Write([]byte("again")); err != partial {
+				t.Errorf("wanted writes after to fail with the partial write got %v", err)
+			}
+			if _, err := conn.Read(make([]byte, 1)); err != partial {
+				t.Errorf("wanted reads after to fail with the partial write got %v", err)
+			}
+		})
+	}
+}
+
+func TestPartialWriteDial_AcrossWrites(t *testing.T) {
+	short := &shortConn{limit: 10, err: syscall.ECONNRESET}
+	conn, err := PartialWriteDial(func(addr string) (net.Conn, error) { return short, nil })("localhost:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the headers went out whole, nothing of the body did
+	if _, err := conn.Write([]byte("headers!!!")); err != nil {
+		t.Fatal(err)
+	}
+	n, err := conn.Write([]byte("body"))
+	var partial *PartialWriteError
+	if n != 0 || !errors.As(err, &partial) || partial.Written != 10 || partial.Err != syscall.ECONNRESET {
+		t.Fatalf("wanted a partial write of the request's 10 bytes got %d; %v", n, err)
+	}
+	if !short.closed {
+		t.Error("wanted the connection closed after a partial write")
+	}
+
+	// once the response is read the next request starts from nothing
+	short = &shortConn{limit: 10, err: syscall.ECONNRESET, response: "HTTP/1.1 200 OK"}
+	conn, err = PartialWriteDial(func(addr string) (net.Conn, error) { return short, nil })("localhost:80")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("request!!!")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Read(make([]byte, 32)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("next")); IsPartialWriteError(err) || err != syscall.ECONNRESET || short.closed {
+		t.Errorf("wanted the reset of the next request left as it is got %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package http_clients
+
+import (
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const queryFuzzRandChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// QueryFuzz is a parsed --query-fuzz spec like page=randInt(1,100)&sort=list(asc,desc)&v=2, values are either
+// literal or generated for every request by randInt(min,max) which excludes max like {{.RandInt}} in body
+// templates, randString(n) or list(a,b,...). It's parsed once and shared between workers which each have their own
+// random source
+type QueryFuzz struct {
+	params []queryFuzzParam
+}
+
+type queryFuzzParam struct {
+	key string
+	// literal is the value if it isn't generated, it's used as written so should already be escaped
+	literal string
+	gen     func(r *rand.Rand) string
+}
+
+func ParseQueryFuzz(spec string) (*QueryFuzz, error) {
+	q := &QueryFuzz{}
+	for _, pair := range strings.Split(spec, "&") {
+		key, value, _ := strings.Cut(pair, "=")
+		if key == "" {
+			return nil, fmt.Errorf("query fuzz parameter %q has no name", pair)
+		}
+		param := queryFuzzParam{key: url.QueryEscape(key), literal: value}
+
+		open := strings.IndexByte(value, '(')
+		if open > 0 && strings.HasSuffix(value, ")") {
+			gen, err := parseQueryFuzzGen(value[:open], strings.Split(value[open+1:len(value)-1], ","))
+			if err != nil {
+				return nil, fmt.Errorf("query fuzz parameter %s; %v", key, err)
+			}
+			param.gen = gen
+		}
+		q.params = append(q.params, param)
+	}
+	return q, nil
+}
+
+func parseQueryFuzzGen(name string, args []string) (func(r *rand.Rand) string, error) {
+	switch name {
+	case "randInt":
+		if len(args) != 2 {
+			return nil, fmt.Errorf("randInt needs a min and max i.e. randInt(1,100) got %d args", len(args))
+		}
+		min, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil {
+			return nil, fmt.Errorf("randInt min %q isn't an integer", args[0])
+		}
+		max, err := strconv.Atoi(strings.TrimSpace(args[1]))
+		if err != nil {
+			return nil, fmt.Errorf("randInt max %q isn't an integer", args[1])
+		}
+		if max <= min {
+			return nil, fmt.Errorf("randInt max %d must be more than min %d", max, min)
+		}
+		return func(r *rand.Rand) string {
+			return strconv.Itoa(min + r.Intn(max-min))
+		}, nil
+	case "randString":
+		if len(args) != 1 {
+			return nil, fmt.Errorf("randString needs a length i.e. randString(8) got %d args", len(args))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(args[0]))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("randString length %q must be a positive integer", args[0])
+		}
+		return func(r *rand.Rand) string {
+			b := make([]byte, n)
+			for i := range b {
+				b[i] = queryFuzzRandChars[r.Intn(len(queryFuzzRandChars))]
+			}
+			return string(b)
+		}, nil
+	case "list":
+		values := make([]string, len(args))
+		for i, arg := range args {
+			values[i] = url.QueryEscape(arg)
+		}
+		return func(r *rand.Rand) string {
+			return values[r.Intn(len(values))]
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown generator %s, needs to be randInt, randString or list", name)
+}
+
+// Append appends a freshly generated query string to dst
+func (q *QueryFuzz) Append(dst []byte, r *rand.Rand) []byte {
+	for i, p := range q.params {
+		if i > 0 {
+			dst = append(dst, '&')
+		}
+		dst = append(dst, p.key...)
+		dst = append(dst, '=')
+		if p.gen != nil {
+			dst = append(dst, p.gen(r)...)
+		} else {
+			dst = append(dst, p.literal...)
+		}
+	}
+	return dst
+}
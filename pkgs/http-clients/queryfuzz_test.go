@@ -0,0 +1,56 @@
+package http_clients
+
+import (
+	"math/rand"
+	"net/url"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestParseQueryFuzz(t *testing.T) {
+	for _, spec := range []string{"page=rand(1,2)", "page=randInt(5,1)", "page=randInt(a,2)", "page=randInt(1)", "id=randString(0)", "=1"} {
+		if _, err := ParseQueryFuzz(spec); err == nil {
+			t.Errorf("wanted error parsing %s", spec)
+		}
+	}
+}
+
+func TestQueryFuzz_Append(t *testing.T) {
+	q, err := ParseQueryFuzz("page=randInt(1,100)&sort=list(asc,desc)&id=randString(8)&fixed=a%20b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idChars := regexp.MustCompile(`^[a-zA-Z0-9]{8}$`)
+	r := rand.New(rand.NewSource(1))
+	distinct := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		query := string(q.Append(nil, r))
+		distinct[query] = true
+		vals, err := url.ParseQuery(query)
+		if err != nil {
+			t.Fatalf("invalid query %s; %v", query, err)
+		}
+		if page, err := strconv.Atoi(vals.Get("page")); err != nil || page < 1 || page >= 100 {
+			t.Errorf("wanted page in [1, 100) got %s", query)
+		}
+		if sort := vals.Get("sort"); sort != "asc" && sort != "desc" {
+			t.Errorf("wanted sort asc or desc got %s", query)
+		}
+		if !idChars.MatchString(vals.Get("id")) {
+			t.Errorf("wanted 8 random alphanumeric id got %s", query)
+		}
+		// literal values are used as written
+		if vals.Get("fixed") != "a b" {
+			t.Errorf("wanted literal parameter kept got %s", query)
+		}
+	}
+	if len(distinct) < 45 {
+		t.Errorf("wanted distinct query strings got %d of 50", len(distinct))
+	}
+
+	if got := string(q.Append([]byte("v=1&"), r)); got[:4] != "v=1&" {
+		t.Errorf("wanted the query appended to dst got %s", got)
+	}
+}
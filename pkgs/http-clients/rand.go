@@ -0,0 +1,26 @@
+package http_clients
+
+import "math/rand"
+
+// RandStream is one of the random choices a worker makes, each gets its own source from --seed so e.g. the requests
+// logged by --log-sample-rate aren't the same ones --close-rate closes the connection of
+type RandStream uint64
+
+const (
+	RandLogSample RandStream = iota + 1
+	RandCloseRate
+	RandTimeoutJitter
+	RandTemplate
+	RandQueryFuzz
+	RandLatencySample
+	RandScenario
+)
+
+// NewRand returns the worker's source for stream, the same on every run with the same --seed. It isn't safe for
+// concurrent use
+func (c *Config) NewRand(stream RandStream) *rand.Rand {
+	// multiplying by the 64 bit golden ratio spreads the streams far apart, so adding the worker ID never lands one
+	// stream on another worker's seed for a different stream
+	seed := uint64(c.Seed) + uint64(stream)*0x9e3779b97f4a7c15
+	return rand.New(rand.NewSource(int64(seed) + int64(c.WorkerID)))
+}
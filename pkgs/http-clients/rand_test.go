@@ -0,0 +1,28 @@
+package http_clients
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestConfig_NewRand(t *testing.T) {
+	streams := []RandStream{RandLogSample, RandCloseRate, RandTimeoutJitter, RandTemplate, RandQueryFuzz, RandLatencySample, RandScenario}
+
+	// the first values of every stream of every worker should differ, so no two make the same choices
+	seen := make(map[int64]string)
+	for worker := 0; worker < 100; worker++ {
+		c := &Config{Seed: 42, WorkerID: worker}
+		for _, s := range streams {
+			v := c.NewRand(s).Int63()
+			if prev, ok := seen[v]; ok {
+				t.Fatalf("wanted worker %d stream %d to differ from %s", worker, s, prev)
+			}
+			seen[v] = fmt.Sprintf("worker %d stream %d", worker, s)
+		}
+	}
+
+	c := &Config{Seed: 42, WorkerID: 3}
+	if c.NewRand(RandCloseRate).Int63() != c.NewRand(RandCloseRate).Int63() {
+		t.Error("wanted the same stream from the same seed on every run")
+	}
+}
@@ -0,0 +1,25 @@
+package http_clients
+
+import "strings"
+
+// SplitRawURI splits uri into scheme://host and the rest, which is sent as the request target verbatim when
+// RawPath is set. The rest is "/" if uri has no path
+func SplitRawURI(uri string) (string, string) {
+	start := strings.Index(uri, "://")
+	if start == -1 {
+		return uri, "/"
+	}
+	start += len("://")
+
+	end := strings.IndexAny(uri[start:], "/?")
+	if end == -1 {
+		return uri, "/"
+	}
+	end += start
+
+	path := uri[end:]
+	if path[0] == '?' {
+		path = "/" + path
+	}
+	return uri[:end], path
+}
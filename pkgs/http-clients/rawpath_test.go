@@ -0,0 +1,19 @@
+package http_clients
+
+import "testing"
+
+func TestSplitRawURI(t *testing.T) {
+	for _, tt := range []struct {
+		uri, wantBase, wantPath string
+	}{
+		{uri: "http://localhost:8080/files/a%2Fb/x%zz?q=%20", wantBase: "http://localhost:8080", wantPath: "/files/a%2Fb/x%zz?q=%20"},
+		{uri: "https://example.com?q=1", wantBase: "https://example.com", wantPath: "/?q=1"},
+		{uri: "http://localhost:8080", wantBase: "http://localhost:8080", wantPath: "/"},
+		{uri: "localhost:8080/path", wantBase: "localhost:8080/path", wantPath: "/"},
+	} {
+		base, path := SplitRawURI(tt.uri)
+		if base != tt.wantBase || path != tt.wantPath {
+			t.Errorf("%s: wanted %s and %s got %s and %s", tt.uri, tt.wantBase, tt.wantPath, base, path)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package http_clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// ScenarioStep is a request of a --scenario, steps are sent in order on each connection and start again from the
+// first once the last is sent. The path, headers and body are templates with the same data as --body-template-file
+// plus the connection's session variables extracted from earlier steps' responses i.e. {{.Vars.token}}
+type ScenarioStep struct {
+	Name    string
+	Method  string
+	Path    *template.Template
+	Headers []HeaderTemplate
+	// Body is nil for a step without a body
+	Body    *template.Template
+	Extract []ScenarioExtract
+}
+
+// ScenarioExtract sets the session variable Var from a step's response, either from the JSON body at Path or from
+// the Header
+type ScenarioExtract struct {
+	Var    string
+	Path   *jsonpath.Path
+	Header string
+}
+
+// scenarioFile is the --scenario JSON format
+type scenarioFile struct {
+	Steps []struct {
+		Name    string   `json:"name"`
+		Method  string   `json:"method"`
+		Path    string   `json:"path"`
+		Headers []string `json:"headers"`
+		Body    string   `json:"body"`
+		Extract []struct {
+			Var      string `json:"var"`
+			JSONPath string `json:"jsonpath"`
+			Header   string `json:"header"`
+		} `json:"extract"`
+	} `json:"steps"`
+}
+
+// LoadScenario reads and parses a --scenario file. A step can only use the variables extracted by the steps before it,
+// so every one is set before it's first sent
+func LoadScenario(path string) ([]ScenarioStep, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenario file %v", err)
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	// a misspelt field would otherwise leave out a header or extraction without saying
+	d.DisallowUnknownFields()
+	var f scenarioFile
+	if err := d.Decode(&f); err != nil {
+		return nil, fmt.Errorf("failed to parse scenario file %s; %v", path, err)
+	}
+	if len(f.Steps) == 0 {
+		return nil, fmt.Errorf("scenario file %s has no steps", path)
+	}
+
+	steps := make([]ScenarioStep, 0, len(f.Steps))
+	extracted := make(map[string]bool)
+	for i, s := range f.Steps {
+		step := ScenarioStep{Name: s.Name, Method: s.Method}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("%d", i+1)
+		}
+		if step.Method == "" {
+			step.Method = "GET"
+		}
+		if !strings.HasPrefix(s.Path, "/") {
+			return nil, fmt.Errorf("scenario step %s path %q must start with /", step.Name, s.Path)
+		}
+		templates := make([]*template.Template, 0, len(s.Headers)+2)
+		if step.Path, err = template.New("path").Option("missingkey=error").Parse(s.Path); err != nil {
+			return nil, fmt.Errorf("scenario step %s path template; %v", step.Name, err)
+		}
+		templates = append(templates, step.Path)
+		for _, h := range s.Headers {
+			name, value, ok := strings.Cut(h, ":")
+			if !ok {
+				return nil, fmt.Errorf("scenario step %s header %s does not contain :", step.Name, h)
+			}
+			t, err := template.New(name).Option("missingkey=error").Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("scenario step %s header %s template; %v", step.Name, name, err)
+			}
+			step.Headers = append(step.Headers, HeaderTemplate{Name: name, Value: t})
+			templates = append(templates, t)
+		}
+		if s.Body != "" {
+			if step.Body, err = template.New("body").Option("missingkey=error").Parse(s.Body); err != nil {
+				return nil, fmt.Errorf("scenario step %s body template; %v", step.Name, err)
+			}
+			templates = append(templates, step.Body)
+		}
+
+		var missing []string
+		for name := range templateFields("Vars", templates...) {
+			if !extracted[name] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("scenario step %s uses %s before a step extracts it", step.Name, strings.Join(missing, ", "))
+		}
+
+		for _, e := range s.Extract {
+			if e.Var == "" {
+				return nil, fmt.Errorf("scenario step %s has an extract without a var", step.Name)
+			}
+			if (e.JSONPath == "") == (e.Header == "") {
+				return nil, fmt.Errorf("scenario step %s extract %s needs either a jsonpath or a header", step.Name, e.Var)
+			}
+			extract := ScenarioExtract{Var: e.Var, Header: e.Header}
+			if e.JSONPath != "" {
+				if extract.Path, err = jsonpath.Parse(e.JSONPath); err != nil {
+					return nil, fmt.Errorf("scenario step %s extract %s; %v", step.Name, e.Var, err)
+				}
+			}
+			step.Extract = append(step.Extract, extract)
+			extracted[e.Var] = true
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// ScenarioTemplates returns all the templates of the steps i.e. to check the {{.Data.<column>}} they use
+func ScenarioTemplates(steps []ScenarioStep) []*template.Template {
+	var templates []*template.Template
+	for _, s := range steps {
+		templates = append(templates, s.Path, s.Body)
+		for _, h := range s.Headers {
+			templates = append(templates, h.Value)
+		}
+	}
+	return templates
+}
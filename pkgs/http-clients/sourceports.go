@@ -0,0 +1,70 @@
+package http_clients
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+)
+
+// SourcePorts hands out the local ports connections are opened from with --source-port-range. It's shared by all
+// connections, ports are taken in turn so reconnects move on to ports which have had longest to be freed
+type SourcePorts struct {
+	first int
+	last  int
+	next  atomic.Uint64
+}
+
+// ParsePortRange parses a range of ports i.e. 20000-30000, a single port is a range of one
+func ParsePortRange(s string) (first, last int, err error) {
+	lo, hi, found := strings.Cut(s, "-")
+	if !found {
+		hi = lo
+	}
+	if first, err = strconv.Atoi(strings.TrimSpace(lo)); err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %s; %v", s, err)
+	}
+	if last, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+		return 0, 0, fmt.Errorf("invalid port range %s; %v", s, err)
+	}
+	if first < 1 || last > 65535 || first > last {
+		return 0, 0, fmt.Errorf("invalid port range %s; ports must be from 1 to 65535 with the first no more than the last", s)
+	}
+	return first, last, nil
+}
+
+func NewSourcePorts(portRange string) (*SourcePorts, error) {
+	first, last, err := ParsePortRange(portRange)
+	if err != nil {
+		return nil, err
+	}
+	return &SourcePorts{first: first, last: last}, nil
+}
+
+// Size is how many ports are in the range
+func (p *SourcePorts) Size() int {
+	return p.last - p.first + 1
+}
+
+// DialContext dials addr from the next port in the range. Ports still in use, by another connection or waiting to be
+// released by the OS, are skipped, once every port has been tried the dial fails
+func (p *SourcePorts) DialContext(ctx context.Context, dialer net.Dialer, network, addr string) (net.Conn, error) {
+	size := uint64(p.Size())
+	for i := uint64(0); i < size; i++ {
+		port := p.first + int((p.next.Add(1)-1)%size)
+		dialer.LocalAddr = &net.TCPAddr{Port: port}
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if ctx.Err() != nil || !(errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)) {
+			return nil, err
+		}
+	}
+	// a dial error so it's retried with --connect-retries, ports may have been freed by then
+	return nil, &net.OpError{Op: "dial", Net: network, Err: fmt.Errorf("no free source port in range %d-%d", p.first, p.last)}
+}
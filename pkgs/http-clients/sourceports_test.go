@@ -0,0 +1,85 @@
+package http_clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		portRange string
+		first     int
+		last      int
+		wantErr   bool
+	}{
+		{portRange: "20000-30000", first: 20000, last: 30000},
+		{portRange: "20000", first: 20000, last: 20000},
+		{portRange: " 20000 - 20001 ", first: 20000, last: 20001},
+		{portRange: "30000-20000", wantErr: true},
+		{portRange: "0-10", wantErr: true},
+		{portRange: "20000-70000", wantErr: true},
+		{portRange: "a-b", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.portRange, func(t *testing.T) {
+			first, last, err := ParsePortRange(tt.portRange)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("wanted error %v got %v", tt.wantErr, err)
+			}
+			if first != tt.first || last != tt.last {
+				t.Errorf("wanted %d-%d got %d-%d", tt.first, tt.last, first, last)
+			}
+		})
+	}
+}
+
+func TestSourcePorts_DialContext(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ports, err := NewSourcePorts("45000-45049")
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[int]bool{}
+	for i := 0; i < 4; i++ {
+		conn, err := ports.DialContext(context.Background(), net.Dialer{}, "tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		port := conn.LocalAddr().(*net.TCPAddr).Port
+		if port < 45000 || port > 45049 {
+			t.Errorf("connection from port %d outside of range", port)
+		}
+		seen[port] = true
+	}
+	if len(seen) != 4 {
+		t.Errorf("wanted each connection from its own port got %v", seen)
+	}
+
+	// the only port in the range is taken so there's nothing to connect from
+	taken := ln.Addr().(*net.TCPAddr).Port
+	ports, err = NewSourcePorts(fmt.Sprintf("%d", taken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ports.DialContext(context.Background(), net.Dialer{}, "tcp", ln.Addr().String()); err == nil || !strings.Contains(err.Error(), "no free source port") {
+		t.Errorf("wanted error for no free source port got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package http_clients
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SRVResolver looks up SRV records, *net.Resolver satisfies it
+type SRVResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// ResolveSRV looks up record i.e. _http._tcp.example.com and returns the targets with the highest priority, the rest
+// are only meant to be used when those are unreachable
+func ResolveSRV(ctx context.Context, resolver SRVResolver, record string) ([]*net.SRV, error) {
+	// with an empty service and proto the name is looked up as is
+	_, srvs, err := resolver.LookupSRV(ctx, "", "", record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lookup SRV record %s; %v", record, err)
+	}
+
+	var targets []*net.SRV
+	for _, srv := range srvs {
+		if srv.Target == "." {
+			// the service is decidedly not available at this domain
+			continue
+		}
+		if len(targets) > 0 && srv.Priority > targets[0].Priority {
+			continue
+		}
+		if len(targets) > 0 && srv.Priority < targets[0].Priority {
+			targets = targets[:0]
+		}
+		targets = append(targets, srv)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no targets found for SRV record %s", record)
+	}
+	return targets, nil
+}
+
+// AssignSRV returns the address each of conns connections should dial, spread across targets in proportion to their
+// weight. Smooth weighted round-robin interleaves the targets so any number of connections is spread evenly
+func AssignSRV(targets []*net.SRV, conns int) []string {
+	weights := make([]int, len(targets))
+	var total int
+	for i, t := range targets {
+		weights[i] = int(t.Weight)
+		total += weights[i]
+	}
+	if total == 0 {
+		// all 0 means no preference
+		for i := range weights {
+			weights[i] = 1
+		}
+		total = len(weights)
+	}
+
+	addrs := make([]string, conns)
+	current := make([]int, len(targets))
+	for c := range addrs {
+		best := 0
+		for i := range current {
+			current[i] += weights[i]
+			if current[i] > current[best] {
+				best = i
+			}
+		}
+		current[best] -= total
+		addrs[c] = net.JoinHostPort(strings.TrimSuffix(targets[best].Target, "."), strconv.Itoa(int(targets[best].Port)))
+	}
+	return addrs
+}
@@ -0,0 +1,70 @@
+package http_clients
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testSRVResolver []*net.SRV
+
+func (r testSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	return "", r, nil
+}
+
+func TestResolveSRV(t *testing.T) {
+	srvs := testSRVResolver{
+		{Target: "a.test.", Port: 80, Priority: 10, Weight: 3},
+		// lower priority fallback
+		{Target: "b.test.", Port: 80, Priority: 20, Weight: 100},
+		{Target: "c.test.", Port: 80, Priority: 10, Weight: 1},
+	}
+	targets, err := ResolveSRV(context.Background(), srvs, "_http._tcp.srv.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*net.SRV{srvs[0], srvs[2]}; !reflect.DeepEqual(targets, want) {
+		t.Errorf("wanted only the highest priority targets got %v", targets)
+	}
+
+	// a higher priority later on replaces the targets found so far
+	srvs = append(srvs, &net.SRV{Target: "d.test.", Port: 80, Priority: 5})
+	if targets, _ := ResolveSRV(context.Background(), srvs, "_http._tcp.srv.test"); len(targets) != 1 || targets[0] != srvs[3] {
+		t.Errorf("wanted the priority 5 target got %v", targets)
+	}
+
+	for _, srvs := range []testSRVResolver{{}, {{Target: "."}}} {
+		if _, err := ResolveSRV(context.Background(), srvs, "_http._tcp.srv.test"); err == nil || !strings.Contains(err.Error(), "no targets found") {
+			t.Errorf("wanted an error for an SRV record without targets got %v", err)
+		}
+	}
+}
+
+func TestAssignSRV(t *testing.T) {
+	count := func(addrs []string) map[string]int {
+		n := make(map[string]int)
+		for _, a := range addrs {
+			n[a]++
+		}
+		return n
+	}
+
+	// 3:1
+	targets := []*net.SRV{{Target: "a.test.", Port: 8080, Weight: 3}, {Target: "b.test.", Port: 9090, Weight: 1}}
+	addrs := AssignSRV(targets, 8)
+	if got := count(addrs); got["a.test:8080"] != 6 || got["b.test:9090"] != 2 {
+		t.Errorf("wanted 6 and 2 connections to each target got %v", addrs)
+	}
+	// interleaved rather than the first target's connections then the second's
+	if got := count(addrs[:4]); got["b.test:9090"] != 1 {
+		t.Errorf("wanted targets interleaved got %v", addrs)
+	}
+
+	// no preference
+	targets[0].Weight, targets[1].Weight = 0, 0
+	if got := count(AssignSRV(targets, 4)); got["a.test:8080"] != 2 || got["b.test:9090"] != 2 {
+		t.Errorf("wanted connections spread evenly without weights got %v", got)
+	}
+}
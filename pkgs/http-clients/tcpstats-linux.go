@@ -0,0 +1,40 @@
+//go:build linux
+
+package http_clients
+
+import (
+	"golang.org/x/sys/unix"
+	"net"
+	"syscall"
+	"time"
+)
+
+// TCPStatsSupported is whether TCP_INFO can be read for --tcp-stats
+const TCPStatsSupported = true
+
+// readTCPInfo reads the TCP_INFO of the socket, false if it isn't a TCP connection or it's already closed
+func readTCPInfo(conn net.Conn) (TCPInfo, bool) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return TCPInfo{}, false
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return TCPInfo{}, false
+	}
+	var info *unix.TCPInfo
+	var infoErr error
+	if err := raw.Control(func(fd uintptr) {
+		info, infoErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	}); err != nil || infoErr != nil {
+		return TCPInfo{}, false
+	}
+	return TCPInfo{
+		Retransmits: info.Total_retrans,
+		SegmentsOut: info.Segs_out,
+		// the kernel reports them in microseconds
+		RTT:    time.Duration(info.Rtt) * time.Microsecond,
+		RTTVar: time.Duration(info.Rttvar) * time.Microsecond,
+		Cwnd:   info.Snd_cwnd,
+	}, true
+}
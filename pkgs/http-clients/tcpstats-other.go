@@ -0,0 +1,12 @@
+//go:build !linux
+
+package http_clients
+
+import "net"
+
+// TCPStatsSupported is whether TCP_INFO can be read for --tcp-stats, only Linux has it
+const TCPStatsSupported = false
+
+func readTCPInfo(conn net.Conn) (TCPInfo, bool) {
+	return TCPInfo{}, false
+}
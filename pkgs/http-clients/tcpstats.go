@@ -0,0 +1,139 @@
+package http_clients
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// TCPInfo is the part of the kernel's TCP_INFO of a connection --tcp-stats reports
+type TCPInfo struct {
+	// Retransmits is the segments retransmitted over the connection's lifetime, SegmentsOut all segments sent
+	Retransmits uint32
+	SegmentsOut uint32
+	// RTT is the smoothed round trip time, RTTVar its variation
+	RTT    time.Duration
+	RTTVar time.Duration
+	// Cwnd is the congestion window in segments
+	Cwnd uint32
+}
+
+// TCPSummary is the TCP_INFO of every connection of a run summed up
+type TCPSummary struct {
+	Conns       int64
+	Retransmits int64
+	SegmentsOut int64
+	RTTAverage  time.Duration
+	RTTMax      time.Duration
+	CwndAverage float64
+}
+
+// TCPStats collects the TCP_INFO of every connection for --tcp-stats, it's shared by all workers. The clients wrap
+// their dial with it, a connection is sampled as it's closed and those still open when the summary is taken
+type TCPStats struct {
+	mu    sync.Mutex
+	open  map[*tcpStatsConn]struct{}
+	conns int64
+	total TCPSummary
+	rtt   time.Duration
+	cwnd  int64
+}
+
+func NewTCPStats() *TCPStats {
+	return &TCPStats{open: make(map[*tcpStatsConn]struct{})}
+}
+
+// Dial wraps the connections dialed by dial to sample them, it has to be given the TCP connection before anything
+// else wraps it i.e. TLS
+func (s *TCPStats) Dial(dial func(addr string) (net.Conn, error)) func(addr string) (net.Conn, error) {
+	return func(addr string) (net.Conn, error) {
+		conn, err := dial(addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.track(conn), nil
+	}
+}
+
+// DialContext is Dial for net/http's dial func
+func (s *TCPStats) DialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return s.track(conn), nil
+	}
+}
+
+func (s *TCPStats) track(conn net.Conn) net.Conn {
+	c := &tcpStatsConn{Conn: conn, stats: s}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.open[c] = struct{}{}
+	return c
+}
+
+// Summary sums up the connections sampled so far and those still open
+func (s *TCPStats) Summary() TCPSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.open {
+		s.sample(c)
+	}
+	summary := s.total
+	if summary.Conns > 0 {
+		summary.RTTAverage = s.rtt / time.Duration(summary.Conns)
+		summary.CwndAverage = float64(s.cwnd) / float64(summary.Conns)
+	}
+	return summary
+}
+
+// sample adds the connection's TCP_INFO, s.mu must be held. A connection is only counted once, with its latest
+// sample as the counts are of its whole lifetime
+func (s *TCPStats) sample(c *tcpStatsConn) {
+	info, ok := readTCPInfo(c.Conn)
+	if !ok {
+		return
+	}
+	if c.sampled != nil {
+		s.total.Conns--
+		s.total.Retransmits -= int64(c.sampled.Retransmits)
+		s.total.SegmentsOut -= int64(c.sampled.SegmentsOut)
+		s.rtt -= c.sampled.RTT
+		s.cwnd -= int64(c.sampled.Cwnd)
+	}
+	c.sampled = &info
+	s.total.Conns++
+	s.total.Retransmits += int64(info.Retransmits)
+	s.total.SegmentsOut += int64(info.SegmentsOut)
+	s.rtt += info.RTT
+	s.cwnd += int64(info.Cwnd)
+	if info.RTT > s.total.RTTMax {
+		s.total.RTTMax = info.RTT
+	}
+}
+
+func (s *TCPStats) close(c *tcpStatsConn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.open[c]; !ok {
+		return
+	}
+	// before the socket's closed and its TCP_INFO gone
+	s.sample(c)
+	delete(s.open, c)
+}
+
+type tcpStatsConn struct {
+	net.Conn
+	stats *TCPStats
+	// sampled is the last TCP_INFO read, guarded by stats.mu
+	sampled *TCPInfo
+}
+
+func (c *tcpStatsConn) Close() error {
+	c.stats.close(c)
+	return c.Conn.Close()
+}
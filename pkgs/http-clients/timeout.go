@@ -0,0 +1,32 @@
+package http_clients
+
+import (
+	"math/rand"
+	"time"
+)
+
+// TimeoutJitter adds a random offset to each request's timeout for --timeout-jitter, so requests sent together
+// against a struggling server don't all time out at the same moment. It belongs to a single worker so isn't safe
+// for concurrent use
+type TimeoutJitter struct {
+	base   time.Duration
+	jitter time.Duration
+	rand   *rand.Rand
+}
+
+// NewTimeoutJitter returns nil if there's no jitter, base is the timeout the client would otherwise use
+func NewTimeoutJitter(config *Config, base time.Duration) *TimeoutJitter {
+	if config.TimeoutJitter <= 0 {
+		return nil
+	}
+	return &TimeoutJitter{
+		base:   base,
+		jitter: config.TimeoutJitter,
+		rand:   config.NewRand(RandTimeoutJitter),
+	}
+}
+
+// Next returns the timeout for the next request, in [base, base + jitter)
+func (j *TimeoutJitter) Next() time.Duration {
+	return j.base + time.Duration(j.rand.Int63n(int64(j.jitter)))
+}
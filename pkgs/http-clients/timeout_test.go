@@ -0,0 +1,41 @@
+package http_clients
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeoutJitter_Next(t *testing.T) {
+	if NewTimeoutJitter(&Config{}, time.Second) != nil {
+		t.Error("wanted no jitter without --timeout-jitter")
+	}
+
+	config := &Config{TimeoutJitter: 300 * time.Millisecond, Seed: 1}
+	j := NewTimeoutJitter(config, 100*time.Millisecond)
+	min, max := time.Duration(1<<62), time.Duration(0)
+	var got []time.Duration
+	for i := 0; i < 100; i++ {
+		d := j.Next()
+		if d < 100*time.Millisecond || d >= 400*time.Millisecond {
+			t.Fatalf("wanted a timeout in [100ms, 400ms) got %s", d)
+		}
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+		got = append(got, d)
+	}
+	if max-min < 200*time.Millisecond {
+		t.Errorf("wanted timeouts spread over the jitter got %s to %s", min, max)
+	}
+
+	// the same timeouts with the same seed
+	j = NewTimeoutJitter(config, 100*time.Millisecond)
+	for i, want := range got {
+		if d := j.Next(); d != want {
+			t.Fatalf("wanted timeout %d to be %s with the same seed got %s", i, want, d)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package http_clients
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// certExts are the extensions of certs in an --mtls-cert-dir, matched to the .key file of the same name
+var certExts = []string{".crt", ".pem", ".cert"}
+
+// TLSStats counts TLS handshakes across all connections, a resumed handshake reused a session ticket from an earlier
+// connection so skipped the full key exchange and certificate verification
+type TLSStats struct {
+	Handshakes atomic.Int64
+	Resumed    atomic.Int64
+}
+
+// LoadCertPool reads a PEM bundle of CA certs for --ca-cert
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s doesn't contain any PEM certificates", file)
+	}
+	return pool, nil
+}
+
+// CertPair is the paths of a client cert and its private key
+type CertPair struct {
+	Cert string
+	Key  string
+}
+
+// LoadCertDir finds the client cert and key pairs of --mtls-cert-dir, sorted by name. Every <name>.key needs a
+// <name>.crt, <name>.pem or <name>.cert and every cert a key, each pair is loaded to check the key matches the cert
+func LoadCertDir(dir string) ([]CertPair, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	certs := make(map[string]string)
+	keys := make(map[string]string)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(e.Name())
+		name := strings.TrimSuffix(e.Name(), ext)
+		if ext == ".key" {
+			keys[name] = filepath.Join(dir, e.Name())
+			continue
+		}
+		for _, certExt := range certExts {
+			if ext != certExt {
+				continue
+			}
+			if other, ok := certs[name]; ok {
+				return nil, fmt.Errorf("%s has more than one cert for key %s.key; %s and %s", dir, name, filepath.Base(other), e.Name())
+			}
+			certs[name] = filepath.Join(dir, e.Name())
+		}
+	}
+
+	var pairs []CertPair
+	for name, key := range keys {
+		cert, ok := certs[name]
+		if !ok {
+			return nil, fmt.Errorf("%s has no cert for key %s.key", dir, name)
+		}
+		if _, err := tls.LoadX509KeyPair(cert, key); err != nil {
+			return nil, fmt.Errorf("%s and %s aren't a cert and key pair; %v", filepath.Base(cert), filepath.Base(key), err)
+		}
+		pairs = append(pairs, CertPair{Cert: cert, Key: key})
+	}
+	for name, cert := range certs {
+		if _, ok := keys[name]; !ok {
+			return nil, fmt.Errorf("%s has no key for cert %s, wanted %s.key", dir, filepath.Base(cert), name)
+		}
+	}
+	if len(pairs) == 0 {
+		return nil, fmt.Errorf("%s doesn't contain any cert and key pairs", dir)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Cert < pairs[j].Cert
+	})
+	return pairs, nil
+}
+
+// TLS fingerprints of --tls-fingerprint, the ClientHello offers what the browser does or crypto/tls's defaults with go
+const (
+	TLSFingerprintGo      = "go"
+	TLSFingerprintChrome  = "chrome"
+	TLSFingerprintFirefox = "firefox"
+)
+
+type tlsFingerprint struct {
+	cipherSuites []uint16
+	curves       []tls.CurveID
+}
+
+// tlsFingerprints are the TLS 1.2 cipher suites and key exchanges each browser offers which crypto/tls supports.
+// crypto/tls orders them itself and doesn't allow extensions, TLS 1.3 suites or GREASE values to be changed, so the
+// ClientHello isn't an exact copy of the browser's but its JA3 fingerprint is no longer Go's
+var tlsFingerprints = map[string]tlsFingerprint{
+	TLSFingerprintChrome: {
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		},
+		curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	},
+	TLSFingerprintFirefox: {
+		cipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+		},
+		curves: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521},
+	},
+}
+
+// IsTLSFingerprint is whether name is one of the --tls-fingerprint modes
+func IsTLSFingerprint(name string) bool {
+	_, ok := tlsFingerprints[name]
+	return ok || name == TLSFingerprintGo
+}
+
+// ConfigureTLS sets up the CA certs, session resumption and handshake stats on a client's tls config. Without a
+// session cache resumption is disabled so every new connection does a full handshake
+func ConfigureTLS(tlsConfig *tls.Config, config *Config) {
+	if fp, ok := tlsFingerprints[config.TLSFingerprint]; ok {
+		tlsConfig.CipherSuites = fp.cipherSuites
+		tlsConfig.CurvePreferences = fp.curves
+	}
+	if config.RootCAs != nil {
+		// server certs are verified against these instead of the system roots
+		tlsConfig.RootCAs = config.RootCAs
+	}
+
+	if config.TLSSessionCache != nil {
+		tlsConfig.ClientSessionCache = config.TLSSessionCache
+	} else {
+		tlsConfig.SessionTicketsDisabled = true
+	}
+
+	if stats := config.TLSStats; stats != nil {
+		// called for every handshake including resumed ones
+		tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			stats.Handshakes.Add(1)
+			if cs.DidResume {
+				stats.Resumed.Add(1)
+			}
+			return nil
+		}
+	}
+}
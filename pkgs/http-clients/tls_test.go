@@ -0,0 +1,314 @@
+package http_clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestCA returns a CA cert and key valid for an hour
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gopayloader test CA"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+// newTestCert returns a cert for localhost signed by ca
+func newTestCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// writeCert writes cert as PEM to name in dir and returns its path
+func writeCert(t *testing.T, dir, name string, cert *x509.Certificate) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigureTLS_SessionResumption(t *testing.T) {
+	var resumed atomic.Int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS.DidResume {
+			resumed.Add(1)
+		}
+	}))
+	defer server.Close()
+
+	for _, resumption := range []bool{false, true} {
+		t.Run(fmt.Sprintf("resumption %t", resumption), func(t *testing.T) {
+			resumed.Store(0)
+			config := &Config{TLSStats: &TLSStats{}}
+			if resumption {
+				config.TLSSessionCache = tls.NewLRUClientSessionCache(1)
+			}
+			tlsConfig := &tls.Config{InsecureSkipVerify: true}
+			ConfigureTLS(tlsConfig, config)
+			// every request is a new connection
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, DisableKeepAlives: true}}
+			for i := 0; i < 5; i++ {
+				resp, err := client.Get(server.URL)
+				if err != nil {
+					t.Fatal(err)
+				}
+				resp.Body.Close()
+			}
+
+			if got := config.TLSStats.Handshakes.Load(); got != 5 {
+				t.Errorf("wanted 5 handshakes got %d", got)
+			}
+			want := int64(0)
+			if resumption {
+				// only the first connection needs a full handshake
+				want = 4
+			}
+			if got := config.TLSStats.Resumed.Load(); got != want || resumed.Load() != want {
+				t.Errorf("wanted %d resumed handshakes got %d, server saw %d", want, got, resumed.Load())
+			}
+		})
+	}
+}
+
+func TestConfigureTLS_RootCAs(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{newTestCert(t, ca, caKey)}}
+	server.StartTLS()
+	defer server.Close()
+
+	dir := t.TempDir()
+	unrelated, _ := newTestCA(t)
+	for _, tt := range []struct {
+		caCert  string
+		trusted bool
+	}{
+		{caCert: writeCert(t, dir, "ca.pem", ca), trusted: true},
+		{caCert: writeCert(t, dir, "unrelated.pem", unrelated), trusted: false},
+	} {
+		pool, err := LoadCertPool(tt.caCert)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tlsConfig := &tls.Config{}
+		ConfigureTLS(tlsConfig, &Config{RootCAs: pool})
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if tt.trusted && err != nil {
+			t.Errorf("wanted the server trusted with the signing CA got %v", err)
+		}
+		if !tt.trusted && err == nil {
+			t.Error("wanted the server untrusted with an unrelated CA")
+		}
+	}
+}
+
+func TestConfigureTLS_Fingerprint(t *testing.T) {
+	type hello struct {
+		ciphers []uint16
+		curves  []tls.CurveID
+	}
+	hellos := make(chan hello, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			hellos <- hello{ciphers: info.CipherSuites, curves: info.SupportedCurves}
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	offered := make(map[string]string)
+	for _, fingerprint := range []string{TLSFingerprintGo, TLSFingerprintChrome, TLSFingerprintFirefox} {
+		if !IsTLSFingerprint(fingerprint) {
+			t.Errorf("wanted %s to be a tls fingerprint", fingerprint)
+		}
+		tlsConfig := &tls.Config{InsecureSkipVerify: true}
+		ConfigureTLS(tlsConfig, &Config{TLSFingerprint: fingerprint})
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		h := <-hellos
+		for _, c := range h.ciphers {
+			if fingerprint == TLSFingerprintChrome && c == tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA {
+				t.Error("wanted chrome to not offer ECDSA CBC ciphers")
+			}
+		}
+		if curves := h.curves; fingerprint == TLSFingerprintFirefox && (len(curves) == 0 || curves[len(curves)-1] != tls.CurveP521) {
+			t.Errorf("wanted firefox to offer P-521 got %v", curves)
+		}
+		offered[fingerprint] = fmt.Sprint(h)
+	}
+	if offered["go"] == offered["chrome"] || offered["go"] == offered["firefox"] || offered["chrome"] == offered["firefox"] {
+		t.Errorf("wanted a different ClientHello for every fingerprint got %v", offered)
+	}
+
+	if IsTLSFingerprint("safari") {
+		t.Error("wanted safari to not be a tls fingerprint")
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	dir := t.TempDir()
+	ca, _ := newTestCA(t)
+	if _, err := LoadCertPool(writeCert(t, dir, "ca.pem", ca)); err != nil {
+		t.Error(err)
+	}
+
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("not a cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCertPool(notPEM); err == nil {
+		t.Error("wanted error for a file without certs")
+	}
+	if _, err := LoadCertPool(filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("wanted error for a missing file")
+	}
+}
+
+// writeCertPair writes cert and its key as PEM to <name>.crt and <name>.key in dir
+func writeCertPair(t *testing.T, dir, name string, cert tls.Certificate) {
+	keyDER, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadCertDir(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	dir := t.TempDir()
+	for _, name := range []string{"client-b", "client-a"} {
+		writeCertPair(t, dir, name, newTestCert(t, ca, caKey))
+	}
+	if err := os.Mkdir(filepath.Join(dir, "nested"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	pairs, err := LoadCertDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sorted so connections get the same cert on every run
+	want := []CertPair{
+		{Cert: filepath.Join(dir, "client-a.crt"), Key: filepath.Join(dir, "client-a.key")},
+		{Cert: filepath.Join(dir, "client-b.crt"), Key: filepath.Join(dir, "client-b.key")},
+	}
+	if !reflect.DeepEqual(pairs, want) {
+		t.Errorf("wanted pairs %v got %v", want, pairs)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		change  func(dir string) error
+		wantErr string
+	}{
+		{
+			name:    "key without a cert",
+			change:  func(dir string) error { return os.Remove(filepath.Join(dir, "client-b.crt")) },
+			wantErr: "no cert for key client-b.key",
+		},
+		{
+			name:    "cert without a key",
+			change:  func(dir string) error { return os.Remove(filepath.Join(dir, "client-b.key")) },
+			wantErr: "no key for cert client-b.crt",
+		},
+		{
+			name: "two certs for a key",
+			change: func(dir string) error {
+				return os.Rename(filepath.Join(dir, "client-a.crt"), filepath.Join(dir, "client-b.pem"))
+			},
+			wantErr: "more than one cert for key client-b.key",
+		},
+		{
+			name: "mismatched pair",
+			change: func(dir string) error {
+				return os.Rename(filepath.Join(dir, "client-a.key"), filepath.Join(dir, "client-b.key"))
+			},
+			wantErr: "client-b.crt and client-b.key aren't a cert and key pair",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"client-a", "client-b"} {
+				writeCertPair(t, dir, name, newTestCert(t, ca, caKey))
+			}
+			if err := tt.change(dir); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadCertDir(dir); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("wanted error %q got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := LoadCertDir(t.TempDir()); err == nil || !strings.Contains(err.Error(), "doesn't contain any cert and key pairs") {
+		t.Errorf("wanted an error for an empty dir got %v", err)
+	}
+}
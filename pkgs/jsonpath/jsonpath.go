@@ -0,0 +1,105 @@
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a JSONPath expression selecting a single value, only child members and array indexes are supported i.e.
+// $.items[0].name or $['content-type']. Negative indexes count from the end of the array
+type Path struct {
+	expr  string
+	steps []step
+}
+
+type step struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func Parse(expr string) (*Path, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return nil, fmt.Errorf("jsonpath: %s must start with $", expr)
+	}
+
+	p := &Path{expr: expr}
+	for i := 1; i < len(expr); {
+		switch expr[i] {
+		case '.':
+			end := i + 1
+			for end < len(expr) && expr[end] != '.' && expr[end] != '[' {
+				end++
+			}
+			if end == i+1 {
+				return nil, fmt.Errorf("jsonpath: missing member name at position %d in %s", i, expr)
+			}
+			p.steps = append(p.steps, step{key: expr[i+1 : end]})
+			i = end
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end == -1 {
+				return nil, fmt.Errorf("jsonpath: unclosed [ at position %d in %s", i, expr)
+			}
+			s, err := parseBracket(expr[i+1 : i+end])
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath: %v at position %d in %s", err, i, expr)
+			}
+			p.steps = append(p.steps, s)
+			i += end + 1
+		default:
+			return nil, fmt.Errorf("jsonpath: unexpected %c at position %d in %s", expr[i], i, expr)
+		}
+	}
+	return p, nil
+}
+
+// parseBracket parses what's between [] which is either a quoted member name or an index
+func parseBracket(s string) (step, error) {
+	if len(s) >= 2 && (s[0] == '\'' || s[0] == '"') {
+		if s[len(s)-1] != s[0] {
+			return step{}, fmt.Errorf("unterminated member name %s", s)
+		}
+		return step{key: s[1 : len(s)-1]}, nil
+	}
+	index, err := strconv.Atoi(s)
+	if err != nil {
+		return step{}, fmt.Errorf("invalid index %s", s)
+	}
+	return step{index: index, isIndex: true}, nil
+}
+
+// Lookup returns the value at the path in v as decoded by encoding/json, false if any part of the path is missing
+func (p *Path) Lookup(v any) (any, bool) {
+	for _, s := range p.steps {
+		if s.isIndex {
+			arr, ok := v.([]any)
+			if !ok {
+				return nil, false
+			}
+			i := s.index
+			if i < 0 {
+				i += len(arr)
+			}
+			if i < 0 || i >= len(arr) {
+				return nil, false
+			}
+			v = arr[i]
+			continue
+		}
+
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		if v, ok = obj[s.key]; !ok {
+			return nil, false
+		}
+	}
+	return v, true
+}
+
+func (p *Path) String() string {
+	return p.expr
+}
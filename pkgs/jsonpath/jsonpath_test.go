@@ -0,0 +1,55 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPath_Lookup(t *testing.T) {
+	var doc any
+	if err := json.Unmarshal([]byte(`{"status":"ok","items":[{"id":1},{"id":2}],"content-type":"json","nested":{"a":{"b":true}}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		expr  string
+		want  any
+		found bool
+	}{
+		{expr: "$.status", want: "ok", found: true},
+		{expr: "$.items[0].id", want: float64(1), found: true},
+		{expr: "$.items[-1].id", want: float64(2), found: true},
+		{expr: "$['content-type']", want: "json", found: true},
+		{expr: `$["nested"].a.b`, want: true, found: true},
+		{expr: "$.items[2]"},
+		{expr: "$.items[-3]"},
+		{expr: "$.status.length"},
+		{expr: "$.missing"},
+		{expr: "$.nested[0]"},
+	} {
+		p, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		got, found := p.Lookup(doc)
+		if found != tt.found || got != tt.want {
+			t.Errorf("%s: wanted %v, %t got %v, %t", tt.expr, tt.want, tt.found, got, found)
+		}
+	}
+
+	p, err := Parse("$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, found := p.Lookup(doc); !found || got == nil {
+		t.Error("wanted $ to return the whole document")
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, expr := range []string{"", "status", "$.", "$..status", "$[0", "$['status]", "$[a]", "$status"} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("wanted error parsing %q", expr)
+		}
+	}
+}
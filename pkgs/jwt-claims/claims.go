@@ -0,0 +1,135 @@
+package jwt_claims
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// alphanumeric are the characters of random string claims
+const alphanumeric = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// Template varies claims between JWTs. It's a JSON object of claim names to how each JWT's value is generated:
+//
+//	{"cycle": ["a", "b"]}  the values in turn, so every value is used once there are as many JWTs
+//	{"oneOf": ["a", "b"]}  one of the values at random
+//	{"int": [1, 100]}      a random integer between min and max inclusive
+//	{"string": 8}          a random alphanumeric string of that length
+type Template struct {
+	// sorted by name so random values don't depend on map order
+	claims []claim
+}
+
+type claim struct {
+	name   string
+	values []interface{}
+	random bool
+	min    int64
+	max    int64
+	length int
+}
+
+type generator struct {
+	Cycle  []interface{} `json:"cycle"`
+	OneOf  []interface{} `json:"oneOf"`
+	Int    []int64       `json:"int"`
+	String int           `json:"string"`
+}
+
+func Parse(template string) (*Template, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(template), &raw); err != nil {
+		return nil, fmt.Errorf("jwt_claims: template isn't a JSON object; %v", err)
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("jwt_claims: template has no claims")
+	}
+
+	t := &Template{}
+	for name, spec := range raw {
+		if name == "" {
+			return nil, fmt.Errorf("jwt_claims: template has an empty claim name")
+		}
+		var g generator
+		dec := json.NewDecoder(bytes.NewReader(spec))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&g); err != nil {
+			return nil, fmt.Errorf("jwt_claims: claim %s needs one of cycle, oneOf, int or string; %v", name, err)
+		}
+
+		c := claim{name: name}
+		set := 0
+		if g.Cycle != nil {
+			set++
+			c.values = g.Cycle
+		}
+		if g.OneOf != nil {
+			set++
+			c.values = g.OneOf
+			c.random = true
+		}
+		if g.Int != nil {
+			set++
+			if len(g.Int) != 2 || g.Int[0] > g.Int[1] {
+				return nil, fmt.Errorf("jwt_claims: claim %s int needs [min, max] with min no more than max", name)
+			}
+			c.min, c.max = g.Int[0], g.Int[1]
+		}
+		if g.String != 0 {
+			set++
+			if g.String < 0 {
+				return nil, fmt.Errorf("jwt_claims: claim %s string needs a length above 0", name)
+			}
+			c.length = g.String
+		}
+		if set != 1 {
+			return nil, fmt.Errorf("jwt_claims: claim %s needs exactly one of cycle, oneOf, int or string", name)
+		}
+		if (g.Cycle != nil || g.OneOf != nil) && len(c.values) == 0 {
+			return nil, fmt.Errorf("jwt_claims: claim %s has no values to choose from", name)
+		}
+		t.claims = append(t.claims, c)
+	}
+	sort.Slice(t.claims, func(i, j int) bool {
+		return t.claims[i].name < t.claims[j].name
+	})
+	return t, nil
+}
+
+// Apply sets the template's claims of the index'th JWT, replacing any already set. Random values are derived from
+// seed and index, so the same JWT always gets the same claims however many goroutines are generating them
+func (t *Template) Apply(claims map[string]interface{}, seed, index int64) {
+	for i, c := range t.claims {
+		r := mix(uint64(seed) ^ mix(uint64(index)) ^ mix(uint64(i)+1))
+		switch {
+		case c.values != nil && !c.random:
+			claims[c.name] = c.values[index%int64(len(c.values))]
+		case c.values != nil:
+			claims[c.name] = c.values[r%uint64(len(c.values))]
+		case c.length > 0:
+			b := make([]byte, c.length)
+			for j := range b {
+				b[j] = alphanumeric[r%uint64(len(alphanumeric))]
+				r = mix(r)
+			}
+			claims[c.name] = string(b)
+		default:
+			span := uint64(c.max-c.min) + 1
+			if span == 0 {
+				// the whole int64 range
+				claims[c.name] = int64(r)
+				continue
+			}
+			claims[c.name] = c.min + int64(r%span)
+		}
+	}
+}
+
+// mix is splitmix64, spreading similar inputs i.e. consecutive indexes over unrelated outputs
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
@@ -0,0 +1,72 @@
+package jwt_claims
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplate_Apply(t *testing.T) {
+	tmpl, err := Parse(`{"sub":{"cycle":["alice","bob","carol"]},"tenant":{"oneOf":["a","b"]},"uid":{"int":[10,20]},"nonce":{"string":12}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenants := make(map[interface{}]bool)
+	for i := int64(0); i < 100; i++ {
+		claims := map[string]interface{}{"sub": "static", "iss": "some-iss"}
+		tmpl.Apply(claims, 42, i)
+
+		if want := []string{"alice", "bob", "carol"}[i%3]; claims["sub"] != want {
+			t.Errorf("wanted sub %s for jwt %d got %v", want, i, claims["sub"])
+		}
+		if claims["iss"] != "some-iss" {
+			t.Errorf("wanted claims not in the template kept got %v", claims)
+		}
+		tenants[claims["tenant"]] = true
+		if uid := claims["uid"].(int64); uid < 10 || uid > 20 {
+			t.Errorf("wanted uid between 10 and 20 got %d", uid)
+		}
+		if nonce := claims["nonce"].(string); len(nonce) != 12 {
+			t.Errorf("wanted a 12 character nonce got %q", nonce)
+		}
+
+		again := map[string]interface{}{}
+		tmpl.Apply(again, 42, i)
+		delete(claims, "iss")
+		if !reflect.DeepEqual(claims, again) {
+			t.Errorf("wanted the same claims for the same seed and index got %v and %v", claims, again)
+		}
+	}
+	if len(tenants) != 2 {
+		t.Errorf("wanted both tenants chosen got %v", tenants)
+	}
+
+	first, other := map[string]interface{}{}, map[string]interface{}{}
+	tmpl.Apply(first, 42, 0)
+	tmpl.Apply(other, 43, 0)
+	if first["nonce"] == other["nonce"] {
+		t.Errorf("wanted a different seed to generate different claims got %v for both", first["nonce"])
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, tmpl := range []string{
+		``,
+		`[]`,
+		`{}`,
+		`{"sub":"alice"}`,
+		`{"sub":{}}`,
+		`{"sub":{"cycle":[]}}`,
+		`{"sub":{"oneOf":[]}}`,
+		`{"sub":{"cycle":["a"],"oneOf":["b"]}}`,
+		`{"sub":{"list":["a"]}}`,
+		`{"uid":{"int":[20,10]}}`,
+		`{"uid":{"int":[1]}}`,
+		`{"nonce":{"string":-1}}`,
+		`{"":{"string":8}}`,
+	} {
+		if _, err := Parse(tmpl); err == nil {
+			t.Errorf("wanted error parsing %s", tmpl)
+		}
+	}
+}
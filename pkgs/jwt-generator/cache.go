@@ -147,7 +147,8 @@ func (c *cache) save(tokens []string) error {
 	}
 
 	add := len(tokens)
-	var pos int64 = 10
+	// jwts start after the count and its new line, otherwise writing the count overwrites the start of the first jwt
+	var pos int64 = byteSizeCounter + 1
 	if stat.Size() > 0 {
 		pos = stat.Size()
 	}
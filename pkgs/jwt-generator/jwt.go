@@ -4,10 +4,12 @@ import (
 	"bufio"
 	"context"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	config "github.com/domsolutions/gopayloader/config"
+	jwt_claims "github.com/domsolutions/gopayloader/pkgs/jwt-claims"
 	jwt_signer "github.com/domsolutions/gopayloader/pkgs/jwt-signer"
 	"github.com/domsolutions/gopayloader/pkgs/jwt-signer/definition"
 	"github.com/golang-jwt/jwt"
@@ -16,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,16 +27,24 @@ const (
 	batchSize = 1000000
 )
 
+// jtiNamespace is the UUID namespace of jti claims derived from a seed
+var jtiNamespace = uuid.MustParse("4a5c2f3e-8d7b-4e61-9f0a-2b6c8e1d7a93")
+
 type Config struct {
 	Ctx                 context.Context
 	Kid                 string
 	JwtKeyPath          string
+	JwtKey              []byte // used instead of reading JwtKeyPath when set
 	jwtKeyBlob          []byte
 	JwtSub              string
 	JwtCustomClaimsJSON string
+	JwtClaimsTemplate   string // varies claims between JWTs, see jwt_claims.Template
+	claimsTemplate      *jwt_claims.Template
+	claimsSeed          int64
 	JwtIss              string
 	JwtAud              string
 	JwtsFilename        string
+	Seed                int64 // makes the jti claims the same on every run, they're random if it's 0
 	signer              definition.Signer
 	store               *cache
 }
@@ -47,9 +58,13 @@ func NewJWTGenerator(config *Config) *JWTGenerator {
 }
 
 func (c *Config) validate() error {
-	jwtKey, err := os.ReadFile(c.JwtKeyPath)
-	if err != nil {
-		return err
+	jwtKey := c.JwtKey
+	if jwtKey == nil {
+		var err error
+		jwtKey, err = os.ReadFile(c.JwtKeyPath)
+		if err != nil {
+			return err
+		}
 	}
 	signer, err := jwt_signer.CreateSigner(jwtKey, c.Kid)
 	if err != nil {
@@ -57,59 +72,88 @@ func (c *Config) validate() error {
 	}
 	c.signer = signer
 	c.jwtKeyBlob = jwtKey
+
+	if c.JwtClaimsTemplate != "" {
+		if c.claimsTemplate, err = jwt_claims.Parse(c.JwtClaimsTemplate); err != nil {
+			return err
+		}
+		// random claims are repeatable like jtis with a seed
+		c.claimsSeed = c.Seed
+		if c.claimsSeed == 0 {
+			c.claimsSeed = time.Now().UnixNano()
+		}
+	}
 	return nil
 }
 
-// GetUserSuppliedJWTs Gets a count number of JWTs from a file, reusing them if not enough exist to match count
-func GetUserSuppliedJWTs(fname string, count int64) (<-chan string, <-chan error) {
+// GetUserSuppliedJWTs Gets a count number of JWTs from files, taking one from each file in turn so their tokens are
+// mixed evenly, and reusing a file's JWTs if not enough exist to match count
+func GetUserSuppliedJWTs(fnames []string, count int64) (<-chan string, <-chan error) {
 	recv := make(chan string, 1000000)
 	errs := make(chan error, 1)
-	go getUserJWTS(fname, count, errs, recv)
+	go getUserJWTS(fnames, count, errs, recv)
 
 	// give goroutine time to prime channel with jwts for workers
 	time.Sleep(1 * time.Second)
 	return recv, errs
 }
 
-func getUserJWTS(fname string, count int64, errs chan<- error, jwts chan<- string) {
+// jwtFile reads the JWTs of one of the files, going back to the beginning once it's read them all
+type jwtFile struct {
+	name    string
+	file    *os.File
+	scanner *bufio.Scanner
+	// read is whether a JWT has been read since the file was last rewound
+	read bool
+}
+
+func (f *jwtFile) next() (string, error) {
+	for {
+		if f.scanner != nil && f.scanner.Scan() {
+			f.read = true
+			return f.scanner.Text(), nil
+		}
+		if f.scanner != nil && !f.read {
+			return "", fmt.Errorf("jwt_generator: retrieving; file %s doesn't contain a JWT", f.name)
+		}
+		// Loops if user asked for more requests than there were JWTs in the file, so JWTs get reused
+		if _, err := f.file.Seek(0, 0); err != nil {
+			return "", err
+		}
+		f.scanner = bufio.NewScanner(f.file)
+		f.scanner.Split(bufio.ScanLines)
+		f.read = false
+	}
+}
+
+func getUserJWTS(fnames []string, count int64, errs chan<- error, jwts chan<- string) {
 	defer func() {
 		close(errs)
 		close(jwts)
 	}()
 
-	file, err := os.OpenFile(fname, os.O_RDONLY, os.ModePerm)
-	if err != nil {
-		errs <- fmt.Errorf("jwt_generator: retrieving; failed to open file containing JWTs; %v", err)
-		return
-	}
-	defer file.Close()
-
-	jwtsSent := int64(0)
-	for jwtsSent < count {
-		// Set pointer to beginning of file
-		if _, err := file.Seek(0, 0); err != nil {
-			errs <- err
-			return
+	files := make([]*jwtFile, 0, len(fnames))
+	defer func() {
+		for _, f := range files {
+			f.file.Close()
 		}
-		scanner := bufio.NewScanner(file)
-		scanner.Split(bufio.ScanLines)
-
-		fileValid := false
-		for scanner.Scan() {
-			fileValid = true
-			jwts <- string(scanner.Bytes())
-			jwtsSent++
-			// Stop reading file when enough JWTs have been fetched
-			if jwtsSent == count {
-				return
-			}
+	}()
+	for _, fname := range fnames {
+		file, err := os.OpenFile(fname, os.O_RDONLY, os.ModePerm)
+		if err != nil {
+			errs <- fmt.Errorf("jwt_generator: retrieving; failed to open file containing JWTs; %v", err)
+			return
 		}
+		files = append(files, &jwtFile{name: fname, file: file})
+	}
 
-		if !fileValid {
-			errs <- fmt.Errorf("jwt_generator: retrieving; file doesn't contain a JWT")
+	for jwtsSent := int64(0); jwtsSent < count; jwtsSent++ {
+		token, err := files[jwtsSent%int64(len(files))].next()
+		if err != nil {
+			errs <- err
 			return
 		}
-		// Loops if user asked for more requests than there were JWTs in the file, so JWTs get reused
+		jwts <- token
 	}
 }
 
@@ -119,9 +163,14 @@ func (j *JWTGenerator) getFileName(dir string) string {
 	hash.Write([]byte(j.config.JwtIss))
 	hash.Write([]byte(j.config.JwtSub))
 	hash.Write([]byte(j.config.JwtCustomClaimsJSON))
+	hash.Write([]byte(j.config.JwtClaimsTemplate))
 	strippedKey := strings.ReplaceAll(strings.ReplaceAll(string(j.config.jwtKeyBlob), "\r", ""), "\n", "") // Replace \r and \n to have the same value in Windows and Linux
 	hash.Write([]byte(strippedKey))
 	hash.Write([]byte(j.config.Kid))
+	if j.config.Seed != 0 {
+		// seeded jtis are kept apart from random ones so a cache never mixes both
+		hash.Write([]byte(strconv.FormatInt(j.config.Seed, 10)))
+	}
 	return filepath.Join(dir, "gopayloader-jwtstore-"+hex.EncodeToString(hash.Sum(nil))+".txt")
 }
 
@@ -174,65 +223,133 @@ func (j *JWTGenerator) batchGenSave(reqJwtAmount, batchSize int64) error {
 	if limit > batchSize {
 		limit = batchSize
 	}
-	workers := runtime.NumCPU()
-	jobs := limit / int64(workers)
-
-	errs := make(chan error)
-	resp := make(chan []string, workers)
 
 	pterm.Info.Printf("Generating batch of %d JWTs and saving to disk\n", limit)
-	for i := 0; i < workers; i++ {
-		if i == 0 {
-			go j.generate(jobs+(limit%int64(workers)), errs, resp)
-			continue
+	if err := j.generateBatch(j.config.store.getJwtCount(), limit, j.config.store.save); err != nil {
+		return err
+	}
+
+	if j.config.store.getJwtCount() == reqJwtAmount {
+		// all jwts generated
+		return nil
+	}
+
+	return j.batchGenSave(reqJwtAmount, batchSize)
+}
+
+// GenerateFile signs count JWTs and writes them to fname one per line, the format read by GetUserSuppliedJWTs, so
+// they can be reused across runs without signing them every time
+func (j *JWTGenerator) GenerateFile(count int64, fname string) error {
+	if err := j.config.validate(); err != nil {
+		return err
+	}
+
+	f, err := os.Create(fname)
+	if err != nil {
+		return fmt.Errorf("jwt: failed to create file to write jwts; %v", err)
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	write := func(tokens []string) error {
+		for _, token := range tokens {
+			if _, err := w.WriteString(token + "\n"); err != nil {
+				return fmt.Errorf("jwt: failed to write jwts; %v", err)
+			}
+		}
+		return nil
+	}
+
+	for generated := int64(0); generated < count; {
+		limit := count - generated
+		if limit > batchSize {
+			limit = batchSize
+		}
+		pterm.Info.Printf("Generating batch of %d JWTs and saving to %s\n", limit, fname)
+		if err := j.generateBatch(generated, limit, write); err != nil {
+			return err
 		}
-		go j.generate(jobs, errs, resp)
+		generated += limit
+	}
+
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("jwt: failed to write jwts; %v", err)
 	}
+	return f.Close()
+}
+
+// generateBatch signs limit JWTs, the first being the start'th JWT overall. They're split in contiguous ranges between
+// a goroutine per GOMAXPROCS and passed to save in order, so with a seed the same tokens are in the same position
+// however many goroutines signed them
+func (j *JWTGenerator) generateBatch(start, limit int64, save func(tokens []string) error) error {
+	workers := int64(runtime.GOMAXPROCS(0))
+	if workers > limit {
+		workers = limit
+	}
+	jobs := limit / workers
 
-	tokens := make([]string, limit, limit)
+	tokens := make([]string, limit)
+	// claims common to all JWTs, computed once so every token in the batch has the same expiry
+	claims := j.commonClaims()
+	errs := make(chan error, workers)
+	done := make(chan struct{}, workers)
 
-	for i := 0; i < workers; i++ {
+	var from int64
+	for i := int64(0); i < workers; i++ {
+		to := from + jobs
+		if i == 0 {
+			to += limit % workers
+		}
+		go j.generate(start+from, tokens[from:to], claims, errs, done)
+		from = to
+	}
+
+	for i := int64(0); i < workers; i++ {
 		select {
 		case <-j.config.Ctx.Done():
 			// user cancelled
 			return errors.New("jwt generation cancelled")
 		case err := <-errs:
 			return err
-		case tokens = <-resp:
-			if len(tokens) == 0 {
-				continue
-			}
-			pterm.Debug.Printf("Finished batch %d saving to disk\n", len(tokens))
-			if err := j.config.store.save(tokens); err != nil {
-				return err
-			}
-			tokens = tokens[:0]
+		case <-done:
 		}
 	}
+	pterm.Debug.Printf("Finished batch %d saving to disk\n", len(tokens))
+	return save(tokens)
+}
 
-	if j.config.store.getJwtCount() == reqJwtAmount {
-		// all jwts generated
-		return nil
+// generate signs a JWT into each of tokens, the first being the start'th JWT overall
+func (j *JWTGenerator) generate(start int64, tokens []string, common jwt.MapClaims, errs chan<- error, done chan<- struct{}) {
+	claims := make(jwt.MapClaims, len(common)+1)
+	for k, v := range common {
+		claims[k] = v
 	}
 
-	return j.batchGenSave(reqJwtAmount, batchSize)
-}
-
-func (j *JWTGenerator) generate(limit int64, errs chan<- error, response chan<- []string) {
-	tokens := make([]string, limit, limit)
 	var err error
-	var i int64 = 0
-
-	claims := j.commonClaims() // Claims common to all JWTs, computed only once
-	for i = 0; i < limit; i++ {
-		claims["jti"] = uuid.New().String()
+	for i := range tokens {
+		claims["jti"] = j.jti(start + int64(i))
+		if j.config.claimsTemplate != nil {
+			j.config.claimsTemplate.Apply(claims, j.config.claimsSeed, start+int64(i))
+		}
 		tokens[i], err = j.config.signer.Generate(claims)
 		if err != nil {
 			errs <- err
 			return
 		}
 	}
-	response <- tokens
+	done <- struct{}{}
+}
+
+// jti is random unless there's a seed, then it's derived from the seed and the JWT's index so the same seed always
+// generates the same IDs
+func (j *JWTGenerator) jti(index int64) string {
+	if j.config.Seed == 0 {
+		return uuid.New().String()
+	}
+	name := make([]byte, 16)
+	binary.BigEndian.PutUint64(name, uint64(j.config.Seed))
+	binary.BigEndian.PutUint64(name[8:], uint64(index))
+	return uuid.NewSHA1(jtiNamespace, name).String()
 }
 
 func (j *JWTGenerator) commonClaims() jwt.MapClaims {
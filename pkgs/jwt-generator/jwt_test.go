@@ -0,0 +1,263 @@
+package jwt_generator
+
+import (
+	"bufio"
+	"context"
+	"github.com/golang-jwt/jwt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestJWTGenerator_GenerateFile(t *testing.T) {
+	keyPath := filepath.Join("..", "..", "test", "private-key-jwt.pem")
+	pem, err := os.ReadFile(keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fname := filepath.Join(t.TempDir(), "jwts.txt")
+	gen := NewJWTGenerator(&Config{
+		Ctx:        context.Background(),
+		JwtKeyPath: keyPath,
+		JwtAud:     "some-aud",
+		JwtIss:     "some-iss",
+	})
+	// not a multiple of the number of CPUs so the remainder is generated too
+	const count = 1001
+	if err := gen.GenerateFile(count, fname); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	jtis := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(scanner.Text(), claims, func(token *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		if err != nil {
+			t.Fatalf("invalid jwt %s; %v", scanner.Text(), err)
+		}
+		if claims["aud"] != "some-aud" || claims["iss"] != "some-iss" {
+			t.Errorf("wanted aud and iss claims got %v", claims)
+		}
+		jtis[claims["jti"].(string)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(jtis) != count {
+		t.Errorf("wanted %d unique jwts got %d", count, len(jtis))
+	}
+}
+
+func TestJWTGenerator_GenerateFileSeeded(t *testing.T) {
+	keyPath := filepath.Join("..", "..", "test", "private-key-jwt.pem")
+	generate := func(procs int, seed int64) []string {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+
+		fname := filepath.Join(t.TempDir(), "jwts.txt")
+		gen := NewJWTGenerator(&Config{
+			Ctx:        context.Background(),
+			JwtKeyPath: keyPath,
+			Seed:       seed,
+		})
+		if err := gen.GenerateFile(1001, fname); err != nil {
+			t.Fatal(err)
+		}
+
+		f, err := os.Open(fname)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		var jtis []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			claims := jwt.MapClaims{}
+			if _, _, err := new(jwt.Parser).ParseUnverified(scanner.Text(), claims); err != nil {
+				t.Fatal(err)
+			}
+			jtis = append(jtis, claims["jti"].(string))
+		}
+		return jtis
+	}
+
+	serial := generate(1, 42)
+	parallel := generate(7, 42)
+	if len(serial) != 1001 || len(parallel) != 1001 {
+		t.Fatalf("wanted 1001 jwts got %d serially and %d in parallel", len(serial), len(parallel))
+	}
+	unique := make(map[string]bool)
+	for i := range serial {
+		if serial[i] != parallel[i] {
+			t.Fatalf("wanted the same jti at %d however many goroutines generated it got %s and %s", i, serial[i], parallel[i])
+		}
+		unique[serial[i]] = true
+	}
+	if len(unique) != 1001 {
+		t.Errorf("wanted 1001 unique jtis got %d", len(unique))
+	}
+
+	if other := generate(7, 43); other[0] == serial[0] {
+		t.Errorf("wanted a different seed to generate different jtis got %s for both", other[0])
+	}
+}
+
+func TestJWTGenerator_GenerateFileClaimsTemplate(t *testing.T) {
+	subs := []string{"user-1", "user-2", "user-3", "user-4", "user-5", "user-6", "user-7"}
+	fname := filepath.Join(t.TempDir(), "jwts.txt")
+	gen := NewJWTGenerator(&Config{
+		Ctx:               context.Background(),
+		JwtKeyPath:        filepath.Join("..", "..", "test", "private-key-jwt.pem"),
+		JwtSub:            "overridden",
+		JwtClaimsTemplate: `{"sub":{"cycle":["user-1","user-2","user-3","user-4","user-5","user-6","user-7"]},"tenant":{"string":8}}`,
+	})
+	if err := gen.GenerateFile(100, fname); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	seen := make(map[string]int)
+	tenants := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		claims := jwt.MapClaims{}
+		if _, _, err := new(jwt.Parser).ParseUnverified(scanner.Text(), claims); err != nil {
+			t.Fatal(err)
+		}
+		seen[claims["sub"].(string)]++
+		tenant, _ := claims["tenant"].(string)
+		if len(tenant) != 8 {
+			t.Errorf("wanted an 8 character tenant got %v", claims["tenant"])
+		}
+		tenants[tenant] = true
+	}
+	if len(seen) != len(subs) {
+		t.Fatalf("wanted jwts for each of %v got %v", subs, seen)
+	}
+	for _, sub := range subs {
+		// 100 jwts over 7 subs
+		if seen[sub] < 14 || seen[sub] > 15 {
+			t.Errorf("wanted sub %s in 14 or 15 jwts got %d", sub, seen[sub])
+		}
+	}
+	if len(tenants) < 90 {
+		t.Errorf("wanted random tenants got %d different of 100", len(tenants))
+	}
+
+	gen = NewJWTGenerator(&Config{
+		Ctx:               context.Background(),
+		JwtKeyPath:        filepath.Join("..", "..", "test", "private-key-jwt.pem"),
+		JwtClaimsTemplate: `{"sub":{"cycle":[]}}`,
+	})
+	if err := gen.GenerateFile(1, fname); err == nil {
+		t.Error("wanted error for an invalid claims template")
+	}
+}
+
+func TestJWTGenerator_GenerateFileKeyBlob(t *testing.T) {
+	pem, err := os.ReadFile(filepath.Join("..", "..", "test", "private-key-jwt.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the key read from an environment variable or stdin, there's no file
+	fname := filepath.Join(t.TempDir(), "jwts.txt")
+	gen := NewJWTGenerator(&Config{
+		Ctx:    context.Background(),
+		JwtKey: pem,
+		JwtSub: "key-from-env",
+	})
+	if err := gen.GenerateFile(10, fname); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	n := 0
+	for scanner.Scan() {
+		claims := jwt.MapClaims{}
+		_, err := jwt.ParseWithClaims(scanner.Text(), claims, func(token *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		})
+		if err != nil {
+			t.Fatalf("invalid jwt %s; %v", scanner.Text(), err)
+		}
+		if claims["sub"] != "key-from-env" {
+			t.Errorf("wanted sub claim got %v", claims)
+		}
+		n++
+	}
+	if n != 10 {
+		t.Errorf("wanted 10 jwts got %d", n)
+	}
+}
+
+func TestGetUserJWTS(t *testing.T) {
+	// tokens of two tenants, the second with fewer so its are reused
+	dir := t.TempDir()
+	tenantA := filepath.Join(dir, "tenant-a.txt")
+	tenantB := filepath.Join(dir, "tenant-b.txt")
+	empty := filepath.Join(dir, "empty.txt")
+	for name, jwts := range map[string]string{tenantA: "a0\na1\na2\na3\n", tenantB: "b0\nb1\n", empty: ""} {
+		if err := os.WriteFile(name, []byte(jwts), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	get := func(fnames []string, count int64) ([]string, error) {
+		jwts := make(chan string, count)
+		errs := make(chan error, 1)
+		getUserJWTS(fnames, count, errs, jwts)
+		var got []string
+		for token := range jwts {
+			got = append(got, token)
+		}
+		return got, <-errs
+	}
+
+	// the files are taken from in turn
+	got, err := get([]string{tenantA, tenantB}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a0", "b0", "a1", "b1", "a2", "b0", "a3", "b1", "a0", "b0"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("wanted JWTs %v got %v", want, got)
+	}
+
+	if _, err := get([]string{tenantA, empty}, 4); err == nil || !strings.Contains(err.Error(), "doesn't contain a JWT") {
+		t.Errorf("wanted error for a file without JWTs got %v", err)
+	}
+	if _, err := get([]string{tenantA, filepath.Join(dir, "missing.txt")}, 4); err == nil {
+		t.Error("wanted error for a missing file")
+	}
+}
@@ -0,0 +1,33 @@
+package limiter
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Bytes counts the bytes transferred by all workers sharing it, cancelling the run once the total reaches the limit
+type Bytes struct {
+	total  atomic.Int64
+	limit  int64
+	cancel context.CancelFunc
+}
+
+func NewBytes(limit int64, cancel context.CancelFunc) *Bytes {
+	return &Bytes{limit: limit, cancel: cancel}
+}
+
+// Add counts n more bytes, cancelling the run if the limit's been reached. Requests already in flight still finish
+// so the total can go over the limit by up to a request per connection
+func (b *Bytes) Add(n int64) {
+	if b.total.Add(n) >= b.limit {
+		b.cancel()
+	}
+}
+
+func (b *Bytes) Total() int64 {
+	return b.total.Load()
+}
+
+func (b *Bytes) Reached() bool {
+	return b.total.Load() >= b.limit
+}
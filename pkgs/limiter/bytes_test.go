@@ -0,0 +1,22 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+)
+
+func TestBytes_Add(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewBytes(1000, cancel)
+	b.Add(600)
+	if b.Reached() || ctx.Err() != nil {
+		t.Fatal("wanted the run to carry on under the limit")
+	}
+	b.Add(600)
+	if !b.Reached() || ctx.Err() == nil {
+		t.Error("wanted the run cancelled once the limit was reached")
+	}
+	if b.Total() != 1200 {
+		t.Errorf("wanted 1200 bytes counted got %d", b.Total())
+	}
+}
@@ -0,0 +1,72 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+)
+
+// Conns controls how many connections are actively sending requests, workers with an ID at or above the active
+// count are parked until it's raised again
+type Conns struct {
+	mu      sync.Mutex
+	active  int
+	max     int
+	changed chan struct{}
+}
+
+func NewConns(active, max int) *Conns {
+	c := &Conns{max: max, changed: make(chan struct{})}
+	c.active = c.clamp(active)
+	return c
+}
+
+func (c *Conns) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.active
+}
+
+func (c *Conns) Max() int {
+	return c.max
+}
+
+// SetActive sets the number of active connections, limited to between 1 and max, returning the new value
+func (c *Conns) SetActive(active int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.active = c.clamp(active)
+	// wake up parked workers to check if they're active again
+	close(c.changed)
+	c.changed = make(chan struct{})
+	return c.active
+}
+
+func (c *Conns) clamp(active int) int {
+	if active < 1 {
+		return 1
+	}
+	if active > c.max {
+		return c.max
+	}
+	return active
+}
+
+// Wait blocks while worker id is parked, returns false if ctx is done first
+func (c *Conns) Wait(ctx context.Context, id int) bool {
+	for {
+		c.mu.Lock()
+		if id < c.active {
+			c.mu.Unlock()
+			return ctx.Err() == nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
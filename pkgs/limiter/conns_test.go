@@ -0,0 +1,47 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestConns(t *testing.T) {
+	c := NewConns(2, 4)
+	if c.Active() != 2 || c.Max() != 4 {
+		t.Fatalf("wanted 2/4 active got %d/%d", c.Active(), c.Max())
+	}
+	// limited to between 1 and the connections started with
+	if active := c.SetActive(5); active != 4 {
+		t.Errorf("wanted 4 active got %d", active)
+	}
+	if active := c.SetActive(0); active != 1 {
+		t.Errorf("wanted 1 active got %d", active)
+	}
+
+	ctx := context.Background()
+	if !c.Wait(ctx, 0) {
+		t.Error("wanted worker 0 to be active")
+	}
+	// worker 2 is parked until there are 3 active
+	woken := make(chan bool)
+	go func() {
+		woken <- c.Wait(ctx, 2)
+	}()
+	c.SetActive(2)
+	select {
+	case <-woken:
+		t.Fatal("wanted worker 2 parked with 2 active")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.SetActive(3)
+	if !<-woken {
+		t.Error("wanted worker 2 active with 3 active")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if c.Wait(cancelled, 3) {
+		t.Error("wanted a parked worker to stop waiting once ctx is done")
+	}
+}
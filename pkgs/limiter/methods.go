@@ -0,0 +1,65 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Methods caps how many requests of each method are in flight at once across all workers sharing it, so expensive
+// requests i.e. the POSTs of a --data-csv method mix can be held back while cheaper ones flow freely. Each capped
+// method has its own semaphore with a slot per request it allows in flight, methods without a cap aren't limited
+type Methods struct {
+	slots map[string]chan struct{}
+}
+
+// ParseMethods parses caps of the form POST=2,PUT=4 into the cap of each method, methods are upper cased
+func ParseMethods(caps string) (map[string]int, error) {
+	parsed := make(map[string]int)
+	for _, c := range strings.Split(caps, ",") {
+		method, n, ok := strings.Cut(strings.TrimSpace(c), "=")
+		if !ok || method == "" {
+			return nil, fmt.Errorf("limiter: method cap %q should be <method>=<max in flight>", c)
+		}
+		max, err := strconv.Atoi(n)
+		if err != nil || max < 1 {
+			return nil, fmt.Errorf("limiter: method %s cap should be at least 1 got %s", method, n)
+		}
+		method = strings.ToUpper(method)
+		if _, ok := parsed[method]; ok {
+			return nil, fmt.Errorf("limiter: method %s is capped more than once", method)
+		}
+		parsed[method] = max
+	}
+	return parsed, nil
+}
+
+func NewMethods(caps map[string]int) *Methods {
+	m := &Methods{slots: make(map[string]chan struct{}, len(caps))}
+	for method, max := range caps {
+		m.slots[method] = make(chan struct{}, max)
+	}
+	return m
+}
+
+// Acquire blocks until a request of method can be sent, returns false if ctx is done first. Every acquired request
+// must be released once its response is received
+func (m *Methods) Acquire(ctx context.Context, method string) bool {
+	slots, ok := m.slots[method]
+	if !ok {
+		return true
+	}
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (m *Methods) Release(method string) {
+	if slots, ok := m.slots[method]; ok {
+		<-slots
+	}
+}
@@ -0,0 +1,60 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseMethods(t *testing.T) {
+	caps, err := ParseMethods("post=2, PUT=4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"POST": 2, "PUT": 4}; !reflect.DeepEqual(caps, want) {
+		t.Errorf("wanted %v got %v", want, caps)
+	}
+
+	for _, caps := range []string{"POST", "=2", "POST=0", "POST=a", "POST=2,post=3"} {
+		if _, err := ParseMethods(caps); err == nil {
+			t.Errorf("wanted error for method caps %s", caps)
+		}
+	}
+}
+
+func TestMethods_Acquire(t *testing.T) {
+	m := NewMethods(map[string]int{"POST": 2})
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		if !m.Acquire(ctx, "POST") {
+			t.Fatal("wanted a POST slot")
+		}
+	}
+	// methods without a cap aren't limited
+	for i := 0; i < 10; i++ {
+		if !m.Acquire(ctx, "GET") {
+			t.Fatal("wanted GETs to not be limited")
+		}
+	}
+
+	acquired := make(chan bool)
+	go func() {
+		acquired <- m.Acquire(ctx, "POST")
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("wanted the third POST to wait with 2 in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	m.Release("POST")
+	if !<-acquired {
+		t.Error("wanted the third POST sent once one was released")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if m.Acquire(cancelled, "POST") {
+		t.Error("wanted no slot once the run is cancelled")
+	}
+}
@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pause stops all workers sharing it from sending requests until resumed, keeping how long they were paused for
+// so it can be left out of throughput
+type Pause struct {
+	// paused is checked without the lock as it's on every request
+	paused  atomic.Bool
+	mu      sync.Mutex
+	since   time.Time
+	total   time.Duration
+	resumed chan struct{}
+}
+
+func NewPause() *Pause {
+	return &Pause{resumed: make(chan struct{})}
+}
+
+// Pause stops requests being sent, returns false if already paused
+func (p *Pause) Pause() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused.Load() {
+		return false
+	}
+	p.since = time.Now()
+	p.paused.Store(true)
+	return true
+}
+
+// Resume lets waiting workers send requests again, returns false if not paused
+func (p *Pause) Resume() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.paused.Load() {
+		return false
+	}
+	p.total += time.Since(p.since)
+	p.paused.Store(false)
+	close(p.resumed)
+	p.resumed = make(chan struct{})
+	return true
+}
+
+func (p *Pause) Paused() bool {
+	return p.paused.Load()
+}
+
+// Total is how long requests have been paused for, including the current pause
+func (p *Pause) Total() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.paused.Load() {
+		return p.total + time.Since(p.since)
+	}
+	return p.total
+}
+
+// Wait blocks while paused, returns false if ctx is done first
+func (p *Pause) Wait(ctx context.Context) bool {
+	if !p.paused.Load() {
+		return ctx.Err() == nil
+	}
+
+	p.mu.Lock()
+	if !p.paused.Load() {
+		// resumed since checking
+		p.mu.Unlock()
+		return ctx.Err() == nil
+	}
+	resumed := p.resumed
+	p.mu.Unlock()
+
+	select {
+	case <-resumed:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPause(t *testing.T) {
+	ctx := context.Background()
+	p := NewPause()
+	if !p.Wait(ctx) || p.Total() != 0 {
+		t.Fatal("wanted no waiting before a pause")
+	}
+	if p.Resume() {
+		t.Error("wanted resume to do nothing when not paused")
+	}
+
+	if !p.Pause() || p.Pause() {
+		t.Error("wanted only the first pause to take effect")
+	}
+	resumed := make(chan bool)
+	go func() {
+		resumed <- p.Wait(ctx)
+	}()
+	select {
+	case <-resumed:
+		t.Fatal("wanted wait to block while paused")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// the current pause counts
+	if total := p.Total(); total < 50*time.Millisecond {
+		t.Errorf("wanted at least 50ms paused got %s", total)
+	}
+
+	if !p.Resume() || !<-resumed {
+		t.Fatal("wanted resume to wake waiting workers")
+	}
+	total := p.Total()
+	time.Sleep(10 * time.Millisecond)
+	if p.Total() != total {
+		t.Error("wanted the paused time kept once resumed")
+	}
+
+	// a pause can be resumed again
+	p.Pause()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if p.Wait(cancelled) {
+		t.Error("wanted no waiting once ctx is done")
+	}
+	p.Resume()
+	if !p.Wait(ctx) {
+		t.Error("wanted no waiting once resumed")
+	}
+}
@@ -0,0 +1,246 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Rate paces requests across all workers sharing it so together they send at most rate requests per second,
+// the rate can be changed while workers are running
+type Rate struct {
+	mu   sync.Mutex
+	rate float64
+	next time.Time
+	// open keeps to the schedule when requests fall behind, slots missed while every worker was busy are sent as
+	// soon as a worker is free rather than skipped
+	open    bool
+	changed chan struct{}
+	// shares are the rates the rate was split into, see Split
+	shares []*Rate
+	// inflight caps an open rate's requests which have arrived but not completed, nil without a cap
+	inflight *inflight
+}
+
+// Backpressure is what capping an open rate's in-flight requests held back, see SetMaxInflight
+type Backpressure struct {
+	Max int
+	// Peak is the most requests in flight at once, those being sent and those due waiting for a worker
+	Peak int
+	// Dropped is how many arrivals were discarded as the cap was reached, Delayed how many waited for room and Delay
+	// their total wait
+	Dropped int64
+	Delayed int64
+	Delay   time.Duration
+}
+
+type inflight struct {
+	drop bool
+	// queue are the scheduled times of the arrivals let in but not yet sent, sending those sent and not done
+	queue   []time.Time
+	sending int
+	// freed is closed when a request's done to wake workers waiting for room
+	freed chan struct{}
+	Backpressure
+}
+
+func NewRate(rate float64) *Rate {
+	return &Rate{rate: rate, changed: make(chan struct{})}
+}
+
+// NewOpenRate paces requests on a fixed schedule like an open model where requests arrive whether or not earlier
+// ones have completed, when the target can't keep up requests queue client-side until a worker is free
+func NewOpenRate(rate float64) *Rate {
+	r := NewRate(rate)
+	r.open = true
+	return r
+}
+
+func (r *Rate) Rate() float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.rate
+}
+
+// SetRate changes the rate, workers already waiting are woken to reserve their slot again at the new rate,
+// otherwise they'd keep waiting as long as the old rate required. A split rate changes each of its shares
+func (r *Rate) SetRate(rate float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rate = rate
+	r.next = time.Now()
+	close(r.changed)
+	r.changed = make(chan struct{})
+	for _, share := range r.shares {
+		share.SetRate(rate / float64(len(r.shares)))
+	}
+}
+
+// Split divides the rate evenly into n rates with their own schedules, one for each worker, so a worker which is
+// free more often can't take the slots of one which isn't. The rate is still the total and changing it changes the
+// shares, only the shares are waited on
+func (r *Rate) Split(n int) []*Rate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shares = make([]*Rate, n)
+	for i := range r.shares {
+		r.shares[i] = NewRate(r.rate / float64(n))
+		r.shares[i].open = r.open
+	}
+	return r.shares
+}
+
+// SetMaxInflight caps the requests of an open rate which have arrived but not completed at max, so a stalled target
+// can't build an endless backlog. When it's reached further arrivals are dropped, or with drop false wait until a
+// request's done and are let in then, keeping their scheduled time. Workers must call Done once each request they
+// were given a slot for has completed
+func (r *Rate) SetMaxInflight(max int, drop bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inflight = &inflight{drop: drop, freed: make(chan struct{}), Backpressure: Backpressure{Max: max}}
+}
+
+// Done frees the slot of a request which has completed, without a cap on in-flight requests it does nothing
+func (r *Rate) Done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inflight == nil {
+		return
+	}
+	now := time.Now()
+	// the arrivals since the last request was sent or done saw the cap as it was before this one's done
+	r.admit(now, false)
+	r.inflight.sending--
+	r.admit(now, true)
+	close(r.inflight.freed)
+	r.inflight.freed = make(chan struct{})
+}
+
+// Backpressure returns what the cap on in-flight requests held back, false without one
+func (r *Rate) Backpressure() (Backpressure, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.inflight == nil {
+		return Backpressure{}, false
+	}
+	return r.inflight.Backpressure, true
+}
+
+// admit lets the arrivals due by now in while there's room under the cap, r.mu must be held. In flight only changes
+// as requests are sent or done, which both admit first, so each arrival is let in or not as the cap was when it
+// arrived. freed is set once a request's done, anything let in then had been waiting for room
+func (r *Rate) admit(now time.Time, freed bool) {
+	in := r.inflight
+	interval := time.Duration(float64(time.Second) / r.rate)
+	for !r.next.After(now) {
+		if in.sending+len(in.queue) >= in.Max {
+			if !in.drop {
+				// waits at the head of the schedule until there's room
+				return
+			}
+			in.Dropped++
+		} else {
+			in.queue = append(in.queue, r.next)
+			if freed {
+				in.Delayed++
+				in.Delay += now.Sub(r.next)
+			}
+			if n := in.sending + len(in.queue); n > in.Peak {
+				in.Peak = n
+			}
+		}
+		r.next = r.next.Add(interval)
+	}
+}
+
+// nextInflight is Next for an open rate with a cap on in-flight requests, the worker's given the oldest arrival let
+// in, waiting for one to arrive or for room when there's none
+func (r *Rate) nextInflight(ctx context.Context) (time.Time, bool) {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		if r.next.IsZero() {
+			r.next = now
+		}
+		in := r.inflight
+		r.admit(now, false)
+		if len(in.queue) > 0 {
+			slot := in.queue[0]
+			in.queue = in.queue[1:]
+			in.sending++
+			r.mu.Unlock()
+			return slot, ctx.Err() == nil
+		}
+		// without room the next arrival is already due and only waits for a request to be done
+		var arrival <-chan time.Time
+		var t *time.Timer
+		if r.next.After(now) {
+			t = time.NewTimer(r.next.Sub(now))
+			arrival = t.C
+		}
+		changed, freed := r.changed, in.freed
+		r.mu.Unlock()
+
+		select {
+		case <-arrival:
+		case <-freed:
+		case <-changed:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if ctx.Err() != nil {
+			return now, false
+		}
+	}
+}
+
+// Wait blocks until the next request is allowed to be sent, returns false if ctx is done first
+func (r *Rate) Wait(ctx context.Context) bool {
+	_, ok := r.Next(ctx)
+	return ok
+}
+
+// Next blocks until the next request is allowed to be sent and returns when it was scheduled to be sent, which is
+// in the past if an open rate has fallen behind. Returns false if ctx is done first
+func (r *Rate) Next(ctx context.Context) (time.Time, bool) {
+	for {
+		r.mu.Lock()
+		now := time.Now()
+		if r.rate <= 0 {
+			r.mu.Unlock()
+			return now, ctx.Err() == nil
+		}
+		if r.inflight != nil {
+			r.mu.Unlock()
+			return r.nextInflight(ctx)
+		}
+
+		if r.next.IsZero() || (!r.open && r.next.Before(now)) {
+			// don't allow a burst to catch up on slots missed while idle
+			r.next = now
+		}
+		slot := r.next
+		wait := slot.Sub(now)
+		r.next = r.next.Add(time.Duration(float64(time.Second) / r.rate))
+		changed := r.changed
+		r.mu.Unlock()
+
+		if wait <= 0 {
+			return slot, ctx.Err() == nil
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+			return slot, true
+		case <-ctx.Done():
+			t.Stop()
+			return slot, false
+		case <-changed:
+			// rate changed, reserve again
+			t.Stop()
+		}
+	}
+}
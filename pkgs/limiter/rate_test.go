@@ -0,0 +1,194 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRate_Next(t *testing.T) {
+	ctx := context.Background()
+	r := NewRate(100)
+	begin := time.Now()
+	for i := 0; i < 10; i++ {
+		if !r.Wait(ctx) {
+			t.Fatal("wanted a slot")
+		}
+	}
+	// the first slot is straight away then one every 10ms
+	if took := time.Since(begin); took < 85*time.Millisecond || took > 200*time.Millisecond {
+		t.Errorf("wanted 10 slots at 100/s to take ~90ms got %s", took)
+	}
+
+	// slots missed while idle aren't sent as a burst to catch up
+	time.Sleep(50 * time.Millisecond)
+	r.Wait(ctx)
+	begin = time.Now()
+	r.Wait(ctx)
+	if took := time.Since(begin); took < 5*time.Millisecond {
+		t.Errorf("wanted the slot after an idle one paced got it after %s", took)
+	}
+
+	// no limit without a rate
+	r = NewRate(0)
+	begin = time.Now()
+	for i := 0; i < 1000; i++ {
+		r.Wait(ctx)
+	}
+	if took := time.Since(begin); took > 50*time.Millisecond {
+		t.Errorf("wanted no waiting without a rate got %s", took)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	r = NewRate(1)
+	r.Wait(ctx)
+	if r.Wait(cancelled) {
+		t.Error("wanted no slot once ctx is done")
+	}
+}
+
+func TestRate_SetRate(t *testing.T) {
+	ctx := context.Background()
+	r := NewRate(0.5)
+	r.Wait(ctx)
+
+	// the second slot is 2s away at the old rate, a worker waiting for it reserves again at the new one
+	got := make(chan time.Duration)
+	go func() {
+		begin := time.Now()
+		r.Wait(ctx)
+		got <- time.Since(begin)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	r.SetRate(1000)
+	if took := <-got; took > 500*time.Millisecond {
+		t.Errorf("wanted the waiting worker woken by the new rate got a slot after %s", took)
+	}
+	if r.Rate() != 1000 {
+		t.Errorf("wanted rate 1000 got %f", r.Rate())
+	}
+}
+
+func TestRate_Split(t *testing.T) {
+	ctx := context.Background()
+	r := NewRate(100)
+	shares := r.Split(2)
+	if len(shares) != 2 || shares[0].Rate() != 50 || shares[1].Rate() != 50 {
+		t.Fatalf("wanted 2 shares of 50/s got %d", len(shares))
+	}
+
+	// one share busy with its own slots doesn't hold up the other's
+	begin := time.Now()
+	for i := 0; i < 5; i++ {
+		shares[0].Wait(ctx)
+	}
+	if took := time.Since(begin); took < 70*time.Millisecond {
+		t.Errorf("wanted 5 slots at 50/s to take ~80ms got %s", took)
+	}
+	begin = time.Now()
+	shares[1].Wait(ctx)
+	if took := time.Since(begin); took > 5*time.Millisecond {
+		t.Errorf("wanted the other share's first slot straight away got it after %s", took)
+	}
+
+	// the rate is still the total, changing it changes the shares
+	r.SetRate(200)
+	if r.Rate() != 200 || shares[0].Rate() != 100 || shares[1].Rate() != 100 {
+		t.Errorf("wanted shares of 100/s from 200/s got %f and %f", shares[0].Rate(), shares[1].Rate())
+	}
+	if shares := NewOpenRate(100).Split(2); !shares[0].open || !shares[1].open {
+		t.Error("wanted the shares of an open rate to be open too")
+	}
+}
+
+func TestRate_MaxInflightDelay(t *testing.T) {
+	ctx := context.Background()
+	r := NewOpenRate(1000)
+	r.SetMaxInflight(2, false)
+	// a second behind so ~1000 arrivals are already due
+	start := time.Now().Add(-time.Second)
+	r.next = start
+
+	for i, want := range []time.Time{start, start.Add(time.Millisecond)} {
+		if slot, ok := r.Next(ctx); !ok || !slot.Equal(want) {
+			t.Fatalf("wanted arrival %d scheduled at %s got %s", i, want, slot)
+		}
+	}
+
+	// the third arrival waits for room rather than being dropped, keeping its scheduled time
+	third := make(chan time.Time)
+	go func() {
+		slot, _ := r.Next(ctx)
+		third <- slot
+	}()
+	select {
+	case <-third:
+		t.Fatal("wanted the third arrival to wait while 2 are in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+	r.Done()
+	if slot := <-third; !slot.Equal(start.Add(2 * time.Millisecond)) {
+		t.Errorf("wanted the third arrival scheduled at %s got %s", start.Add(2*time.Millisecond), slot)
+	}
+
+	bp, ok := r.Backpressure()
+	if !ok {
+		t.Fatal("wanted backpressure with a cap")
+	}
+	if bp.Max != 2 || bp.Peak != 2 || bp.Dropped != 0 || bp.Delayed != 1 || bp.Delay < time.Second {
+		t.Errorf("wanted the third arrival delayed over a second and nothing dropped got %+v", bp)
+	}
+}
+
+func TestRate_MaxInflightDrop(t *testing.T) {
+	ctx := context.Background()
+	r := NewOpenRate(1000)
+	r.SetMaxInflight(2, true)
+	start := time.Now().Add(-time.Second)
+	r.next = start
+
+	for i := 0; i < 2; i++ {
+		if _, ok := r.Next(ctx); !ok {
+			t.Fatal("wanted a slot")
+		}
+	}
+	// every other arrival due was dropped as there was no room
+	bp, _ := r.Backpressure()
+	if bp.Dropped < 990 || bp.Peak != 2 {
+		t.Errorf("wanted ~998 arrivals dropped at a peak of 2 got %+v", bp)
+	}
+
+	// once there's room the next arrival is let in on time, nothing counts as delayed
+	r.Done()
+	slot, ok := r.Next(ctx)
+	if !ok || time.Since(slot) > 100*time.Millisecond {
+		t.Errorf("wanted the next arrival after room was freed got one scheduled at %s", slot)
+	}
+	if bp, _ := r.Backpressure(); bp.Delayed != 0 || bp.Delay != 0 {
+		t.Errorf("wanted nothing delayed when dropping got %+v", bp)
+	}
+}
+
+func TestRate_MaxInflightCancelled(t *testing.T) {
+	r := NewOpenRate(1000)
+	r.SetMaxInflight(1, false)
+	if _, ok := r.Next(context.Background()); !ok {
+		t.Fatal("wanted a slot")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, ok := r.Next(ctx); ok {
+		t.Error("wanted no slot while the only one is in flight and ctx is done")
+	}
+}
+
+func TestRate_DoneWithoutMaxInflight(t *testing.T) {
+	r := NewOpenRate(1000)
+	// nothing to free
+	r.Done()
+	if _, ok := r.Backpressure(); ok {
+		t.Error("wanted no backpressure without a cap")
+	}
+}
@@ -0,0 +1,79 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+// Window tracks the error rate of requests over a sliding window of time, split into buckets so old results
+// expire a bucket at a time
+type Window struct {
+	mu      sync.Mutex
+	width   time.Duration
+	buckets []windowBucket
+}
+
+type windowBucket struct {
+	epoch  int64
+	total  int64
+	failed int64
+}
+
+func NewWindow(size time.Duration, buckets int) *Window {
+	return &Window{
+		width:   size / time.Duration(buckets),
+		buckets: make([]windowBucket, buckets),
+	}
+}
+
+// BucketWidth is how often results expire from the window
+func (w *Window) BucketWidth() time.Duration {
+	return w.width
+}
+
+func (w *Window) Record(failed bool) {
+	epoch := time.Now().UnixNano() / int64(w.width)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		// bucket was last used a full window ago
+		*b = windowBucket{epoch: epoch}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+// ErrorRate returns the fraction of requests which failed within the window and the number of requests it's based on
+func (w *Window) ErrorRate() (float64, int64) {
+	epoch := time.Now().UnixNano() / int64(w.width)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var total, failed int64
+	for _, b := range w.buckets {
+		if epoch-b.epoch < int64(len(w.buckets)) {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(total), total
+}
+
+// Reset clears all results, i.e. so results from before a rate change don't affect the next decision
+func (w *Window) Reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for i := range w.buckets {
+		w.buckets[i] = windowBucket{}
+	}
+}
@@ -0,0 +1,38 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindow_ErrorRate(t *testing.T) {
+	w := NewWindow(100*time.Millisecond, 5)
+	if w.BucketWidth() != 20*time.Millisecond {
+		t.Errorf("wanted 20ms buckets got %s", w.BucketWidth())
+	}
+	if rate, total := w.ErrorRate(); rate != 0 || total != 0 {
+		t.Errorf("wanted no results got %f of %d", rate, total)
+	}
+
+	for i := 0; i < 4; i++ {
+		w.Record(i == 0)
+	}
+	if rate, total := w.ErrorRate(); rate != 0.25 || total != 4 {
+		t.Errorf("wanted 1 of 4 failed got %f of %d", rate, total)
+	}
+
+	// results expire once they're a full window old
+	time.Sleep(150 * time.Millisecond)
+	if rate, total := w.ErrorRate(); rate != 0 || total != 0 {
+		t.Errorf("wanted the results expired got %f of %d", rate, total)
+	}
+	w.Record(true)
+	if rate, total := w.ErrorRate(); rate != 1 || total != 1 {
+		t.Errorf("wanted only the new result got %f of %d", rate, total)
+	}
+
+	w.Reset()
+	if _, total := w.ErrorRate(); total != 0 {
+		t.Errorf("wanted no results after reset got %d", total)
+	}
+}
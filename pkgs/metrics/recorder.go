@@ -0,0 +1,127 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	contentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	contentTypePrometheus  = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// latencyBuckets are the upper bounds in seconds of the latency histogram, same as the Prometheus client defaults
+var latencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Recorder keeps request counts and a latency histogram for the metrics endpoint. Each histogram bucket keeps the
+// most recent request with a trace ID as its exemplar, so a slow bucket links to an actual request
+type Recorder struct {
+	mu        sync.Mutex
+	completed uint64
+	failed    uint64
+	// buckets are not cumulative, the last is +Inf
+	buckets   []uint64
+	exemplars []*exemplar
+	sum       float64
+}
+
+type exemplar struct {
+	traceID string
+	value   float64
+	time    time.Time
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		buckets:   make([]uint64, len(latencyBuckets)+1),
+		exemplars: make([]*exemplar, len(latencyBuckets)+1),
+	}
+}
+
+// Observe records a completed request, traceID is empty if the request wasn't sent with one
+func (r *Recorder) Observe(latency time.Duration, traceID string) {
+	seconds := latency.Seconds()
+	i := 0
+	for i < len(latencyBuckets) && seconds > latencyBuckets[i] {
+		i++
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.completed++
+	r.buckets[i]++
+	r.sum += seconds
+	if traceID != "" {
+		r.exemplars[i] = &exemplar{traceID: traceID, value: seconds, time: time.Now()}
+	}
+}
+
+func (r *Recorder) Failed() {
+	r.mu.Lock()
+	r.failed++
+	r.mu.Unlock()
+}
+
+// ServeHTTP writes the OpenMetrics exposition if the scraper accepts it, otherwise the Prometheus text format which
+// doesn't support exemplars
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	openMetrics := strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text")
+	if openMetrics {
+		w.Header().Set("Content-Type", contentTypeOpenMetrics)
+	} else {
+		w.Header().Set("Content-Type", contentTypePrometheus)
+	}
+	r.Write(w, openMetrics)
+}
+
+// Write writes the metrics in the OpenMetrics format including exemplars, or the Prometheus text format
+func (r *Recorder) Write(w io.Writer, openMetrics bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b := &strings.Builder{}
+	// OpenMetrics names the counter family without the _total suffix
+	if openMetrics {
+		b.WriteString("# TYPE gopayloader_requests counter\n")
+		b.WriteString("# HELP gopayloader_requests Requests sent by result.\n")
+	} else {
+		b.WriteString("# TYPE gopayloader_requests_total counter\n")
+		b.WriteString("# HELP gopayloader_requests_total Requests sent by result.\n")
+	}
+	fmt.Fprintf(b, "gopayloader_requests_total{result=\"completed\"} %d\n", r.completed)
+	fmt.Fprintf(b, "gopayloader_requests_total{result=\"failed\"} %d\n", r.failed)
+
+	b.WriteString("# TYPE gopayloader_request_duration_seconds histogram\n")
+	b.WriteString("# HELP gopayloader_request_duration_seconds Latency of completed requests.\n")
+	var cumulative uint64
+	for i, count := range r.buckets {
+		cumulative += count
+		le := "+Inf"
+		if i < len(latencyBuckets) {
+			le = formatFloat(latencyBuckets[i])
+		}
+		fmt.Fprintf(b, "gopayloader_request_duration_seconds_bucket{le=\"%s\"} %d", le, cumulative)
+		if e := r.exemplars[i]; openMetrics && e != nil {
+			fmt.Fprintf(b, " # {trace_id=\"%s\"} %s %s", e.traceID, formatFloat(e.value), formatFloat(float64(e.time.UnixNano())/1e9))
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "gopayloader_request_duration_seconds_sum %s\n", formatFloat(r.sum))
+	fmt.Fprintf(b, "gopayloader_request_duration_seconds_count %d\n", r.completed)
+
+	if openMetrics {
+		b.WriteString("# EOF\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecorder_ServeHTTP(t *testing.T) {
+	r := NewRecorder()
+	r.Observe(3*time.Millisecond, "")
+	r.Observe(4*time.Millisecond, "0af7651916cd43dd8448eb211c80319c")
+	r.Observe(2*time.Second, "4bf92f3577b34da6a3ce929d0e0e4736")
+	r.Failed()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/openmetrics-text") {
+		t.Errorf("wanted OpenMetrics content type got %s", rec.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{
+		"# TYPE gopayloader_requests counter\n",
+		`gopayloader_requests_total{result="completed"} 3` + "\n",
+		`gopayloader_requests_total{result="failed"} 1` + "\n",
+		// the latest traced request of each bucket is its exemplar, buckets are cumulative
+		`gopayloader_request_duration_seconds_bucket{le="0.005"} 2 # {trace_id="0af7651916cd43dd8448eb211c80319c"} 0.004 `,
+		`gopayloader_request_duration_seconds_bucket{le="1"} 2` + "\n",
+		`gopayloader_request_duration_seconds_bucket{le="2.5"} 3 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 2 `,
+		`gopayloader_request_duration_seconds_bucket{le="+Inf"} 3` + "\n",
+		"gopayloader_request_duration_seconds_count 3\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("wanted %q in\n%s", want, body)
+		}
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("wanted OpenMetrics to end with EOF got\n%s", body)
+	}
+
+	// Prometheus text format doesn't support exemplars
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body = rec.Body.String()
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("wanted Prometheus content type got %s", rec.Header().Get("Content-Type"))
+	}
+	if strings.Contains(body, "trace_id") || strings.Contains(body, "# EOF") || !strings.Contains(body, "# TYPE gopayloader_requests_total counter\n") {
+		t.Errorf("wanted Prometheus text format without exemplars got\n%s", body)
+	}
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The process metrics Prometheus client libraries export about the process serving them
+const (
+	processCPUSeconds = "process_cpu_seconds_total"
+	processRSSBytes   = "process_resident_memory_bytes"
+)
+
+// Process is the CPU and memory a target reported about itself in one scrape, HasCPU and HasRSS are false when it
+// doesn't export the metric
+type Process struct {
+	At         time.Time
+	CPUSeconds float64
+	RSSBytes   float64
+	HasCPU     bool
+	HasRSS     bool
+}
+
+// ScrapeProcess reads the process metrics from a Prometheus text format endpoint such as a target's /metrics
+func ScrapeProcess(ctx context.Context, client *http.Client, url string) (Process, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return Process{}, fmt.Errorf("metrics: invalid metrics url %s; %v", url, err)
+	}
+	req.Header.Set("Accept", "text/plain")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Process{}, fmt.Errorf("metrics: failed to scrape %s; %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return Process{}, fmt.Errorf("metrics: scraping %s responded %d", url, resp.StatusCode)
+	}
+
+	p := Process{At: time.Now()}
+	s := bufio.NewScanner(resp.Body)
+	// label values can be long, i.e. build info
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		line := s.Text()
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		name, value, ok := sample(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == processCPUSeconds && !p.HasCPU:
+			p.CPUSeconds, p.HasCPU = value, true
+		case name == processRSSBytes && !p.HasRSS:
+			p.RSSBytes, p.HasRSS = value, true
+		}
+	}
+	if err := s.Err(); err != nil {
+		return Process{}, fmt.Errorf("metrics: failed reading %s; %v", url, err)
+	}
+	return p, nil
+}
+
+// sample splits a sample line, name{labels} value [timestamp], into its name and value
+func sample(line string) (string, float64, bool) {
+	end := strings.IndexAny(line, "{ ")
+	if end == -1 {
+		return "", 0, false
+	}
+	name, rest := line[:end], line[end:]
+	if rest[0] == '{' {
+		// quoted label values can contain } so the value is after the last one
+		labelsEnd := strings.LastIndexByte(rest, '}')
+		if labelsEnd == -1 {
+			return "", 0, false
+		}
+		rest = rest[labelsEnd+1:]
+	}
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return "", 0, false
+	}
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return name, value, true
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestScrapeProcess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metrics":
+			// as a Prometheus client library exports them
+			fmt.Fprint(w, `# HELP http_requests_total Requests served.
+# TYPE http_requests_total counter
+http_requests_total{code="200",path="/a}b"} 42
+# HELP process_cpu_seconds_total Total user and system CPU time spent in seconds.
+# TYPE process_cpu_seconds_total counter
+process_cpu_seconds_total 12.5
+# HELP process_resident_memory_bytes Resident memory size in bytes.
+# TYPE process_resident_memory_bytes gauge
+process_resident_memory_bytes 1.048576e+08 1715000000000
+`)
+		case "/other":
+			fmt.Fprint(w, "go_goroutines 8\n")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	p, err := ScrapeProcess(context.Background(), server.Client(), server.URL+"/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.HasCPU || p.CPUSeconds != 12.5 || !p.HasRSS || p.RSSBytes != 100*1024*1024 || p.At.IsZero() {
+		t.Errorf("wanted 12.5 CPU seconds and 100MB resident got %+v", p)
+	}
+
+	p, err = ScrapeProcess(context.Background(), server.Client(), server.URL+"/other")
+	if err != nil || p.HasCPU || p.HasRSS {
+		t.Errorf("wanted no process metrics from a target without them got %+v; %v", p, err)
+	}
+
+	if _, err := ScrapeProcess(context.Background(), server.Client(), server.URL+"/missing"); err == nil {
+		t.Error("wanted error for a target which doesn't serve metrics")
+	}
+}
+
+func TestSample(t *testing.T) {
+	tests := []struct {
+		line  string
+		name  string
+		value float64
+		ok    bool
+	}{
+		{line: "process_cpu_seconds_total 12.5", name: "process_cpu_seconds_total", value: 12.5, ok: true},
+		{line: `http_requests_total{code="200",path="/a}b"} 42 1715000000000`, name: "http_requests_total", value: 42, ok: true},
+		{line: "process_resident_memory_bytes 1.5e+06", name: "process_resident_memory_bytes", value: 1.5e6, ok: true},
+		{line: "no_value"},
+		{line: `unclosed{code="200" 1`},
+		{line: "not_a_number abc"},
+	}
+	for _, tt := range tests {
+		name, value, ok := sample(tt.line)
+		if name != tt.name || value != tt.value || ok != tt.ok {
+			t.Errorf("sample(%q) wanted %s %v %v got %s %v %v", tt.line, tt.name, tt.value, tt.ok, name, value, ok)
+		}
+	}
+}
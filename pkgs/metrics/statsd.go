@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	// statsdPacketSize fits in the UDP payload of an Ethernet frame, the size StatsD clients batch metrics up to
+	statsdPacketSize = 1432
+	// statsdSamples is how many latencies are sent as timers each flush, the rest are sampled out
+	statsdSamples = 100
+)
+
+// StatsD sends request counts and latencies to a StatsD server over UDP every flush. Metrics are batched into as few
+// packets as fit and a uniform sample of the latencies is sent with its sample rate, so the server still counts
+// every request without a packet per request. It isn't synchronised, requests are recorded and flushed by one
+// goroutine
+type StatsD struct {
+	conn      net.Conn
+	completed int64
+	failed    int64
+	// samples is a uniform sample of the latencies since the last flush, seen is how many there were
+	samples []time.Duration
+	seen    int64
+	rand    *rand.Rand
+	packet  []byte
+}
+
+func DialStatsD(addr string) (*StatsD, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("metrics: failed to dial statsd %s; %v", addr, err)
+	}
+	return &StatsD{
+		conn:    conn,
+		samples: make([]time.Duration, 0, statsdSamples),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		packet:  make([]byte, 0, statsdPacketSize),
+	}, nil
+}
+
+// Observe records a completed request
+func (s *StatsD) Observe(latency time.Duration) {
+	s.completed++
+	s.seen++
+	if len(s.samples) < statsdSamples {
+		s.samples = append(s.samples, latency)
+		return
+	}
+	// reservoir sampling keeps every latency equally likely to be sent
+	if i := s.rand.Int63n(s.seen); i < statsdSamples {
+		s.samples[i] = latency
+	}
+}
+
+// Failed records n failed requests
+func (s *StatsD) Failed(n int64) {
+	s.failed += n
+}
+
+// Flush sends the metrics recorded since the last flush, counters aren't sent while they're 0
+func (s *StatsD) Flush() error {
+	defer func() {
+		s.completed, s.failed, s.seen = 0, 0, 0
+		s.samples = s.samples[:0]
+		s.packet = s.packet[:0]
+	}()
+
+	if s.completed > 0 {
+		if err := s.add("gopayloader.requests.completed:" + strconv.FormatInt(s.completed, 10) + "|c"); err != nil {
+			return err
+		}
+	}
+	if s.failed > 0 {
+		if err := s.add("gopayloader.requests.failed:" + strconv.FormatInt(s.failed, 10) + "|c"); err != nil {
+			return err
+		}
+	}
+	rate := ""
+	if s.seen > int64(len(s.samples)) {
+		rate = "|@" + strconv.FormatFloat(float64(len(s.samples))/float64(s.seen), 'g', 4, 64)
+	}
+	for _, latency := range s.samples {
+		ms := strconv.FormatFloat(float64(latency)/float64(time.Millisecond), 'f', 3, 64)
+		if err := s.add("gopayloader.request.latency:" + ms + "|ms" + rate); err != nil {
+			return err
+		}
+	}
+	return s.send()
+}
+
+// add appends a metric to the packet being batched, sending the packet first if the metric doesn't fit
+func (s *StatsD) add(metric string) error {
+	if len(s.packet) > 0 && len(s.packet)+1+len(metric) > statsdPacketSize {
+		if err := s.send(); err != nil {
+			return err
+		}
+	}
+	if len(s.packet) > 0 {
+		s.packet = append(s.packet, '\n')
+	}
+	s.packet = append(s.packet, metric...)
+	return nil
+}
+
+func (s *StatsD) send() error {
+	if len(s.packet) == 0 {
+		return nil
+	}
+	_, err := s.conn.Write(s.packet)
+	s.packet = s.packet[:0]
+	if err != nil {
+		return fmt.Errorf("metrics: failed to send to statsd; %v", err)
+	}
+	return nil
+}
+
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// statsdServer listens for StatsD packets, they're read with readPackets
+func statsdServer(t *testing.T) net.PacketConn {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		pc.Close()
+	})
+	return pc
+}
+
+// readPackets reads packets until none arrive for a while
+func readPackets(t *testing.T, pc net.PacketConn) []string {
+	var packets []string
+	buf := make([]byte, 64*1024)
+	for {
+		pc.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			return packets
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+func TestStatsD_Flush(t *testing.T) {
+	pc := statsdServer(t)
+	s, err := DialStatsD(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 500; i++ {
+		s.Observe(time.Duration(i) * time.Millisecond)
+	}
+	s.Failed(3)
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var completed, failed int64
+	var timers int
+	var sampled float64
+	packets := readPackets(t, pc)
+	for _, p := range packets {
+		if len(p) > statsdPacketSize {
+			t.Fatalf("wanted packets batched to fit a frame got %d bytes", len(p))
+		}
+		for _, m := range strings.Split(p, "\n") {
+			name, val, _ := strings.Cut(m, ":")
+			fields := strings.Split(val, "|")
+			switch name {
+			case "gopayloader.requests.completed":
+				completed, _ = strconv.ParseInt(fields[0], 10, 64)
+				if fields[1] != "c" {
+					t.Errorf("wanted completed requests as a counter got %s", m)
+				}
+			case "gopayloader.requests.failed":
+				failed, _ = strconv.ParseInt(fields[0], 10, 64)
+			case "gopayloader.request.latency":
+				if fields[1] != "ms" || len(fields) != 3 {
+					t.Fatalf("wanted latency as a sampled timer got %s", m)
+				}
+				timers++
+				rate, _ := strconv.ParseFloat(strings.TrimPrefix(fields[2], "@"), 64)
+				sampled += 1 / rate
+			default:
+				t.Errorf("unexpected metric %s", m)
+			}
+		}
+	}
+	if len(packets) < 2 {
+		t.Errorf("wanted the metrics batched into a few packets got %d", len(packets))
+	}
+	if completed != 500 || failed != 3 {
+		t.Errorf("wanted 500 completed and 3 failed requests counted got %d and %d", completed, failed)
+	}
+	// latencies are sampled rather than sent for every request, the sample rates still add up to every request
+	if timers != statsdSamples {
+		t.Errorf("wanted %d latencies sent got %d", statsdSamples, timers)
+	}
+	if sampled < 495 || sampled > 505 {
+		t.Errorf("wanted sample rates accounting for 500 requests got %.1f", sampled)
+	}
+
+	// counters aren't sent while they're 0
+	if err := s.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if packets := readPackets(t, pc); len(packets) != 0 {
+		t.Errorf("wanted nothing sent without requests got %q", packets)
+	}
+}
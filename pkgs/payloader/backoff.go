@@ -0,0 +1,67 @@
+package payloader
+
+import (
+	"context"
+	"github.com/pterm/pterm"
+	"time"
+)
+
+const (
+	// backoffBuckets is how many buckets the error window is split into, the rate is adjusted once per bucket
+	backoffBuckets = 5
+	// backoffDecrease is the factor the rate is multiplied by when the error rate crosses the threshold
+	backoffDecrease = 0.5
+	// backoffIncrease is the fraction of the max rate added back each time the error rate is below the threshold
+	backoffIncrease = 0.1
+	// backoffMinRate stops the rate reaching 0, otherwise no requests would be sent to notice a recovery
+	backoffMinRate = 1
+)
+
+// adaptiveBackoff adjusts the rate limiter using AIMD once per bucket of the error window until ctx is done
+func (p *PayLoader) adaptiveBackoff(ctx context.Context) {
+	tick := time.NewTicker(p.errorWindow.BucketWidth())
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			p.backoff()
+		}
+	}
+}
+
+// backoff multiplicatively decreases the rate when the error rate within the window crosses the threshold and
+// additively increases it back up to the configured rate otherwise
+func (p *PayLoader) backoff() {
+	errRate, total := p.errorWindow.ErrorRate()
+	if total == 0 {
+		// nothing to base a decision on
+		return
+	}
+
+	rate := p.rateLimiter.Rate()
+	if errRate > p.config.BackoffThreshold {
+		rate *= backoffDecrease
+		if rate < backoffMinRate {
+			rate = backoffMinRate
+		}
+		p.rateLimiter.SetRate(rate)
+		// errors from before the decrease shouldn't cause another one
+		p.errorWindow.Reset()
+		if p.config.Verbose {
+			pterm.Warning.Printf("Error rate %.1f%%, backing off to %.2f request/s\n", errRate*100, rate)
+		}
+		return
+	}
+
+	maxRate := p.config.Rate
+	if rate < maxRate {
+		rate += maxRate * backoffIncrease
+		if rate > maxRate {
+			rate = maxRate
+		}
+		p.rateLimiter.SetRate(rate)
+	}
+}
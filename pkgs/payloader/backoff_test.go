@@ -0,0 +1,51 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_Backoff(t *testing.T) {
+	p := NewPayLoader(&config.Config{Rate: 100, BackoffThreshold: 0.1})
+	p.rateLimiter = limiter.NewRate(100)
+	p.errorWindow = limiter.NewWindow(time.Minute, backoffBuckets)
+
+	p.backoff()
+	if p.rateLimiter.Rate() != 100 {
+		t.Errorf("wanted the rate kept without any results got %f", p.rateLimiter.Rate())
+	}
+
+	// the rate halves each time the error rate crosses the threshold, down to the min
+	for _, want := range []float64{50, 25, 12.5, 6.25, 3.125, 1.5625, 1, 1} {
+		p.errorWindow.Record(true)
+		p.backoff()
+		if p.rateLimiter.Rate() != want {
+			t.Errorf("wanted the rate backed off to %f got %f", want, p.rateLimiter.Rate())
+		}
+	}
+	if _, total := p.errorWindow.ErrorRate(); total != 0 {
+		t.Error("wanted the errors cleared after backing off")
+	}
+
+	// then recovers by a tenth of the configured rate at a time without going over it
+	p.rateLimiter.SetRate(75)
+	for _, want := range []float64{85, 95, 100, 100} {
+		p.errorWindow.Record(false)
+		p.backoff()
+		if p.rateLimiter.Rate() != want {
+			t.Errorf("wanted the rate recovered to %f got %f", want, p.rateLimiter.Rate())
+		}
+	}
+
+	// errors at the threshold aren't over it
+	p.errorWindow.Reset()
+	for i := 0; i < 10; i++ {
+		p.errorWindow.Record(i == 0)
+	}
+	p.backoff()
+	if p.rateLimiter.Rate() != 100 {
+		t.Errorf("wanted the rate kept at the threshold got %f", p.rateLimiter.Rate())
+	}
+}
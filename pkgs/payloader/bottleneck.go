@@ -0,0 +1,114 @@
+package payloader
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// bottleneckErrorShare is the share of requests failing in one way before it's taken as the bottleneck
+	bottleneckErrorShare = 0.05
+	// bottleneckIdleUtilisation is how busy connections need to be kept for the client not to be the bottleneck
+	bottleneckIdleUtilisation = 0.5
+)
+
+// bottleneckSignals is what the verdict is based on, all of it is already collected for the results
+type bottleneckSignals struct {
+	conns       int
+	rateLimited bool
+	completed   int64
+	failed      int64
+	rps         float64
+	latency     time.Duration
+	// queued is how much longer requests took from when they were scheduled than from when they were sent, only
+	// with --open-model
+	queued time.Duration
+	// serverErrors are 5xx and 429 responses
+	serverErrors int64
+	// connErrors are failures to connect or connections dropped by the network or target, timeouts are requests
+	// sent which didn't get a response in time
+	connErrors int64
+	timeouts   int64
+}
+
+// computeBottleneck sets a one line guess at what limited the run, see bottleneck
+func (p *PayLoader) computeBottleneck(results *GoPayloaderResults) {
+	s := bottleneckSignals{
+		conns:       int(p.config.Conns),
+		rateLimited: p.config.Rate > 0 || p.config.RatePerConn > 0,
+		completed:   results.CompletedReqs,
+		failed:      results.FailedReqs,
+		rps:         results.RPS.Average,
+		latency:     results.Latency.Average,
+	}
+	if results.TotalLatency != nil {
+		s.queued = results.TotalLatency.Average - results.Latency.Average
+	}
+	for code, count := range results.Responses {
+		if code >= 500 || code == 429 {
+			s.serverErrors += count
+		}
+	}
+	for err, count := range results.Errors {
+		switch {
+		case isConnError(err):
+			s.connErrors += int64(count)
+		case strings.Contains(err, "timeout") || strings.Contains(err, "timed out"):
+			s.timeouts += int64(count)
+		}
+	}
+	results.Bottleneck = bottleneck(s)
+}
+
+// isConnError matches errors opening a connection or the connection being dropped, dial timeouts included
+func isConnError(err string) bool {
+	for _, e := range []string{"dial", "connection refused", "connection reset", "no such host", "broken pipe", "closed connection", "connection closed", "partially written"} {
+		if strings.Contains(err, e) {
+			return true
+		}
+	}
+	return false
+}
+
+// bottleneck checks the signals in order and returns the first match, empty if there's nothing to go on:
+//
+//  1. at least 5% of requests failed to connect or had their connection dropped, likely network-bound
+//  2. at least 5% of requests got 5xx/429 responses or timed out, likely server-bound
+//  3. with --open-model, requests queued for longer than the target took to respond, likely server-bound
+//  4. connections were busy less than half the time (requests per second x average latency / connections, by
+//     Little's law), without a rate that's the client not sending fast enough so likely client-bound
+//  5. otherwise connections were kept busy waiting on responses, likely server-bound, or held back by the rate
+//
+// Connect latency and client CPU aren't measured so a slow network and a slow server can't be told apart by latency
+func bottleneck(s bottleneckSignals) string {
+	total := s.completed + s.failed
+	if total == 0 {
+		return ""
+	}
+
+	if share := float64(s.connErrors) / float64(total); share >= bottleneckErrorShare {
+		return fmt.Sprintf("likely network-bound (%.1f%% of requests failed to connect or were dropped)", share*100)
+	}
+	if share := float64(s.serverErrors+s.timeouts) / float64(total); share >= bottleneckErrorShare {
+		return fmt.Sprintf("likely server-bound (%.1f%% of requests got 5xx/429 responses or timed out)", share*100)
+	}
+	if s.completed == 0 || s.conns == 0 {
+		return ""
+	}
+	if s.queued > s.latency {
+		return fmt.Sprintf("likely server-bound (requests queued %s on average, longer than the target took to respond)", s.queued)
+	}
+
+	utilisation := s.rps * s.latency.Seconds() / float64(s.conns)
+	if utilisation < bottleneckIdleUtilisation {
+		if s.rateLimited {
+			return "held back by the rate limit (target kept up)"
+		}
+		return fmt.Sprintf("likely client-bound (connections were waiting on the client %.0f%% of the time)", (1-utilisation)*100)
+	}
+	if s.rateLimited {
+		return "likely server-bound (connections were kept busy at the rate limit)"
+	}
+	return "likely server-bound (connections were kept busy waiting on responses)"
+}
@@ -0,0 +1,76 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"testing"
+	"time"
+)
+
+func TestBottleneck(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals bottleneckSignals
+		want    string
+	}{
+		{name: "no requests", signals: bottleneckSignals{conns: 10}, want: ""},
+		{
+			name:    "connection errors",
+			signals: bottleneckSignals{conns: 10, completed: 90, failed: 10, connErrors: 10, rps: 1000, latency: 10 * time.Millisecond},
+			want:    "likely network-bound (10.0% of requests failed to connect or were dropped)",
+		},
+		{
+			name:    "server errors and timeouts",
+			signals: bottleneckSignals{conns: 10, completed: 97, failed: 3, serverErrors: 4, timeouts: 3, rps: 1000, latency: 10 * time.Millisecond},
+			want:    "likely server-bound (7.0% of requests got 5xx/429 responses or timed out)",
+		},
+		{
+			name:    "few errors",
+			signals: bottleneckSignals{conns: 10, completed: 99, failed: 1, connErrors: 1, serverErrors: 1, rps: 1000, latency: 10 * time.Millisecond},
+			want:    "likely server-bound (connections were kept busy waiting on responses)",
+		},
+		{
+			name:    "queued",
+			signals: bottleneckSignals{conns: 1, rateLimited: true, completed: 100, rps: 20, latency: 50 * time.Millisecond, queued: 200 * time.Millisecond},
+			want:    "likely server-bound (requests queued 200ms on average, longer than the target took to respond)",
+		},
+		{
+			// 100 req/s at 10ms keeps 1 of the 10 connections busy
+			name:    "idle connections",
+			signals: bottleneckSignals{conns: 10, completed: 100, rps: 100, latency: 10 * time.Millisecond},
+			want:    "likely client-bound (connections were waiting on the client 90% of the time)",
+		},
+		{
+			name:    "idle connections rate limited",
+			signals: bottleneckSignals{conns: 10, rateLimited: true, completed: 100, rps: 100, latency: 10 * time.Millisecond},
+			want:    "held back by the rate limit (target kept up)",
+		},
+		{
+			name:    "busy connections rate limited",
+			signals: bottleneckSignals{conns: 10, rateLimited: true, completed: 100, rps: 900, latency: 10 * time.Millisecond},
+			want:    "likely server-bound (connections were kept busy at the rate limit)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bottleneck(tt.signals); got != tt.want {
+				t.Errorf("wanted %q got %q", tt.want, got)
+			}
+		})
+	}
+
+	// errors are categorised from their keys
+	p := NewPayLoader(&config.Config{Conns: 10})
+	results := &GoPayloaderResults{
+		CompletedReqs: 90,
+		FailedReqs:    10,
+		Errors: map[string]uint{
+			"dial tcp 127.0.0.1:1: connect: connection refused": 6,
+			"connection reset by peer":                          2,
+			"timeout":                                           2,
+		},
+	}
+	p.computeBottleneck(results)
+	if want := "likely network-bound (8.0% of requests failed to connect or were dropped)"; results.Bottleneck != want {
+		t.Errorf("wanted %q got %q", want, results.Bottleneck)
+	}
+}
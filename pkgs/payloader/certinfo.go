@@ -0,0 +1,140 @@
+package payloader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/pterm/pterm"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// certExpiryWarning is how close to expiring the target's cert has to be for --cert-info to warn about it
+const certExpiryWarning = 30 * 24 * time.Hour
+
+// CertInfo is the target's certificate as reported by --cert-info
+type CertInfo struct {
+	Subject   string
+	Issuer    string
+	DNSNames  []string
+	NotBefore time.Time
+	NotAfter  time.Time
+	// VerifyErr is why the cert isn't trusted for the target host, nil if it is
+	VerifyErr error
+}
+
+// FetchCertInfo does a TLS handshake with the target to get its certificate. The handshake doesn't verify the cert
+// so details are still reported for an untrusted or expired one, it's verified against the system roots, or
+// --ca-cert, afterwards
+func FetchCertInfo(conf *config.Config) (*CertInfo, error) {
+	reqURI := conf.ReqURI
+	if conf.RawPath {
+		reqURI, _ = http_clients.SplitRawURI(conf.ReqURI)
+	}
+	u, err := url.Parse(reqURI)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Hostname()
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: true,
+	}
+	clientCert, err := firstClientCert(conf)
+	if err != nil {
+		return nil, err
+	}
+	if clientCert.Cert != "" {
+		// servers requiring client certs would fail the handshake without it
+		cert, err := tls.LoadX509KeyPair(clientCert.Cert, clientCert.Key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	dialer := &net.Dialer{Timeout: conf.ReadTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), tlsConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed TLS handshake to get certificate; %v", err)
+	}
+	defer conn.Close()
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return nil, errors.New("target didn't send a certificate")
+	}
+
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	opts := x509.VerifyOptions{DNSName: host, Intermediates: intermediates}
+	if conf.CACert != "" {
+		if opts.Roots, err = http_clients.LoadCertPool(conf.CACert); err != nil {
+			return nil, err
+		}
+	}
+	_, verifyErr := leaf.Verify(opts)
+
+	return &CertInfo{
+		Subject:   leaf.Subject.String(),
+		Issuer:    leaf.Issuer.String(),
+		DNSNames:  leaf.DNSNames,
+		NotBefore: leaf.NotBefore,
+		NotAfter:  leaf.NotAfter,
+		VerifyErr: verifyErr,
+	}, nil
+}
+
+// ReportCertInfo prints the target's certificate details before running, warning if it's untrusted, expired or
+// expires soon
+func ReportCertInfo(conf *config.Config) (*CertInfo, error) {
+	info, err := FetchCertInfo(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	pterm.Info.Printf("Target certificate subject: %s\n", info.Subject)
+	pterm.Info.Printf("Target certificate issuer: %s\n", info.Issuer)
+	if len(info.DNSNames) > 0 {
+		pterm.Info.Printf("Target certificate DNS names: %s\n", strings.Join(info.DNSNames, ", "))
+	}
+	pterm.Info.Printf("Target certificate valid from %s until %s\n", info.NotBefore.Format(time.RFC1123), info.NotAfter.Format(time.RFC1123))
+
+	now := time.Now()
+	switch {
+	case now.After(info.NotAfter):
+		pterm.Warning.Printf("Target certificate expired %s ago\n", now.Sub(info.NotAfter).Round(time.Second))
+	case info.NotAfter.Sub(now) < certExpiryWarning:
+		pterm.Warning.Printf("Target certificate expires in %s\n", info.NotAfter.Sub(now).Round(time.Second))
+	}
+	if info.VerifyErr != nil {
+		pterm.Warning.Printf("Target certificate isn't trusted; %v\n", info.VerifyErr)
+	}
+	return info, nil
+}
+
+// firstClientCert is the --mtls-cert pair or the first of --mtls-cert-dir, for one off requests outside the run's
+// connections. It's empty without mTLS
+func firstClientCert(conf *config.Config) (http_clients.CertPair, error) {
+	if conf.MTLSCertDir == "" {
+		return http_clients.CertPair{Cert: conf.MTLSCert, Key: conf.MTLSKey}, nil
+	}
+	pairs, err := http_clients.LoadCertDir(conf.MTLSCertDir)
+	if err != nil {
+		return http_clients.CertPair{}, err
+	}
+	return pairs[0], nil
+}
@@ -0,0 +1,79 @@
+package payloader
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/pterm/pterm"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportCertInfo(t *testing.T) {
+	crt, err := os.ReadFile(filepath.Join("..", "..", "test", "server.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(crt)
+	want, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = tlsConfig()
+	server.StartTLS()
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &bytes.Buffer{}
+	pterm.SetDefaultOutput(out)
+	pterm.DisableStyling()
+	defer func() {
+		pterm.SetDefaultOutput(os.Stdout)
+		pterm.EnableStyling()
+	}()
+
+	info, err := ReportCertInfo(&config.Config{
+		ReqURI:      "https://localhost:" + port,
+		CertInfo:    true,
+		ReadTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Subject != want.Subject.String() || info.Issuer != want.Issuer.String() || !info.NotAfter.Equal(want.NotAfter) {
+		t.Errorf("wanted cert %s issued by %s expiring %s got %+v", want.Subject, want.Issuer, want.NotAfter, info)
+	}
+	// the test cert is self signed by a CA which isn't in the system roots
+	if info.VerifyErr == nil {
+		t.Error("wanted test cert to be untrusted")
+	}
+
+	printed := out.String()
+	for _, s := range []string{
+		"Target certificate subject: " + want.Subject.String(),
+		"Target certificate issuer: " + want.Issuer.String(),
+		"Target certificate DNS names: localhost",
+		want.NotAfter.Format(time.RFC1123),
+		"Target certificate isn't trusted",
+	} {
+		if !strings.Contains(printed, s) {
+			t.Errorf("wanted %q printed got %s", s, printed)
+		}
+	}
+	if time.Now().After(want.NotAfter) && !strings.Contains(printed, "Target certificate expired") {
+		t.Errorf("wanted expired warning printed got %s", printed)
+	}
+}
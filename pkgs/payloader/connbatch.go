@@ -0,0 +1,12 @@
+package payloader
+
+import "time"
+
+// connBatchDelay is how long the connection waits before opening. With --conn-batch-size connections open in batches
+// --conn-batch-interval apart so thousands of connections don't all dial at once, otherwise they open straight away
+func (p *PayLoader) connBatchDelay(conn uint) time.Duration {
+	if p.config.ConnBatchSize == 0 {
+		return 0
+	}
+	return p.config.ConnBatchInterval * time.Duration(conn/p.config.ConnBatchSize)
+}
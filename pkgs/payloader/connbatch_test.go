@@ -0,0 +1,22 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_ConnBatchDelay(t *testing.T) {
+	p := NewPayLoader(&config.Config{Conns: 6})
+	if got := p.connBatchDelay(5); got != 0 {
+		t.Errorf("wanted every connection to open straight away without batches got %s", got)
+	}
+
+	// 3 batches of 2, 200ms apart
+	p = NewPayLoader(&config.Config{Conns: 6, ConnBatchSize: 2, ConnBatchInterval: 200 * time.Millisecond})
+	for conn, want := range []time.Duration{0, 0, 200 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 400 * time.Millisecond} {
+		if got := p.connBatchDelay(uint(conn)); got != want {
+			t.Errorf("wanted connection %d to open after %s got %s", conn, want, got)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"sort"
+	"time"
+)
+
+// minOutlierSamples is the fewest latencies a connection needs before its median is compared, a handful of requests
+// on a connection that started late say nothing about where it's routed
+const minOutlierSamples = 10
+
+// ConnOutlier is a connection whose median latency was at least --conn-outlier-factor times the median of the run,
+// i.e. one pinned to a degraded backend behind a load balancer. The latencies are of a sample of its requests
+type ConnOutlier struct {
+	WorkerID int
+	Requests int64
+	Samples  int
+	Median   time.Duration
+	P90      time.Duration
+	Max      time.Duration
+	// Factor is how many times the run's median the connection's median was
+	Factor float64
+}
+
+// latencySamples is how many latencies each connection keeps to compare with --conn-outlier-factor, none without it
+func (p *PayLoader) latencySamples() int {
+	if p.config.ConnOutlierFactor <= 0 {
+		return 0
+	}
+	if p.config.MaxSamples > 0 {
+		return p.config.MaxSamples
+	}
+	return worker.DefaultLatencySamples
+}
+
+// computeConnOutliers compares each connection's median latency to the run's, connections are listed slowest first
+func (p *PayLoader) computeConnOutliers(workers []worker.Worker, results *GoPayloaderResults) {
+	if p.config.ConnOutlierFactor <= 0 || p.latencies == nil || p.latencies.total == 0 {
+		return
+	}
+	median := p.latencies.percentile(50)
+	if median > results.Latency.Max {
+		median = results.Latency.Max
+	}
+	if median <= 0 {
+		return
+	}
+
+	for id, w := range workers {
+		stats := w.Stats()
+		if len(stats.LatencySample) < minOutlierSamples {
+			continue
+		}
+		sample := append([]time.Duration(nil), stats.LatencySample...)
+		sort.Slice(sample, func(i, j int) bool {
+			return sample[i] < sample[j]
+		})
+		connMedian := sample[len(sample)/2]
+		factor := float64(connMedian) / float64(median)
+		if factor < p.config.ConnOutlierFactor {
+			continue
+		}
+		results.ConnOutliers = append(results.ConnOutliers, ConnOutlier{
+			WorkerID: id,
+			Requests: stats.CompletedReqs,
+			Samples:  len(sample),
+			Median:   connMedian,
+			P90:      sample[len(sample)*9/10],
+			Max:      sample[len(sample)-1],
+			Factor:   factor,
+		})
+	}
+	sort.Slice(results.ConnOutliers, func(i, j int) bool {
+		return results.ConnOutliers[i].Factor > results.ConnOutliers[j].Factor
+	})
+}
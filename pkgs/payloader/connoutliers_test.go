@@ -0,0 +1,76 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_LatencySamples(t *testing.T) {
+	if n := NewPayLoader(&config.Config{}).latencySamples(); n != 0 {
+		t.Errorf("wanted no latencies sampled without conn outlier factor got %d", n)
+	}
+	if n := NewPayLoader(&config.Config{ConnOutlierFactor: 5}).latencySamples(); n != worker.DefaultLatencySamples {
+		t.Errorf("wanted %d latencies sampled got %d", worker.DefaultLatencySamples, n)
+	}
+	if n := NewPayLoader(&config.Config{ConnOutlierFactor: 5, MaxSamples: 16}).latencySamples(); n != 16 {
+		t.Errorf("wanted max samples of 16 latencies sampled got %d", n)
+	}
+}
+
+func TestPayLoader_ComputeConnOutliers(t *testing.T) {
+	sample := func(n int, latency time.Duration) []time.Duration {
+		s := make([]time.Duration, n)
+		for i := range s {
+			s[i] = latency + time.Duration(i)*time.Microsecond
+		}
+		return s
+	}
+	// the first connection is as if it were pinned to a degraded backend
+	workers := []worker.Worker{
+		&testWorker{stats: worker.Stats{CompletedReqs: 20, LatencySample: sample(20, 40*time.Millisecond)}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 20, LatencySample: sample(20, 2*time.Millisecond)}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 20, LatencySample: sample(20, 2*time.Millisecond)}},
+		// too few samples to say where it's routed
+		&testWorker{stats: worker.Stats{CompletedReqs: 5, LatencySample: sample(5, 40*time.Millisecond)}},
+	}
+
+	tests := []struct {
+		name   string
+		factor float64
+		want   int
+	}{
+		{name: "not checked", want: 0},
+		{name: "slow connection", factor: 5, want: 1},
+		{name: "below factor", factor: 50, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPayLoader(&config.Config{ConnOutlierFactor: tt.factor})
+			p.latencies = newLatencyHistogram()
+			results := &GoPayloaderResults{}
+			for _, w := range workers {
+				for _, l := range w.Stats().LatencySample {
+					p.latencies.record(l)
+					results.Latency.observe(l)
+				}
+			}
+
+			p.computeConnOutliers(workers, results)
+			if len(results.ConnOutliers) != tt.want {
+				t.Fatalf("wanted %d outliers got %+v", tt.want, results.ConnOutliers)
+			}
+			if tt.want == 0 {
+				return
+			}
+			o := results.ConnOutliers[0]
+			if o.WorkerID != 0 || o.Requests != 20 || o.Samples != 20 || o.Median < 40*time.Millisecond || o.Factor < 5 {
+				t.Errorf("wanted connection 0 with a median of at least 40ms got %+v", o)
+			}
+			if o.P90 < o.Median || o.Max < o.P90 {
+				t.Errorf("wanted max >= p90 >= median got %+v", o)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/pterm/pterm"
+	"time"
+)
+
+// CapacityEstimate is the throughput --estimate projects for a run from the latency of a few probe requests. It's a
+// rough closed model estimate: each connection waits for its response before sending the next request so it can send
+// at most one request per latency, assuming the target keeps the same latency under the full load
+type CapacityEstimate struct {
+	Probes  int64
+	Failed  int64
+	Latency time.Duration
+	Conns   uint
+	RPS     float64
+	// RateLimit is the --rate or --rate-per-conn the RPS was capped to, 0 if it wasn't
+	RateLimit float64
+}
+
+// EstimateCapacity sends --estimate-probes requests one after the other on a single connection and projects the
+// throughput of the configured connections from their average latency, nothing else is sent
+func EstimateCapacity(conf *config.Config) (*CapacityEstimate, error) {
+	pterm.Info.Printf("Sending %d probe requests to estimate throughput\n", conf.EstimateProbes)
+	res, err := probeRun(*conf, int64(conf.EstimateProbes))
+	if err != nil {
+		return nil, err
+	}
+	est := &CapacityEstimate{
+		Probes:  res.CompletedReqs + res.FailedReqs,
+		Failed:  res.FailedReqs,
+		Latency: res.Latency.Average,
+		Conns:   conf.Conns,
+	}
+	limit := conf.Rate
+	if conf.RatePerConn > 0 {
+		limit = conf.RatePerConn * float64(conf.Conns)
+	}
+	est.RPS = projectRPS(est.Latency, conf.Conns)
+	if limit > 0 && limit < est.RPS {
+		est.RPS, est.RateLimit = limit, limit
+	}
+	return est, nil
+}
+
+// projectRPS is the requests per second conns connections can send when each request takes latency
+func projectRPS(latency time.Duration, conns uint) float64 {
+	if latency <= 0 {
+		return 0
+	}
+	return float64(conns) / latency.Seconds()
+}
@@ -0,0 +1,91 @@
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProjectRPS(t *testing.T) {
+	// a connection waiting 50ms for each response sends 20 a second
+	for _, tt := range []struct {
+		latency time.Duration
+		conns   uint
+		want    float64
+	}{
+		{latency: 50 * time.Millisecond, conns: 1, want: 20},
+		{latency: 50 * time.Millisecond, conns: 10, want: 200},
+		{latency: 2 * time.Millisecond, conns: 8, want: 4000},
+		{latency: 0, conns: 8, want: 0},
+	} {
+		if got := projectRPS(tt.latency, tt.conns); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s with %d conns: wanted %g RPS got %g", tt.latency, tt.conns, tt.want, got)
+		}
+	}
+}
+
+func TestEstimateCapacity(t *testing.T) {
+	var reqs atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name string
+		rate float64
+	}{
+		{name: "latency"},
+		{name: "rate", rate: 100},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			reqs.Store(0)
+			conf := &config.Config{
+				Ctx:            context.Background(),
+				ReqURI:         server.URL,
+				ReqTarget:      100000,
+				Conns:          10,
+				Rate:           tt.rate,
+				ReadTimeout:    5 * time.Second,
+				WriteTimeout:   5 * time.Second,
+				Method:         "GET",
+				Client:         worker.HttpClientFastHTTP1,
+				VerboseTicker:  time.Second,
+				Estimate:       true,
+				EstimateProbes: 5,
+			}
+			if err := conf.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			est, err := EstimateCapacity(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// only the probes are sent
+			if reqs.Load() != 5 || est.Probes != 5 || est.Failed != 0 {
+				t.Errorf("wanted 5 probe requests sent got %d, %+v", reqs.Load(), est)
+			}
+			if est.Latency < 20*time.Millisecond || est.Conns != 10 {
+				t.Errorf("wanted a probe latency of at least 20ms for 10 conns got %+v", est)
+			}
+			// 10 connections at a little over 20ms each is a little under 500 RPS, unless the rate is lower
+			want := 10 / est.Latency.Seconds()
+			if tt.rate > 0 {
+				want = tt.rate
+			}
+			if math.Abs(est.RPS-want) > 1e-9 || est.RPS > 500 {
+				t.Errorf("wanted projected RPS %g got %g", want, est.RPS)
+			}
+			if (tt.rate > 0) != (est.RateLimit > 0) {
+				t.Errorf("wanted rate limit %g got %g", tt.rate, est.RateLimit)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build linux
+
+package payloader
+
+import "golang.org/x/sys/unix"
+
+const (
+	ioctlReadTermios  = unix.TCGETS
+	ioctlWriteTermios = unix.TCSETS
+)
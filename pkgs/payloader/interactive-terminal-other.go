@@ -0,0 +1,9 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package payloader
+
+import "errors"
+
+func cbreak(fd int) (func(), error) {
+	return nil, errors.New("single keypresses not supported on this platform")
+}
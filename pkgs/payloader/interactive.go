@@ -0,0 +1,89 @@
+package payloader
+
+import (
+	"bufio"
+	"context"
+	"github.com/pterm/pterm"
+	"io"
+	"os"
+	"sync"
+)
+
+// rateStep is the fraction the rate is changed by on each keypress
+const rateStep = 0.1
+
+var (
+	stdinKeysOnce sync.Once
+	stdinKeys     <-chan byte
+)
+
+// startInteractive reads keypresses from stdin to change the rate and number of active connections while running,
+// returns a func to restore the terminal once finished
+func (p *PayLoader) startInteractive(ctx context.Context) func() {
+	restore, err := cbreak(int(os.Stdin.Fd()))
+	if err != nil {
+		pterm.Warning.Printf("Unable to read single keypresses from terminal, press enter after each key; %v \n", err)
+		restore = func() {}
+	}
+
+	pterm.Info.Println("Interactive mode; press + or - to change rate, ] or [ to add or remove connections")
+	// a read from stdin can't be interrupted, so a single reader is shared by every run of the process rather than
+	// leaving one blocked after each run to swallow the keys meant for the next
+	stdinKeysOnce.Do(func() {
+		stdinKeys = readKeys(os.Stdin)
+	})
+	go p.interactive(ctx, stdinKeys)
+	return restore
+}
+
+// readKeys sends every byte read from in until it's closed or fails
+func readKeys(in io.Reader) <-chan byte {
+	keys := make(chan byte)
+	go func() {
+		defer close(keys)
+		r := bufio.NewReader(in)
+		for {
+			key, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			keys <- key
+		}
+	}()
+	return keys
+}
+
+// interactive handles keys until ctx is done or there are no more
+func (p *PayLoader) interactive(ctx context.Context, keys <-chan byte) {
+	for {
+		select {
+		case <-ctx.Done():
+			// workers finished
+			return
+		case key, ok := <-keys:
+			if !ok {
+				return
+			}
+			p.handleKey(key)
+		}
+	}
+}
+
+func (p *PayLoader) handleKey(key byte) {
+	switch key {
+	case '+', '=':
+		rate := p.rateLimiter.Rate() * (1 + rateStep)
+		p.rateLimiter.SetRate(rate)
+		pterm.Info.Printf("Rate increased to %.2f request/s\n", rate)
+	case '-', '_':
+		rate := p.rateLimiter.Rate() * (1 - rateStep)
+		p.rateLimiter.SetRate(rate)
+		pterm.Info.Printf("Rate decreased to %.2f request/s\n", rate)
+	case ']':
+		active := p.connLimiter.SetActive(p.connLimiter.Active() + 1)
+		pterm.Info.Printf("%d/%d connections active\n", active, p.connLimiter.Max())
+	case '[':
+		active := p.connLimiter.SetActive(p.connLimiter.Active() - 1)
+		pterm.Info.Printf("%d/%d connections active\n", active, p.connLimiter.Max())
+	}
+}
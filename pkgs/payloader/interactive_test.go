@@ -0,0 +1,50 @@
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_Interactive(t *testing.T) {
+	p := NewPayLoader(&config.Config{})
+	p.rateLimiter = limiter.NewRate(100)
+	p.connLimiter = limiter.NewConns(4, 4)
+
+	p.interactive(context.Background(), readKeys(strings.NewReader("++-[[[]")))
+
+	if rate := p.rateLimiter.Rate(); math.Abs(rate-108.9) > 0.001 {
+		t.Errorf("wanted rate 108.9 got %f", rate)
+	}
+	if active := p.connLimiter.Active(); active != 2 {
+		t.Errorf("wanted 2 active connections got %d", active)
+	}
+
+	// can't go above connections started with or below 1
+	p.interactive(context.Background(), readKeys(strings.NewReader("]]]]]")))
+	if active := p.connLimiter.Active(); active != 4 {
+		t.Errorf("wanted 4 active connections got %d", active)
+	}
+	p.interactive(context.Background(), readKeys(strings.NewReader("[[[[[")))
+	if active := p.connLimiter.Active(); active != 1 {
+		t.Errorf("wanted 1 active connection got %d", active)
+	}
+
+	// stops with the run even though more keys could still be pressed
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.interactive(ctx, make(chan byte))
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("wanted interactive mode to stop once the run is done")
+	}
+}
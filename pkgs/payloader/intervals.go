@@ -0,0 +1,110 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/intervals"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+	"sort"
+	"time"
+)
+
+// intervalRows sums up the requests of each --ticker interval into a row of --output-interval-csv. Completed requests
+// are recorded as their stats arrive, failed requests are counted from the workers' stats like the progress output
+type intervalRows struct {
+	out        *intervals.Writer
+	workers    []worker.Worker
+	start      time.Time
+	latencies  *latencyHistogram
+	max        time.Duration
+	reqs       int64
+	bytes      int64
+	prevFailed int64
+	// bytesPerSec is the series of every interval flushed, for Bandwidth
+	bytesPerSec []float64
+	// stopped is set once a row fails to write, the run carries on without the rest
+	stopped bool
+}
+
+// Bandwidth is the bytes per second of the requests and responses completed in each --ticker interval, dips and
+// plateaus the average throughput hides show up in the series
+type Bandwidth struct {
+	// Series is every interval in order, the last cut short by the end of the run. Min, Median and Max leave the last
+	// out as its few requests over a short time are noise, unless it's the only one
+	Series []float64
+	Min    float64
+	Median float64
+	Max    float64
+}
+
+func newIntervalRows(out *intervals.Writer, workers []worker.Worker) *intervalRows {
+	return &intervalRows{out: out, workers: workers, latencies: newLatencyHistogram()}
+}
+
+func (r *intervalRows) record(latency time.Duration, bytes int64) {
+	r.latencies.record(latency)
+	if latency > r.max {
+		r.max = latency
+	}
+	r.reqs++
+	r.bytes += bytes
+}
+
+// flush writes the interval ending now and starts the next
+func (r *intervalRows) flush(now time.Time) {
+	var failed int64
+	for _, w := range r.workers {
+		failed += w.Failed()
+	}
+	row := intervals.Row{
+		Time:     now,
+		Requests: r.reqs,
+		Errors:   failed - r.prevFailed,
+		Bytes:    r.bytes,
+	}
+	if elapsed := now.Sub(r.start).Seconds(); elapsed > 0 {
+		row.RPS = float64(r.reqs) / elapsed
+		row.BytesPerSec = float64(r.bytes) / elapsed
+	}
+	r.bytesPerSec = append(r.bytesPerSec, row.BytesPerSec)
+	if r.reqs > 0 {
+		// the bucket's upper bound can be past the slowest request
+		row.P50, row.P99 = r.latencies.percentile(50), r.latencies.percentile(99)
+		if row.P50 > r.max {
+			row.P50 = r.max
+		}
+		if row.P99 > r.max {
+			row.P99 = r.max
+		}
+	}
+
+	r.start, r.prevFailed = now, failed
+	r.reqs, r.bytes, r.max = 0, 0, 0
+	r.latencies = newLatencyHistogram()
+
+	if r.stopped {
+		return
+	}
+	if err := r.out.Write(row); err != nil {
+		pterm.Warning.Printf("Stopped writing interval rows; %v\n", err)
+		r.stopped = true
+	}
+}
+
+// bandwidth is the distribution of bytes per second over the intervals flushed, nil before any were
+func (r *intervalRows) bandwidth() *Bandwidth {
+	if len(r.bytesPerSec) == 0 {
+		return nil
+	}
+	full := r.bytesPerSec
+	if len(full) > 1 {
+		full = full[:len(full)-1]
+	}
+	sorted := append([]float64(nil), full...)
+	sort.Float64s(sorted)
+	return &Bandwidth{
+		Series: r.bytesPerSec,
+		Min:    sorted[0],
+		Median: sorted[len(sorted)/2],
+		Max:    sorted[len(sorted)-1],
+	}
+}
@@ -0,0 +1,72 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/intervals"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIntervalRows_Flush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intervals.csv")
+	out, err := intervals.Create(path, units.Latency{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &testWorker{}
+	r := newIntervalRows(out, []worker.Worker{w})
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	r.start = start
+
+	for i := 0; i < 20; i++ {
+		r.record(10*time.Millisecond, 1300)
+	}
+	w.stats.FailedReqs = 2
+	r.flush(start.Add(200 * time.Millisecond))
+	// the last interval is cut short by the end of the run
+	r.record(5*time.Millisecond, 1300)
+	w.stats.FailedReqs = 3
+	r.flush(start.Add(250 * time.Millisecond))
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// latencies are capped at the slowest request rather than the bucket's upper bound, errors are the new failures
+	want := []string{
+		"timestamp,requests,rps,errors,p50_ms,p99_ms,bytes,bytes_per_sec",
+		"2024-05-01T10:00:00.200Z,20,100.00,2,10.000,10.000,26000,130000.00",
+		"2024-05-01T10:00:00.250Z,1,20.00,1,5.000,5.000,1300,26000.00",
+	}
+	if got := strings.Split(strings.TrimSpace(string(b)), "\n"); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestIntervalRows_Bandwidth(t *testing.T) {
+	r := &intervalRows{}
+	if r.bandwidth() != nil {
+		t.Error("wanted no bandwidth before an interval was flushed")
+	}
+
+	// the short last interval is left out of the distribution but kept in the series
+	r.bytesPerSec = []float64{130000, 90000, 150000, 120000, 26000}
+	want := &Bandwidth{Series: r.bytesPerSec, Min: 90000, Median: 130000, Max: 150000}
+	if got := r.bandwidth(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted bandwidth %+v got %+v", want, got)
+	}
+
+	r.bytesPerSec = []float64{26000}
+	want = &Bandwidth{Series: r.bytesPerSec, Min: 26000, Median: 26000, Max: 26000}
+	if got := r.bandwidth(); !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted the only interval used got %+v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package payloader
+
+import (
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/pterm/pterm"
+	"net"
+	"net/http"
+	"time"
+)
+
+// serveMetrics serves the metrics endpoint for Prometheus to scrape while running, until the returned func is called
+func (p *PayLoader) serveMetrics() (func(), error) {
+	ln, err := net.Listen("tcp", p.config.MetricsAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics on %s; %v", p.config.MetricsAddr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", p.metrics)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go srv.Serve(ln)
+
+	pterm.Info.Printf("Serving metrics on http://%s/metrics\n", ln.Addr())
+	return func() {
+		srv.Close()
+	}, nil
+}
+
+// Metrics returns the recorder behind the metrics endpoint, nil if it isn't enabled
+func (p *PayLoader) Metrics() *metrics.Recorder {
+	return p.metrics
+}
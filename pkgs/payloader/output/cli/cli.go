@@ -2,37 +2,100 @@ package cli
 
 import (
 	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
 	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
 	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/pterm/pterm"
 	"os"
+	"sort"
 	"strconv"
 	"time"
 )
 
-func Display(results *payloader.GoPayloaderResults) {
+// Display shows the results with latencies in the unit and precision of format
+func Display(results *payloader.GoPayloaderResults, format units.Latency) {
 	pterm.Success.Printf("Gopayloader results \n\n")
 	fmt.Println("")
+	render(results, format, os.Stdout)
+}
 
+func render(results *payloader.GoPayloaderResults, format units.Latency, out *os.File) {
 	t := table.NewWriter()
-	t.SetOutputMirror(os.Stdout)
+	t.SetOutputMirror(out)
+	colors := newPalette(out)
 
-	displayOverview(results, t)
+	displayOverview(results, format, t, colors)
 	displayRPS(results.RPS, t)
 	displayReqSize(results.ReqByteSize, t)
 	displayRespSize(results.RespByteSize, t)
-	displayLatency(results.Latency, t)
+	if results.DownloadedBytes > 0 {
+		displayDownload(results, t)
+	}
+	if results.Bandwidth != nil {
+		displayBandwidth(results.Bandwidth, t)
+	}
+	if results.TotalLatency != nil {
+		// both are shown as service latency alone hides time requests queued when the target fell behind
+		displayLatency("service latency", results.Latency, format, t)
+		displayPercentiles("service latency", results.Latency, results.PercentilesWarning, results.LatencySLOs, format, t, colors)
+		displayLatency("total latency", *results.TotalLatency, format, t)
+		// latency SLOs are of service latency
+		displayPercentiles("total latency", *results.TotalLatency, "", nil, format, t, colors)
+	} else {
+		displayLatency("latency", results.Latency, format, t)
+		displayPercentiles("latency", results.Latency, results.PercentilesWarning, results.LatencySLOs, format, t, colors)
+	}
+	if results.TTFB != nil {
+		displayLatency("TTFB", *results.TTFB, format, t)
+		displayPercentiles("TTFB", *results.TTFB, "", nil, format, t, colors)
+	}
 	displayResponseCodes(results.Responses, t)
+	if len(results.StatusBands) > 0 {
+		displayStatusBands(results.StatusBands, format, t)
+	}
+
+	if len(results.Targets) > 0 {
+		displayTargets(results.Targets, format, t)
+	}
+
+	if len(results.ConnOutliers) > 0 {
+		displayConnOutliers(results.ConnOutliers, format, t)
+	}
+
+	if results.TargetUsage != nil {
+		displayTargetUsage(results.TargetUsage, t)
+	}
+
+	if results.TLSHandshakes > 0 {
+		displayTLS(results, t)
+	}
+
+	if results.TCP != nil {
+		displayTCP(results.TCP, t)
+	}
 
 	if len(results.Errors) > 0 {
 		displayErrors(results.Errors, t)
 	}
 
+	if results.DivergedReqs > 0 {
+		displayDivergences(results.DivergedReqs, results.Divergences, t)
+	}
+
+	if len(results.Slowest) > 0 {
+		displaySlowest(results.Slowest, format, t)
+	}
+
 	t.Render()
 }
 
-func displayOverview(results *payloader.GoPayloaderResults, t table.Writer) {
+func displayOverview(results *payloader.GoPayloaderResults, format units.Latency, t table.Writer, colors palette) {
+	successRate := fmt.Sprintf("%.3f%%", results.SuccessRate)
+	if results.SLO != nil {
+		successRate = colors.successRate(results.SLO, results.SuccessRate)
+	}
 	t.AppendHeader(table.Row{"Metric", "Result"})
 	t.AppendRows([]table.Row{
 		{"Total time", results.Total},
@@ -40,10 +103,73 @@ func displayOverview(results *payloader.GoPayloaderResults, t table.Writer) {
 		{"End time", results.End.Format(time.RFC1123)},
 		{"Completed requests", results.CompletedReqs},
 		{"Failed requests", results.FailedReqs},
+		{"Success rate", successRate},
 	})
+	if results.Paused > 0 {
+		t.AppendRow(table.Row{"Paused time", results.Paused})
+	}
+	if results.Bottleneck != "" {
+		t.AppendRow(table.Row{"Bottleneck", results.Bottleneck})
+	}
+	if results.RetriedResets > 0 {
+		t.AppendRow(table.Row{"Retries after connection reset", results.RetriedResets})
+	}
+	if results.RetryAfterPauses > 0 {
+		t.AppendRow(table.Row{"Paused for Retry-After", fmt.Sprintf("%d times, %s in total", results.RetryAfterPauses, results.RetryAfterWait)})
+	}
+	if bp := results.Backpressure; bp != nil {
+		t.AppendRow(table.Row{"Peak in flight", fmt.Sprintf("%d of %d", bp.Peak, bp.Max)})
+		if bp.Dropped > 0 {
+			t.AppendRow(table.Row{"Dropped at max in flight", bp.Dropped})
+		}
+		if bp.Delayed > 0 {
+			t.AppendRow(table.Row{"Delayed at max in flight", fmt.Sprintf("%d, %s in total", bp.Delayed, bp.Delay)})
+		}
+	}
+	if results.ThinkTimes > 0 {
+		t.AppendRow(table.Row{"Think time", fmt.Sprintf("%d pauses, %s average", results.ThinkTimes, results.ThinkTime/time.Duration(results.ThinkTimes))})
+	}
+	if results.OversizedResps > 0 {
+		t.AppendRow(table.Row{"Oversized responses", fmt.Sprintf("%d, largest %d bytes", results.OversizedResps, results.LargestOversized)})
+	}
+	if results.Stability != nil {
+		t.AppendRow(table.Row{"Stable p99 latency", stability(results.Stability, format)})
+	}
+	if results.RateCheck != nil {
+		t.AppendRow(table.Row{fmt.Sprintf("Target rate (%g/s)", results.RateCheck.Target), colors.verdict(results.RateCheck.Met)})
+	}
+	if results.SLOBreach != nil {
+		t.AppendRow(table.Row{"Stopped on SLO breach", fmt.Sprintf("after %s, %s", results.SLOBreach.After.Round(time.Millisecond), results.SLOBreach.Reason)})
+	}
+	if results.SLO != nil {
+		t.AppendRow(table.Row{fmt.Sprintf("Success rate SLO (%g%%)", results.SLO.Target), colors.verdict(results.SLO.Passed)})
+	}
+	for _, slo := range results.LatencySLOs {
+		if slo.Path != "" {
+			// the endpoint's latency isn't in the percentiles so it's shown with the verdict
+			measured := "no requests"
+			if slo.Latency > 0 {
+				measured = format.Format(slo.Latency.Round(time.Microsecond))
+			}
+			t.AppendRow(table.Row{fmt.Sprintf("%s P%g latency SLO (%s)", slo.Path, slo.Percentile, slo.Target), colors.verdict(slo.Passed) + ", " + measured})
+			continue
+		}
+		t.AppendRow(table.Row{fmt.Sprintf("P%g latency SLO (%s)", slo.Percentile, slo.Target), colors.verdict(slo.Passed)})
+	}
 	t.AppendSeparator()
 }
 
+func stability(s *payloader.Stability, format units.Latency) string {
+	switch {
+	case s.Stable:
+		return fmt.Sprintf("yes after %s, %s ±%.1f%%", s.After.Round(time.Millisecond), format.Format(s.P99.Round(time.Microsecond)), s.Variation*100)
+	case s.P99 == 0:
+		return "no, too few intervals to compare"
+	default:
+		return fmt.Sprintf("no, %s ±%.1f%%", format.Format(s.P99.Round(time.Microsecond)), s.Variation*100)
+	}
+}
+
 func displayReqSize(req payloader.ByteSize, t table.Writer) {
 	rows := make([]table.Row, 0)
 	rows = append(rows, table.Row{"Req size (bytes)", req.Single})
@@ -62,6 +188,89 @@ func displayRespSize(resp payloader.ByteSize, t table.Writer) {
 	t.AppendSeparator()
 }
 
+func displayDownload(results *payloader.GoPayloaderResults, t table.Writer) {
+	rows := make([]table.Row, 0)
+	rows = append(rows, table.Row{"Downloaded/second (MB)", fmt.Sprintf("%.3f", float64(results.DownloadedPerSecond)/(1024*1024))})
+	rows = append(rows, table.Row{"Downloaded total (MB)", fmt.Sprintf("%.3f", float64(results.DownloadedBytes)/(1024*1024))})
+	if results.ChecksumMatches > 0 {
+		rows = append(rows, table.Row{"Checksum matches", results.ChecksumMatches})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
+func displayBandwidth(b *payloader.Bandwidth, t table.Writer) {
+	mb := func(bytes float64) string {
+		return fmt.Sprintf("%.3f", bytes/(1024*1024))
+	}
+	t.AppendRow(table.Row{"Size/second per interval (MB)", fmt.Sprintf("%s min, %s median, %s max over %d intervals",
+		mb(b.Min), mb(b.Median), mb(b.Max), len(b.Series))})
+	t.AppendSeparator()
+}
+
+func displaySlowest(slowest []worker.SlowRequest, format units.Latency, t table.Writer) {
+	rows := make([]table.Row, 0, len(slowest))
+	for i, r := range slowest {
+		detail := fmt.Sprintf("%s %d %s at %s, %d byte response", format.Format(r.Latency), r.Status, r.URL, r.Start.Format(time.RFC3339Nano), r.RespSize)
+		if r.TraceID != "" {
+			detail += ", trace " + r.TraceID
+		}
+		rows = append(rows, table.Row{fmt.Sprintf("Slowest request #%d", i+1), detail})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
+func displayTargets(targets []payloader.TargetResults, format units.Latency, t table.Writer) {
+	rows := make([]table.Row, 0, len(targets))
+	for _, target := range targets {
+		detail := fmt.Sprintf("%.2f req/s, %s average latency, %.2f%% errors over %d connections",
+			target.RPS, format.Format(target.LatencyAverage), target.ErrorRate, target.Conns)
+		rows = append(rows, table.Row{"Target " + target.Addr, detail})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
+func displayTargetUsage(usage *payloader.TargetUsage, t table.Writer) {
+	if usage.Scrapes == 0 {
+		t.AppendRow(table.Row{"Target metrics", fmt.Sprintf("no successful scrapes of %s", usage.URL)})
+		t.AppendSeparator()
+		return
+	}
+	cpu := "not exported"
+	if usage.HasCPU {
+		cpu = fmt.Sprintf("%.2f average, %.2f peak", usage.CPU, usage.PeakCPU)
+	} else if usage.Scrapes == 1 {
+		cpu = "needs more than one scrape"
+	}
+	rss := "not exported"
+	if usage.HasRSS {
+		rss = fmt.Sprintf("%.3f average, %.3f peak", float64(usage.RSS)/(1024*1024), float64(usage.PeakRSS)/(1024*1024))
+	}
+	scrapes := strconv.Itoa(usage.Scrapes)
+	if usage.FailedScrapes > 0 {
+		scrapes += fmt.Sprintf(", %d failed", usage.FailedScrapes)
+	}
+	t.AppendRows([]table.Row{
+		{"Target CPU (cores)", cpu},
+		{"Target memory (MB)", rss},
+		{"Target metrics scrapes", scrapes},
+	})
+	t.AppendSeparator()
+}
+
+func displayConnOutliers(outliers []payloader.ConnOutlier, format units.Latency, t table.Writer) {
+	rows := make([]table.Row, 0, len(outliers))
+	for _, o := range outliers {
+		detail := fmt.Sprintf("%s median latency, %.1fx overall, p90 %s, max %s over %d of %d requests",
+			format.Format(o.Median), o.Factor, format.Format(o.P90), format.Format(o.Max), o.Samples, o.Requests)
+		rows = append(rows, table.Row{fmt.Sprintf("Slow connection #%d", o.WorkerID), detail})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
 func displayErrors(errors map[string]uint, t table.Writer) {
 	rows := make([]table.Row, 0)
 	for err, count := range errors {
@@ -71,6 +280,40 @@ func displayErrors(errors map[string]uint, t table.Writer) {
 	t.AppendSeparator()
 }
 
+func displayDivergences(diverged int64, divergences map[string]uint, t table.Writer) {
+	rows := make([]table.Row, 0)
+	rows = append(rows, table.Row{"Diverged requests", diverged})
+	for divergence, count := range divergences {
+		rows = append(rows, table.Row{"Divergence; " + divergence, count})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
+func displayTLS(results *payloader.GoPayloaderResults, t table.Writer) {
+	t.AppendRows([]table.Row{
+		{"TLS handshakes", results.TLSHandshakes},
+		{"TLS full handshakes", results.TLSHandshakes - results.TLSResumed},
+		{"TLS resumed handshakes", results.TLSResumed},
+	})
+	t.AppendSeparator()
+}
+
+func displayTCP(tcp *http_clients.TCPSummary, t table.Writer) {
+	retransmits := fmt.Sprintf("%d", tcp.Retransmits)
+	if tcp.SegmentsOut > 0 {
+		retransmits += fmt.Sprintf(" (%.3f%% of segments sent)", float64(tcp.Retransmits)/float64(tcp.SegmentsOut)*100)
+	}
+	t.AppendRows([]table.Row{
+		{"TCP connections sampled", tcp.Conns},
+		{"TCP retransmits", retransmits},
+		{"TCP RTT average", tcp.RTTAverage},
+		{"TCP RTT max", tcp.RTTMax},
+		{"TCP congestion window average (segments)", fmt.Sprintf("%.1f", tcp.CwndAverage)},
+	})
+	t.AppendSeparator()
+}
+
 func displayResponseCodes(resps map[worker.ResponseCode]int64, t table.Writer) {
 	rows := make([]table.Row, 0)
 	for code, freq := range resps {
@@ -80,21 +323,162 @@ func displayResponseCodes(resps map[worker.ResponseCode]int64, t table.Writer) {
 	t.AppendSeparator()
 }
 
-func displayLatency(results payloader.Latency, t table.Writer) {
+func displayStatusBands(bands []payloader.StatusBand, format units.Latency, t table.Writer) {
+	rows := make([]table.Row, 0, len(bands))
+	for _, b := range bands {
+		codes := make([]int, 0, len(b.Responses))
+		for code := range b.Responses {
+			codes = append(codes, int(code))
+		}
+		sort.Ints(codes)
+		detail := ""
+		for i, code := range codes {
+			if i > 0 {
+				detail += ", "
+			}
+			count := b.Responses[worker.ResponseCode(code)]
+			detail += fmt.Sprintf("%d: %d (%.1f%%)", code, count, float64(count)/float64(b.Requests)*100)
+		}
+		rows = append(rows, table.Row{fmt.Sprintf("Response codes; %s, up to %s", b.Band, format.Format(b.Upper)), detail})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
+func displayLatency(name string, results payloader.Latency, format units.Latency, t table.Writer) {
 	t.AppendRows([]table.Row{
-		{"Average latency", results.Average},
-		{"Max latency", results.Max},
-		{"Min latency", results.Min},
+		{"Average " + name, format.Format(results.Average)},
+		{"Max " + name, format.Format(results.Max)},
+		{"Min " + name, format.Format(results.Min)},
 	})
 	t.AppendSeparator()
 }
 
+func displayPercentiles(name string, results payloader.Latency, warning string, slos []payloader.LatencySLO, format units.Latency, t table.Writer, colors palette) {
+	if warning != "" {
+		t.AppendRow(table.Row{"Latency percentiles", "Not reported; " + warning})
+		t.AppendSeparator()
+		return
+	}
+	if len(results.Percentiles) == 0 {
+		return
+	}
+
+	rows := make([]table.Row, 0, len(results.Percentiles))
+	for _, p := range results.Percentiles {
+		latency := format.Format(p.Latency)
+		for _, slo := range slos {
+			if slo.Path == "" && slo.Percentile == p.Percentile {
+				latency = colors.latency(p.Latency, slo.Target, format)
+			}
+		}
+		rows = append(rows, table.Row{fmt.Sprintf("P%g %s", p.Percentile, name), latency})
+	}
+	t.AppendRows(rows)
+	t.AppendSeparator()
+}
+
 func displayRPS(results payloader.RPS, t table.Writer) {
 	t.AppendRows([]table.Row{
 		{"Average RPS", fmt.Sprintf("%.3f", results.Average)},
+		{"Goodput (successful RPS)", fmt.Sprintf("%.3f", results.Goodput)},
 		{"Max RPS", results.Max},
 		{"Min RPS", results.Min},
 	})
 
 	t.AppendSeparator()
 }
+
+// DisplayProtocols shows a row per protocol run with --compare-protocols so they can be compared side by side
+func DisplayProtocols(results []payloader.ProtocolResults, format units.Latency) {
+	pterm.Success.Printf("Gopayloader protocol comparison \n\n")
+	fmt.Println("")
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Protocol", "Client", "Completed requests", "Failed requests", "Average RPS", "Average latency", "Max latency", "Min latency"})
+
+	for _, r := range results {
+		if r.Results == nil {
+			t.AppendRow(table.Row{r.Protocol.Name, r.Protocol.Client, "Skipped; " + r.Skipped})
+			continue
+		}
+		t.AppendRow(table.Row{
+			r.Protocol.Name,
+			r.Protocol.Client,
+			r.Results.CompletedReqs,
+			r.Results.FailedReqs,
+			fmt.Sprintf("%.3f", r.Results.RPS.Average),
+			format.Format(r.Results.Latency.Average),
+			format.Format(r.Results.Latency.Max),
+			format.Format(r.Results.Latency.Min),
+		})
+	}
+
+	t.Render()
+}
+
+func DisplayRepeat(results *payloader.RepeatResults, format units.Latency) {
+	pterm.Success.Printf("Gopayloader results of %d runs \n\n", len(results.Runs))
+	fmt.Println("")
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Run", "Completed requests", "Failed requests", "Average RPS", "Average latency", "P99 latency"})
+
+	for i, r := range results.Runs {
+		p99 := "-"
+		for _, p := range r.Latency.Percentiles {
+			if p.Percentile == 99 {
+				p99 = format.Format(p.Latency)
+			}
+		}
+		t.AppendRow(table.Row{i + 1, r.CompletedReqs, r.FailedReqs, fmt.Sprintf("%.3f", r.RPS.Average), format.Format(r.Latency.Average), p99})
+	}
+	if len(results.Runs) == 0 {
+		t.Render()
+		return
+	}
+	t.AppendSeparator()
+
+	p99 := func(f func(s *payloader.LatencySpread) time.Duration) string {
+		if results.P99 == nil {
+			return "-"
+		}
+		return format.Format(f(results.P99))
+	}
+	t.AppendRows([]table.Row{
+		{"Total", results.CompletedReqs, results.FailedReqs, "", "", ""},
+		{"Mean", "", "", fmt.Sprintf("%.3f", results.RPS.Mean), format.Format(results.Latency.Mean),
+			p99(func(s *payloader.LatencySpread) time.Duration { return s.Mean })},
+		{"Std dev", "", "", fmt.Sprintf("%.3f", results.RPS.StdDev), format.Format(results.Latency.StdDev),
+			p99(func(s *payloader.LatencySpread) time.Duration { return s.StdDev })},
+		{"Min", "", "", fmt.Sprintf("%.3f", results.RPS.Min), format.Format(results.Latency.Min),
+			p99(func(s *payloader.LatencySpread) time.Duration { return s.Min })},
+		{"Max", "", "", fmt.Sprintf("%.3f", results.RPS.Max), format.Format(results.Latency.Max),
+			p99(func(s *payloader.LatencySpread) time.Duration { return s.Max })},
+	})
+
+	t.Render()
+}
+
+// DisplayEstimate shows the throughput projected with --estimate and how it was worked out
+func DisplayEstimate(est *payloader.CapacityEstimate, format units.Latency) {
+	pterm.Success.Printf("Gopayloader throughput estimate \n\n")
+	fmt.Println("")
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendRows([]table.Row{
+		{"Probe requests", est.Probes},
+		{"Failed probe requests", est.Failed},
+		{"Average probe latency", format.Format(est.Latency)},
+		{"Connections", est.Conns},
+		{"Projected RPS", fmt.Sprintf("%.3f", est.RPS)},
+	})
+	if est.RateLimit > 0 {
+		t.AppendRow(table.Row{"Capped by rate", fmt.Sprintf("%g", est.RateLimit)})
+	}
+	t.Render()
+	pterm.Info.Println("This is a rough closed model estimate of connections / latency, the target's latency is likely to grow under the full load")
+}
@@ -0,0 +1,155 @@
+package cli
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRender_Colors(t *testing.T) {
+	results := &payloader.GoPayloaderResults{
+		CompletedReqs: 999,
+		FailedReqs:    1,
+		SuccessRate:   99.9,
+		SLO:           &payloader.SLO{Target: 99, Passed: true},
+		Latency: payloader.Latency{
+			Percentiles: []payloader.Percentile{
+				{Percentile: 50, Latency: 10 * time.Millisecond},
+				{Percentile: 95, Latency: 90 * time.Millisecond},
+				{Percentile: 99, Latency: 300 * time.Millisecond},
+			},
+		},
+		LatencySLOs: []payloader.LatencySLO{
+			{Percentile: 50, Target: 100 * time.Millisecond, Latency: 10 * time.Millisecond, Passed: true},
+			{Percentile: 95, Target: 100 * time.Millisecond, Latency: 90 * time.Millisecond, Passed: true},
+			{Percentile: 99, Target: 250 * time.Millisecond, Latency: 300 * time.Millisecond, Passed: false},
+		},
+	}
+
+	output := func() string {
+		path := filepath.Join(t.TempDir(), "results")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		render(results, units.Latency{}, f)
+		f.Close()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	// redirected to a file
+	if out := output(); strings.Contains(out, "\x1b[") {
+		t.Errorf("wanted no colors when output isn't a terminal got\n%s", out)
+	}
+
+	orig := isTerminal
+	defer func() { isTerminal = orig }()
+	isTerminal = func(fd int) bool { return true }
+	out := output()
+	for _, want := range []string{
+		colorGreen + "99.900%" + colorReset,
+		colorGreen + "10ms" + colorReset,
+		colorYellow + "90ms" + colorReset,
+		colorRed + "300ms" + colorReset,
+		colorRed + "FAIL" + colorReset,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("wanted %q in output for a terminal got\n%s", want, out)
+		}
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if out := output(); strings.Contains(out, "\x1b[") {
+		t.Errorf("wanted no colors with NO_COLOR set got\n%s", out)
+	}
+}
+
+func TestRender_LatencyUnit(t *testing.T) {
+	results := &payloader.GoPayloaderResults{
+		CompletedReqs: 100,
+		Latency: payloader.Latency{
+			Average:     1234567 * time.Nanosecond,
+			Max:         2 * time.Second,
+			Min:         850 * time.Microsecond,
+			Percentiles: []payloader.Percentile{{Percentile: 99, Latency: 1500 * time.Millisecond}},
+		},
+	}
+
+	output := func(format units.Latency) string {
+		path := filepath.Join(t.TempDir(), "results")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		render(results, format, f)
+		f.Close()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	for _, tt := range []struct {
+		format units.Latency
+		want   []string
+	}{
+		{format: units.Latency{Unit: units.Auto, Precision: units.AsNeeded}, want: []string{"1.234567ms", "2s", "850µs", "1.5s"}},
+		{format: units.Latency{Unit: units.Auto, Precision: 2}, want: []string{"1.23ms", "2.00s", "850.00µs", "1.50s"}},
+		{format: units.Latency{Unit: units.Microseconds, Precision: 0}, want: []string{"1235µs", "2000000µs", "850µs", "1500000µs"}},
+		{format: units.Latency{Unit: units.Seconds, Precision: 3}, want: []string{"0.001s", "2.000s", "0.001s", "1.500s"}},
+	} {
+		out := output(tt.format)
+		for _, want := range tt.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%+v: wanted %q in output got\n%s", tt.format, want, out)
+			}
+		}
+	}
+}
+
+func TestRender_TargetUsage(t *testing.T) {
+	for _, tt := range []struct {
+		usage payloader.TargetUsage
+		want  []string
+	}{
+		{
+			usage: payloader.TargetUsage{Scrapes: 6, FailedScrapes: 1, HasCPU: true, CPU: 1.5, PeakCPU: 2.25, HasRSS: true, RSS: 100 * 1024 * 1024, PeakRSS: 150 * 1024 * 1024},
+			want:  []string{"1.50 average, 2.25 peak", "100.000 average, 150.000 peak", "6, 1 failed"},
+		},
+		{
+			usage: payloader.TargetUsage{Scrapes: 3, HasRSS: true, RSS: 1024 * 1024, PeakRSS: 1024 * 1024},
+			want:  []string{"Target CPU (cores)", "not exported", "1.000 average, 1.000 peak"},
+		},
+		{
+			usage: payloader.TargetUsage{URL: "http://localhost:8080/metrics", FailedScrapes: 5},
+			want:  []string{"no successful scrapes of http://localhost:8080/metrics"},
+		},
+	} {
+		path := filepath.Join(t.TempDir(), "results")
+		f, err := os.Create(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		usage := tt.usage
+		render(&payloader.GoPayloaderResults{CompletedReqs: 10, TargetUsage: &usage}, units.Latency{}, f)
+		f.Close()
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%+v: wanted %q in output got\n%s", tt.usage, want, b)
+			}
+		}
+	}
+}
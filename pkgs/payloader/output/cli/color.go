@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"golang.org/x/term"
+	"os"
+	"time"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorRed    = "\x1b[31m"
+	// warnRatio is how close to an SLO a result is shown as a warning rather than within it
+	warnRatio = 0.8
+)
+
+// isTerminal is replaced in tests as there's no terminal to test against
+var isTerminal = term.IsTerminal
+
+// palette colors results against their SLOs, green within it, yellow getting close and red missing it. Without
+// colors values are shown as is
+type palette struct {
+	enabled bool
+}
+
+// newPalette only colors when out is a terminal and NO_COLOR isn't set, so piped or redirected results stay plain
+func newPalette(out *os.File) palette {
+	if os.Getenv("NO_COLOR") != "" {
+		return palette{}
+	}
+	return palette{enabled: isTerminal(int(out.Fd()))}
+}
+
+func (p palette) paint(color string, v any) string {
+	if !p.enabled {
+		return fmt.Sprint(v)
+	}
+	return color + fmt.Sprint(v) + colorReset
+}
+
+// latency colors a latency, written in format, against the target it needs to be within
+func (p palette) latency(latency, target time.Duration, format units.Latency) string {
+	s := format.Format(latency)
+	switch {
+	case latency > target:
+		return p.paint(colorRed, s)
+	case float64(latency) > float64(target)*warnRatio:
+		return p.paint(colorYellow, s)
+	default:
+		return p.paint(colorGreen, s)
+	}
+}
+
+// successRate colors the success rate by how much of the SLO's error budget was used, yellow past most of it
+func (p palette) successRate(slo *payloader.SLO, rate float64) string {
+	s := fmt.Sprintf("%.3f%%", rate)
+	budget := 100 - slo.Target
+	switch {
+	case !slo.Passed:
+		return p.paint(colorRed, s)
+	case 100-rate > budget*warnRatio:
+		return p.paint(colorYellow, s)
+	default:
+		return p.paint(colorGreen, s)
+	}
+}
+
+func (p palette) verdict(passed bool) string {
+	if passed {
+		return p.paint(colorGreen, "PASS")
+	}
+	return p.paint(colorRed, "FAIL")
+}
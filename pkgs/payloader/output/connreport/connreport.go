@@ -0,0 +1,122 @@
+package connreport
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Header is the columns of each row
+var Header = []string{"connection", "opened", "closed", "lifetime_ms", "requests", "close_reason", "local_addr", "remote_addr"}
+
+// Why a connection was closed
+const (
+	// ReasonServerClose is the target closing the connection, seen as EOF or a reset reading or writing on it
+	ReasonServerClose = "server close"
+	// ReasonConnectionClose is a response with Connection: close, asked for by the request i.e. with --close-rate or
+	// decided by the target
+	ReasonConnectionClose = "connection: close"
+	// ReasonMaxLifetime is the client closing it after --max-conn-duration
+	ReasonMaxLifetime = "max lifetime"
+	// ReasonKeepAliveExpiry is the client closing it after being idle for --max-idle-conn-duration
+	ReasonKeepAliveExpiry = "keep-alive expiry"
+	ReasonTimeout         = "timeout"
+	ReasonError           = "error"
+	// ReasonClientClose is the client closing it for any other reason i.e. with keep-alive disabled
+	ReasonClientClose = "client close"
+	// ReasonEndOfRun is the connection being closed as its worker finished
+	ReasonEndOfRun = "end of run"
+	// ReasonOpenAtEnd is a connection the client never closed by the time the run ended
+	ReasonOpenAtEnd = "open at end"
+)
+
+// Connection is the lifecycle of a single connection written as a row once it's closed
+type Connection struct {
+	// WorkerID is the worker the connection was opened by, written 1 based like the JTL thread names
+	WorkerID int
+	Opened   time.Time
+	Closed   time.Time
+	Requests int64
+	Reason   string
+	// Err is what was read or written when the connection failed, for ReasonError and ReasonTimeout
+	Err        error
+	LocalAddr  string
+	RemoteAddr string
+}
+
+// Writer writes a row for every connection of a run to a CSV file, it's shared by all workers
+type Writer struct {
+	mu     sync.Mutex
+	file   *os.File
+	csv    *csv.Writer
+	counts map[string]int64
+}
+
+// Create truncates or creates the file at path and writes the header
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("connreport: failed to create %s; %v", path, err)
+	}
+	w := &Writer{file: file, csv: csv.NewWriter(file), counts: make(map[string]int64)}
+	if err := w.csv.Write(Header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("connreport: failed to write header; %v", err)
+	}
+	return w, nil
+}
+
+// Write adds a row for the connection, write errors are returned by Close
+func (w *Writer) Write(c Connection) {
+	reason := c.Reason
+	if c.Err != nil {
+		reason += ": " + c.Err.Error()
+	}
+	row := []string{
+		strconv.Itoa(c.WorkerID + 1),
+		c.Opened.Format("2006-01-02T15:04:05.000Z07:00"),
+		c.Closed.Format("2006-01-02T15:04:05.000Z07:00"),
+		strconv.FormatInt(c.Closed.Sub(c.Opened).Milliseconds(), 10),
+		strconv.FormatInt(c.Requests, 10),
+		reason,
+		c.LocalAddr,
+		c.RemoteAddr,
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.counts[c.Reason]++
+	w.csv.Write(row)
+}
+
+// Counts is how many connections were written for each close reason
+func (w *Writer) Counts() map[string]int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	counts := make(map[string]int64, len(w.counts))
+	for reason, n := range w.counts {
+		counts[reason] = n
+	}
+	return counts
+}
+
+// Close flushes the rows and closes the file, returning the first error writing any row. Closing again does nothing
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("connreport: failed to write connections; %v", err)
+	}
+	return file.Close()
+}
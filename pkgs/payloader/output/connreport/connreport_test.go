@@ -0,0 +1,57 @@
+package connreport
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "conns.csv")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened := time.UnixMilli(1700000000123).UTC()
+	w.Write(Connection{WorkerID: 0, Opened: opened, Closed: opened.Add(1500 * time.Millisecond), Requests: 12, Reason: ReasonConnectionClose,
+		LocalAddr: "127.0.0.1:50001", RemoteAddr: "127.0.0.1:8888"})
+	w.Write(Connection{WorkerID: 1, Opened: opened, Closed: opened.Add(40 * time.Millisecond), Requests: 3, Reason: ReasonTimeout,
+		Err: errors.New("i/o timeout"), LocalAddr: "127.0.0.1:50002", RemoteAddr: "127.0.0.1:8888"})
+	w.Write(Connection{WorkerID: 1, Opened: opened, Closed: opened.Add(2 * time.Second), Requests: 30, Reason: ReasonConnectionClose,
+		LocalAddr: "127.0.0.1:50003", RemoteAddr: "127.0.0.1:8888"})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("wanted closing again to do nothing got %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"connection,opened,closed,lifetime_ms,requests,close_reason,local_addr,remote_addr",
+		"1,2023-11-14T22:13:20.123Z,2023-11-14T22:13:21.623Z,1500,12,connection: close,127.0.0.1:50001,127.0.0.1:8888",
+		"2,2023-11-14T22:13:20.123Z,2023-11-14T22:13:20.163Z,40,3,timeout: i/o timeout,127.0.0.1:50002,127.0.0.1:8888",
+		"2,2023-11-14T22:13:20.123Z,2023-11-14T22:13:22.123Z,2000,30,connection: close,127.0.0.1:50003,127.0.0.1:8888",
+	}
+	got := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("wanted %d lines got %d; %s", len(want), len(got), b)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: wanted\n%s\ngot\n%s", i+1, want[i], got[i])
+		}
+	}
+
+	counts := w.Counts()
+	if len(counts) != 2 || counts[ReasonConnectionClose] != 2 || counts[ReasonTimeout] != 1 {
+		t.Errorf("wanted 2 closed by connection: close and 1 timeout got %v", counts)
+	}
+}
@@ -0,0 +1,112 @@
+package flamegraph
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Request is how long each phase of a request took. Connection is getting a connection, which for a new one includes
+// resolving, connecting and the TLS handshake. TTFB is from having the connection to the first byte of the response,
+// sending the request and the target handling it, Transfer from then until the response was read
+type Request struct {
+	Connection time.Duration
+	DNS        time.Duration
+	Connect    time.Duration
+	TLS        time.Duration
+	TTFB       time.Duration
+	Transfer   time.Duration
+}
+
+// Writer sums up the phases of every request of a run and writes them as folded stacks, one line per phase of its
+// stack of frames and the total microseconds spent in it, which flamegraph.pl, speedscope and inferno read. It's
+// shared by all workers
+type Writer struct {
+	mu       sync.Mutex
+	file     *os.File
+	requests int64
+	total    Request
+}
+
+// Create truncates or creates the file at path, the stacks are written to it on Close
+func Create(path string) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("flamegraph: failed to create %s; %v", path, err)
+	}
+	return &Writer{file: file}, nil
+}
+
+// Add sums the phases of a request
+func (w *Writer) Add(r Request) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.requests++
+	w.total.Connection += r.Connection
+	w.total.DNS += r.DNS
+	w.total.Connect += r.Connect
+	w.total.TLS += r.TLS
+	w.total.TTFB += r.TTFB
+	w.total.Transfer += r.Transfer
+}
+
+// Requests is how many requests have been added
+func (w *Writer) Requests() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.requests
+}
+
+// Close writes the stacks and closes the file. Closing again does nothing
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+
+	out := bufio.NewWriter(file)
+	for _, s := range w.stacks() {
+		fmt.Fprintf(out, "%s %d\n", s.frames, s.micros)
+	}
+	if err := out.Flush(); err != nil {
+		file.Close()
+		return fmt.Errorf("flamegraph: failed to write stacks; %v", err)
+	}
+	return file.Close()
+}
+
+type stack struct {
+	frames string
+	micros int64
+}
+
+// stacks are the phases with any time in them. Resolving, connecting and the TLS handshake are within getting the
+// connection, what's left of it i.e. waiting for an idle connection is the connection frame's own
+func (w *Writer) stacks() []stack {
+	connection := w.total.Connection - w.total.DNS - w.total.Connect - w.total.TLS
+	if connection < 0 {
+		connection = 0
+	}
+	var stacks []stack
+	for _, s := range []struct {
+		frames string
+		d      time.Duration
+	}{
+		{"request;connection;dns", w.total.DNS},
+		{"request;connection;connect", w.total.Connect},
+		{"request;connection;tls", w.total.TLS},
+		{"request;connection", connection},
+		{"request;ttfb", w.total.TTFB},
+		{"request;transfer", w.total.Transfer},
+	} {
+		if micros := s.d.Microseconds(); micros > 0 {
+			stacks = append(stacks, stack{s.frames, micros})
+		}
+	}
+	return stacks
+}
@@ -0,0 +1,53 @@
+package flamegraph
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "latency.folded")
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a new connection then one reused, which spent a little time waiting for it
+	w.Add(Request{Connection: 3 * time.Millisecond, DNS: 500 * time.Microsecond, Connect: time.Millisecond, TLS: 1500 * time.Microsecond,
+		TTFB: 10 * time.Millisecond, Transfer: 2 * time.Millisecond})
+	w.Add(Request{Connection: 20 * time.Microsecond, TTFB: 8 * time.Millisecond, Transfer: 1 * time.Millisecond})
+	if w.Requests() != 2 {
+		t.Errorf("wanted 2 requests got %d", w.Requests())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("wanted closing again to do nothing got %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"request;connection;dns 500",
+		"request;connection;connect 1000",
+		"request;connection;tls 1500",
+		"request;connection 20",
+		"request;ttfb 18000",
+		"request;transfer 3000",
+	}
+	got := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("wanted %d lines got %d; %s", len(want), len(got), b)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: wanted %s got %s", i+1, want[i], got[i])
+		}
+	}
+}
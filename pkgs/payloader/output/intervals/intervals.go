@@ -0,0 +1,82 @@
+package intervals
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Header is the columns of each row, the latency columns are named for their unit i.e. p50_ms
+func Header(unit string) []string {
+	return []string{"timestamp", "requests", "rps", "errors", "p50_" + unit, "p99_" + unit, "bytes", "bytes_per_sec"}
+}
+
+// Row is one --ticker interval of a run
+type Row struct {
+	// Time is when the interval ended
+	Time time.Time
+	// Requests completed in the interval, RPS is over its length which is shorter for the last one
+	Requests int64
+	RPS      float64
+	Errors   int64
+	P50      time.Duration
+	P99      time.Duration
+	// Bytes is the size of the requests and responses completed in the interval, BytesPerSec over its length like RPS
+	Bytes       int64
+	BytesPerSec float64
+}
+
+// Writer writes a row for every interval of a run to a CSV file, rows are written as intervals complete so they can
+// be followed while the run's going
+type Writer struct {
+	file    *os.File
+	csv     *csv.Writer
+	latency units.Latency
+}
+
+// Create truncates or creates the file at path and writes the header, latencies are written in the unit and precision
+// of latency
+func Create(path string, latency units.Latency) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("intervals: failed to create %s; %v", path, err)
+	}
+	w := &Writer{file: file, csv: csv.NewWriter(file), latency: latency}
+	if err := w.csv.Write(Header(latency.ExportUnit())); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("intervals: failed to write header; %v", err)
+	}
+	w.csv.Flush()
+	return w, nil
+}
+
+func (w *Writer) Write(r Row) error {
+	w.csv.Write([]string{
+		r.Time.Format("2006-01-02T15:04:05.000Z07:00"),
+		strconv.FormatInt(r.Requests, 10),
+		strconv.FormatFloat(r.RPS, 'f', 2, 64),
+		strconv.FormatInt(r.Errors, 10),
+		w.latency.Export(r.P50),
+		w.latency.Export(r.P99),
+		strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatFloat(r.BytesPerSec, 'f', 2, 64),
+	})
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return fmt.Errorf("intervals: failed to write row; %v", err)
+	}
+	return nil
+}
+
+// Close closes the file, closing again does nothing
+func (w *Writer) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+	return file.Close()
+}
@@ -0,0 +1,75 @@
+package intervals
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/units"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intervals.csv")
+	w, err := Create(path, units.Latency{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if err := w.Write(Row{Time: start.Add(time.Second), Requests: 950, RPS: 950, Errors: 2, P50: 4200 * time.Microsecond, P99: 31 * time.Millisecond, Bytes: 171000, BytesPerSec: 171000}); err != nil {
+		t.Fatal(err)
+	}
+	// rows are readable before the file is closed
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 2 {
+		t.Errorf("wanted the row flushed once written got %q", b)
+	}
+	if err := w.Write(Row{Time: start.Add(1500 * time.Millisecond), RPS: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("wanted closing again to do nothing got %v", err)
+	}
+
+	b, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"timestamp,requests,rps,errors,p50_ms,p99_ms,bytes,bytes_per_sec",
+		"2024-05-01T10:00:01.000Z,950,950.00,2,4.200,31.000,171000,171000.00",
+		"2024-05-01T10:00:01.500Z,0,0.00,0,0.000,0.000,0,0.00",
+	}
+	got := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("wanted\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestWriter_LatencyUnit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "intervals.csv")
+	w, err := Create(path, units.Latency{Unit: units.Microseconds, Precision: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(Row{Time: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC), Requests: 10, RPS: 10, P50: 4260 * time.Nanosecond, P99: 31 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "timestamp,requests,rps,errors,p50_us,p99_us,bytes,bytes_per_sec\n2024-05-01T10:00:01.000Z,10,10.00,0,4.3,31000.0,0,0.00"
+	if got := strings.TrimSpace(string(b)); got != want {
+		t.Errorf("wanted\n%s\ngot\n%s", want, got)
+	}
+}
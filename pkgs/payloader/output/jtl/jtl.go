@@ -0,0 +1,132 @@
+package jtl
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Header is JMeter's default CSV result file columns, in the order JMeter writes them
+var Header = []string{
+	"timeStamp", "elapsed", "label", "responseCode", "responseMessage", "threadName", "dataType", "success",
+	"failureMessage", "bytes", "sentBytes", "grpThreads", "allThreads", "URL", "Latency", "IdleTime", "Connect",
+}
+
+// Sample is a single request written as a row
+type Sample struct {
+	Start    time.Time
+	Elapsed  time.Duration
+	Method   string
+	URL      string
+	Status   int
+	Err      error
+	WorkerID int
+	ReqSize  int64
+	RespSize int64
+}
+
+// Writer writes a row for every request to a JMeter JTL CSV file, it's shared by all workers
+type Writer struct {
+	mu           sync.Mutex
+	file         *os.File
+	csv          *csv.Writer
+	threads      string
+	successCodes []int
+}
+
+// Create truncates or creates the file at path and writes the header. threads is the number of connections, which
+// JMeter calls threads, successCodes are the response codes counted as successful, any 2xx if empty
+func Create(path string, threads int, successCodes []int) (*Writer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("jtl: failed to create %s; %v", path, err)
+	}
+	w := &Writer{
+		file:         file,
+		csv:          csv.NewWriter(file),
+		threads:      strconv.Itoa(threads),
+		successCodes: successCodes,
+	}
+	if err := w.csv.Write(Header); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("jtl: failed to write header; %v", err)
+	}
+	return w, nil
+}
+
+// Write adds a row for the sample. Requests which failed without a response are written the same way JMeter writes
+// them, with a non HTTP response code and the error as the message, a failed header assertion keeps the response
+// code. Write errors are returned by Close
+func (w *Writer) Write(s Sample) {
+	code := strconv.Itoa(s.Status)
+	message := http.StatusText(s.Status)
+	failure := ""
+	success := s.Err == nil && w.isSuccessCode(s.Status)
+	if s.Err != nil {
+		failure = s.Err.Error()
+		if s.Status == 0 {
+			code = "Non HTTP response code: error"
+			message = "Non HTTP response message: " + s.Err.Error()
+		}
+	}
+	// only the total time is known so it's also used for the time to first byte
+	elapsed := strconv.FormatInt(s.Elapsed.Milliseconds(), 10)
+
+	row := []string{
+		strconv.FormatInt(s.Start.UnixMilli(), 10),
+		elapsed,
+		s.Method,
+		code,
+		message,
+		fmt.Sprintf("gopayloader 1-%d", s.WorkerID+1),
+		"text",
+		strconv.FormatBool(success),
+		failure,
+		strconv.FormatInt(s.RespSize, 10),
+		strconv.FormatInt(s.ReqSize, 10),
+		w.threads,
+		w.threads,
+		s.URL,
+		elapsed,
+		"0",
+		"0",
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.csv.Write(row)
+}
+
+func (w *Writer) isSuccessCode(code int) bool {
+	if len(w.successCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range w.successCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// Close flushes the rows and closes the file, returning the first error writing any row. Closing again does nothing
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		file.Close()
+		return fmt.Errorf("jtl: failed to write samples; %v", err)
+	}
+	return file.Close()
+}
@@ -0,0 +1,70 @@
+package jtl
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jtl")
+	w, err := Create(path, 2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.UnixMilli(1700000000123)
+	w.Write(Sample{Start: start, Elapsed: 42 * time.Millisecond, Method: "GET", URL: "http://localhost:8888/", Status: 200, WorkerID: 0, ReqSize: 60, RespSize: 120})
+	w.Write(Sample{Start: start, Elapsed: 7 * time.Millisecond, Method: "GET", URL: "http://localhost:8888/", Status: 503, WorkerID: 1, ReqSize: 60, RespSize: 30})
+	w.Write(Sample{Start: start, Elapsed: time.Second, Method: "GET", URL: "http://localhost:8888/", Err: errors.New("timeout"), WorkerID: 1, ReqSize: 60})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("wanted closing again to do nothing got %v", err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"timeStamp,elapsed,label,responseCode,responseMessage,threadName,dataType,success,failureMessage,bytes,sentBytes,grpThreads,allThreads,URL,Latency,IdleTime,Connect",
+		"1700000000123,42,GET,200,OK,gopayloader 1-1,text,true,,120,60,2,2,http://localhost:8888/,42,0,0",
+		"1700000000123,7,GET,503,Service Unavailable,gopayloader 1-2,text,false,,30,60,2,2,http://localhost:8888/,7,0,0",
+		"1700000000123,1000,GET,Non HTTP response code: error,Non HTTP response message: timeout,gopayloader 1-2,text,false,timeout,0,60,2,2,http://localhost:8888/,1000,0,0",
+	}
+	got := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(got) != len(want) {
+		t.Fatalf("wanted %d lines got %d; %s", len(want), len(got), b)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("line %d: wanted\n%s\ngot\n%s", i+1, want[i], got[i])
+		}
+	}
+}
+
+func TestWriter_SuccessCodes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jtl")
+	w, err := Create(path, 1, []int{200, 404})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(Sample{Start: time.Now(), Method: "GET", URL: "http://localhost:8888/", Status: 404})
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	row := strings.Split(strings.Split(strings.TrimSpace(string(b)), "\n")[1], ",")
+	if row[3] != "404" || row[7] != "true" {
+		t.Errorf("wanted 404 to be successful got %v", row)
+	}
+}
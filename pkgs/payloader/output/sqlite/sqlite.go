@@ -0,0 +1,134 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	_ "modernc.org/sqlite"
+	"time"
+)
+
+// schema keeps one row per run so results can be queried over time, durations are stored in nanoseconds and times
+// as RFC3339 strings so they can be used with SQLite's date functions
+const schema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+	target             TEXT    NOT NULL,
+	method             TEXT    NOT NULL,
+	client             TEXT    NOT NULL,
+	connections        INTEGER NOT NULL,
+	start_time         TEXT    NOT NULL,
+	end_time           TEXT    NOT NULL,
+	duration_ns        INTEGER NOT NULL,
+	completed_reqs     INTEGER NOT NULL,
+	failed_reqs        INTEGER NOT NULL,
+	rps_average        REAL    NOT NULL,
+	rps_max            INTEGER NOT NULL,
+	rps_min            INTEGER NOT NULL,
+	latency_average_ns INTEGER NOT NULL,
+	latency_max_ns     INTEGER NOT NULL,
+	latency_min_ns     INTEGER NOT NULL,
+	req_bytes_total    INTEGER NOT NULL,
+	resp_bytes_total   INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS run_responses (
+	run_id      INTEGER NOT NULL REFERENCES runs(id),
+	status_code INTEGER NOT NULL,
+	count       INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS run_errors (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	error  TEXT    NOT NULL,
+	count  INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS run_intervals (
+	run_id             INTEGER NOT NULL REFERENCES runs(id),
+	start_time         TEXT    NOT NULL,
+	requests           INTEGER NOT NULL,
+	latency_average_ns INTEGER NOT NULL
+);
+`
+
+// DB is the SQLite database runs are saved to
+type DB struct {
+	db *sql.DB
+}
+
+// Open opens the SQLite database at path, creating it and its schema if needed. It's opened before the run so a
+// path which can't be written fails before the run rather than losing its results
+func Open(path string) (*DB, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: failed to open %s; %v", path, err)
+	}
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("sqlite: failed to create schema in %s; %v", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close closes the database
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Save appends the results as a new run. All rows are written in a single transaction, returns the ID of the run
+func (d *DB) Save(conf *config.Config, results *payloader.GoPayloaderResults) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to start transaction; %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(`INSERT INTO runs (target, method, client, connections, start_time, end_time, duration_ns,
+		completed_reqs, failed_reqs, rps_average, rps_max, rps_min, latency_average_ns, latency_max_ns,
+		latency_min_ns, req_bytes_total, resp_bytes_total) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		conf.ReqURI, conf.Method, conf.Client, conf.Conns, results.Start.Format(time.RFC3339Nano),
+		results.End.Format(time.RFC3339Nano), int64(results.Total), results.CompletedReqs, results.FailedReqs,
+		results.RPS.Average, results.RPS.Max, results.RPS.Min, int64(results.Latency.Average),
+		int64(results.Latency.Max), int64(results.Latency.Min), results.ReqByteSize.Total, results.RespByteSize.Total)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to insert run; %v", err)
+	}
+	runID, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to get run id; %v", err)
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO run_responses (run_id, status_code, count) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to prepare responses insert; %v", err)
+	}
+	for code, count := range results.Responses {
+		if _, err := stmt.Exec(runID, int(code), count); err != nil {
+			return 0, fmt.Errorf("sqlite: failed to insert response code; %v", err)
+		}
+	}
+
+	stmt, err = tx.Prepare(`INSERT INTO run_errors (run_id, error, count) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to prepare errors insert; %v", err)
+	}
+	for e, count := range results.Errors {
+		if _, err := stmt.Exec(runID, e, count); err != nil {
+			return 0, fmt.Errorf("sqlite: failed to insert error; %v", err)
+		}
+	}
+
+	stmt, err = tx.Prepare(`INSERT INTO run_intervals (run_id, start_time, requests, latency_average_ns) VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: failed to prepare intervals insert; %v", err)
+	}
+	for _, i := range results.Intervals {
+		if _, err := stmt.Exec(runID, i.Start.Format(time.RFC3339Nano), i.Requests, int64(i.LatencyAverage)); err != nil {
+			return 0, fmt.Errorf("sqlite: failed to insert interval; %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("sqlite: failed to commit; %v", err)
+	}
+	return runID, nil
+}
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSave(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	conf := &config.Config{
+		Ctx:           context.Background(),
+		ReqURI:        server.URL,
+		Conns:         2,
+		Duration:      2 * time.Second,
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  5 * time.Second,
+		Method:        "GET",
+		Client:        "fasthttp-1",
+		VerboseTicker: time.Second,
+	}
+	results, err := payloader.NewPayLoader(conf).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "runs.db")
+	// runs are appended so history is kept, across opens
+	for want := int64(1); want <= 2; want++ {
+		out, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		runID, err := out.Save(conf, results)
+		out.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if runID != want {
+			t.Errorf("wanted run id %d got %d", want, runID)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var target string
+	var completed, ok200, intervals int64
+	row := db.QueryRow(`SELECT target, completed_reqs FROM runs WHERE id = 2`)
+	if err := row.Scan(&target, &completed); err != nil {
+		t.Fatal(err)
+	}
+	if target != server.URL {
+		t.Errorf("wanted target %s got %s", server.URL, target)
+	}
+	if completed != results.CompletedReqs || completed == 0 {
+		t.Errorf("wanted %d completed reqs got %d", results.CompletedReqs, completed)
+	}
+
+	row = db.QueryRow(`SELECT count FROM run_responses WHERE run_id = 2 AND status_code = 200`)
+	if err := row.Scan(&ok200); err != nil {
+		t.Fatal(err)
+	}
+	if ok200 != completed {
+		t.Errorf("wanted %d 200 responses got %d", completed, ok200)
+	}
+
+	row = db.QueryRow(`SELECT COUNT(*) FROM run_intervals WHERE run_id = 2 AND requests > 0`)
+	if err := row.Scan(&intervals); err != nil {
+		t.Fatal(err)
+	}
+	if intervals < 1 {
+		t.Errorf("wanted per second intervals to be saved got %d", intervals)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	// a directory can't be opened as a database
+	if _, err := Open(t.TempDir()); err == nil {
+		t.Error("wanted error opening a directory")
+	}
+}
@@ -0,0 +1,104 @@
+package units
+
+import (
+	"strconv"
+	"time"
+)
+
+// Units of --latency-unit, Auto picks one for each latency by its size
+const (
+	Auto         = "auto"
+	Nanoseconds  = "ns"
+	Microseconds = "us"
+	Milliseconds = "ms"
+	Seconds      = "s"
+)
+
+// AsNeeded is the --latency-precision which keeps every significant digit
+const AsNeeded = -1
+
+// exportPrecision is the decimal places of exported latencies with Auto and AsNeeded, enough for microseconds in
+// milliseconds
+const exportPrecision = 3
+
+// Latency is how latencies are written, in Unit to Precision decimal places. Auto with AsNeeded and the zero Latency
+// are time.Duration's own format, and milliseconds to 3 places in exports
+type Latency struct {
+	Unit      string
+	Precision int
+}
+
+func IsUnit(unit string) bool {
+	switch unit {
+	case Auto, Nanoseconds, Microseconds, Milliseconds, Seconds:
+		return true
+	}
+	return false
+}
+
+// Format writes d with its unit i.e. 12.500ms
+func (l Latency) Format(d time.Duration) string {
+	if l.Unit == "" || l.Unit == Auto && l.Precision == AsNeeded {
+		return d.String()
+	}
+	unit := l.Unit
+	if unit == Auto {
+		unit = autoUnit(d)
+	}
+	return value(d, unit, l.Precision) + suffix(unit)
+}
+
+// ExportUnit is the unit of exported latencies, exports like CSV columns need the same unit for every row so Auto is
+// milliseconds
+func (l Latency) ExportUnit() string {
+	if l.Unit == "" || l.Unit == Auto {
+		return Milliseconds
+	}
+	return l.Unit
+}
+
+// Export writes d as a number in ExportUnit without the unit
+func (l Latency) Export(d time.Duration) string {
+	precision := l.Precision
+	if l.Unit == "" || l.Unit == Auto && precision == AsNeeded {
+		precision = exportPrecision
+	}
+	return value(d, l.ExportUnit(), precision)
+}
+
+func autoUnit(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	switch {
+	case d >= time.Second:
+		return Seconds
+	case d >= time.Millisecond:
+		return Milliseconds
+	case d >= time.Microsecond:
+		return Microseconds
+	default:
+		return Nanoseconds
+	}
+}
+
+func value(d time.Duration, unit string, precision int) string {
+	scale := time.Millisecond
+	switch unit {
+	case Nanoseconds:
+		scale = time.Nanosecond
+	case Microseconds:
+		scale = time.Microsecond
+	case Seconds:
+		scale = time.Second
+	}
+	return strconv.FormatFloat(float64(d)/float64(scale), 'f', precision, 64)
+}
+
+// suffix matches time.Duration's, µs rather than us
+func suffix(unit string) string {
+	if unit == Microseconds {
+		return "µs"
+	}
+	return unit
+}
@@ -0,0 +1,62 @@
+package units
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatency_Format(t *testing.T) {
+	for _, tt := range []struct {
+		latency Latency
+		d       time.Duration
+		want    string
+	}{
+		// time.Duration's own format unless a unit or precision is chosen
+		{latency: Latency{}, d: 12345678 * time.Nanosecond, want: "12.345678ms"},
+		{latency: Latency{Unit: Auto, Precision: AsNeeded}, d: 12345678 * time.Nanosecond, want: "12.345678ms"},
+		{latency: Latency{Unit: Auto, Precision: 2}, d: 12345678 * time.Nanosecond, want: "12.35ms"},
+		{latency: Latency{Unit: Auto, Precision: 1}, d: 850 * time.Microsecond, want: "850.0µs"},
+		{latency: Latency{Unit: Auto, Precision: 3}, d: 90 * time.Second, want: "90.000s"},
+		{latency: Latency{Unit: Auto, Precision: 0}, d: 420 * time.Nanosecond, want: "420ns"},
+		{latency: Latency{Unit: Microseconds, Precision: AsNeeded}, d: 12345678 * time.Nanosecond, want: "12345.678µs"},
+		{latency: Latency{Unit: Microseconds, Precision: 0}, d: 12345678 * time.Nanosecond, want: "12346µs"},
+		{latency: Latency{Unit: Seconds, Precision: 4}, d: 1500 * time.Millisecond, want: "1.5000s"},
+		{latency: Latency{Unit: Nanoseconds, Precision: 0}, d: 3 * time.Microsecond, want: "3000ns"},
+		{latency: Latency{Unit: Milliseconds, Precision: AsNeeded}, d: 2 * time.Second, want: "2000ms"},
+	} {
+		if got := tt.latency.Format(tt.d); got != tt.want {
+			t.Errorf("%+v of %s: wanted %s got %s", tt.latency, tt.d, tt.want, got)
+		}
+	}
+}
+
+func TestLatency_Export(t *testing.T) {
+	for _, tt := range []struct {
+		latency Latency
+		unit    string
+		want    string
+	}{
+		// exported columns need one unit, auto is milliseconds to 3 places like before units could be chosen
+		{latency: Latency{}, unit: Milliseconds, want: "4.200"},
+		{latency: Latency{Unit: Auto, Precision: AsNeeded}, unit: Milliseconds, want: "4.200"},
+		{latency: Latency{Unit: Auto, Precision: 1}, unit: Milliseconds, want: "4.2"},
+		{latency: Latency{Unit: Microseconds, Precision: 0}, unit: Microseconds, want: "4200"},
+		{latency: Latency{Unit: Seconds, Precision: AsNeeded}, unit: Seconds, want: "0.0042"},
+	} {
+		if unit := tt.latency.ExportUnit(); unit != tt.unit {
+			t.Errorf("%+v: wanted unit %s got %s", tt.latency, tt.unit, unit)
+		}
+		if got := tt.latency.Export(4200 * time.Microsecond); got != tt.want {
+			t.Errorf("%+v: wanted %s got %s", tt.latency, tt.want, got)
+		}
+	}
+
+	for _, unit := range []string{"ns", "us", "ms", "s", "auto"} {
+		if !IsUnit(unit) {
+			t.Errorf("wanted %s to be a unit", unit)
+		}
+	}
+	if IsUnit("µs") || IsUnit("m") {
+		t.Error("wanted only ns, us, ms, s and auto to be units")
+	}
+}
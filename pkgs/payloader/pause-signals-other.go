@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || dragonfly || freebsd || netbsd || openbsd)
+
+package payloader
+
+import "os"
+
+// there's no SIGUSR1 or SIGUSR2 to pause with
+var (
+	pauseSignal  os.Signal
+	resumeSignal os.Signal
+)
+
+const resumeSignalName = ""
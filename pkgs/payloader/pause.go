@@ -0,0 +1,38 @@
+package payloader
+
+import (
+	"context"
+	"github.com/pterm/pterm"
+	"os"
+	"os/signal"
+)
+
+// handlePauseSignals pauses sending requests on pauseSignal and resumes on resumeSignal, stats are kept across a
+// pause and the time paused is left out of throughput
+func (p *PayLoader) handlePauseSignals(ctx context.Context) {
+	if pauseSignal == nil {
+		return
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, pauseSignal, resumeSignal)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig := <-c:
+				if sig == pauseSignal {
+					if p.pause.Pause() {
+						pterm.Info.Printf("Paused, send %s to resume\n", resumeSignalName)
+					}
+					continue
+				}
+				if p.pause.Resume() {
+					pterm.Info.Println("Resumed")
+				}
+			}
+		}
+	}()
+}
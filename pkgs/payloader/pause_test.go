@@ -0,0 +1,40 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_HandlePauseSignals(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewPayLoader(&config.Config{})
+	p.pause = limiter.NewPause()
+	p.handlePauseSignals(ctx)
+
+	waitFor := func(paused bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for p.pause.Paused() != paused {
+			if time.Now().After(deadline) {
+				t.Fatalf("wanted paused to be %t", paused)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(true)
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(false)
+}
@@ -3,6 +3,7 @@ package payloader
 import (
 	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
 	"github.com/pterm/pterm"
+	"sort"
 	"time"
 )
 
@@ -11,6 +12,7 @@ func (p *PayLoader) ComputeResults(workers []worker.Worker, results *GoPayloader
 	results.End = p.stopTime
 	results.Total = p.stopTime.Sub(p.startTime)
 	results.Errors = make(map[string]uint)
+	results.Divergences = make(map[string]uint)
 	results.Responses = make(map[worker.ResponseCode]int64)
 
 	pterm.Debug.Println("Calculating response code statistics")
@@ -19,6 +21,18 @@ func (p *PayLoader) ComputeResults(workers []worker.Worker, results *GoPayloader
 		stats := w.Stats()
 		results.CompletedReqs += stats.CompletedReqs
 		results.FailedReqs += stats.FailedReqs
+		results.DivergedReqs += stats.DivergedReqs
+		results.RetriedResets += stats.RetriedResets
+		results.RetryAfterPauses += stats.RetryAfterPauses
+		results.RetryAfterWait += stats.RetryAfterWait
+		results.ThinkTimes += stats.ThinkTimes
+		results.ThinkTime += stats.ThinkTime
+		results.DownloadedBytes += stats.DownloadedBytes
+		results.ChecksumMatches += stats.ChecksumMatches
+		results.OversizedResps += stats.OversizedResps
+		if stats.LargestOversized > results.LargestOversized {
+			results.LargestOversized = stats.LargestOversized
+		}
 
 		for err, count := range stats.Errors {
 			if _, ok := results.Errors[err]; ok {
@@ -28,6 +42,12 @@ func (p *PayLoader) ComputeResults(workers []worker.Worker, results *GoPayloader
 			}
 		}
 
+		for divergence, count := range stats.Divergences {
+			results.Divergences[divergence] += count
+		}
+
+		results.Slowest = append(results.Slowest, stats.Slowest...)
+
 		for code, val := range stats.Responses {
 			if _, ok := results.Responses[code]; ok {
 				results.Responses[code] += val
@@ -37,26 +57,177 @@ func (p *PayLoader) ComputeResults(workers []worker.Worker, results *GoPayloader
 		}
 	}
 
+	// each worker kept its own slowest, only the slowest of those are reported
+	sort.Slice(results.Slowest, func(i, j int) bool {
+		return results.Slowest[i].Latency > results.Slowest[j].Latency
+	})
+	if len(results.Slowest) > p.config.Slowest {
+		results.Slowest = results.Slowest[:p.config.Slowest]
+	}
+
+	if p.tlsStats != nil {
+		results.TLSHandshakes = p.tlsStats.Handshakes.Load()
+		results.TLSResumed = p.tlsStats.Resumed.Load()
+	}
+	if p.tcpStats != nil {
+		tcp := p.tcpStats.Summary()
+		results.TCP = &tcp
+	}
+	if p.rateLimiter != nil {
+		if backpressure, ok := p.rateLimiter.Backpressure(); ok {
+			results.Backpressure = &backpressure
+		}
+	}
+
+	// throughput only counts time requests were being sent
+	active := results.Total
+	if p.pause != nil {
+		results.Paused = p.pause.Total()
+		if results.Paused > results.Total {
+			// still paused when the run ended
+			results.Paused = results.Total
+		}
+		active -= results.Paused
+	}
+
+	p.computeTargets(workers, active, results)
+
+	if results.DownloadedBytes > 0 && active > 0 {
+		results.DownloadedPerSecond = int64(float64(results.DownloadedBytes) / active.Seconds())
+	}
+
 	if results.CompletedReqs > 0 {
 		results.Latency.Average = results.Latency.Total / time.Duration(results.CompletedReqs)
-		results.RPS.Average = float64(results.CompletedReqs) / (float64(results.Total) / float64(time.Second))
+		if results.TotalLatency != nil {
+			results.TotalLatency.Average = results.TotalLatency.Total / time.Duration(results.CompletedReqs)
+		}
+		if results.TTFB != nil && p.ttfbLatencies.total > 0 {
+			// requests which failed before a response started aren't counted
+			results.TTFB.Average = results.TTFB.Total / time.Duration(p.ttfbLatencies.total)
+		}
+		results.RPS.Average = float64(results.CompletedReqs) / (float64(active) / float64(time.Second))
+		results.SuccessfulResps = p.successfulResponses(results.Responses)
+		results.RPS.Goodput = float64(results.SuccessfulResps) / (float64(active) / float64(time.Second))
 
 		results.ReqByteSize.Single = workers[0].ReqSize()
 		results.ReqByteSize.Total = workers[0].ReqSize() * results.CompletedReqs
-		if numSeconds := int64(results.Total / time.Second); numSeconds == 0 {
+		if numSeconds := int64(active / time.Second); numSeconds == 0 {
 			results.ReqByteSize.PerSecond = workers[0].ReqSize() * results.CompletedReqs
 		} else {
-			results.ReqByteSize.PerSecond = (workers[0].ReqSize() * results.CompletedReqs) / int64(results.Total/time.Second)
+			results.ReqByteSize.PerSecond = (workers[0].ReqSize() * results.CompletedReqs) / int64(active/time.Second)
 		}
 
 		results.RespByteSize.Single = workers[0].RespSize()
 		results.RespByteSize.Total = workers[0].RespSize() * results.CompletedReqs
-		if numSeconds := int64(results.Total / time.Second); numSeconds == 0 {
+		if numSeconds := int64(active / time.Second); numSeconds == 0 {
 			results.RespByteSize.PerSecond = workers[0].RespSize() * results.CompletedReqs
 		} else {
-			results.RespByteSize.PerSecond = (workers[0].RespSize() * results.CompletedReqs) / int64(results.Total/time.Second)
+			results.RespByteSize.PerSecond = (workers[0].RespSize() * results.CompletedReqs) / int64(active/time.Second)
+		}
+	}
+
+	p.computeRateCheck(results, active)
+	if results.RateCheck != nil && !results.RateCheck.Met {
+		pterm.Warning.Printf("Achieved %.2f requests per second is below the target rate of %g, the intended load wasn't applied\n", results.RateCheck.Achieved, results.RateCheck.Target)
+	}
+
+	p.computeSLO(results)
+	if results.SLO != nil && !results.SLO.Passed {
+		pterm.Warning.Printf("Success rate %.3f%% is below the SLO of %g%%\n", results.SuccessRate, results.SLO.Target)
+	}
+
+	p.computePercentiles(results)
+	if results.PercentilesWarning != "" {
+		pterm.Warning.Printf("Latency percentiles not reported; %s\n", results.PercentilesWarning)
+	}
+	p.computeLatencySLOs(results)
+	for _, slo := range results.LatencySLOs {
+		switch {
+		case slo.Passed:
+		case slo.Path != "" && slo.Latency == 0:
+			pterm.Warning.Printf("No requests to %s completed to check its P%g latency SLO of %s\n", slo.Path, slo.Percentile, slo.Target)
+		case slo.Path != "":
+			pterm.Warning.Printf("P%g latency %s of %s is above the SLO of %s\n", slo.Percentile, slo.Latency, slo.Path, slo.Target)
+		default:
+			pterm.Warning.Printf("P%g latency %s is above the SLO of %s\n", slo.Percentile, slo.Latency, slo.Target)
 		}
 	}
 
+	p.computeConnOutliers(workers, results)
+	for _, o := range results.ConnOutliers {
+		pterm.Warning.Printf("Connection %d median latency %s is %.1fx the median of all connections; %d requests, %d sampled, p90 %s, max %s\n",
+			o.WorkerID, o.Median, o.Factor, o.Requests, o.Samples, o.P90, o.Max)
+	}
+	p.computeStatusBands(results)
+
+	p.computeBottleneck(results)
+
 	return results, nil
 }
+
+// computeTargets groups workers by the SRV target they dialed, so a slow or failing target isn't hidden by the
+// combined results
+func (p *PayLoader) computeTargets(workers []worker.Worker, active time.Duration, results *GoPayloaderResults) {
+	if len(p.srvAddrs) == 0 {
+		return
+	}
+
+	byAddr := make(map[string]*TargetResults)
+	latency := make(map[string]time.Duration)
+	for i, w := range workers {
+		addr := p.srvAddrs[i]
+		target, ok := byAddr[addr]
+		if !ok {
+			target = &TargetResults{Addr: addr}
+			byAddr[addr] = target
+		}
+		stats := w.Stats()
+		target.Conns++
+		target.CompletedReqs += stats.CompletedReqs
+		target.FailedReqs += stats.FailedReqs
+		latency[addr] += stats.Latency
+	}
+	if len(byAddr) < 2 {
+		// nothing to break down with a single target
+		return
+	}
+
+	for addr, target := range byAddr {
+		if total := target.CompletedReqs + target.FailedReqs; total > 0 {
+			target.ErrorRate = float64(target.FailedReqs) / float64(total) * 100
+		}
+		if target.CompletedReqs > 0 {
+			target.LatencyAverage = latency[addr] / time.Duration(target.CompletedReqs)
+			if active > 0 {
+				target.RPS = float64(target.CompletedReqs) / active.Seconds()
+			}
+		}
+		results.Targets = append(results.Targets, *target)
+	}
+	sort.Slice(results.Targets, func(i, j int) bool {
+		return results.Targets[i].Addr < results.Targets[j].Addr
+	})
+}
+
+// successfulResponses counts responses with one of the --success-codes, any 2xx without them
+func (p *PayLoader) successfulResponses(responses map[worker.ResponseCode]int64) int64 {
+	var successful int64
+	for code, count := range responses {
+		if p.isSuccessCode(int(code)) {
+			successful += count
+		}
+	}
+	return successful
+}
+
+func (p *PayLoader) isSuccessCode(code int) bool {
+	if len(p.config.SuccessCodes) == 0 {
+		return code >= 200 && code < 300
+	}
+	for _, c := range p.config.SuccessCodes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
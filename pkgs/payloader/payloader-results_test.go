@@ -0,0 +1,259 @@
+package payloader
+
+import (
+	"bytes"
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testWorker is a finished worker with the given stats, for testing results without sending requests
+type testWorker struct {
+	stats worker.Stats
+}
+
+func (w *testWorker) Run(wg *sync.WaitGroup) {
+	wg.Done()
+}
+
+func (w *testWorker) Stats() worker.Stats {
+	return w.stats
+}
+
+func (w *testWorker) Failed() int64 {
+	return w.stats.FailedReqs
+}
+
+func (w *testWorker) ReqSize() int64 {
+	return 100
+}
+
+func (w *testWorker) RespSize() int64 {
+	return 200
+}
+
+// computeResults computes the results of workers over a run of total
+func computeResults(t *testing.T, p *PayLoader, total time.Duration, workers ...worker.Worker) *GoPayloaderResults {
+	t.Helper()
+	p.stopTime = time.Now()
+	p.startTime = p.stopTime.Add(-total)
+	results, err := p.ComputeResults(workers, &GoPayloaderResults{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestPayLoader_ComputeResultsPaused(t *testing.T) {
+	p := NewPayLoader(&config.Config{})
+	p.pause = limiter.NewPause()
+	p.pause.Pause()
+	time.Sleep(50 * time.Millisecond)
+	p.pause.Resume()
+
+	results := computeResults(t, p, time.Second, &testWorker{stats: worker.Stats{CompletedReqs: 100}})
+	if results.Paused < 50*time.Millisecond || results.Paused > 500*time.Millisecond {
+		t.Errorf("wanted ~50ms paused got %s", results.Paused)
+	}
+	// throughput leaves out the time paused
+	wantRPS := 100 / (time.Second - results.Paused).Seconds()
+	if diff := results.RPS.Average - wantRPS; diff > 0.001 || diff < -0.001 {
+		t.Errorf("wanted average RPS %.3f excluding paused time got %.3f", wantRPS, results.RPS.Average)
+	}
+
+	// still paused when the run ended
+	p.pause.Pause()
+	time.Sleep(20 * time.Millisecond)
+	if results := computeResults(t, p, 10*time.Millisecond, &testWorker{}); results.Paused != results.Total {
+		t.Errorf("wanted paused time capped at the total %s got %s", results.Total, results.Paused)
+	}
+}
+
+func TestPayLoader_ComputeResultsGoodput(t *testing.T) {
+	w := &testWorker{stats: worker.Stats{
+		CompletedReqs: 100,
+		Responses:     map[worker.ResponseCode]int64{200: 40, 204: 10, 500: 50},
+	}}
+
+	results := computeResults(t, NewPayLoader(&config.Config{}), time.Second, w)
+	if results.SuccessfulResps != 50 {
+		t.Errorf("wanted the 2xx responses successful got %d", results.SuccessfulResps)
+	}
+	if results.RPS.Average != 100 || results.RPS.Goodput != 50 {
+		t.Errorf("wanted goodput of 50/s from 100/s got %.3f from %.3f", results.RPS.Goodput, results.RPS.Average)
+	}
+
+	results = computeResults(t, NewPayLoader(&config.Config{SuccessCodes: []int{200, 500}}), time.Second, w)
+	if results.SuccessfulResps != 90 || results.RPS.Goodput != 90 {
+		t.Errorf("wanted only the success codes counted got %d, %.3f/s", results.SuccessfulResps, results.RPS.Goodput)
+	}
+}
+
+func TestPayLoader_ComputeResultsOversized(t *testing.T) {
+	results := computeResults(t, NewPayLoader(&config.Config{}), time.Second,
+		&testWorker{stats: worker.Stats{CompletedReqs: 10, OversizedResps: 3, LargestOversized: 64 * 1024}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 10, OversizedResps: 1, LargestOversized: 96 * 1024}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 10}},
+	)
+	// summed across workers with the largest of any
+	if results.OversizedResps != 4 || results.LargestOversized != 96*1024 {
+		t.Errorf("wanted 4 oversized responses, the largest 96KB got %d, %d", results.OversizedResps, results.LargestOversized)
+	}
+}
+
+func TestPayLoader_ComputeResultsThinkTime(t *testing.T) {
+	results := computeResults(t, NewPayLoader(&config.Config{}), time.Second,
+		&testWorker{stats: worker.Stats{CompletedReqs: 10, ThinkTimes: 10, ThinkTime: time.Second}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 5, ThinkTimes: 5, ThinkTime: 300 * time.Millisecond}},
+	)
+	if results.ThinkTimes != 15 || results.ThinkTime != 1300*time.Millisecond {
+		t.Errorf("wanted 15 think times totalling 1.3s got %d totalling %s", results.ThinkTimes, results.ThinkTime)
+	}
+}
+
+func TestPayLoader_ComputeResultsSlowest(t *testing.T) {
+	slowest := func(latencies ...time.Duration) []worker.SlowRequest {
+		var s []worker.SlowRequest
+		for _, l := range latencies {
+			s = append(s, worker.SlowRequest{Latency: l})
+		}
+		return s
+	}
+	workers := []worker.Worker{
+		&testWorker{stats: worker.Stats{Slowest: slowest(30*time.Millisecond, 80*time.Millisecond, 10*time.Millisecond)}},
+		&testWorker{stats: worker.Stats{Slowest: slowest(20*time.Millisecond, 50*time.Millisecond)}},
+	}
+
+	// the slowest of every worker's, slowest first
+	results := computeResults(t, NewPayLoader(&config.Config{Slowest: 3}), time.Second, workers...)
+	var got []time.Duration
+	for _, r := range results.Slowest {
+		got = append(got, r.Latency)
+	}
+	if want := []time.Duration{80 * time.Millisecond, 50 * time.Millisecond, 30 * time.Millisecond}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted slowest %v got %v", want, got)
+	}
+
+	if results := computeResults(t, NewPayLoader(&config.Config{Slowest: 10}), time.Second, workers...); len(results.Slowest) != 5 {
+		t.Errorf("wanted all 5 requests when fewer were kept than asked for got %d", len(results.Slowest))
+	}
+}
+
+func TestPayLoader_ComputeResultsDownloaded(t *testing.T) {
+	results := computeResults(t, NewPayLoader(&config.Config{}), 2*time.Second,
+		&testWorker{stats: worker.Stats{CompletedReqs: 5, DownloadedBytes: 3 << 20, ChecksumMatches: 5}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 5, DownloadedBytes: 1 << 20, ChecksumMatches: 4}},
+	)
+	if results.DownloadedBytes != 4<<20 || results.ChecksumMatches != 9 {
+		t.Errorf("wanted every worker's downloads counted got %d bytes %d matches", results.DownloadedBytes, results.ChecksumMatches)
+	}
+	if results.DownloadedPerSecond != 2<<20 {
+		t.Errorf("wanted %d bytes downloaded per second got %d", 2<<20, results.DownloadedPerSecond)
+	}
+}
+
+func TestPayLoader_ComputeResultsTargets(t *testing.T) {
+	p := NewPayLoader(&config.Config{})
+	p.srvAddrs = []string{"10.0.0.1:80", "10.0.0.2:80", "10.0.0.1:80", "10.0.0.2:80"}
+	workers := []worker.Worker{
+		&testWorker{stats: worker.Stats{CompletedReqs: 100, Latency: 100 * time.Millisecond}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 15, FailedReqs: 5, Latency: 300 * time.Millisecond}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 100, Latency: 300 * time.Millisecond}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 15, FailedReqs: 5, Latency: 300 * time.Millisecond}},
+	}
+
+	results := computeResults(t, p, 2*time.Second, workers...)
+	want := []TargetResults{
+		{Addr: "10.0.0.1:80", Conns: 2, CompletedReqs: 200, RPS: 100, LatencyAverage: 2 * time.Millisecond},
+		{Addr: "10.0.0.2:80", Conns: 2, CompletedReqs: 30, FailedReqs: 10, RPS: 15, LatencyAverage: 20 * time.Millisecond, ErrorRate: 25},
+	}
+	if !reflect.DeepEqual(results.Targets, want) {
+		t.Errorf("wanted targets %+v got %+v", want, results.Targets)
+	}
+
+	// nothing to break down with a single target or without --srv-record
+	p.srvAddrs = []string{"10.0.0.1:80", "10.0.0.1:80", "10.0.0.1:80", "10.0.0.1:80"}
+	if results := computeResults(t, p, 2*time.Second, workers...); results.Targets != nil {
+		t.Errorf("wanted no targets for a single target got %+v", results.Targets)
+	}
+	p.srvAddrs = nil
+	if results := computeResults(t, p, 2*time.Second, workers...); results.Targets != nil {
+		t.Errorf("wanted no targets without an SRV record got %+v", results.Targets)
+	}
+}
+
+func TestPayLoader_ComputeResultsRateCheckWarning(t *testing.T) {
+	out := &bytes.Buffer{}
+	pterm.SetDefaultOutput(out)
+	pterm.DisableStyling()
+	defer func() {
+		pterm.SetDefaultOutput(os.Stdout)
+		pterm.EnableStyling()
+	}()
+
+	p := NewPayLoader(&config.Config{Conns: 2, Rate: 1000})
+	results := computeResults(t, p, time.Second, &testWorker{stats: worker.Stats{CompletedReqs: 40}})
+	if results.RateCheck == nil || results.RateCheck.Met {
+		t.Fatalf("wanted an unmet rate check got %+v", results.RateCheck)
+	}
+	if !strings.Contains(out.String(), "below the target rate of 1000") {
+		t.Errorf("wanted a warning about the target rate got %q", out.String())
+	}
+}
+
+// recordTimings records timings as the run's completed requests
+func recordTimings(p *PayLoader, results *GoPayloaderResults, timings ...http_clients.ReqTiming) {
+	recv := make(chan http_clients.ReqTiming, len(timings))
+	for _, t := range timings {
+		recv <- t
+	}
+	// the run is over so the buffered timings are counted and it returns
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.calcReqStats(ctx, recv, results)
+}
+
+func TestPayLoader_ComputeResultsTTFB(t *testing.T) {
+	p := NewPayLoader(&config.Config{TTFB: true})
+	p.latencies = newLatencyHistogram()
+	p.statusLatencies = make(map[worker.ResponseCode]*latencyHistogram)
+	p.ttfbLatencies = newLatencyHistogram()
+	results := &GoPayloaderResults{TTFB: &Latency{}}
+
+	sent := time.Now().UnixNano()
+	ms := int64(time.Millisecond)
+	recordTimings(p, results,
+		http_clients.ReqTiming{Sent: sent, FirstByte: sent + 20*ms, Done: sent + 70*ms},
+		http_clients.ReqTiming{Sent: sent, FirstByte: sent + 40*ms, Done: sent + 90*ms},
+		// failed before the response started
+		http_clients.ReqTiming{Sent: sent, Done: sent + 5*ms},
+	)
+	p.stopTime = time.Now()
+	p.startTime = p.stopTime.Add(-time.Second)
+	results, err := p.ComputeResults([]worker.Worker{&testWorker{stats: worker.Stats{CompletedReqs: 3}}}, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ttfb := results.TTFB
+	if ttfb.Min != 20*time.Millisecond || ttfb.Max != 40*time.Millisecond || ttfb.Average != 30*time.Millisecond {
+		t.Errorf("wanted ttfb min 20ms max 40ms average 30ms got %s %s %s", ttfb.Min, ttfb.Max, ttfb.Average)
+	}
+	if len(ttfb.Percentiles) == 0 {
+		t.Fatal("wanted ttfb percentiles")
+	}
+	for i, p := range ttfb.Percentiles {
+		if p.Latency > results.Latency.Percentiles[i].Latency {
+			t.Errorf("wanted P%g ttfb %s no more than latency %s", p.Percentile, p.Latency, results.Latency.Percentiles[i].Latency)
+		}
+	}
+}
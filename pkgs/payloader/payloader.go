@@ -2,18 +2,38 @@ package payloader
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/expr"
+	"github.com/domsolutions/gopayloader/pkgs/har"
 	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
 	jwt_generator "github.com/domsolutions/gopayloader/pkgs/jwt-generator"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/connreport"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/flamegraph"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/intervals"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/jtl"
 	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
 	"github.com/pterm/pterm"
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
+	"log"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"text/template"
 	"time"
 )
 
@@ -23,6 +43,8 @@ const (
 
 var (
 	JwtCacheDir string
+	// srvResolver looks up --srv-record
+	srvResolver http_clients.SRVResolver = net.DefaultResolver
 )
 
 func init() {
@@ -34,9 +56,32 @@ func init() {
 }
 
 type PayLoader struct {
-	config    *config.Config
-	startTime time.Time
-	stopTime  time.Time
+	config      *config.Config
+	startTime   time.Time
+	stopTime    time.Time
+	rateLimiter *limiter.Rate
+	connLimiter *limiter.Conns
+	errorWindow *limiter.Window
+	pause       *limiter.Pause
+	byteLimit   *limiter.Bytes
+	tlsStats    *http_clients.TLSStats
+	tcpStats    *http_clients.TCPStats
+	metrics     *metrics.Recorder
+	latencies   *latencyHistogram
+	// totalLatencies is from when requests were scheduled rather than sent, only with --open-model
+	totalLatencies *latencyHistogram
+	// ttfbLatencies are from when requests were sent to the first byte of their response, only with --ttfb
+	ttfbLatencies *latencyHistogram
+	// statusLatencies are the latencies of the responses with each status code
+	statusLatencies map[worker.ResponseCode]*latencyHistogram
+	// pathLatencies are the latencies of the requests to each path with a --slo-latency
+	pathLatencies map[string]*pathLatency
+	// srvAddrs is the address each worker dialed with --srv-record, by worker ID
+	srvAddrs     []string
+	stable       *stability
+	intervalRows *intervalRows
+	statsd       *statsdExport
+	sloGuard     *sloGuard
 }
 
 type GoPayloaderResults struct {
@@ -45,12 +90,100 @@ type GoPayloaderResults struct {
 	End           time.Time
 	CompletedReqs int64
 	FailedReqs    int64
+	DivergedReqs  int64
+	// RetriedResets is how many times requests were resent after the connection was reset, with --retry-on-reset
+	RetriedResets int64
 	RPS           RPS
 	Latency       Latency
 	Responses     map[worker.ResponseCode]int64
 	Errors        map[string]uint
+	Divergences   map[string]uint
 	ReqByteSize   ByteSize
 	RespByteSize  ByteSize
+	Intervals     []Interval
+	// TLSHandshakes is how many connections did a TLS handshake, TLSResumed how many of those resumed a session
+	TLSHandshakes int64
+	TLSResumed    int64
+	// TCP is the TCP_INFO of the connections, nil without --tcp-stats
+	TCP *http_clients.TCPSummary
+	// Backpressure is what --max-inflight held back, nil without it
+	Backpressure *limiter.Backpressure
+	// Paused is how long sending requests was paused for, it's left out of RPS and per second sizes
+	Paused time.Duration
+	// PercentilesWarning is set instead of Latency.Percentiles when there were fewer than --min-samples latencies
+	PercentilesWarning string
+	// SuccessRate is the percentage of requests which completed, SLO is nil unless --slo-success-rate is set.
+	// LatencySLOs are one for each --slo-latency
+	SuccessRate float64
+	SLO         *SLO
+	LatencySLOs []LatencySLO
+	// RateCheck is nil without --rate or --rate-per-conn, or when the rate changes while running
+	RateCheck *RateCheck
+	// Slowest is the --slowest completed requests, slowest first
+	Slowest []worker.SlowRequest
+	// TotalLatency is from when each request was scheduled to be sent rather than when it was, so includes time
+	// queued behind slow responses. Latency is only the service time, TotalLatency is nil without --open-model
+	TotalLatency *Latency
+	// TTFB is from when each request was sent to the first byte of its response, unlike Latency it excludes reading
+	// the body. It's nil without --ttfb
+	TTFB *Latency
+	// DownloadedBytes is the size of the streamed response bodies with --stream-response-body, ChecksumMatches how
+	// many of them matched --expect-sha256
+	DownloadedBytes     int64
+	DownloadedPerSecond int64
+	ChecksumMatches     int64
+	// Bottleneck is a heuristic guess at what limited the run i.e. likely server-bound, empty if there's nothing to
+	// go on
+	Bottleneck string
+	// Targets breaks the results down by the address connections dialed, only set when --srv-record spread them
+	// across more than one target
+	Targets []TargetResults
+	// Stability is nil without --run-until-stable
+	Stability *Stability
+	// Bandwidth is nil without --output-interval-csv
+	Bandwidth *Bandwidth
+	// ConnOutliers are the connections which were much slower than the rest with --conn-outlier-factor
+	ConnOutliers []ConnOutlier
+	// StatusBands splits the response codes by how fast the responses were, i.e. whether the slowest were mostly
+	// errors. Only set when there was more than one response code
+	StatusBands []StatusBand
+	// RetryAfterPauses is how many throttled responses had a Retry-After which was waited out with
+	// --honor-retry-after, RetryAfterWait the total time asked for
+	RetryAfterPauses int64
+	RetryAfterWait   time.Duration
+	// ThinkTimes is how many pauses connections took between requests with --adaptive-think-time, ThinkTime their
+	// total
+	ThinkTimes int64
+	ThinkTime  time.Duration
+	// OversizedResps is how many responses were larger than --max-expected-body, though they may have succeeded, and
+	// LargestOversized the largest of them in bytes
+	OversizedResps   int64
+	LargestOversized int64
+	// SuccessfulResps is how many responses had one of the --success-codes, any 2xx without them
+	SuccessfulResps int64
+	// TargetUsage is nil without --target-metrics-url
+	TargetUsage *TargetUsage
+	// SLOBreach is set when --abort-on-slo-breach stopped the run
+	SLOBreach *SLOBreach
+}
+
+// TargetResults are the results of the connections to one target, ErrorRate is the percentage of requests which
+// failed
+type TargetResults struct {
+	Addr           string
+	Conns          int
+	CompletedReqs  int64
+	FailedReqs     int64
+	RPS            float64
+	LatencyAverage time.Duration
+	ErrorRate      float64
+}
+
+// Interval is the successful requests completed in each second of the run
+type Interval struct {
+	Start          time.Time
+	Requests       int64
+	LatencyAverage time.Duration
 }
 
 type ByteSize struct {
@@ -63,6 +196,9 @@ type RPS struct {
 	Average float64
 	Max     int64
 	Min     int64
+	// Goodput is the average successful responses per second, see --success-codes. Unlike Average it drops when a
+	// broken server fails fast
+	Goodput float64
 }
 
 type Latency struct {
@@ -70,6 +206,18 @@ type Latency struct {
 	Max     time.Duration
 	Min     time.Duration
 	Total   time.Duration
+	// Percentiles are nil when there were too few samples, see GoPayloaderResults.PercentilesWarning
+	Percentiles []Percentile
+}
+
+func (l *Latency) observe(t time.Duration) {
+	if t > l.Max {
+		l.Max = t
+	}
+	if t < l.Min || l.Min == 0 {
+		l.Min = t
+	}
+	l.Total += t
 }
 
 func NewPayLoader(config *config.Config) *PayLoader {
@@ -88,10 +236,55 @@ func (p *PayLoader) startWorkers(wg *sync.WaitGroup) {
 	wg.Done()
 }
 
+// jwtsNeeded is how many JWTs the run takes, with --jwt-rotate-every each connection takes one for every so many
+// requests plus one for the requests left over
+func (p *PayLoader) jwtsNeeded() int64 {
+	if p.config.JwtRotateEvery <= 1 {
+		return p.config.ReqTarget
+	}
+	n := p.config.ReqTarget/p.config.JwtRotateEvery + int64(p.config.Conns)
+	if n > p.config.ReqTarget {
+		return p.config.ReqTarget
+	}
+	return n
+}
+
+// identityHeaders is headers with the --identities identity's own headers and JWT added, a connection sends all of its
+// requests as that identity
+func identityHeaders(headers []string, identity http_clients.Identity, jwtHeader string) []string {
+	// copied so the connections don't append to each other's headers
+	h := append(headers[:len(headers):len(headers)], identity.Headers...)
+	if identity.JWT != "" {
+		h = append(h, jwtHeader+": "+identity.JWT)
+	}
+	return h
+}
+
+// protoContentType adds the protobuf Content-Type to headers unless one is already set
+func protoContentType(headers []string) []string {
+	for _, h := range headers {
+		name, _, _ := strings.Cut(h, ":")
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Type") {
+			return headers
+		}
+	}
+	// copied so the config's headers aren't appended to
+	return append(headers[:len(headers):len(headers)], "Content-Type: application/x-protobuf")
+}
+
 func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 	var jwtErr <-chan error
 	var jwtStream <-chan string
 
+	var replay [][]har.Entry
+	if p.config.ReplayHAR != "" {
+		// loaded first as the recording decides how many requests are sent
+		var err error
+		if replay, err = p.loadReplay(); err != nil {
+			return nil, err
+		}
+	}
+
 	if p.config.SendJWT && p.config.ReqTarget != 0 {
 		if JwtCacheDir == "" {
 			pterm.Error.Println("Can't save jwts if no cache directory")
@@ -102,25 +295,29 @@ func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 		}
 
 		pterm.Info.Printf("Sending jwts with requests\n")
+		jwtCount := p.jwtsNeeded()
 		if p.config.JwtsFilename != "" {
-			pterm.Info.Printf("Using JWTs from %s \n", p.config.JwtsFilename)
-			jwtStream, jwtErr = jwt_generator.GetUserSuppliedJWTs(p.config.JwtsFilename, p.config.ReqTarget)
+			pterm.Info.Printf("Using JWTs from %s \n", strings.Join(p.config.JwtsFilenames, ", "))
+			jwtStream, jwtErr = jwt_generator.GetUserSuppliedJWTs(p.config.JwtsFilenames, jwtCount)
 		} else {
 			pterm.Info.Printf("Checking for JWTs in cache\n")
 			jwt := jwt_generator.NewJWTGenerator(&jwt_generator.Config{
 				Ctx:                 p.config.Ctx,
 				Kid:                 p.config.JwtKID,
 				JwtKeyPath:          p.config.JwtKey,
+				JwtKey:              p.config.JwtKeyBlob,
 				JwtSub:              p.config.JwtSub,
 				JwtCustomClaimsJSON: p.config.JwtCustomClaimsJSON,
+				JwtClaimsTemplate:   p.config.JwtClaimsTemplate,
 				JwtIss:              p.config.JwtIss,
 				JwtAud:              p.config.JwtAud,
+				Seed:                p.config.Seed,
 			})
 
-			if err := jwt.Generate(p.config.ReqTarget, JwtCacheDir, false); err != nil {
+			if err := jwt.Generate(jwtCount, JwtCacheDir, false); err != nil {
 				return nil, err
 			}
-			jwtStream, jwtErr = jwt.JWTS(p.config.ReqTarget)
+			jwtStream, jwtErr = jwt.JWTS(jwtCount)
 		}
 	}
 
@@ -146,36 +343,494 @@ func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 		msg := printer.Sprintf("Running requests for %s for %d connection/s against %s\n",
 			p.config.Duration, int(p.config.Conns), p.config.ReqURI)
 		pterm.Info.Printf(msg)
+	} else if p.config.ReqTarget == 0 {
+		msg := printer.Sprintf("Running requests with %d connection/s against %s\n", int(p.config.Conns), p.config.ReqURI)
+		pterm.Info.Printf(msg)
 	} else {
 		msg := printer.Sprintf("Running %d request/s with %d connection/s against %s\n", p.config.ReqTarget, int(p.config.Conns), p.config.ReqURI)
 		pterm.Info.Printf(msg)
 	}
+	p.warnTraceCredentials()
+
+	workerCtx := p.config.Ctx
+	if p.config.MaxBytes > 0 {
+		var stop context.CancelFunc
+		workerCtx, stop = context.WithCancel(p.config.Ctx)
+		defer stop()
+		p.byteLimit = limiter.NewBytes(p.config.MaxBytes, stop)
+		pterm.Info.Printf("Stopping once %s are transferred\n", printer.Sprintf("%d bytes", p.config.MaxBytes))
+	}
+	if p.config.RunUntilStable {
+		var stop context.CancelFunc
+		workerCtx, stop = context.WithCancel(workerCtx)
+		defer stop()
+		p.stable = newStability(p.config.StableThreshold, p.config.StableMin, stop)
+		pterm.Info.Printf("Stopping once p99 latency is stable, after at least %s and at most %s\n", p.config.StableMin, p.config.Duration)
+	}
+
+	// before the workers are made as they only set the path of requests when there's one to record
+	p.pathLatencies = p.newPathLatencies()
 
 	workers := make([]worker.Worker, p.config.Conns)
-	reqStats := make(chan time.Duration, 1000000)
+	reqStats := make(chan http_clients.ReqTiming, 1000000)
+	if p.config.AbortOnSLOBreach {
+		var stop context.CancelFunc
+		workerCtx, stop = context.WithCancel(workerCtx)
+		defer stop()
+		p.sloGuard = p.newSLOGuard(workers, stop)
+		pterm.Info.Printf("Stopping if the requests of the last %s breach the SLOs\n", p.config.SLOWindow)
+	}
+
+	var rateShares []*limiter.Rate
+	if p.config.Rate > 0 {
+		p.rateLimiter = p.newRate(p.config.Rate)
+		pterm.Info.Printf("Limiting to %.2f request/s across all connections\n", p.config.Rate)
+		if p.config.FairRate {
+			rateShares = p.rateLimiter.Split(int(p.config.Conns))
+			pterm.Info.Printf("Splitting the rate evenly, %.2f request/s per connection\n", p.config.Rate/float64(p.config.Conns))
+		}
+	}
+	if p.config.RatePerConn > 0 {
+		pterm.Info.Printf("Limiting to %.2f request/s per connection\n", p.config.RatePerConn)
+	}
+	if p.config.AdaptiveThinkTime > 0 {
+		pterm.Info.Printf("Pausing %s between each connection's requests, longer after errors and slow responses\n", p.config.AdaptiveThinkTime)
+	}
+	if p.config.OpenModel {
+		pterm.Info.Printf("Scheduling requests on an open model, total latency includes time queued when the target falls behind\n")
+	}
+	if p.config.MaxInflight > 0 {
+		drop := p.config.InflightOverflow == "drop"
+		p.rateLimiter.SetMaxInflight(p.config.MaxInflight, drop)
+		if drop {
+			pterm.Info.Printf("Dropping requests which arrive while %d are in flight\n", p.config.MaxInflight)
+		} else {
+			pterm.Info.Printf("Delaying requests which arrive while %d are in flight until one is done\n", p.config.MaxInflight)
+		}
+	}
+	if p.config.CompareURI != "" {
+		pterm.Info.Printf("Comparing every response against %s\n", p.config.CompareURI)
+	}
+
+	seed := p.config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	var requestLog *log.Logger
+	if p.config.LogSampleRate > 0 {
+		out := p.config.RequestLog
+		if out == nil {
+			out = os.Stderr
+		}
+		// log.Logger serialises writes so it's safe to share between workers
+		requestLog = log.New(out, "", log.LstdFlags|log.Lmicroseconds)
+	}
+
+	var resolver http_clients.Resolver
+	if p.config.DoHURL != "" {
+		// shared by all connections so the target is only resolved once per TTL
+		resolver = http_clients.NewDoHResolver(p.config.DoHURL, p.config.ReadTimeout+p.config.WriteTimeout, p.config.SkipVerify)
+		pterm.Info.Printf("Resolving target host via DNS-over-HTTPS %s\n", p.config.DoHURL)
+	}
+	lookupSRV := srvResolver
+	if p.config.DNSServer != "" {
+		addr, err := http_clients.DNSServerAddr(p.config.DNSServer)
+		if err != nil {
+			return nil, err
+		}
+		dns := http_clients.NewDNSResolver(addr)
+		// SRV records are likely to be in the same service discovery setup as the target
+		resolver, lookupSRV = dns, dns
+		pterm.Info.Printf("Resolving target host via DNS server %s\n", addr)
+	}
+	var srvAddrs []string
+	if p.config.SRVRecord != "" {
+		targets, err := http_clients.ResolveSRV(p.config.Ctx, lookupSRV, p.config.SRVRecord)
+		if err != nil {
+			return nil, err
+		}
+		// resolved once, connections stay with the target they're assigned for the whole run
+		srvAddrs = http_clients.AssignSRV(targets, int(p.config.Conns))
+		p.srvAddrs = srvAddrs
+		pterm.Info.Printf("Spreading connections across %d targets of SRV record %s\n", len(targets), p.config.SRVRecord)
+	}
+	var sourcePorts *http_clients.SourcePorts
+	if p.config.SourcePortRange != "" {
+		var err error
+		if sourcePorts, err = http_clients.NewSourcePorts(p.config.SourcePortRange); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Opening connections from source ports %s\n", p.config.SourcePortRange)
+	}
+	if p.config.AdaptiveBackoff {
+		p.errorWindow = limiter.NewWindow(p.config.BackoffWindow, backoffBuckets)
+		pterm.Info.Printf("Backing off when error rate over %s is above %.1f%%\n", p.config.BackoffWindow, p.config.BackoffThreshold*100)
+	}
+	p.tlsStats = &http_clients.TLSStats{}
+	if p.config.TCPStats {
+		p.tcpStats = http_clients.NewTCPStats()
+		pterm.Info.Printf("Collecting TCP stats of every connection\n")
+	}
+	var rootCAs *x509.CertPool
+	if p.config.CACert != "" {
+		var err error
+		if rootCAs, err = http_clients.LoadCertPool(p.config.CACert); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Verifying server certs against CA certs in %s\n", p.config.CACert)
+	}
+	clientCerts := []http_clients.CertPair{{Cert: p.config.MTLSCert, Key: p.config.MTLSKey}}
+	if p.config.MTLSCertDir != "" {
+		var err error
+		if clientCerts, err = http_clients.LoadCertDir(p.config.MTLSCertDir); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Assigning %d client certs from %s to connections\n", len(clientCerts), p.config.MTLSCertDir)
+	}
+	var identities []http_clients.Identity
+	if p.config.Identities != "" {
+		var err error
+		if identities, err = http_clients.LoadIdentities(p.config.Identities); err != nil {
+			return nil, err
+		}
+		// a cert for each identity, so connections are given the cert of the identity they act as
+		clientCerts = make([]http_clients.CertPair, len(identities))
+		for i, id := range identities {
+			clientCerts[i] = id.Cert
+		}
+		pterm.Info.Printf("Assigning %d identities from %s to connections\n", len(identities), p.config.Identities)
+	}
+	var sessionCaches []tls.ClientSessionCache
+	if p.config.TLSSessionResumption {
+		// shared so connections can resume sessions from any earlier connection to the target with the same client
+		// cert, a resumed session keeps the identity of the handshake it came from
+		sessionCaches = make([]tls.ClientSessionCache, len(clientCerts))
+		for i := range sessionCaches {
+			sessionCaches[i] = tls.NewLRUClientSessionCache(int(p.config.Conns))
+		}
+		pterm.Info.Printf("Resuming TLS sessions on new connections\n")
+	}
+
+	if p.config.MetricsAddr != "" {
+		p.metrics = metrics.NewRecorder()
+		stop, err := p.serveMetrics()
+		if err != nil {
+			return nil, err
+		}
+		defer stop()
+	}
+	var jtlWriter *jtl.Writer
+	if p.config.JTLPath != "" {
+		var err error
+		if jtlWriter, err = jtl.Create(p.config.JTLPath, int(p.config.Conns), p.config.SuccessCodes); err != nil {
+			return nil, err
+		}
+		// closed once workers are done so write errors are returned, this is for when the run fails before then
+		defer jtlWriter.Close()
+		pterm.Info.Printf("Writing every request to JTL file %s\n", p.config.JTLPath)
+	}
+	var connsReport *connreport.Writer
+	var connTrackers []*http_clients.ConnTracker
+	if p.config.ConnsReportPath != "" {
+		var err error
+		if connsReport, err = connreport.Create(p.config.ConnsReportPath); err != nil {
+			return nil, err
+		}
+		defer connsReport.Close()
+		pterm.Info.Printf("Writing every connection to connections report %s\n", p.config.ConnsReportPath)
+	}
+	var phases *flamegraph.Writer
+	if p.config.LatencyFlamegraph != "" {
+		var err error
+		if phases, err = flamegraph.Create(p.config.LatencyFlamegraph); err != nil {
+			return nil, err
+		}
+		defer phases.Close()
+		pterm.Info.Printf("Writing the phases of every request to latency flamegraph %s\n", p.config.LatencyFlamegraph)
+	}
+	if p.config.IntervalCSVPath != "" {
+		out, err := intervals.Create(p.config.IntervalCSVPath, p.config.LatencyFormat())
+		if err != nil {
+			return nil, err
+		}
+		defer out.Close()
+		p.intervalRows = newIntervalRows(out, workers)
+		pterm.Info.Printf("Writing a row every %s to interval CSV file %s\n", p.config.VerboseTicker, p.config.IntervalCSVPath)
+	}
+	if p.config.StatsDAddr != "" {
+		client, err := metrics.DialStatsD(p.config.StatsDAddr)
+		if err != nil {
+			return nil, err
+		}
+		defer client.Close()
+		p.statsd = newStatsdExport(client, workers)
+		pterm.Info.Printf("Sending metrics every %s to statsd %s\n", p.config.VerboseTicker, p.config.StatsDAddr)
+	}
+	if p.config.TraceHeader != "" {
+		pterm.Info.Printf("Sending a trace ID with every request in header %s\n", p.config.TraceHeader)
+	}
+	progressOut, err := p.openProgress()
+	if err != nil {
+		return nil, err
+	}
+	if progressOut != nil {
+		// closing it tells the frontend the run is over
+		defer progressOut.Close()
+	}
+
+	var bodyTemplate *template.Template
+	var reqIndex *atomic.Int64
+	if p.config.BodyTemplateFile != "" {
+		var err error
+		bodyTemplate, err = http_clients.ParseBodyTemplate(p.config.BodyTemplateFile)
+		if err != nil {
+			return nil, err
+		}
+		reqIndex = &atomic.Int64{}
+		pterm.Info.Printf("Rendering request bodies from template %s\n", p.config.BodyTemplateFile)
+	}
+	headerTemplates, err := http_clients.ParseHeaderTemplates(p.config.Headers)
+	if err != nil {
+		return nil, err
+	}
+	if len(headerTemplates) > 0 && reqIndex == nil {
+		reqIndex = &atomic.Int64{}
+	}
+	for _, h := range headerTemplates {
+		pterm.Info.Printf("Rendering header %s from its template on every request\n", h.Name)
+	}
+	uriTemplate, err := http_clients.ParseURITemplate(p.config.ReqURI)
+	if err != nil {
+		return nil, err
+	}
+	if uriTemplate != nil {
+		if reqIndex == nil {
+			reqIndex = &atomic.Int64{}
+		}
+		pterm.Info.Println("Rendering the request URL from its template on every request")
+	}
+	var dataCSV *http_clients.DataCSV
+	if p.config.DataCSV != "" {
+		if dataCSV, err = http_clients.LoadDataCSV(p.config.DataCSV); err != nil {
+			return nil, err
+		}
+		if reqIndex == nil {
+			reqIndex = &atomic.Int64{}
+		}
+		pterm.Info.Printf("Binding %d rows of %s to request templates\n", len(dataCSV.Rows), p.config.DataCSV)
+	}
+	var scenario []http_clients.ScenarioStep
+	if p.config.Scenario != "" {
+		if scenario, err = http_clients.LoadScenario(p.config.Scenario); err != nil {
+			return nil, err
+		}
+		if err := config.CheckScenario(scenario); err != nil {
+			return nil, err
+		}
+		if reqIndex == nil {
+			reqIndex = &atomic.Int64{}
+		}
+		pterm.Info.Printf("Sending the %d steps of scenario %s in order on each connection\n", len(scenario), p.config.Scenario)
+	}
+	templates := []*template.Template{bodyTemplate, uriTemplate}
+	for _, h := range headerTemplates {
+		templates = append(templates, h.Value)
+	}
+	templates = append(templates, http_clients.ScenarioTemplates(scenario)...)
+	if err := config.CheckDataFields(dataCSV, templates...); err != nil {
+		return nil, err
+	}
+	var queryFuzz *http_clients.QueryFuzz
+	if p.config.QueryFuzz != "" {
+		var err error
+		if queryFuzz, err = http_clients.ParseQueryFuzz(p.config.QueryFuzz); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Fuzzing query parameters %s\n", p.config.QueryFuzz)
+	}
+	switch p.config.ForceContentLength {
+	case "":
+	case http_clients.ContentLengthNone:
+		pterm.Warning.Printf("Sending malformed requests with a body but no Content-Length\n")
+	default:
+		pterm.Warning.Printf("Sending malformed requests with Content-Length: %s whatever the size of the body\n", p.config.ForceContentLength)
+	}
+	var methodLimit *limiter.Methods
+	if p.config.MethodConcurrency != "" {
+		caps, err := limiter.ParseMethods(p.config.MethodConcurrency)
+		if err != nil {
+			return nil, err
+		}
+		methodLimit = limiter.NewMethods(caps)
+		pterm.Info.Printf("Capping requests in flight by method %s\n", p.config.MethodConcurrency)
+	}
+	var expectSHA256 []byte
+	if p.config.StreamRespBody {
+		if p.config.ExpectSHA256 != "" {
+			var err error
+			if expectSHA256, err = hex.DecodeString(p.config.ExpectSHA256); err != nil {
+				return nil, err
+			}
+			pterm.Info.Printf("Streaming response bodies and verifying their SHA-256 is %s\n", p.config.ExpectSHA256)
+		} else {
+			pterm.Info.Printf("Streaming and discarding response bodies\n")
+		}
+	}
+	var assertJSONPath *jsonpath.Path
+	if p.config.AssertJSONPath != "" {
+		var err error
+		if assertJSONPath, err = jsonpath.Parse(p.config.AssertJSONPath); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Asserting json path %s of responses\n", p.config.AssertJSONPath)
+	}
+	var successExpr *expr.Expr
+	if p.config.SuccessExpr != "" {
+		var err error
+		if successExpr, err = expr.Parse(p.config.SuccessExpr); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Requests are successful when %s\n", successExpr)
+	}
+	body := p.config.Body
+	if p.config.BodyHex != "" {
+		// already checked it decodes when validating
+		b, err := config.DecodeBodyHex(p.config.BodyHex)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	headers, bodyFile := p.config.Headers, p.config.BodyFile
+	if p.config.BodyProtoMessage != "" {
+		// already checked it encodes when validating, encoded once here rather than by every worker
+		b, err := p.config.EncodeProtoBody()
+		if err != nil {
+			return nil, err
+		}
+		body, bodyFile, headers = string(b), "", protoContentType(headers)
+		pterm.Info.Printf("Sending the body as protobuf message %s, %d bytes\n", p.config.BodyProtoMessage, len(b))
+	}
+	if p.config.Interactive {
+		p.connLimiter = limiter.NewConns(int(p.config.Conns), int(p.config.Conns))
+	}
+	p.pause = limiter.NewPause()
+	if p.config.RampDown > 0 {
+		pterm.Info.Printf("Ramping down connections over the last %s\n", p.config.RampDown)
+	}
+	if p.config.ConnBatchSize > 0 && p.config.ConnBatchSize < p.config.Conns {
+		pterm.Info.Printf("Opening connections in batches of %d every %s\n", p.config.ConnBatchSize, p.config.ConnBatchInterval)
+	}
 
 	var conn uint
 	for conn = 0; conn < p.config.Conns; conn++ {
+		clientCert := int(conn) % len(clientCerts)
+		var sessionCache tls.ClientSessionCache
+		if sessionCaches != nil {
+			sessionCache = sessionCaches[clientCert]
+		}
 		c := &http_clients.Config{
-			ReqURI:           p.config.ReqURI,
-			DisableKeepAlive: p.config.DisableKeepAlive,
-			SkipVerify:       p.config.SkipVerify,
-			MTLSKey:          p.config.MTLSKey,
-			MTLSCert:         p.config.MTLSCert,
-			ReqTarget:        reqsPerWorker,
-			Ctx:              p.config.Ctx,
-			StartTrigger:     startTrigger,
-			Until:            p.config.Duration,
-			ReqEvery:         reqEvery,
-			ReadTimeout:      p.config.ReadTimeout,
-			WriteTimeout:     p.config.WriteTimeout,
-			Method:           p.config.Method,
-			Verbose:          p.config.Verbose,
-			Headers:          p.config.Headers,
-			Body:             p.config.Body,
-			BodyFile:         p.config.BodyFile,
-			ReqStats:         reqStats,
-			Client:           p.config.Client,
+			ReqURI:              p.config.ReqURI,
+			RawPath:             p.config.RawPath,
+			DisableKeepAlive:    p.config.DisableKeepAlive,
+			HTTP10:              p.config.HTTPVersion == "1.0",
+			CloseRate:           p.config.CloseRate,
+			MaxHeaderSize:       int(p.config.MaxHeaderSize),
+			MaxExpectedBody:     p.config.MaxExpectedBody,
+			StreamBody:          p.config.StreamRespBody,
+			DiscardBody:         p.config.DiscardBody,
+			TTFB:                p.config.TTFB,
+			ExpectSHA256:        expectSHA256,
+			SkipVerify:          p.config.SkipVerify,
+			MTLSKey:             clientCerts[clientCert].Key,
+			MTLSCert:            clientCerts[clientCert].Cert,
+			ReqTarget:           reqsPerWorker,
+			Ctx:                 workerCtx,
+			StartTrigger:        startTrigger,
+			Until:               p.rampDownUntil(conn),
+			StartDelay:          p.connBatchDelay(conn),
+			ReqEvery:            reqEvery,
+			ReadTimeout:         p.config.ReadTimeout,
+			WriteTimeout:        p.config.WriteTimeout,
+			TimeoutJitter:       p.config.TimeoutJitter,
+			MaxIdleConnDuration: p.config.MaxIdleConnDuration,
+			MaxConnDuration:     p.config.MaxConnDuration,
+			H2PingInterval:      p.config.H2PingInterval,
+			Method:              p.config.Method,
+			Verbose:             p.config.Verbose,
+			LogSampleRate:       p.config.LogSampleRate,
+			RequestLog:          requestLog,
+			Seed:                seed,
+			Headers:             headers,
+			HeaderTemplates:     headerTemplates,
+			HeaderOrder:         p.config.HeaderOrder,
+			ContentLength:       p.config.ForceContentLength,
+			Body:                body,
+			BodyFile:            bodyFile,
+			BodyStream:          p.config.BodyStream,
+			BodyTemplate:        bodyTemplate,
+			ReqIndex:            reqIndex,
+			Scenario:            scenario,
+			URITemplate:         uriTemplate,
+			DataCSV:             dataCSV,
+			QueryFuzz:           queryFuzz,
+			ExpectHeaders:       p.config.ExpectHeaders,
+			ExpectPresent:       p.config.ExpectHeadersPresent,
+			AssertJSONPath:      assertJSONPath,
+			AssertJSONEquals:    p.config.AssertJSONEquals,
+			SuccessExpr:         successExpr,
+			ValidateEvery:       p.config.ValidateEvery,
+			ReqStats:            reqStats,
+			ReqBytes:            p.intervalRows != nil,
+			ReqPath:             p.pathLatencies != nil,
+			LatencySamples:      p.latencySamples(),
+			Client:              p.config.Client,
+			WorkerID:            int(conn),
+			RateLimiter:         p.connRateLimiter(conn, rateShares),
+			ConnLimiter:         p.connLimiter,
+			ErrorWindow:         p.errorWindow,
+			Pause:               p.pause,
+			ByteLimit:           p.byteLimit,
+			MethodLimit:         methodLimit,
+			ConnectRetries:      p.config.ConnectRetries,
+			RetryOnReset:        p.config.RetryOnReset,
+			HonorRetryAfter:     p.config.HonorRetryAfter,
+			AdaptiveThinkTime:   p.config.AdaptiveThinkTime,
+			TLSSessionCache:     sessionCache,
+			RootCAs:             rootCAs,
+			TLSFingerprint:      p.config.TLSFingerprint,
+			TLSStats:            p.tlsStats,
+			TCPStats:            p.tcpStats,
+			TraceHeader:         p.config.TraceHeader,
+			HMACSecret:          p.config.HMACSecret,
+			HMACHeader:          p.config.HMACHeader,
+			HMACAlgo:            p.config.HMACAlgo,
+			ContentHashHeader:   p.config.ContentHashHeader,
+			Metrics:             p.metrics,
+			JTL:                 jtlWriter,
+			Slowest:             p.config.Slowest,
+			Resolver:            resolver,
+			SourcePorts:         sourcePorts,
+			CompareURI:          p.config.CompareURI,
+			CompareHeaders:      p.config.CompareHeaders,
+		}
+
+		if identities != nil {
+			c.Headers = identityHeaders(headers, identities[clientCert], p.config.JwtHeader)
+		}
+		if srvAddrs != nil {
+			c.DialAddr = srvAddrs[conn]
+		}
+		if phases != nil {
+			c.Flamegraph = phases
+			c.Phases = true
+		}
+		if connsReport != nil {
+			c.ConnTracker = http_clients.NewConnTracker(connsReport, int(conn))
+			connTrackers = append(connTrackers, c.ConnTracker)
+		}
+		if replay != nil {
+			c.Replay = replay[conn]
 		}
 
 		// evenly distribute remainder reqs
@@ -187,6 +842,7 @@ func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 		if p.config.SendJWT {
 			c.JwtStreamReceiver = jwtStream
 			c.JWTHeader = p.config.JwtHeader
+			c.JwtRotateEvery = p.config.JwtRotateEvery
 		}
 
 		w, err := worker.NewWorker(c)
@@ -206,9 +862,59 @@ func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 	if p.config.Verbose {
 		go p.displayProgress(ctx, workers, int(p.config.ReqTarget), p.config.Duration)
 	}
+	progressDone := make(chan struct{})
+	if progressOut != nil {
+		go func() {
+			p.streamProgress(ctx, workers, progressOut)
+			close(progressDone)
+		}()
+	} else {
+		close(progressDone)
+	}
+	// the bar is finished before anything else is printed so it isn't left sharing a line
+	barCtx, stopProgressBar := context.WithCancel(ctx)
+	defer stopProgressBar()
+	progressBarDone := make(chan struct{})
+	if out := progressBarOut(); p.config.Progress && out != nil {
+		go func() {
+			p.showProgressBar(barCtx, workers, out)
+			close(progressBarDone)
+		}()
+	} else {
+		close(progressBarDone)
+	}
+	if p.config.Interactive {
+		restore := p.startInteractive(ctx)
+		defer restore()
+	}
+	if p.config.AdaptiveBackoff {
+		go p.adaptiveBackoff(ctx)
+	}
+	p.handlePauseSignals(ctx)
 
 	results := &GoPayloaderResults{}
-	go p.calcReqStats(ctx, reqStats, results)
+	p.latencies = newLatencyHistogram()
+	p.statusLatencies = make(map[worker.ResponseCode]*latencyHistogram)
+	if p.config.OpenModel {
+		results.TotalLatency = &Latency{}
+		p.totalLatencies = newLatencyHistogram()
+	}
+	if p.config.TTFB {
+		results.TTFB = &Latency{}
+		p.ttfbLatencies = newLatencyHistogram()
+	}
+	statsDone := make(chan struct{})
+	go func() {
+		p.calcReqStats(ctx, reqStats, results)
+		close(statsDone)
+	}()
+	targetUsage := make(chan *TargetUsage, 1)
+	if p.config.TargetMetricsURL != "" {
+		pterm.Info.Printf("Scraping target metrics every %s from %s\n", p.config.TargetMetricsEvery, p.config.TargetMetricsURL)
+		go func() {
+			targetUsage <- p.scrapeTarget(ctx)
+		}()
+	}
 
 	if jwtErr != nil {
 		err, _ := <-jwtErr
@@ -219,42 +925,258 @@ func (p *PayLoader) handleReqs() (*GoPayloaderResults, error) {
 	}
 
 	workersComplete.Wait()
+	stopProgressBar()
+	<-progressBarDone
 	pterm.Success.Printf("Payload complete, calculating results\n")
+	if jtlWriter != nil {
+		if err := jtlWriter.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if connsReport != nil {
+		for _, t := range connTrackers {
+			t.Finish()
+		}
+		if err := connsReport.Close(); err != nil {
+			return nil, err
+		}
+		p.printConnCloses(connsReport.Counts())
+	}
+	if phases != nil {
+		if err := phases.Close(); err != nil {
+			return nil, err
+		}
+		pterm.Info.Printf("Wrote the phases of %d requests to latency flamegraph %s\n", phases.Requests(), p.config.LatencyFlamegraph)
+	}
+	if p.byteLimit != nil && p.byteLimit.Reached() {
+		pterm.Info.Printf("Stopped after transferring %s\n", printer.Sprintf("%d bytes", p.byteLimit.Total()))
+	}
 
 	p.stopTimer()
 	stopStatsCalc()
+	<-statsDone
+	<-progressDone
+	if p.config.TargetMetricsURL != "" {
+		results.TargetUsage = <-targetUsage
+	}
+	if p.sloGuard != nil && p.sloGuard.breach != nil {
+		results.SLOBreach = p.sloGuard.breach
+		pterm.Warning.Printf("Stopped after %s as %s\n", results.SLOBreach.After.Round(time.Millisecond), results.SLOBreach.Reason)
+	}
+	if p.intervalRows != nil {
+		results.Bandwidth = p.intervalRows.bandwidth()
+	}
+	if p.stable != nil {
+		stable := p.stable.result
+		results.Stability = &stable
+		if stable.Stable {
+			pterm.Info.Printf("Stopped after %s once p99 latency was stable at %s\n", stable.After.Round(time.Millisecond), stable.P99.Round(time.Microsecond))
+		} else {
+			pterm.Warning.Printf("p99 latency wasn't stable by the end of the time window\n")
+		}
+	}
 
 	return p.ComputeResults(workers, results)
 }
 
-func (p *PayLoader) calcReqStats(ctx context.Context, recv <-chan time.Duration, result *GoPayloaderResults) {
-	var t time.Duration
+// loadReplay reads the --replay-har recording and deals its requests out to connections in the order they were sent,
+// each request keeps its path and query but is sent to the target. With --replay-multiplier every request is repeated
+// straight after itself at the same offset, so the copies go out together on different connections
+func (p *PayLoader) loadReplay() ([][]har.Entry, error) {
+	entries, err := har.Load(p.config.ReplayHAR)
+	if err != nil {
+		return nil, err
+	}
+	target, err := url.Parse(p.config.ReqURI)
+	if err != nil {
+		return nil, err
+	}
+
+	multiplier := p.config.ReplayMultiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	replay := make([][]har.Entry, p.config.Conns)
+	var n int
+	for _, e := range entries {
+		recorded, err := url.Parse(e.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid url in HAR file %s; %v", e.URL, err)
+		}
+		u := *target
+		u.Path, u.RawPath, u.RawQuery = recorded.Path, recorded.RawPath, recorded.RawQuery
+		e.URL = u.String()
+		for i := 0; i < multiplier; i++ {
+			conn := n % int(p.config.Conns)
+			replay[conn] = append(replay[conn], e)
+			n++
+		}
+	}
+	p.config.ReqTarget = int64(n)
+	if multiplier > 1 {
+		pterm.Info.Printf("Replaying %d requests, %dx the %d recorded, over %s from HAR file %s\n", n, multiplier, len(entries), entries[len(entries)-1].Offset, p.config.ReplayHAR)
+	} else {
+		pterm.Info.Printf("Replaying %d requests over %s from HAR file %s\n", n, entries[len(entries)-1].Offset, p.config.ReplayHAR)
+	}
+	return replay, nil
+}
+
+// printConnCloses sums up the --connections-report by why connections were closed, most common first
+func (p *PayLoader) printConnCloses(counts map[string]int64) {
+	var total int64
+	reasons := make([]string, 0, len(counts))
+	for reason, n := range counts {
+		reasons = append(reasons, reason)
+		total += n
+	}
+	sort.Slice(reasons, func(i, j int) bool {
+		if counts[reasons[i]] != counts[reasons[j]] {
+			return counts[reasons[i]] > counts[reasons[j]]
+		}
+		return reasons[i] < reasons[j]
+	})
+	closes := make([]string, len(reasons))
+	for i, reason := range reasons {
+		closes[i] = fmt.Sprintf("%d %s", counts[reason], reason)
+	}
+	pterm.Info.Printf("Wrote %d connections to connections report %s; %s\n", total, p.config.ConnsReportPath, strings.Join(closes, ", "))
+}
+
+// connRateLimiter is the rate limiter connection conn waits on, its share of --rate with --fair-rate, its own with
+// --rate-per-conn or otherwise the one all connections share
+func (p *PayLoader) connRateLimiter(conn uint, shares []*limiter.Rate) *limiter.Rate {
+	if shares != nil {
+		return shares[conn]
+	}
+	if p.config.RatePerConn > 0 {
+		// each connection paces itself so total load grows with the number of connections
+		return p.newRate(p.config.RatePerConn)
+	}
+	return p.rateLimiter
+}
+
+// newRate returns a rate limiter which keeps to its schedule when requests fall behind with --open-model
+func (p *PayLoader) newRate(rate float64) *limiter.Rate {
+	if p.config.OpenModel {
+		return limiter.NewOpenRate(rate)
+	}
+	return limiter.NewRate(rate)
+}
+
+func (p *PayLoader) calcReqStats(ctx context.Context, recv <-chan http_clients.ReqTiming, result *GoPayloaderResults) {
+	var t http_clients.ReqTiming
 	var rps int64 = 0
+	var latency time.Duration
+	start := time.Now()
 	timer := time.NewTicker(time.Second)
+	var intervalTick <-chan time.Time
+	if p.intervalRows != nil {
+		p.intervalRows.start = start
+		tick := time.NewTicker(p.config.VerboseTicker)
+		defer tick.Stop()
+		intervalTick = tick.C
+	}
+	var statsdTick <-chan time.Time
+	if p.statsd != nil {
+		tick := time.NewTicker(p.config.VerboseTicker)
+		defer tick.Stop()
+		statsdTick = tick.C
+	}
+	var stableCheck <-chan time.Time
+	if p.stable != nil {
+		p.stable.start = start
+		tick := time.NewTicker(p.config.StableInterval)
+		defer tick.Stop()
+		stableCheck = tick.C
+	}
+	var sloCheck <-chan time.Time
+	if p.sloGuard != nil {
+		p.sloGuard.start = start
+		tick := time.NewTicker(p.config.SLOWindow / sloWindowSlices)
+		defer tick.Stop()
+		sloCheck = tick.C
+	}
+
+	observe := func(t http_clients.ReqTiming) {
+		service := time.Duration(t.Done - t.Sent)
+		rps++
+		latency += service
+		result.Latency.observe(service)
+		p.latencies.record(service)
+		p.recordStatusLatency(worker.ResponseCode(t.Status), service)
+		if t.Path != "" {
+			p.recordPathLatency(t.Path, service)
+		}
+		if p.stable != nil {
+			p.stable.record(service)
+		}
+		if p.sloGuard != nil {
+			p.sloGuard.record(t.Path, service)
+		}
+		if p.intervalRows != nil {
+			p.intervalRows.record(service, t.Bytes)
+		}
+		if p.statsd != nil {
+			p.statsd.client.Observe(service)
+		}
+
+		if result.TotalLatency != nil {
+			total := time.Duration(t.Done - t.Scheduled)
+			result.TotalLatency.observe(total)
+			p.totalLatencies.record(total)
+		}
+		if result.TTFB != nil && t.FirstByte >= t.Sent {
+			ttfb := time.Duration(t.FirstByte - t.Sent)
+			result.TTFB.observe(ttfb)
+			p.ttfbLatencies.record(ttfb)
+		}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			// req finished
-			return
+			// req finished, all workers are done so count the latencies still buffered
+			for {
+				select {
+				case t = <-recv:
+					observe(t)
+				default:
+					if p.intervalRows != nil {
+						// the last interval is cut short by the end of the run
+						p.intervalRows.flush(time.Now())
+					}
+					if p.statsd != nil {
+						p.statsd.flush()
+					}
+					return
+				}
+			}
 		case <-timer.C:
-			// new RPS
+			// new RPS, no requests are sent while paused so it'd always be the min
 			if rps > result.RPS.Max {
 				result.RPS.Max = rps
 			}
-			if rps < result.RPS.Min || result.RPS.Min == 0 {
+			if (rps < result.RPS.Min || result.RPS.Min == 0) && !p.pause.Paused() {
 				result.RPS.Min = rps
 			}
+			interval := Interval{Start: start, Requests: rps}
+			if rps > 0 {
+				interval.LatencyAverage = latency / time.Duration(rps)
+			}
+			result.Intervals = append(result.Intervals, interval)
+			start = start.Add(time.Second)
 			rps = 0
+			latency = 0
+		case now := <-stableCheck:
+			p.stable.check(now)
+		case now := <-sloCheck:
+			p.sloGuard.check(now)
+		case now := <-intervalTick:
+			p.intervalRows.flush(now)
+		case <-statsdTick:
+			p.statsd.flush()
 		case t = <-recv:
-			rps++
-			if t > result.Latency.Max {
-				result.Latency.Max = t
-			}
-			if t < result.Latency.Min || result.Latency.Min == 0 {
-				result.Latency.Min = t
-			}
-			result.Latency.Total += t
+			observe(t)
 		}
 	}
 }
@@ -262,7 +1184,7 @@ func (p *PayLoader) calcReqStats(ctx context.Context, recv <-chan time.Duration,
 func (p *PayLoader) displayProgress(ctx context.Context, workers []worker.Worker, reqTarget int, endTime time.Duration) {
 	tick := time.NewTicker(p.config.VerboseTicker)
 	var stats worker.Stats
-	var prevSuccess, prevError int64 = 0, 0
+	var prevSuccess, prevError, prevKB int64 = 0, 0, 0
 	var progress *pterm.ProgressbarPrinter
 
 	displayStats, err := pterm.DefaultArea.Start(
@@ -303,6 +1225,11 @@ func (p *PayLoader) displayProgress(ctx context.Context, workers []worker.Worker
 
 			if endTime != 0 {
 				progress.Add(int(p.config.VerboseTicker.Seconds()))
+			} else if reqTarget == 0 {
+				// only a byte limit
+				kb := p.byteLimit.Total() / 1024
+				progress.Add(int(kb - prevKB))
+				prevKB = kb
 			} else {
 				progress.Add(int(success-prevSuccess) + int(errs-prevError))
 			}
@@ -328,6 +1255,18 @@ func (p *PayLoader) getProgressBar(endTime time.Duration, reqTarget int) (*pterm
 		return progress, nil
 	}
 
+	if reqTarget == 0 {
+		progress, err := pterm.DefaultProgressbar.
+			WithTotal(int(p.config.MaxBytes / 1024)).
+			WithShowElapsedTime().
+			WithTitle("Sending requests until " + strconv.FormatInt(p.config.MaxBytes/1024, 10) + " KB are transferred").Start()
+		if err != nil {
+			pterm.Error.Printf("Failed to create progress bar, got error; %v \n", err)
+			return nil, err
+		}
+		return progress, nil
+	}
+
 	progress, err := pterm.DefaultProgressbar.WithTotal(reqTarget).WithTitle("Sending " + strconv.Itoa(reqTarget) + " requests").Start()
 	if err != nil {
 		pterm.Error.Printf("Failed to create progress bar, got error; %v \n", err)
@@ -3,17 +3,30 @@ package payloader
 import (
 	"context"
 	"crypto/tls"
+	"encoding/csv"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
 	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
 	"github.com/quic-go/quic-go"
 	httpv3server "github.com/quic-go/quic-go/http3"
 	"github.com/valyala/fasthttp"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -457,3 +470,678 @@ func testPayLoader_Run(t *testing.T, addr, client string, cleanup func()) {
 	}
 
 }
+
+func TestPayLoader_ConnRateLimiter(t *testing.T) {
+	// with --rate-per-conn each connection has its own rate
+	p := NewPayLoader(&config.Config{Conns: 3, RatePerConn: 10})
+	first, second := p.connRateLimiter(0, nil), p.connRateLimiter(1, nil)
+	if first == second || first.Rate() != 10 || second.Rate() != 10 {
+		t.Errorf("wanted a rate of 10 for each connection got %f and %f", first.Rate(), second.Rate())
+	}
+
+	// otherwise they share the total rate, or their share of it with --fair-rate
+	p = NewPayLoader(&config.Config{Conns: 3, Rate: 30})
+	p.rateLimiter = limiter.NewRate(30)
+	if p.connRateLimiter(0, nil) != p.rateLimiter || p.connRateLimiter(1, nil) != p.rateLimiter {
+		t.Error("wanted the connections to share the rate")
+	}
+	shares := p.rateLimiter.Split(3)
+	if got := p.connRateLimiter(2, shares); got != shares[2] || got.Rate() != 10 {
+		t.Errorf("wanted the connection's share of the rate got %f", got.Rate())
+	}
+}
+
+// testServer starts a target for a run, it's closed when the test finishes
+func testServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// testConfig is a valid config for a run of client against uri, change sets what the test is about
+func testConfig(t *testing.T, uri, client string, change func(c *config.Config)) *config.Config {
+	conf := &config.Config{
+		Ctx:           context.Background(),
+		ReqURI:        uri,
+		Conns:         1,
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  5 * time.Second,
+		Method:        "GET",
+		Client:        client,
+		VerboseTicker: time.Second,
+	}
+	change(conf)
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return conf
+}
+
+func TestPayLoader_RunOpenModel(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	// the single connection can do ~20 req/s so requests scheduled at 50 req/s queue behind each other
+	got, err := NewPayLoader(testConfig(t, server.URL, worker.HttpClientFastHTTP1, func(c *config.Config) {
+		c.ReqTarget = 20
+		c.Rate = 50
+		c.OpenModel = true
+	})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CompletedReqs != 20 {
+		t.Fatalf("wanted 20 completed reqs got %d", got.CompletedReqs)
+	}
+	if got.TotalLatency == nil {
+		t.Fatal("wanted total latency with open model")
+	}
+	// the last request is scheduled at 380ms but can't be sent until ~950ms
+	if got.TotalLatency.Max < got.Latency.Max+400*time.Millisecond {
+		t.Errorf("wanted max total latency to include time queued, got %s total %s service", got.TotalLatency.Max, got.Latency.Max)
+	}
+	if got.TotalLatency.Average <= got.Latency.Average {
+		t.Errorf("wanted average total latency to exceed service latency, got %s total %s service", got.TotalLatency.Average, got.Latency.Average)
+	}
+	if len(got.TotalLatency.Percentiles) != len(got.Latency.Percentiles) {
+		t.Errorf("wanted total latency percentiles got %v", got.TotalLatency.Percentiles)
+	}
+}
+
+func TestPayLoader_RunReplayHAR(t *testing.T) {
+	// as exported by a browser, recorded against another host
+	recording := filepath.Join(t.TempDir(), "recording.har")
+	if err := os.WriteFile(recording, []byte(`{"log": {"version": "1.2", "entries": [
+		{"startedDateTime": "2024-05-01T10:00:00.600Z", "request": {"method": "DELETE", "url": "https://shop.example.com/cart/1",
+			"headers": [{"name": "X-Req-Id", "value": "4"}]}},
+		{"startedDateTime": "2024-05-01T10:00:00Z", "request": {"method": "GET", "url": "https://shop.example.com/products?page=2",
+			"headers": [{"name": ":authority", "value": "shop.example.com"}, {"name": "Cookie", "value": "session=abc"}, {"name": "X-Req-Id", "value": "1"}]}},
+		{"startedDateTime": "2024-05-01T10:00:00Z", "request": {"method": "POST", "url": "https://shop.example.com/cart",
+			"headers": [{"name": "Content-Type", "value": "application/json"}, {"name": "X-Req-Id", "value": "2"}],
+			"postData": {"mimeType": "application/json", "text": "{\"product\":1}"}}},
+		{"startedDateTime": "2024-05-01T10:00:00.300Z", "request": {"method": "PUT", "url": "https://shop.example.com/cart/1?qty=3",
+			"headers": [{"name": "X-Req-Id", "value": "3"}], "postData": {"text": "3"}}}
+	]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type replayed struct {
+		method, uri, host, cookie, contentType, body string
+		at                                           time.Time
+	}
+	want := map[string]replayed{
+		"1": {method: "GET", uri: "/products?page=2", cookie: "session=abc"},
+		"2": {method: "POST", uri: "/cart", contentType: "application/json", body: `{"product":1}`},
+		"3": {method: "PUT", uri: "/cart/1?qty=3", body: "3"},
+		"4": {method: "DELETE", uri: "/cart/1"},
+	}
+
+	for _, client := range []string{worker.HttpClientFastHTTP1, worker.HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			got := make(map[string]replayed)
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				got[r.Header.Get("X-Req-Id")] = replayed{
+					method:      r.Method,
+					uri:         r.RequestURI,
+					host:        r.Host,
+					cookie:      r.Header.Get("Cookie"),
+					contentType: r.Header.Get("Content-Type"),
+					body:        string(body),
+					at:          time.Now(),
+				}
+				mu.Unlock()
+			})
+
+			results, err := NewPayLoader(testConfig(t, server.URL, client, func(c *config.Config) {
+				c.Conns = 2
+				c.ReplayHAR = recording
+			})).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results.CompletedReqs != 4 {
+				t.Errorf("wanted 4 completed requests got %d; %v", results.CompletedReqs, results.Errors)
+			}
+
+			host := strings.TrimPrefix(server.URL, "http://")
+			mu.Lock()
+			defer mu.Unlock()
+			for id, w := range want {
+				g := got[id]
+				if g.method != w.method || g.uri != w.uri || g.host != host || g.cookie != w.cookie || g.body != w.body ||
+					(w.contentType != "" && g.contentType != w.contentType) {
+					t.Errorf("request %s wanted %+v got %+v", id, w, g)
+				}
+			}
+			// the recorded timings are kept, allowing for scheduling delay
+			if gap := got["4"].at.Sub(got["1"].at); gap < 550*time.Millisecond || gap > time.Second {
+				t.Errorf("wanted the last request ~600ms after the first got %s", gap)
+			}
+			if gap := got["3"].at.Sub(got["1"].at); gap < 250*time.Millisecond {
+				t.Errorf("wanted the third request ~300ms after the first got %s", gap)
+			}
+		})
+	}
+
+	// the recording is only read for the run
+	invalid := filepath.Join(t.TempDir(), "invalid.har")
+	if err := os.WriteFile(invalid, []byte("not a recording"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := testConfig(t, "http://localhost:8888", worker.HttpClientFastHTTP1, func(c *config.Config) { c.ReplayHAR = invalid })
+	if _, err := NewPayLoader(conf).Run(); err == nil {
+		t.Error("wanted error for a replay har which isn't a recording")
+	}
+}
+
+func TestPayLoader_RunIntervalCSV(t *testing.T) {
+	var reqs atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if reqs.Add(1)%10 == 0 {
+			// drops the connection so the request fails, POSTs aren't retried
+			panic(http.ErrAbortHandler)
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("some response"))
+	})
+
+	path := filepath.Join(t.TempDir(), "intervals.csv")
+	results, err := NewPayLoader(testConfig(t, server.URL, worker.HttpClientNetHTTP, func(c *config.Config) {
+		c.Duration = time.Second
+		c.Conns = 2
+		c.Method = "POST"
+		c.VerboseTicker = 200 * time.Millisecond
+		c.IntervalCSVPath = path
+	})).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rows[0], ",") != "timestamp,requests,rps,errors,p50_ms,p99_ms,bytes,bytes_per_sec" {
+		t.Fatalf("wanted header got %v", rows[0])
+	}
+	rows = rows[1:]
+	// one every 200ms then the rest of the last
+	if len(rows) < 5 || len(rows) > 6 {
+		t.Fatalf("wanted 5 or 6 intervals got %d; %v", len(rows), rows)
+	}
+
+	size := results.ReqByteSize.Single + results.RespByteSize.Single
+	var requests, failed int64
+	var prev time.Time
+	for i, row := range rows {
+		ts, err := time.Parse("2006-01-02T15:04:05.000Z07:00", row[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// the last interval is cut short by the end of the run so can end in the same millisecond as the one before
+		if ts.Before(prev) || (i < len(rows)-1 && !ts.After(prev)) {
+			t.Errorf("wanted intervals in order got %s after %s", ts, prev)
+		}
+		prev = ts
+		n, _ := strconv.ParseInt(row[1], 10, 64)
+		rps, _ := strconv.ParseFloat(row[2], 64)
+		errs, _ := strconv.ParseInt(row[3], 10, 64)
+		p50, _ := strconv.ParseFloat(row[4], 64)
+		p99, _ := strconv.ParseFloat(row[5], 64)
+		b, _ := strconv.ParseInt(row[6], 10, 64)
+		requests += n
+		failed += errs
+
+		if i < len(rows)-1 {
+			// full intervals, each request takes at least 10ms
+			if n == 0 || p50 < 10 || p99 < p50 {
+				t.Errorf("wanted requests in interval %d got %v", i, row)
+			}
+			if diff := rps - float64(n)/0.2; diff > 5 || diff < -5 {
+				t.Errorf("wanted rps over 200ms in interval %d got %v", i, row)
+			}
+		}
+		if b != n*size {
+			t.Errorf("wanted %d bytes of %d byte requests in interval %d got %d", n*size, size, i, b)
+		}
+	}
+	if requests != results.CompletedReqs || failed != results.FailedReqs || failed == 0 {
+		t.Errorf("wanted intervals to add up to %d completed and %d failed got %d and %d", results.CompletedReqs, results.FailedReqs, requests, failed)
+	}
+}
+
+func TestPayLoader_RunRepeat(t *testing.T) {
+	var served atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+	})
+
+	results, err := Repeat(testConfig(t, server.URL, worker.HttpClientFastHTTP1, func(c *config.Config) {
+		c.ReqTarget = 200
+		c.Conns = 2
+		c.MinSamples = 100
+		c.Repeat = 3
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results.Runs) != 3 || served.Load() != 600 {
+		t.Fatalf("wanted 3 runs of 200 requests got %d runs and %d requests", len(results.Runs), served.Load())
+	}
+	if results.CompletedReqs != 600 || results.FailedReqs != 0 {
+		t.Errorf("wanted 600 completed requests across runs got %d completed %d failed", results.CompletedReqs, results.FailedReqs)
+	}
+
+	var rpsSum float64
+	minRPS, maxRPS := results.Runs[0].RPS.Average, results.Runs[0].RPS.Average
+	for _, run := range results.Runs {
+		if run.CompletedReqs != 200 {
+			t.Errorf("wanted 200 completed requests in each run got %d", run.CompletedReqs)
+		}
+		rpsSum += run.RPS.Average
+		if run.RPS.Average < minRPS {
+			minRPS = run.RPS.Average
+		}
+		if run.RPS.Average > maxRPS {
+			maxRPS = run.RPS.Average
+		}
+	}
+	if mean := rpsSum / 3; math.Abs(results.RPS.Mean-mean) > 1e-6 {
+		t.Errorf("wanted mean RPS %f got %f", mean, results.RPS.Mean)
+	}
+	if results.RPS.Min != minRPS || results.RPS.Max != maxRPS {
+		t.Errorf("wanted RPS between %f and %f got %+v", minRPS, maxRPS, results.RPS)
+	}
+	if results.P99 == nil || results.P99.Min > results.P99.Mean || results.P99.Mean > results.P99.Max {
+		t.Errorf("wanted p99 spread across runs got %+v", results.P99)
+	}
+}
+
+func TestPayLoader_RunDataCSV(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.csv")
+	if err := os.WriteFile(data, []byte("id,method,user\n1,GET,alice\n2,PUT,bob\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tmpl := filepath.Join(dir, "body.tmpl")
+	if err := os.WriteFile(tmpl, []byte(`{"user": "{{.Data.user}}"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []string{worker.HttpClientFastHTTP1, worker.HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			var reqs []string
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				mu.Lock()
+				reqs = append(reqs, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-User"), b))
+				mu.Unlock()
+			})
+
+			results, err := NewPayLoader(testConfig(t, server.URL+"/users/{{.Data.id}}", client, func(c *config.Config) {
+				c.ReqTarget = 4
+				c.Headers = []string{"X-User: {{.Data.user}}"}
+				c.BodyTemplateFile = tmpl
+				c.DataCSV = data
+			})).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results.CompletedReqs != 4 {
+				t.Fatalf("wanted 4 completed requests got %d; %v", results.CompletedReqs, results.Errors)
+			}
+
+			alice := `GET /users/1 alice {"user": "alice"}`
+			bob := `PUT /users/2 bob {"user": "bob"}`
+			want := []string{alice, bob, alice, bob}
+			mu.Lock()
+			defer mu.Unlock()
+			if strings.Join(reqs, "\n") != strings.Join(want, "\n") {
+				t.Errorf("wanted the rows bound to requests in turn got\n%s", strings.Join(reqs, "\n"))
+			}
+		})
+	}
+
+	// the csv is only read for the run
+	conf := testConfig(t, "http://localhost:8888/users/{{.Data.email}}", worker.HttpClientFastHTTP1, func(c *config.Config) {
+		c.ReqTarget = 1
+		c.DataCSV = data
+	})
+	if _, err := NewPayLoader(conf).Run(); err == nil || !strings.Contains(err.Error(), "no column for email") {
+		t.Errorf("wanted missing column error got %v", err)
+	}
+}
+
+func TestPayLoader_RunScenario(t *testing.T) {
+	scenario := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(scenario, []byte(`{"steps": [
+		{"name": "login", "method": "POST", "path": "/login", "headers": ["Content-Type: application/json"],
+			"body": "{\"user\": \"u{{.WorkerID}}\"}",
+			"extract": [{"var": "token", "jsonpath": "$.auth.token"}, {"var": "session", "header": "X-Session"}]},
+		{"name": "orders", "path": "/orders?session={{.Vars.session}}",
+			"headers": ["Authorization: Bearer {{.Vars.token}}", "X-User: u{{.WorkerID}}"]}
+	]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []string{worker.HttpClientFastHTTP1, worker.HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var logins, orders, denied atomic.Int64
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/login":
+					var login struct{ User string }
+					if err := json.NewDecoder(r.Body).Decode(&login); err != nil || r.Method != "POST" {
+						w.WriteHeader(http.StatusBadRequest)
+						return
+					}
+					n := logins.Add(1)
+					w.Header().Set("X-Session", fmt.Sprintf("s%d", n))
+					fmt.Fprintf(w, `{"auth": {"token": "tok-%s-%d"}}`, login.User, n)
+				case "/orders":
+					orders.Add(1)
+					// the token was issued to the same connection's user, with the session it was issued with
+					token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+					session := r.URL.Query().Get("session")
+					if token != fmt.Sprintf("tok-%s-%s", r.Header.Get("X-User"), strings.TrimPrefix(session, "s")) {
+						denied.Add(1)
+						w.WriteHeader(http.StatusUnauthorized)
+					}
+				}
+			})
+
+			results, err := NewPayLoader(testConfig(t, server.URL, client, func(c *config.Config) {
+				c.ReqTarget = 8
+				c.Conns = 2
+				c.Scenario = scenario
+			})).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results.CompletedReqs != 8 || results.Responses[200] != 8 {
+				t.Errorf("wanted 8 completed requests all 200 got %d, %v; %v", results.CompletedReqs, results.Responses, results.Errors)
+			}
+			if logins.Load() != 4 || orders.Load() != 4 || denied.Load() != 0 {
+				t.Errorf("wanted 4 logins and 4 authorised orders got %d logins, %d orders, %d denied", logins.Load(), orders.Load(), denied.Load())
+			}
+		})
+	}
+
+	// a step which fails to extract starts the scenario again rather than sending the steps which need it
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/orders" {
+			t.Errorf("orders sent without a token")
+		}
+		fmt.Fprint(w, `{"auth": {}}`)
+	})
+	conf := testConfig(t, server.URL, worker.HttpClientFastHTTP1, func(c *config.Config) {
+		c.ReqTarget = 3
+		c.Scenario = scenario
+	})
+	results, err := NewPayLoader(conf).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "scenario step login expected json path $.auth.token to extract token"
+	if results.FailedReqs != 3 || results.Errors[key] != 3 {
+		t.Errorf("wanted 3 failed extractions got %d; %v", results.FailedReqs, results.Errors)
+	}
+
+	// steps can only use what the steps before them extract
+	if err := os.WriteFile(scenario, []byte(`{"steps": [
+		{"path": "/orders", "headers": ["Authorization: Bearer {{.Vars.token}}"]},
+		{"method": "POST", "path": "/login", "extract": [{"var": "token", "jsonpath": "$.token"}]}
+	]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// the steps are only read for the run
+	if _, err := NewPayLoader(conf).Run(); err == nil || !strings.Contains(err.Error(), "step 1 uses token before a step extracts it") {
+		t.Errorf("wanted error for a variable used before it's extracted got %v", err)
+	}
+}
+
+func TestPayLoader_RunAbortOnSLOBreach(t *testing.T) {
+	// the target slows down a second into the run
+	start := time.Now()
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if time.Since(start) > time.Second {
+			time.Sleep(200 * time.Millisecond)
+		}
+	})
+
+	conf := testConfig(t, server.URL, worker.HttpClientFastHTTP1, func(c *config.Config) {
+		c.Duration = 20 * time.Second
+		c.Conns = 4
+		c.SLOLatency = []string{"p99=100ms"}
+		c.AbortOnSLOBreach = true
+		c.SLOWindow = time.Second
+		c.MinSamples = 5
+	})
+	results, err := NewPayLoader(conf).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.SLOBreach == nil {
+		t.Fatal("wanted the run to stop on the latency SLO breach")
+	}
+	if !strings.HasPrefix(results.SLOBreach.Reason, "p99 latency") || !strings.HasSuffix(results.SLOBreach.Reason, "over the last 1s is over the SLO of 100ms") {
+		t.Errorf("wanted the p99 latency SLO breached got %s", results.SLOBreach.Reason)
+	}
+	if results.SLOBreach.After < time.Second || results.SLOBreach.After > 3*time.Second || results.Total > 5*time.Second {
+		t.Errorf("wanted the run stopped soon after the target slowed down got stopped after %s of %s", results.SLOBreach.After, results.Total)
+	}
+
+	// a window which keeps to the SLOs runs to the end
+	conf.SLOLatency = []string{"p99=1s"}
+	conf.Duration = 2 * time.Second
+	results, err = NewPayLoader(conf).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results.SLOBreach != nil || results.Total < 2*time.Second {
+		t.Errorf("wanted the run to finish got stopped after %s; %+v", results.Total, results.SLOBreach)
+	}
+}
+
+func TestPayLoader_RunConnectionsReport(t *testing.T) {
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	read := func(t *testing.T, path string) [][]string {
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		rows, err := csv.NewReader(f).ReadAll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) == 0 || strings.Join(rows[0], ",") != "connection,opened,closed,lifetime_ms,requests,close_reason,local_addr,remote_addr" {
+			t.Fatalf("wanted the header got %v", rows)
+		}
+		return rows[1:]
+	}
+
+	for _, client := range []string{worker.HttpClientFastHTTP1, worker.HttpClientNetHTTP} {
+		t.Run(client+" churn", func(t *testing.T) {
+			conns.Store(0)
+			path := filepath.Join(t.TempDir(), "conns.csv")
+			// the last request of neither worker closes its connection with this seed
+			results, err := NewPayLoader(testConfig(t, server.URL, client, func(c *config.Config) {
+				c.ReqTarget = 100
+				c.Conns = 2
+				c.Seed = 6
+				c.CloseRate = 0.2
+				c.ConnsReportPath = path
+			})).Run()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if results.CompletedReqs != 100 {
+				t.Fatalf("wanted 100 completed reqs got %d; %v", results.CompletedReqs, results.Errors)
+			}
+
+			rows := read(t, path)
+			// every connection the server saw is reported once with the requests it served
+			if int64(len(rows)) != conns.Load() {
+				t.Errorf("wanted %d connections got %d", conns.Load(), len(rows))
+			}
+			var requests int64
+			reasons := make(map[string]int)
+			workers := make(map[string]bool)
+			for _, row := range rows {
+				n, _ := strconv.ParseInt(row[4], 10, 64)
+				requests += n
+				reasons[row[5]]++
+				workers[row[0]] = true
+				opened, err := time.Parse("2006-01-02T15:04:05.000Z07:00", row[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+				closed, err := time.Parse("2006-01-02T15:04:05.000Z07:00", row[2])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if closed.Before(opened) || row[6] == "" || row[7] != strings.TrimPrefix(server.URL, "http://") {
+					t.Errorf("wanted a connection opened before closed with its addresses got %v", row)
+				}
+			}
+			if requests != 100 {
+				t.Errorf("wanted the connections to have served 100 requests got %d", requests)
+			}
+			// the close rate closes ~20 connections, the last of each worker's is closed as it finishes
+			if reasons["connection: close"] < 10 || reasons["end of run"] != 2 || len(reasons) != 2 {
+				t.Errorf("wanted connections closed by connection: close and 2 at the end of the run got %v", reasons)
+			}
+			if !workers["1"] || !workers["2"] || len(workers) != 2 {
+				t.Errorf("wanted connections of workers 1 and 2 got %v", workers)
+			}
+		})
+
+		t.Run(client+" lifetime", func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "conns.csv")
+			if _, err := NewPayLoader(testConfig(t, server.URL, client, func(c *config.Config) {
+				c.Duration = 600 * time.Millisecond
+				c.Rate = 100
+				c.MaxConnDuration = 100 * time.Millisecond
+				c.ConnsReportPath = path
+			})).Run(); err != nil {
+				t.Fatal(err)
+			}
+			reasons := make(map[string]int)
+			for _, row := range read(t, path) {
+				reasons[row[5]]++
+			}
+			if reasons["max lifetime"] < 2 {
+				t.Errorf("wanted connections closed after their max lifetime got %v", reasons)
+			}
+		})
+	}
+}
+
+func TestPayLoader_JwtsNeeded(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+		want   int64
+	}{
+		{name: "every request", config: config.Config{ReqTarget: 20, Conns: 2}, want: 20},
+		{name: "rotate every 1", config: config.Config{ReqTarget: 20, Conns: 2, JwtRotateEvery: 1}, want: 20},
+		// one for every 4 requests plus one for each connection's leftover requests
+		{name: "rotate every 4", config: config.Config{ReqTarget: 20, Conns: 2, JwtRotateEvery: 4}, want: 7},
+		{name: "no more than requests", config: config.Config{ReqTarget: 3, Conns: 4, JwtRotateEvery: 2}, want: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewPayLoader(&tt.config).jwtsNeeded(); got != tt.want {
+				t.Errorf("wanted %d jwts got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestProtoContentType(t *testing.T) {
+	headers := make([]string, 1, 2)
+	headers[0] = "Accept: */*"
+	got := protoContentType(headers)
+	if want := []string{"Accept: */*", "Content-Type: application/x-protobuf"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted headers %v got %v", want, got)
+	}
+	// appended to a copy so the config's headers, which have room for it, aren't changed
+	if extra := headers[:2]; extra[1] != "" {
+		t.Errorf("wanted the config's headers left as they were got %v", extra)
+	}
+
+	set := []string{"content-type : application/vnd.shop+protobuf"}
+	if got := protoContentType(set); !reflect.DeepEqual(got, set) {
+		t.Errorf("wanted a set Content-Type kept got %v", got)
+	}
+}
+
+func TestPayLoader_LoadReplay(t *testing.T) {
+	recording := filepath.Join(t.TempDir(), "recording.har")
+	if err := os.WriteFile(recording, []byte(`{"log": {"version": "1.2", "entries": [
+		{"startedDateTime": "2024-05-01T10:00:00Z", "request": {"method": "GET", "url": "https://shop.example.com/products?page=2"}},
+		{"startedDateTime": "2024-05-01T10:00:00.200Z", "request": {"method": "GET", "url": "https://shop.example.com/cart"}},
+		{"startedDateTime": "2024-05-01T10:00:00.400Z", "request": {"method": "POST", "url": "https://shop.example.com/checkout"}}
+	]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := NewPayLoader(&config.Config{ReqURI: "http://localhost:8888", Conns: 3, ReplayHAR: recording, ReplayMultiplier: 2})
+	replay, err := p.loadReplay()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2x the recording doubles the requests
+	if p.config.ReqTarget != 6 {
+		t.Errorf("wanted a target of 6 requests got %d", p.config.ReqTarget)
+	}
+	// each copy straight after the request on the next connection, at the same offset
+	want := [][]string{
+		{"0s GET http://localhost:8888/products?page=2", "200ms GET http://localhost:8888/cart"},
+		{"0s GET http://localhost:8888/products?page=2", "400ms POST http://localhost:8888/checkout"},
+		{"200ms GET http://localhost:8888/cart", "400ms POST http://localhost:8888/checkout"},
+	}
+	got := make([][]string, len(replay))
+	for i, entries := range replay {
+		for _, e := range entries {
+			got[i] = append(got[i], fmt.Sprintf("%s %s %s", e.Offset, e.Method, e.URL))
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("wanted requests dealt out as %v got %v", want, got)
+	}
+}
+
+func TestIdentityHeaders(t *testing.T) {
+	headers := make([]string, 1, 4)
+	headers[0] = "Accept: */*"
+	a := identityHeaders(headers, http_clients.Identity{Name: "a", JWT: "jwt-a", Headers: []string{"X-Tenant: a"}}, "some-jwt")
+	b := identityHeaders(headers, http_clients.Identity{Name: "b", Headers: []string{"X-Tenant: b"}}, "some-jwt")
+	// each connection has its own headers even though the config's have room for them
+	if want := []string{"Accept: */*", "X-Tenant: a", "some-jwt: jwt-a"}; !reflect.DeepEqual(a, want) {
+		t.Errorf("wanted headers %v got %v", want, a)
+	}
+	if want := []string{"Accept: */*", "X-Tenant: b"}; !reflect.DeepEqual(b, want) {
+		t.Errorf("wanted headers without a jwt %v got %v", want, b)
+	}
+}
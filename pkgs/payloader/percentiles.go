@@ -0,0 +1,122 @@
+package payloader
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// histogramGrowth is how much wider each bucket is than the one before, percentiles are within 1%
+	histogramGrowth = 1.01
+	// histogramMin is the upper bound of the first bucket, anything faster is counted in it
+	histogramMin = time.Microsecond
+	// histogramBuckets covers up to ~100s, slower latencies are counted in the last bucket
+	histogramBuckets = 1852
+)
+
+// percentiles reported when there are at least --min-samples latencies
+var percentiles = []float64{50, 90, 95, 99}
+
+type Percentile struct {
+	Percentile float64
+	Latency    time.Duration
+}
+
+// latencyHistogram buckets latencies exponentially so percentiles can be calculated without keeping every sample,
+// which would grow without limit on long running tests
+type latencyHistogram struct {
+	counts []int64
+	total  int64
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{counts: make([]int64, histogramBuckets)}
+}
+
+func (h *latencyHistogram) record(d time.Duration) {
+	h.counts[histogramBucket(d)]++
+	h.total++
+}
+
+// add counts the latencies of o in h too
+func (h *latencyHistogram) add(o *latencyHistogram) {
+	for i, count := range o.counts {
+		h.counts[i] += count
+	}
+	h.total += o.total
+}
+
+// histogramBucket is the index of the bucket d is counted in
+func histogramBucket(d time.Duration) int {
+	if d <= histogramMin {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(histogramMin)) / math.Log(histogramGrowth)))
+	if i >= histogramBuckets {
+		i = histogramBuckets - 1
+	}
+	return i
+}
+
+// bucketUpper is the upper bound of the ith bucket
+func bucketUpper(i int) time.Duration {
+	return time.Duration(float64(histogramMin) * math.Pow(histogramGrowth, float64(i)))
+}
+
+// percentile returns the upper bound of the bucket the pth percentile latency is in
+func (h *latencyHistogram) percentile(p float64) time.Duration {
+	i := h.percentileBucket(p)
+	if i < 0 {
+		return 0
+	}
+	return bucketUpper(i)
+}
+
+// percentileBucket returns the index of the bucket the pth percentile latency is in, -1 if there isn't one
+func (h *latencyHistogram) percentileBucket(p float64) int {
+	rank := int64(math.Ceil(p / 100 * float64(h.total)))
+	var seen int64
+	for i, count := range h.counts {
+		seen += count
+		if seen >= rank {
+			return i
+		}
+	}
+	return -1
+}
+
+// computePercentiles sets the latency percentiles, unless there are too few samples for them to mean anything in
+// which case a warning is set instead
+func (p *PayLoader) computePercentiles(results *GoPayloaderResults) {
+	if p.latencies == nil || p.latencies.total == 0 {
+		return
+	}
+	if p.latencies.total < p.config.MinSamples {
+		results.PercentilesWarning = fmt.Sprintf("only %d latency samples, fewer than the minimum of %d",
+			p.latencies.total, p.config.MinSamples)
+		return
+	}
+
+	results.Latency.Percentiles = p.latencies.percentiles(results.Latency.Max)
+	if results.TotalLatency != nil {
+		results.TotalLatency.Percentiles = p.totalLatencies.percentiles(results.TotalLatency.Max)
+	}
+	if results.TTFB != nil && p.ttfbLatencies.total > 0 {
+		results.TTFB.Percentiles = p.ttfbLatencies.percentiles(results.TTFB.Max)
+	}
+}
+
+// percentiles returns each of the reported percentiles, max is the slowest latency recorded
+func (h *latencyHistogram) percentiles(max time.Duration) []Percentile {
+	res := make([]Percentile, 0, len(percentiles))
+	for _, pc := range percentiles {
+		latency := h.percentile(pc)
+		// the bucket's upper bound can be past the slowest request
+		if latency > max {
+			latency = max
+		}
+		res = append(res, Percentile{Percentile: pc, Latency: latency})
+	}
+	return res
+}
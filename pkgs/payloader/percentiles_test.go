@@ -0,0 +1,72 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram_Percentile(t *testing.T) {
+	h := newLatencyHistogram()
+	for i := 1; i <= 100; i++ {
+		h.record(time.Duration(i) * time.Millisecond)
+	}
+
+	// the bucket's upper bound is within 1% of the actual latency
+	for _, tt := range []struct {
+		p    float64
+		want time.Duration
+	}{
+		{p: 50, want: 50 * time.Millisecond},
+		{p: 90, want: 90 * time.Millisecond},
+		{p: 99, want: 99 * time.Millisecond},
+		{p: 100, want: 100 * time.Millisecond},
+	} {
+		got := h.percentile(tt.p)
+		if got < tt.want || got > tt.want+tt.want/100 {
+			t.Errorf("wanted P%g within 1%% of %s got %s", tt.p, tt.want, got)
+		}
+	}
+
+	if histogramBucket(0) != 0 || histogramBucket(time.Hour) != histogramBuckets-1 {
+		t.Error("wanted latencies outside the histogram counted in the first and last buckets")
+	}
+}
+
+func TestPayLoader_ComputePercentiles(t *testing.T) {
+	p := NewPayLoader(&config.Config{MinSamples: 100})
+	p.latencies = newLatencyHistogram()
+	for i := 0; i < 5; i++ {
+		p.latencies.record(time.Millisecond)
+	}
+
+	results := &GoPayloaderResults{}
+	p.computePercentiles(results)
+	// shown after "not reported" so it doesn't say so itself
+	if results.PercentilesWarning != "only 5 latency samples, fewer than the minimum of 100" {
+		t.Errorf("wanted a warning about too few samples got %q", results.PercentilesWarning)
+	}
+	if len(results.Latency.Percentiles) != 0 {
+		t.Errorf("wanted no percentiles from 5 samples got %v", results.Latency.Percentiles)
+	}
+
+	for i := 1; i <= 195; i++ {
+		p.latencies.record(time.Duration(i) * 10 * time.Microsecond)
+	}
+	results = &GoPayloaderResults{}
+	results.Latency.Min, results.Latency.Max = 10*time.Microsecond, 1950*time.Microsecond
+	p.computePercentiles(results)
+	if results.PercentilesWarning != "" {
+		t.Errorf("wanted no warning got %q", results.PercentilesWarning)
+	}
+	if len(results.Latency.Percentiles) != 4 {
+		t.Fatalf("wanted 4 percentiles got %v", results.Latency.Percentiles)
+	}
+	prev := results.Latency.Min
+	for _, pc := range results.Latency.Percentiles {
+		if pc.Latency < prev || pc.Latency > results.Latency.Max {
+			t.Errorf("wanted P%g latency %s between %s and max %s", pc.Percentile, pc.Latency, prev, results.Latency.Max)
+		}
+		prev = pc.Latency
+	}
+}
@@ -0,0 +1,93 @@
+package payloader
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+	"os"
+	"time"
+)
+
+// ProgressSnapshot is written as a line of JSON to --progress-fd or --progress-pipe every --ticker, for frontends
+// showing the progress of a run. RPS is over the time since the last snapshot, the last snapshot has Done set
+type ProgressSnapshot struct {
+	Time           time.Time `json:"time"`
+	ElapsedSeconds float64   `json:"elapsed_seconds"`
+	CompletedReqs  int64     `json:"completed_requests"`
+	FailedReqs     int64     `json:"failed_requests"`
+	RPS            float64   `json:"rps"`
+	// TargetReqs is -r, 0 when the run is limited by time or bytes
+	TargetReqs int64 `json:"target_requests"`
+	Done       bool  `json:"done"`
+}
+
+// openProgress opens --progress-fd or --progress-pipe, nil if neither is set. A pipe which doesn't exist is created as
+// a regular file
+func (p *PayLoader) openProgress() (*os.File, error) {
+	if p.config.ProgressFD > 0 {
+		f := os.NewFile(uintptr(p.config.ProgressFD), "progress")
+		if f == nil {
+			return nil, fmt.Errorf("invalid progress fd %d", p.config.ProgressFD)
+		}
+		return f, nil
+	}
+	if p.config.ProgressPipe != "" {
+		// opening a named pipe blocks until the frontend opens it for reading
+		f, err := os.OpenFile(p.config.ProgressPipe, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open progress pipe; %v", err)
+		}
+		return f, nil
+	}
+	return nil, nil
+}
+
+// streamProgress writes a snapshot every --ticker until ctx is done, then the last one
+func (p *PayLoader) streamProgress(ctx context.Context, workers []worker.Worker, out *os.File) {
+	enc := json.NewEncoder(out)
+	tick := time.NewTicker(p.config.VerboseTicker)
+	defer tick.Stop()
+
+	var prevCompleted int64
+	prevTime := p.startTime
+	write := func(done bool) bool {
+		now := time.Now()
+		s := ProgressSnapshot{
+			Time:           now,
+			ElapsedSeconds: now.Sub(p.startTime).Seconds(),
+			TargetReqs:     p.config.ReqTarget,
+			Done:           done,
+		}
+		for _, w := range workers {
+			stats := w.Stats()
+			s.CompletedReqs += stats.CompletedReqs
+			s.FailedReqs += stats.FailedReqs
+		}
+		if elapsed := now.Sub(prevTime).Seconds(); elapsed > 0 {
+			s.RPS = float64(s.CompletedReqs-prevCompleted) / elapsed
+		}
+		prevCompleted, prevTime = s.CompletedReqs, now
+
+		if err := enc.Encode(&s); err != nil {
+			// the frontend went away, the run carries on without it
+			pterm.Warning.Printf("Stopped writing progress; %v\n", err)
+			return false
+		}
+		return true
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// workers finished
+			write(true)
+			return
+		case <-tick.C:
+			if !write(false) {
+				return
+			}
+		}
+	}
+}
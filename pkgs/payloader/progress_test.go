@@ -0,0 +1,99 @@
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package payloader
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"github.com/domsolutions/gopayloader/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_RunProgressPipe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	pipe := filepath.Join(t.TempDir(), "progress")
+	if err := syscall.Mkfifo(pipe, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// read like a frontend would, until gopayloader closes its end
+	snapshots := make(chan []ProgressSnapshot, 1)
+	go func() {
+		var read []ProgressSnapshot
+		defer func() { snapshots <- read }()
+		f, err := os.Open(pipe)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var s ProgressSnapshot
+			if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+				t.Errorf("snapshot isn't valid json %s; %v", scanner.Text(), err)
+				return
+			}
+			read = append(read, s)
+		}
+	}()
+
+	conf := &config.Config{
+		Ctx:           context.Background(),
+		ReqURI:        server.URL,
+		Duration:      time.Second,
+		Conns:         2,
+		ReadTimeout:   5 * time.Second,
+		WriteTimeout:  5 * time.Second,
+		Method:        "GET",
+		Client:        "fasthttp-1",
+		VerboseTicker: 200 * time.Millisecond,
+		ProgressPipe:  pipe,
+	}
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	results, err := NewPayLoader(conf).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var read []ProgressSnapshot
+	select {
+	case read = <-snapshots:
+	case <-time.After(5 * time.Second):
+		t.Fatal("progress pipe wasn't closed once the run finished")
+	}
+	// one every 200ms then the last
+	if len(read) < 4 || len(read) > 7 {
+		t.Fatalf("wanted ~6 snapshots got %d", len(read))
+	}
+	for i, s := range read {
+		if i > 0 && (s.CompletedReqs < read[i-1].CompletedReqs || !s.Time.After(read[i-1].Time)) {
+			t.Errorf("wanted snapshots in order with growing counts got %+v after %+v", s, read[i-1])
+		}
+		if s.Done != (i == len(read)-1) {
+			t.Errorf("wanted only the last snapshot to be done got %+v at %d", s, i)
+		}
+		if !s.Done && (s.CompletedReqs == 0 || s.RPS <= 0 || s.ElapsedSeconds <= 0) {
+			t.Errorf("wanted requests to be progressing got %+v", s)
+		}
+	}
+	if last := read[len(read)-1]; last.CompletedReqs != results.CompletedReqs || last.FailedReqs != results.FailedReqs {
+		t.Errorf("wanted the last snapshot to match the results got %+v for %d completed", last, results.CompletedReqs)
+	}
+
+	conf.ProgressFD = 1
+	conf.ProgressPipe = ""
+	if err := conf.Validate(); err == nil {
+		t.Error("wanted error for writing progress to stdout")
+	}
+}
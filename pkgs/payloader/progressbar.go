@@ -0,0 +1,68 @@
+package payloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is how many characters the bar itself takes up
+const progressBarWidth = 30
+
+// progressBarOut is where --progress is drawn, nil when stderr isn't a terminal as the line is redrawn in place which
+// would fill a log with every update. Replaced in tests
+var progressBarOut = func() io.Writer {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+	return os.Stderr
+}
+
+// renderProgressBar is a line showing how far through target requests a run is and, going by the rate so far, how
+// much longer it'll take
+func renderProgressBar(sent, target int64, elapsed time.Duration) string {
+	if sent > target {
+		sent = target
+	}
+	filled := int(sent * progressBarWidth / target)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+	eta := "-"
+	if sent > 0 {
+		eta = (time.Duration(float64(elapsed) / float64(sent) * float64(target-sent))).Round(time.Second).String()
+	}
+	return fmt.Sprintf("[%s] %3d%% %d/%d requests, ETA %s", bar, sent*100/target, sent, target, eta)
+}
+
+// showProgressBar redraws the --progress bar every --ticker until ctx is done, then draws it a last time and moves on
+// to a new line
+func (p *PayLoader) showProgressBar(ctx context.Context, workers []worker.Worker, out io.Writer) {
+	tick := time.NewTicker(p.config.VerboseTicker)
+	defer tick.Stop()
+
+	draw := func() {
+		var sent int64
+		for _, w := range workers {
+			stats := w.Stats()
+			sent += stats.CompletedReqs + stats.FailedReqs
+		}
+		// padded to clear what's left of a longer line before it
+		fmt.Fprintf(out, "\r%-80s", renderProgressBar(sent, p.config.ReqTarget, time.Since(p.startTime)))
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// workers finished
+			draw()
+			fmt.Fprintln(out)
+			return
+		case <-tick.C:
+			draw()
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package payloader
+
+import (
+	"bytes"
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderProgressBar(t *testing.T) {
+	tests := []struct {
+		name    string
+		sent    int64
+		elapsed time.Duration
+		want    string
+	}{
+		{name: "not started", want: "[" + strings.Repeat(" ", 30) + "]   0% 0/40 requests, ETA -"},
+		// the rest go at the rate so far
+		{name: "half way", sent: 20, elapsed: 10 * time.Second, want: "[" + strings.Repeat("=", 15) + strings.Repeat(" ", 15) + "]  50% 20/40 requests, ETA 10s"},
+		{name: "finished", sent: 40, elapsed: 20 * time.Second, want: "[" + strings.Repeat("=", 30) + "] 100% 40/40 requests, ETA 0s"},
+		// failed requests after the target was reached
+		{name: "over target", sent: 45, elapsed: 20 * time.Second, want: "[" + strings.Repeat("=", 30) + "] 100% 40/40 requests, ETA 0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := renderProgressBar(tt.sent, 40, tt.elapsed); got != tt.want {
+				t.Errorf("wanted %q got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPayLoader_ShowProgressBar(t *testing.T) {
+	p := NewPayLoader(&config.Config{ReqTarget: 40, VerboseTicker: 10 * time.Millisecond})
+	p.startTime = time.Now()
+	workers := []worker.Worker{
+		&testWorker{stats: worker.Stats{CompletedReqs: 15}},
+		&testWorker{stats: worker.Stats{CompletedReqs: 4, FailedReqs: 1}},
+	}
+
+	out := &bytes.Buffer{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.showProgressBar(ctx, workers, out)
+		close(done)
+	}()
+	time.Sleep(35 * time.Millisecond)
+	cancel()
+	<-done
+
+	if !strings.HasSuffix(out.String(), "\n") {
+		t.Errorf("wanted the bar to end on a new line got %q", out.String())
+	}
+	frames := strings.Split(strings.TrimSpace(out.String()), "\r")
+	// redrawn every tick and a last time once done
+	if len(frames) < 3 {
+		t.Fatalf("wanted the bar redrawn while running got %q", frames)
+	}
+	for i, f := range frames {
+		if !strings.Contains(f, "50% 20/40 requests") {
+			t.Errorf("wanted completed and failed requests counted got %q", f)
+		}
+		// padded to clear what's left of a longer line before it, the last line's padding was trimmed above
+		if i < len(frames)-1 && len(f) != 80 {
+			t.Errorf("wanted the line padded to 80 characters got %d", len(f))
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package payloader
+
+import (
+	"errors"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+)
+
+// Protocol is a HTTP version run by --compare-protocols and the client used to send it
+type Protocol struct {
+	Name   string
+	Client string
+}
+
+var Protocols = []Protocol{
+	{Name: "HTTP/1.1", Client: worker.HttpClientFastHTTP1},
+	{Name: "HTTP/2", Client: worker.HttpClientFastHTTP2},
+	{Name: "HTTP/3", Client: worker.HttpClientNetHTTP3},
+}
+
+type ProtocolResults struct {
+	Protocol Protocol
+	Results  *GoPayloaderResults
+	// Skipped is why the protocol wasn't run i.e. the target doesn't support it, Results is nil when set
+	Skipped string
+}
+
+// CompareProtocols runs the same workload over each of the Protocols one after the other. A single request is sent
+// first with each client so protocols the target doesn't support are skipped rather than failing for the whole run
+func CompareProtocols(conf *config.Config) ([]ProtocolResults, error) {
+	results := make([]ProtocolResults, 0, len(Protocols))
+	for _, protocol := range Protocols {
+		if conf.Ctx.Err() != nil {
+			// user aborted, return what's been run so far
+			break
+		}
+
+		c := *conf
+		c.Client = protocol.Client
+		c.CompareProtocols = false
+
+		pterm.Info.Printf("Checking %s support with %s client\n", protocol.Name, protocol.Client)
+		if err := probe(c); err != nil {
+			pterm.Warning.Printf("Skipping %s; %v\n", protocol.Name, err)
+			results = append(results, ProtocolResults{Protocol: protocol, Skipped: err.Error()})
+			continue
+		}
+
+		pterm.Info.Printf("Running %s with %s client\n", protocol.Name, protocol.Client)
+		res, err := NewPayLoader(&c).Run()
+		if err != nil {
+			return nil, fmt.Errorf("%s run failed; %v", protocol.Name, err)
+		}
+		results = append(results, ProtocolResults{Protocol: protocol, Results: res})
+	}
+	return results, nil
+}
+
+// probe sends a single request with the client
+func probe(conf config.Config) error {
+	_, err := probeRun(conf, 1)
+	return err
+}
+
+// probeRun sends reqs requests one after the other on a single connection, anything which only makes sense for a full
+// run is turned off. It fails if none of them completed
+func probeRun(conf config.Config, reqs int64) (*GoPayloaderResults, error) {
+	conf.ReqTarget = reqs
+	conf.Conns = 1
+	conf.Duration = 0
+	conf.MaxBytes = 0
+	conf.Rate = 0
+	conf.RatePerConn = 0
+	conf.OpenModel = false
+	conf.AdaptiveBackoff = false
+	conf.Interactive = false
+	conf.Verbose = false
+	conf.LogSampleRate = 0
+	conf.CompareURI = ""
+	conf.CompareHeaders = nil
+	conf.SQLitePath = ""
+	conf.JTLPath = ""
+
+	res, err := NewPayLoader(&conf).Run()
+	if err != nil {
+		return nil, err
+	}
+	if res.CompletedReqs == 0 {
+		for e := range res.Errors {
+			return nil, fmt.Errorf("request failed; %s", e)
+		}
+		return nil, errors.New("request failed")
+	}
+	return res, nil
+}
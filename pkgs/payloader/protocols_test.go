@@ -0,0 +1,95 @@
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	httpv3server "github.com/quic-go/quic-go/http3"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCompareProtocols(t *testing.T) {
+	var protos sync.Map
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count, _ := protos.LoadOrStore(r.ProtoMajor, &atomic.Int64{})
+		count.(*atomic.Int64).Add(1)
+		_, _ = w.Write([]byte("hello"))
+	})
+
+	server := httptest.NewUnstartedServer(handler)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	conf := &config.Config{
+		Ctx:              context.Background(),
+		ReqURI:           server.URL,
+		ReqTarget:        20,
+		Conns:            2,
+		SkipVerify:       true,
+		ReadTimeout:      time.Second,
+		WriteTimeout:     time.Second,
+		Method:           "GET",
+		Client:           "fasthttp-1",
+		VerboseTicker:    time.Second,
+		CompareProtocols: true,
+	}
+
+	check := func(results []ProtocolResults, wantSkipped map[string]bool) {
+		if len(results) != len(Protocols) {
+			t.Fatalf("wanted %d protocols got %d", len(Protocols), len(results))
+		}
+		for i, r := range results {
+			if r.Protocol != Protocols[i] {
+				t.Errorf("wanted protocol %s got %s", Protocols[i].Name, r.Protocol.Name)
+			}
+			if wantSkipped[r.Protocol.Name] {
+				if r.Results != nil || r.Skipped == "" {
+					t.Errorf("wanted %s to be skipped", r.Protocol.Name)
+				}
+				continue
+			}
+			if r.Results == nil {
+				t.Errorf("wanted %s to run, skipped; %s", r.Protocol.Name, r.Skipped)
+				continue
+			}
+			if r.Results.CompletedReqs != 20 {
+				t.Errorf("wanted 20 completed %s requests got %d", r.Protocol.Name, r.Results.CompletedReqs)
+			}
+		}
+	}
+
+	// no HTTP/3 listener yet so it should be skipped
+	results, err := CompareProtocols(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(results, map[string]bool{"HTTP/3": true})
+
+	h3 := &httpv3server.Server{
+		Handler:   handler,
+		Addr:      server.Listener.Addr().String(),
+		TLSConfig: server.TLS,
+	}
+	go func() {
+		_ = h3.ListenAndServe()
+	}()
+	defer h3.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	results, err = CompareProtocols(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check(results, nil)
+
+	for _, major := range []int{1, 2, 3} {
+		if _, ok := protos.Load(major); !ok {
+			t.Errorf("wanted requests over HTTP/%d", major)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package payloader
+
+import "time"
+
+// rampDownUntil is how long the connection sends requests for. With --ramp-down connections are retired evenly over
+// the end of the time window, the last one running for the whole window, otherwise they all run for the whole window
+func (p *PayLoader) rampDownUntil(conn uint) time.Duration {
+	if p.config.RampDown == 0 {
+		return p.config.Duration
+	}
+	retireEvery := p.config.RampDown / time.Duration(p.config.Conns)
+	return p.config.Duration - retireEvery*time.Duration(p.config.Conns-1-conn)
+}
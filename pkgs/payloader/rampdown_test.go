@@ -0,0 +1,24 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_RampDownUntil(t *testing.T) {
+	p := NewPayLoader(&config.Config{Duration: 2 * time.Second, Conns: 4})
+	for conn := uint(0); conn < 4; conn++ {
+		if got := p.rampDownUntil(conn); got != 2*time.Second {
+			t.Errorf("wanted connection %d to run for the whole window without ramp down got %s", conn, got)
+		}
+	}
+
+	// a connection is retired every 400ms over the last 1600ms, the last runs for the whole window
+	p = NewPayLoader(&config.Config{Duration: 2 * time.Second, Conns: 4, RampDown: 1600 * time.Millisecond})
+	for conn, want := range []time.Duration{800 * time.Millisecond, 1200 * time.Millisecond, 1600 * time.Millisecond, 2 * time.Second} {
+		if got := p.rampDownUntil(uint(conn)); got != want {
+			t.Errorf("wanted connection %d retired after %s got %s", conn, want, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package payloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/http-clients/nethttp"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+	"net/url"
+	"time"
+)
+
+// readyPollInterval is how often the readiness path is checked with --wait-for-ready
+const readyPollInterval = 500 * time.Millisecond
+
+// WaitForReady polls the target's readiness path until it responds with a 2xx status or the ready timeout passes.
+// Unlike --connect-retries, a connection being accepted isn't enough, the application has to say it's ready
+func WaitForReady(conf *config.Config) error {
+	readyURL, err := readyURL(conf)
+	if err != nil {
+		return err
+	}
+
+	clientCert, err := firstClientCert(conf)
+	if err != nil {
+		return err
+	}
+	c := &http_clients.Config{
+		SkipVerify:   conf.SkipVerify,
+		MTLSCert:     clientCert.Cert,
+		MTLSKey:      clientCert.Key,
+		ReadTimeout:  conf.ReadTimeout,
+		WriteTimeout: conf.WriteTimeout,
+	}
+	if conf.CACert != "" {
+		if c.RootCAs, err = http_clients.LoadCertPool(conf.CACert); err != nil {
+			return err
+		}
+	}
+	var client http_clients.GoPayLoaderClient
+	if conf.Client == worker.HttpClientNetHTTP3 {
+		client, err = nethttp.GetNetHTTP3Client(c)
+	} else {
+		client, err = nethttp.GetNetHTTPClient(c)
+	}
+	if err != nil {
+		return err
+	}
+	defer client.CloseConns()
+
+	req, err := client.NewReq("GET", readyURL)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(conf.Ctx, conf.ReadyTimeout)
+	defer cancel()
+	tick := time.NewTicker(readyPollInterval)
+	defer tick.Stop()
+
+	pterm.Info.Printf("Waiting up to %s for %s to be ready\n", conf.ReadyTimeout, readyURL)
+	start := time.Now()
+	var notReady string
+	for {
+		resp := client.NewResponse()
+		err := client.Do(req, resp)
+		if err == nil {
+			status := resp.StatusCode()
+			resp.Close()
+			if status >= 200 && status < 300 {
+				pterm.Success.Printf("Target ready after %s\n", time.Since(start).Round(time.Millisecond))
+				return nil
+			}
+			notReady = fmt.Sprintf("status code %d", status)
+		} else {
+			notReady = err.Error()
+		}
+
+		select {
+		case <-ctx.Done():
+			if conf.Ctx.Err() != nil {
+				// user cancelled
+				return conf.Ctx.Err()
+			}
+			return fmt.Errorf("target wasn't ready after %s, last check got %s", conf.ReadyTimeout, notReady)
+		case <-tick.C:
+		}
+	}
+}
+
+// readyURL is the target with its path replaced by the ready path, or the target itself without one
+func readyURL(conf *config.Config) (string, error) {
+	if conf.ReadyPath == "" {
+		return conf.ReqURI, nil
+	}
+
+	reqURI := conf.ReqURI
+	if conf.RawPath {
+		reqURI, _ = http_clients.SplitRawURI(conf.ReqURI)
+	}
+	u, err := url.Parse(reqURI)
+	if err != nil {
+		return "", err
+	}
+	return u.Scheme + "://" + u.Host + conf.ReadyPath, nil
+}
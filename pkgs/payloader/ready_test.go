@@ -0,0 +1,78 @@
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitForReady(t *testing.T) {
+	var ready atomic.Bool
+	var checks, reqs atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			checks.Add(1)
+			if !ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		reqs.Add(1)
+	}))
+	defer server.Close()
+
+	conf := &config.Config{
+		Ctx:          context.Background(),
+		ReqURI:       server.URL + "/load",
+		ReadTimeout:  time.Second,
+		WriteTimeout: time.Second,
+		Client:       "fasthttp-1",
+		WaitForReady: true,
+		ReadyPath:    "/healthz",
+		ReadyTimeout: 300 * time.Millisecond,
+	}
+	err := WaitForReady(conf)
+	if err == nil || !strings.Contains(err.Error(), "status code 503") {
+		t.Fatalf("wanted timeout error with the last status got %v", err)
+	}
+
+	time.AfterFunc(700*time.Millisecond, func() {
+		ready.Store(true)
+	})
+	conf.ReadyTimeout = 5 * time.Second
+	start := time.Now()
+	if err := WaitForReady(conf); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 700*time.Millisecond {
+		t.Errorf("wanted to wait until ready after 700ms got %s", waited)
+	}
+	if checks.Load() < 3 {
+		t.Errorf("wanted readiness to be polled until ready got %d checks", checks.Load())
+	}
+	// only the ready path is checked, the target isn't
+	if reqs.Load() != 0 {
+		t.Errorf("wanted no requests to the target got %d", reqs.Load())
+	}
+}
+
+func TestReadyURL(t *testing.T) {
+	for _, tt := range []struct {
+		uri, path, want string
+		rawPath         bool
+	}{
+		{uri: "http://localhost:8080/load?q=1", path: "/healthz", want: "http://localhost:8080/healthz"},
+		{uri: "http://localhost:8080/load", want: "http://localhost:8080/load"},
+		{uri: "http://localhost:8080/x%zz", path: "/ready", rawPath: true, want: "http://localhost:8080/ready"},
+	} {
+		got, err := readyURL(&config.Config{ReqURI: tt.uri, ReadyPath: tt.path, RawPath: tt.rawPath})
+		if err != nil || got != tt.want {
+			t.Errorf("%s: wanted %s got %s; %v", tt.uri, tt.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package payloader
+
+import (
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/pterm/pterm"
+	"math"
+	"time"
+)
+
+// RepeatResults are the results of each of the --repeat runs and how much they varied, so one unlucky run can be
+// told apart from a real change
+type RepeatResults struct {
+	Runs          []*GoPayloaderResults
+	CompletedReqs int64
+	FailedReqs    int64
+	RPS           Spread
+	// Latency is the spread of each run's average latency
+	Latency LatencySpread
+	// P99 is nil unless every run reported percentiles
+	P99 *LatencySpread
+}
+
+// Spread is the mean, standard deviation and range of a number across runs
+type Spread struct {
+	Mean   float64
+	StdDev float64
+	Min    float64
+	Max    float64
+}
+
+type LatencySpread struct {
+	Mean   time.Duration
+	StdDev time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+// Repeat runs the same workload --repeat times one after the other. A run the user aborted is still counted, the
+// rest aren't run
+func Repeat(conf *config.Config) (*RepeatResults, error) {
+	results := &RepeatResults{}
+	for i := 0; i < conf.Repeat; i++ {
+		if conf.Ctx.Err() != nil {
+			break
+		}
+
+		c := *conf
+		c.Repeat = 0
+		pterm.Info.Printf("Starting run %d of %d\n", i+1, conf.Repeat)
+		res, err := NewPayLoader(&c).Run()
+		if err != nil {
+			return nil, fmt.Errorf("run %d failed; %v", i+1, err)
+		}
+		results.Runs = append(results.Runs, res)
+	}
+	results.combine()
+	return results, nil
+}
+
+func (r *RepeatResults) combine() {
+	if len(r.Runs) == 0 {
+		return
+	}
+	rps := make([]float64, 0, len(r.Runs))
+	latencies := make([]float64, 0, len(r.Runs))
+	p99s := make([]float64, 0, len(r.Runs))
+	for _, run := range r.Runs {
+		r.CompletedReqs += run.CompletedReqs
+		r.FailedReqs += run.FailedReqs
+		rps = append(rps, run.RPS.Average)
+		latencies = append(latencies, float64(run.Latency.Average))
+		for _, p := range run.Latency.Percentiles {
+			if p.Percentile == 99 {
+				p99s = append(p99s, float64(p.Latency))
+			}
+		}
+	}
+	r.RPS = spread(rps)
+	r.Latency = spread(latencies).latency()
+	if len(p99s) == len(r.Runs) {
+		p99 := spread(p99s).latency()
+		r.P99 = &p99
+	}
+}
+
+func spread(values []float64) Spread {
+	s := Spread{Min: values[0], Max: values[0]}
+	var sum float64
+	for _, v := range values {
+		sum += v
+		s.Min = math.Min(s.Min, v)
+		s.Max = math.Max(s.Max, v)
+	}
+	s.Mean = sum / float64(len(values))
+	var sq float64
+	for _, v := range values {
+		sq += (v - s.Mean) * (v - s.Mean)
+	}
+	// population standard deviation, the runs are all there is rather than a sample of something bigger
+	s.StdDev = math.Sqrt(sq / float64(len(values)))
+	return s
+}
+
+func (s Spread) latency() LatencySpread {
+	return LatencySpread{
+		Mean:   time.Duration(s.Mean),
+		StdDev: time.Duration(s.StdDev),
+		Min:    time.Duration(s.Min),
+		Max:    time.Duration(s.Max),
+	}
+}
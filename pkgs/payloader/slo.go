@@ -0,0 +1,154 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"strings"
+	"time"
+)
+
+// rateTolerance is how far below --rate or --rate-per-conn the achieved rate can be before the target counts as unmet,
+// connections opening and the last partial second keep a run which kept up from reaching it exactly
+const rateTolerance = 0.05
+
+// sloTolerance stops a success rate which is exactly the target failing on floating point error i.e. 999 of 1000
+// against 99.9
+const sloTolerance = 1e-9
+
+// SLO is the success rate verdict against --slo-success-rate
+type SLO struct {
+	// Target is the minimum success rate as a percentage
+	Target float64
+	Passed bool
+}
+
+// successRate is the percentage of requests which completed, 0 when none were sent
+func successRate(completed, failed int64) float64 {
+	total := completed + failed
+	if total == 0 {
+		return 0
+	}
+	return float64(completed) / float64(total) * 100
+}
+
+// computeSLO sets the success rate and, if there's a target, whether it was met. A run with no requests fails as
+// there's nothing to show the target was met
+func (p *PayLoader) computeSLO(results *GoPayloaderResults) {
+	results.SuccessRate = successRate(results.CompletedReqs, results.FailedReqs)
+	if p.config.SLOSuccessRate == 0 {
+		return
+	}
+	results.SLO = &SLO{
+		Target: p.config.SLOSuccessRate,
+		Passed: results.CompletedReqs+results.FailedReqs > 0 && results.SuccessRate >= p.config.SLOSuccessRate-sloTolerance,
+	}
+}
+
+// LatencySLO is the verdict against one --slo-latency, Latency is the percentile of service latency measured. Path is
+// set for an SLO on one endpoint, whose requests are then the only ones it's checked against
+type LatencySLO struct {
+	Path       string
+	Percentile float64
+	Target     time.Duration
+	Latency    time.Duration
+	Passed     bool
+}
+
+// pathLatency is the latencies of the requests to a path with a --slo-latency, max caps the percentile like the
+// run's max latency does as the histogram's buckets can be past the slowest request
+type pathLatency struct {
+	latencies *latencyHistogram
+	max       time.Duration
+}
+
+// newPathLatencies has an entry for each path with a --slo-latency, nil when none are on a path
+func (p *PayLoader) newPathLatencies() map[string]*pathLatency {
+	var paths map[string]*pathLatency
+	for _, s := range p.config.SLOLatency {
+		path, _ := config.SplitSLOPath(s)
+		if path == "" {
+			continue
+		}
+		if paths == nil {
+			paths = make(map[string]*pathLatency)
+		}
+		paths[path] = &pathLatency{latencies: newLatencyHistogram()}
+	}
+	return paths
+}
+
+// recordPathLatency records the latency against each SLO path the request's path is or is under, so /api covers
+// /api/users too
+func (p *PayLoader) recordPathLatency(path string, latency time.Duration) {
+	for prefix, l := range p.pathLatencies {
+		if !underPath(path, prefix) {
+			continue
+		}
+		l.latencies.record(latency)
+		if latency > l.max {
+			l.max = latency
+		}
+	}
+}
+
+func underPath(path, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	return len(path) == len(prefix) || strings.HasSuffix(prefix, "/") || path[len(prefix)] == '/'
+}
+
+// computeLatencySLOs checks each --slo-latency against the latency histogram, so the percentile doesn't have to be one
+// of those reported. Like the success rate SLO a run with no completed requests fails, as does an SLO on a path
+// which had none
+func (p *PayLoader) computeLatencySLOs(results *GoPayloaderResults) {
+	for _, s := range p.config.SLOLatency {
+		path, spec := config.SplitSLOPath(s)
+		// already checked it parses when validating
+		percentile, target, err := config.ParseLatencySLO(spec)
+		if err != nil {
+			continue
+		}
+		slo := LatencySLO{Path: path, Percentile: percentile, Target: target}
+		latencies, max := p.latencies, results.Latency.Max
+		if path != "" {
+			latencies, max = nil, 0
+			if l, ok := p.pathLatencies[path]; ok {
+				latencies, max = l.latencies, l.max
+			}
+		}
+		if latencies != nil && latencies.total > 0 {
+			slo.Latency = latencies.percentile(percentile)
+			if slo.Latency > max {
+				slo.Latency = max
+			}
+			slo.Passed = slo.Latency <= target
+		}
+		results.LatencySLOs = append(results.LatencySLOs, slo)
+	}
+}
+
+// RateCheck is whether --rate or --rate-per-conn was achieved, a run which didn't keep up didn't apply the load it was
+// meant to. Achieved counts failed requests too as they were still sent
+type RateCheck struct {
+	Target   float64
+	Achieved float64
+	Met      bool
+}
+
+// computeRateCheck compares the rate requests were sent at to the target. It's skipped when the rate changes while
+// running as there's no one target to compare to
+func (p *PayLoader) computeRateCheck(results *GoPayloaderResults, active time.Duration) {
+	target := p.config.Rate
+	if p.config.RatePerConn > 0 {
+		target = p.config.RatePerConn * float64(p.config.Conns)
+	}
+	if target == 0 || p.config.Interactive || p.config.AdaptiveBackoff {
+		return
+	}
+	check := &RateCheck{Target: target}
+	if active > 0 {
+		check.Achieved = float64(results.CompletedReqs+results.FailedReqs) / active.Seconds()
+	}
+	check.Met = check.Achieved >= target*(1-rateTolerance)
+	results.RateCheck = check
+}
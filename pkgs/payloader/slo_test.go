@@ -0,0 +1,161 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_ComputeSLO(t *testing.T) {
+	tests := []struct {
+		name      string
+		completed int64
+		failed    int64
+		target    float64
+		wantRate  float64
+		wantSLO   *SLO
+	}{
+		{name: "no target", completed: 90, failed: 10, wantRate: 90},
+		{name: "all completed", completed: 100, target: 99.9, wantRate: 100, wantSLO: &SLO{Target: 99.9, Passed: true}},
+		{name: "exactly at target", completed: 999, failed: 1, target: 99.9, wantRate: 99.9, wantSLO: &SLO{Target: 99.9, Passed: true}},
+		{name: "below target", completed: 998, failed: 2, target: 99.9, wantRate: 99.8, wantSLO: &SLO{Target: 99.9, Passed: false}},
+		{name: "all failed", failed: 5, target: 50, wantRate: 0, wantSLO: &SLO{Target: 50, Passed: false}},
+		{name: "no requests", target: 50, wantRate: 0, wantSLO: &SLO{Target: 50, Passed: false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPayLoader(&config.Config{SLOSuccessRate: tt.target})
+			results := &GoPayloaderResults{CompletedReqs: tt.completed, FailedReqs: tt.failed}
+			p.computeSLO(results)
+
+			if math.Abs(results.SuccessRate-tt.wantRate) > 1e-9 {
+				t.Errorf("wanted success rate %g got %g", tt.wantRate, results.SuccessRate)
+			}
+			if !reflect.DeepEqual(results.SLO, tt.wantSLO) {
+				t.Errorf("wanted SLO %+v got %+v", tt.wantSLO, results.SLO)
+			}
+		})
+	}
+}
+
+func TestPayLoader_ComputeLatencySLOs(t *testing.T) {
+	p := NewPayLoader(&config.Config{SLOLatency: []string{"p99=1s", "p50=5ms"}})
+	p.latencies = newLatencyHistogram()
+	for i := 0; i < 50; i++ {
+		p.latencies.record(20 * time.Millisecond)
+	}
+	results := &GoPayloaderResults{Latency: Latency{Max: 20 * time.Millisecond}}
+	p.computeLatencySLOs(results)
+
+	want := []LatencySLO{
+		{Percentile: 99, Target: time.Second, Latency: 20 * time.Millisecond, Passed: true},
+		{Percentile: 50, Target: 5 * time.Millisecond, Latency: 20 * time.Millisecond},
+	}
+	if !reflect.DeepEqual(results.LatencySLOs, want) {
+		t.Errorf("wanted latency SLOs %+v got %+v", want, results.LatencySLOs)
+	}
+
+	// nothing completed to show the target was met
+	p.latencies = newLatencyHistogram()
+	results = &GoPayloaderResults{}
+	p.computeLatencySLOs(results)
+	if len(results.LatencySLOs) != 2 || results.LatencySLOs[0].Passed {
+		t.Errorf("wanted latency SLOs to fail without completed requests got %+v", results.LatencySLOs)
+	}
+}
+
+func TestPayLoader_ComputeLatencySLOsPerPath(t *testing.T) {
+	// search passes its own budget while failing the static assets' one, static passes a budget search would miss
+	p := NewPayLoader(&config.Config{
+		SLOLatency: []string{"/search:p99=1s", "/static:p99=100ms", "/search:p50=100ms", "/missing:p99=1s", "p99=1s"},
+	})
+	p.latencies = newLatencyHistogram()
+	p.statusLatencies = make(map[worker.ResponseCode]*latencyHistogram)
+	p.pathLatencies = p.newPathLatencies()
+	results := &GoPayloaderResults{}
+
+	sent := time.Now().UnixNano()
+	ms := int64(time.Millisecond)
+	var timings []http_clients.ReqTiming
+	for i := 0; i < 10; i++ {
+		timings = append(timings,
+			http_clients.ReqTiming{Sent: sent, Done: sent + 200*ms, Path: "/search"},
+			http_clients.ReqTiming{Sent: sent, Done: sent + 5*ms, Path: "/static/app.js"},
+		)
+	}
+	recordTimings(p, results, timings...)
+	p.computeLatencySLOs(results)
+
+	if len(results.LatencySLOs) != 5 {
+		t.Fatalf("wanted 5 latency SLOs got %+v", results.LatencySLOs)
+	}
+	if s := results.LatencySLOs[0]; s.Path != "/search" || !s.Passed || s.Latency < 200*time.Millisecond {
+		t.Errorf("wanted /search p99 within 1s got %+v", s)
+	}
+	if s := results.LatencySLOs[1]; s.Path != "/static" || !s.Passed || s.Latency == 0 || s.Latency >= 100*time.Millisecond {
+		t.Errorf("wanted /static p99 within 100ms got %+v", s)
+	}
+	if s := results.LatencySLOs[2]; s.Path != "/search" || s.Passed || s.Latency < 200*time.Millisecond {
+		t.Errorf("wanted /search p50 to miss 100ms got %+v", s)
+	}
+	if s := results.LatencySLOs[3]; s.Path != "/missing" || s.Passed || s.Latency != 0 {
+		t.Errorf("wanted /missing to fail with no requests got %+v", s)
+	}
+	if s := results.LatencySLOs[4]; s.Path != "" || !s.Passed {
+		t.Errorf("wanted overall p99 within 1s got %+v", s)
+	}
+}
+
+func TestUnderPath(t *testing.T) {
+	tests := []struct {
+		path   string
+		prefix string
+		want   bool
+	}{
+		{path: "/api", prefix: "/api", want: true},
+		{path: "/api/users", prefix: "/api", want: true},
+		{path: "/api/users", prefix: "/api/", want: true},
+		{path: "/apis", prefix: "/api"},
+		{path: "/", prefix: "/api"},
+	}
+	for _, tt := range tests {
+		if got := underPath(tt.path, tt.prefix); got != tt.want {
+			t.Errorf("underPath(%q, %q) wanted %v got %v", tt.path, tt.prefix, tt.want, got)
+		}
+	}
+}
+
+func TestPayLoader_ComputeRateCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+		reqs   int64
+		want   *RateCheck
+	}{
+		{name: "no rate", config: config.Config{Conns: 2}, reqs: 100},
+		{name: "met", config: config.Config{Conns: 2, Rate: 100}, reqs: 100, want: &RateCheck{Target: 100, Achieved: 100, Met: true}},
+		// connections opening and the last partial second
+		{name: "within tolerance", config: config.Config{Conns: 2, Rate: 100}, reqs: 96, want: &RateCheck{Target: 100, Achieved: 96, Met: true}},
+		{name: "unmet", config: config.Config{Conns: 2, Rate: 1000}, reqs: 40, want: &RateCheck{Target: 1000, Achieved: 40}},
+		{name: "rate per conn", config: config.Config{Conns: 2, RatePerConn: 50}, reqs: 100, want: &RateCheck{Target: 100, Achieved: 100, Met: true}},
+		// no one target to compare to
+		{name: "interactive", config: config.Config{Conns: 2, Rate: 100, Interactive: true}, reqs: 10},
+		{name: "adaptive backoff", config: config.Config{Conns: 2, Rate: 100, AdaptiveBackoff: true}, reqs: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewPayLoader(&tt.config)
+			// failed requests were still sent
+			results := &GoPayloaderResults{CompletedReqs: tt.reqs - tt.reqs/4, FailedReqs: tt.reqs / 4}
+			p.computeRateCheck(results, time.Second)
+			if !reflect.DeepEqual(results.RateCheck, tt.want) {
+				t.Errorf("wanted rate check %+v got %+v", tt.want, results.RateCheck)
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package payloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"time"
+)
+
+// sloWindowSlices is how many slices the --slo-window is kept in, the window moves on a slice at a time
+const sloWindowSlices = 10
+
+// SLOBreach is why a run with --abort-on-slo-breach was stopped and how far into it
+type SLOBreach struct {
+	After  time.Duration
+	Reason string
+}
+
+// sloGuard checks the SLOs against the requests of the last --slo-window while running and stops the run at the first
+// breach, rather than carrying on against a target which is already past its budget. A window isn't judged until it
+// has --min-samples requests so a slow first request can't end the run
+type sloGuard struct {
+	successRate float64
+	latencySLOs []LatencySLO
+	window      time.Duration
+	minSamples  int64
+	stop        context.CancelFunc
+	workers     []worker.Worker
+	start       time.Time
+	// slices are the window, current is where requests are being recorded. Latencies are kept by SLO path, "" for
+	// the SLOs on every request
+	slices     []*sloSlice
+	current    int
+	prevFailed int64
+	merged     *latencyHistogram
+	breach     *SLOBreach
+}
+
+type sloSlice struct {
+	latencies map[string]*pathLatency
+	completed int64
+	failed    int64
+}
+
+func (p *PayLoader) newSLOGuard(workers []worker.Worker, stop context.CancelFunc) *sloGuard {
+	g := &sloGuard{
+		successRate: p.config.SLOSuccessRate,
+		window:      p.config.SLOWindow,
+		minSamples:  p.config.MinSamples,
+		stop:        stop,
+		workers:     workers,
+		merged:      newLatencyHistogram(),
+	}
+	for _, s := range p.config.SLOLatency {
+		path, spec := config.SplitSLOPath(s)
+		// already checked it parses when validating
+		percentile, target, err := config.ParseLatencySLO(spec)
+		if err != nil {
+			continue
+		}
+		g.latencySLOs = append(g.latencySLOs, LatencySLO{Path: path, Percentile: percentile, Target: target})
+	}
+	g.slices = make([]*sloSlice, sloWindowSlices)
+	for i := range g.slices {
+		g.slices[i] = g.newSlice()
+	}
+	return g
+}
+
+func (g *sloGuard) newSlice() *sloSlice {
+	s := &sloSlice{latencies: make(map[string]*pathLatency)}
+	for _, slo := range g.latencySLOs {
+		s.latencies[slo.Path] = &pathLatency{latencies: newLatencyHistogram()}
+	}
+	return s
+}
+
+// record counts a completed request, path is only set when there are SLOs on a path
+func (g *sloGuard) record(path string, latency time.Duration) {
+	s := g.slices[g.current]
+	s.completed++
+	for prefix, l := range s.latencies {
+		if prefix != "" && !underPath(path, prefix) {
+			continue
+		}
+		l.latencies.record(latency)
+		if latency > l.max {
+			l.max = latency
+		}
+	}
+}
+
+// check judges the window every slice, stopping the run on a breach, then moves the window on. Failed requests aren't
+// sent as stats so they're counted from the workers
+func (g *sloGuard) check(now time.Time) {
+	if g.breach != nil {
+		return
+	}
+	var failed int64
+	for _, w := range g.workers {
+		failed += w.Failed()
+	}
+	g.slices[g.current].failed += failed - g.prevFailed
+	g.prevFailed = failed
+
+	if reason := g.breached(); reason != "" {
+		g.breach = &SLOBreach{After: now.Sub(g.start), Reason: reason}
+		g.stop()
+		return
+	}
+	g.current = (g.current + 1) % len(g.slices)
+	g.slices[g.current] = g.newSlice()
+}
+
+// breached returns which SLO the window breaches, empty if none
+func (g *sloGuard) breached() string {
+	if g.successRate > 0 {
+		var completed, failed int64
+		for _, s := range g.slices {
+			completed += s.completed
+			failed += s.failed
+		}
+		if completed+failed > 0 && completed+failed >= g.minSamples {
+			if rate := successRate(completed, failed); rate < g.successRate-sloTolerance {
+				return fmt.Sprintf("success rate %.3f%% over the last %s is below the SLO of %g%%", rate, g.window, g.successRate)
+			}
+		}
+	}
+
+	for _, slo := range g.latencySLOs {
+		for i := range g.merged.counts {
+			g.merged.counts[i] = 0
+		}
+		g.merged.total = 0
+		var max time.Duration
+		for _, s := range g.slices {
+			l := s.latencies[slo.Path]
+			g.merged.add(l.latencies)
+			if l.max > max {
+				max = l.max
+			}
+		}
+		if g.merged.total == 0 || g.merged.total < g.minSamples {
+			continue
+		}
+		// capped like the run's percentiles as the bucket's upper bound can be past the slowest request
+		latency := g.merged.percentile(slo.Percentile)
+		if latency > max {
+			latency = max
+		}
+		if latency > slo.Target {
+			name := fmt.Sprintf("p%g latency", slo.Percentile)
+			if slo.Path != "" {
+				name = slo.Path + " " + name
+			}
+			return fmt.Sprintf("%s %s over the last %s is over the SLO of %s", name, latency.Round(time.Microsecond), g.window, slo.Target)
+		}
+	}
+	return ""
+}
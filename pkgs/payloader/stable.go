@@ -0,0 +1,83 @@
+package payloader
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// stableWindow is how many of the latest --stable-interval p99 latencies have to agree for the run to be stable
+const stableWindow = 5
+
+// Stability is how a --run-until-stable run ended. Stable is false when it ran until -t without converging, P99 and
+// Variation are of the last intervals checked either way
+type Stability struct {
+	Stable bool
+	// After is how far into the run it was stable
+	After time.Duration
+	// P99 is the mean p99 latency of the intervals, Variation their relative standard deviation
+	P99       time.Duration
+	Variation float64
+}
+
+// stability takes the p99 latency of each --stable-interval and stops the run once the latest stableWindow of them
+// vary by less than --stable-threshold, unless it's been running less than --stable-min
+type stability struct {
+	threshold float64
+	min       time.Duration
+	stop      context.CancelFunc
+	start     time.Time
+	interval  *latencyHistogram
+	p99s      []time.Duration
+	result    Stability
+}
+
+func newStability(threshold float64, min time.Duration, stop context.CancelFunc) *stability {
+	return &stability{
+		threshold: threshold,
+		min:       min,
+		stop:      stop,
+		interval:  newLatencyHistogram(),
+	}
+}
+
+func (s *stability) record(d time.Duration) {
+	s.interval.record(d)
+}
+
+// check ends the interval, stopping the run if it's stable. Intervals without requests i.e. while paused are skipped
+// as there's no p99 to compare
+func (s *stability) check(now time.Time) {
+	if s.result.Stable || s.interval.total == 0 {
+		return
+	}
+	s.p99s = append(s.p99s, s.interval.percentile(99))
+	if len(s.p99s) > stableWindow {
+		s.p99s = s.p99s[1:]
+	}
+	s.interval = newLatencyHistogram()
+	if len(s.p99s) < stableWindow {
+		return
+	}
+
+	var mean float64
+	for _, p99 := range s.p99s {
+		mean += float64(p99)
+	}
+	mean /= float64(len(s.p99s))
+	var variance float64
+	for _, p99 := range s.p99s {
+		variance += (float64(p99) - mean) * (float64(p99) - mean)
+	}
+	variance /= float64(len(s.p99s))
+	s.result.P99 = time.Duration(mean)
+	s.result.Variation = math.Sqrt(variance) / mean
+
+	elapsed := now.Sub(s.start)
+	if elapsed < s.min || s.result.Variation >= s.threshold {
+		return
+	}
+	s.result.Stable = true
+	s.result.After = elapsed
+	s.stop()
+}
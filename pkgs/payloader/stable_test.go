@@ -0,0 +1,70 @@
+package payloader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStability_Check(t *testing.T) {
+	// checks an interval every 200ms from the start, returns the index of the one the run was stable after or -1
+	type interval struct {
+		latency time.Duration
+		reqs    int
+	}
+	run := func(s *stability, intervals []interval) int {
+		s.start = time.Unix(0, 0)
+		for i, in := range intervals {
+			for r := 0; r < in.reqs; r++ {
+				s.record(in.latency)
+			}
+			s.check(s.start.Add(time.Duration(i+1) * 200 * time.Millisecond))
+			if s.result.Stable {
+				return i
+			}
+		}
+		return -1
+	}
+	steady := func(latency time.Duration, n int) []interval {
+		var in []interval
+		for i := 0; i < n; i++ {
+			in = append(in, interval{latency: latency, reqs: 20})
+		}
+		return in
+	}
+
+	// slow while warming up then steady, the warm up has to leave the window of intervals compared
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newStability(0.25, 500*time.Millisecond, cancel)
+	if i := run(s, append(steady(100*time.Millisecond, 3), steady(20*time.Millisecond, 10)...)); i != 7 {
+		t.Errorf("wanted the run stable after the 5th steady interval got %d", i)
+	}
+	if ctx.Err() == nil {
+		t.Error("wanted the run stopped once stable")
+	}
+	if r := s.result; r.After != 1600*time.Millisecond || r.P99 < 20*time.Millisecond || r.P99 > 25*time.Millisecond || r.Variation >= 0.25 {
+		t.Errorf("wanted p99 of ~20ms varying under 25%% 1.6s in got %+v", r)
+	}
+
+	// not before --stable-min
+	s = newStability(0.25, 2*time.Second, func() {})
+	if i := run(s, steady(20*time.Millisecond, 20)); i != 9 {
+		t.Errorf("wanted the run stable once 2s in got %d", i)
+	}
+
+	// intervals without requests i.e. while paused don't count
+	s = newStability(0.25, 0, func() {})
+	if i := run(s, append([]interval{{}, {}}, steady(20*time.Millisecond, 5)...)); i != 6 {
+		t.Errorf("wanted empty intervals skipped got stable after %d", i)
+	}
+
+	// latency doubles every interval so never converges
+	var ramp []interval
+	for i := 0; i < 10; i++ {
+		ramp = append(ramp, interval{latency: time.Millisecond << i, reqs: 20})
+	}
+	s = newStability(0.25, 0, func() {})
+	if i := run(s, ramp); i != -1 || s.result.Variation < 0.25 {
+		t.Errorf("wanted the run not to be stable got stable after %d; %+v", i, s.result)
+	}
+}
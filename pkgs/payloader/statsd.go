@@ -0,0 +1,36 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+)
+
+// statsdExport sends the requests of each --ticker interval to --statsd-addr. Completed requests are recorded as their
+// stats arrive, failed requests are counted from the workers' stats like the progress output
+type statsdExport struct {
+	client     *metrics.StatsD
+	workers    []worker.Worker
+	prevFailed int64
+	// warned is set once a flush fails, UDP errors i.e. nothing listening yet are reported once and sending carries on
+	warned bool
+}
+
+func newStatsdExport(client *metrics.StatsD, workers []worker.Worker) *statsdExport {
+	return &statsdExport{client: client, workers: workers}
+}
+
+// flush sends the interval ending now
+func (s *statsdExport) flush() {
+	var failed int64
+	for _, w := range s.workers {
+		failed += w.Failed()
+	}
+	s.client.Failed(failed - s.prevFailed)
+	s.prevFailed = failed
+
+	if err := s.client.Flush(); err != nil && !s.warned {
+		pterm.Warning.Printf("Failed to send metrics to statsd; %v\n", err)
+		s.warned = true
+	}
+}
@@ -0,0 +1,76 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStatsdExport_Flush(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	client, err := metrics.DialStatsD(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	w := &testWorker{stats: worker.Stats{FailedReqs: 2}}
+	s := newStatsdExport(client, []worker.Worker{w, &testWorker{stats: worker.Stats{FailedReqs: 1}}})
+	buf := make([]byte, 1024)
+	// failed requests are counted since the last flush
+	for _, want := range []string{"gopayloader.requests.failed:3|c", "gopayloader.requests.failed:4|c"} {
+		s.flush()
+		pc.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := pc.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(buf[:n]); got != want {
+			t.Errorf("wanted %s got %s", want, got)
+		}
+		w.stats.FailedReqs += 4
+	}
+}
+
+func TestPayLoader_RecordStatsD(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	client, err := metrics.DialStatsD(pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	p := NewPayLoader(&config.Config{VerboseTicker: time.Second})
+	p.latencies = newLatencyHistogram()
+	p.statusLatencies = make(map[worker.ResponseCode]*latencyHistogram)
+	p.statsd = newStatsdExport(client, []worker.Worker{&testWorker{}})
+	sent := time.Now().UnixNano()
+	// the last interval is flushed as the run ends
+	recordTimings(p, &GoPayloaderResults{},
+		http_clients.ReqTiming{Sent: sent, Done: sent + int64(2*time.Millisecond)},
+		http_clients.ReqTiming{Sent: sent, Done: sent + int64(4*time.Millisecond)},
+	)
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "gopayloader.requests.completed:2|c\ngopayloader.request.latency:2.000|ms\ngopayloader.request.latency:4.000|ms"
+	if got := string(buf[:n]); got != want {
+		t.Errorf("wanted %q got %q", want, got)
+	}
+}
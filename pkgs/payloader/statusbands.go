@@ -0,0 +1,71 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"time"
+)
+
+// StatusBand is how the responses within one band of the run's latency were split between response codes. Bands are
+// from the latency histogram so their edges are the bucket bounds the percentiles fall in
+type StatusBand struct {
+	// Band is which part of the latency distribution i.e. "p90 to p99", Upper is the slowest latency in it
+	Band      string
+	Upper     time.Duration
+	Requests  int64
+	Responses map[worker.ResponseCode]int64
+}
+
+// statusBands are the percentiles the bands end at, the last is everything slower than the one before
+var statusBands = []struct {
+	name       string
+	percentile float64
+}{
+	{"up to p50", 50},
+	{"p50 to p90", 90},
+	{"p90 to p99", 99},
+	{"above p99", 100},
+}
+
+func (p *PayLoader) recordStatusLatency(code worker.ResponseCode, latency time.Duration) {
+	h, ok := p.statusLatencies[code]
+	if !ok {
+		h = newLatencyHistogram()
+		p.statusLatencies[code] = h
+	}
+	h.record(latency)
+}
+
+// computeStatusBands cross-tabulates response codes against the run's latency percentiles. Like the percentiles
+// there have to be --min-samples latencies, and with one response code there's nothing to compare
+func (p *PayLoader) computeStatusBands(results *GoPayloaderResults) {
+	if len(p.statusLatencies) < 2 || p.latencies.total == 0 || p.latencies.total < p.config.MinSamples {
+		return
+	}
+
+	lower := -1
+	for _, b := range statusBands {
+		upper := histogramBuckets - 1
+		if b.percentile < 100 {
+			upper = p.latencies.percentileBucket(b.percentile)
+		}
+		band := StatusBand{Band: b.name, Upper: bucketUpper(upper), Responses: make(map[worker.ResponseCode]int64)}
+		if band.Upper > results.Latency.Max {
+			band.Upper = results.Latency.Max
+		}
+		for code, h := range p.statusLatencies {
+			var count int64
+			for i := lower + 1; i <= upper; i++ {
+				count += h.counts[i]
+			}
+			if count > 0 {
+				band.Responses[code] = count
+				band.Requests += count
+			}
+		}
+		lower = upper
+		// percentiles in the same bucket leave nothing between them
+		if band.Requests > 0 {
+			results.StatusBands = append(results.StatusBands, band)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_ComputeStatusBands(t *testing.T) {
+	// every 10th request is a slow error, as if a backend timed out
+	sent := time.Now().UnixNano()
+	var timings []http_clients.ReqTiming
+	for i := 1; i <= 200; i++ {
+		timing := http_clients.ReqTiming{Sent: sent, Done: sent + int64(time.Millisecond), Status: 200}
+		if i%10 == 0 {
+			timing.Done, timing.Status = sent+int64(30*time.Millisecond), 500
+		}
+		timings = append(timings, timing)
+	}
+	statusBands := func(conf *config.Config, timings []http_clients.ReqTiming) []StatusBand {
+		p := NewPayLoader(conf)
+		p.latencies = newLatencyHistogram()
+		p.statusLatencies = make(map[worker.ResponseCode]*latencyHistogram)
+		results := &GoPayloaderResults{}
+		recordTimings(p, results, timings...)
+		p.computeStatusBands(results)
+		return results.StatusBands
+	}
+
+	bands := map[string]StatusBand{}
+	var total int64
+	for _, b := range statusBands(&config.Config{}, timings) {
+		bands[b.Band] = b
+		total += b.Requests
+	}
+	if total != 200 {
+		t.Fatalf("wanted every response in a band got %d; %+v", total, bands)
+	}
+	if fast := bands["up to p50"]; fast.Requests == 0 || fast.Responses[500] != 0 {
+		t.Errorf("wanted only 200s in the fastest half got %+v", fast)
+	}
+	// the 500s are the slowest 10%
+	slow := bands["p90 to p99"].Responses[500] + bands["above p99"].Responses[500]
+	if slow != 20 {
+		t.Errorf("wanted all 20 500s above p90 got %d; %+v", slow, bands)
+	}
+	// the slowest 500s can share a bucket with p99 leaving nothing above it
+	if errs, ok := bands["above p99"]; ok && errs.Responses[500] != errs.Requests {
+		t.Errorf("wanted only 500s above p99 got %+v", errs)
+	}
+
+	if got := statusBands(&config.Config{}, timings[:9]); got != nil {
+		t.Errorf("wanted no bands with only 200s got %+v", got)
+	}
+	if got := statusBands(&config.Config{MinSamples: 500}, timings); got != nil {
+		t.Errorf("wanted no bands with fewer than min samples got %+v", got)
+	}
+}
@@ -0,0 +1,102 @@
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"github.com/pterm/pterm"
+	"net/http"
+	"time"
+)
+
+// TargetUsage is the CPU and memory the target reported about itself on --target-metrics-url while running. CPU is
+// in cores i.e. 1.5 is one and a half cores busy, on average over the run and PeakCPU over the busiest interval
+// between two scrapes. RSS is the average resident memory of the scrapes in bytes. HasCPU and HasRSS are false when
+// the target doesn't export process_cpu_seconds_total or process_resident_memory_bytes
+type TargetUsage struct {
+	URL           string
+	Scrapes       int
+	FailedScrapes int
+	HasCPU        bool
+	CPU           float64
+	PeakCPU       float64
+	HasRSS        bool
+	RSS           int64
+	PeakRSS       int64
+}
+
+// scrapeTarget scrapes --target-metrics-url when the run starts and every --target-metrics-interval, then once more
+// when ctx is done so even a run shorter than the interval has CPU used over it
+func (p *PayLoader) scrapeTarget(ctx context.Context) *TargetUsage {
+	client := &http.Client{Timeout: p.config.TargetMetricsEvery}
+	usage := &TargetUsage{URL: p.config.TargetMetricsURL}
+	var samples []metrics.Process
+	scrape := func(ctx context.Context) {
+		s, err := metrics.ScrapeProcess(ctx, client, p.config.TargetMetricsURL)
+		if err != nil {
+			if ctx.Err() != nil {
+				// cut off by the end of the run rather than the target failing, it's scraped again once more
+				return
+			}
+			if usage.FailedScrapes == 0 {
+				// reported once, the target may only be briefly too busy to answer
+				pterm.Warning.Printf("Failed to scrape target metrics; %v\n", err)
+			}
+			usage.FailedScrapes++
+			return
+		}
+		samples = append(samples, s)
+	}
+
+	scrape(ctx)
+	tick := time.NewTicker(p.config.TargetMetricsEvery)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			scrape(context.Background())
+			usage.summarise(samples)
+			return usage
+		case <-tick.C:
+			scrape(ctx)
+		}
+	}
+}
+
+// summarise works out CPU from the change in CPU seconds between scrapes, a counter going down is the target
+// restarting so that interval is left out
+func (u *TargetUsage) summarise(samples []metrics.Process) {
+	u.Scrapes = len(samples)
+	var cpu, wall float64
+	var rssTotal float64
+	var rssScrapes int
+	for i, s := range samples {
+		if s.HasRSS {
+			u.HasRSS = true
+			rssTotal += s.RSSBytes
+			rssScrapes++
+			if int64(s.RSSBytes) > u.PeakRSS {
+				u.PeakRSS = int64(s.RSSBytes)
+			}
+		}
+		if i == 0 || !s.HasCPU || !samples[i-1].HasCPU {
+			continue
+		}
+		used := s.CPUSeconds - samples[i-1].CPUSeconds
+		elapsed := s.At.Sub(samples[i-1].At).Seconds()
+		if used < 0 || elapsed <= 0 {
+			continue
+		}
+		u.HasCPU = true
+		cpu += used
+		wall += elapsed
+		if used/elapsed > u.PeakCPU {
+			u.PeakCPU = used / elapsed
+		}
+	}
+	if wall > 0 {
+		u.CPU = cpu / wall
+	}
+	if rssScrapes > 0 {
+		u.RSS = int64(rssTotal / float64(rssScrapes))
+	}
+}
@@ -0,0 +1,72 @@
+package payloader
+
+import (
+	"context"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTargetUsage_Summarise(t *testing.T) {
+	start := time.Now()
+	mb := float64(1024 * 1024)
+	samples := []metrics.Process{
+		{At: start, CPUSeconds: 10, HasCPU: true, RSSBytes: 100 * mb, HasRSS: true},
+		// a core for the first second then half a core
+		{At: start.Add(time.Second), CPUSeconds: 11, HasCPU: true, RSSBytes: 150 * mb, HasRSS: true},
+		{At: start.Add(2 * time.Second), CPUSeconds: 11.5, HasCPU: true, RSSBytes: 100 * mb, HasRSS: true},
+		// the target restarted so its CPU seconds went back down, that interval is left out
+		{At: start.Add(3 * time.Second), CPUSeconds: 0.5, HasCPU: true, RSSBytes: 50 * mb, HasRSS: true},
+	}
+	u := &TargetUsage{}
+	u.summarise(samples)
+	want := TargetUsage{Scrapes: 4, HasCPU: true, CPU: 0.75, PeakCPU: 1, HasRSS: true, RSS: int64(100 * mb), PeakRSS: int64(150 * mb)}
+	if *u != want {
+		t.Errorf("wanted target usage %+v got %+v", want, *u)
+	}
+
+	// a target without process metrics
+	u = &TargetUsage{}
+	u.summarise([]metrics.Process{{At: start}, {At: start.Add(time.Second)}})
+	if want := (TargetUsage{Scrapes: 2}); *u != want {
+		t.Errorf("wanted no CPU or memory got %+v", *u)
+	}
+}
+
+func TestPayLoader_ScrapeTarget(t *testing.T) {
+	// busy with half a core throughout
+	start := time.Now()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, "process_cpu_seconds_total %g\n", 10+time.Since(start).Seconds()/2)
+	}))
+	defer server.Close()
+
+	scrape := func(path string) *TargetUsage {
+		p := NewPayLoader(&config.Config{TargetMetricsURL: server.URL + path, TargetMetricsEvery: 200 * time.Millisecond})
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		return p.scrapeTarget(ctx)
+	}
+
+	usage := scrape("/metrics")
+	// when it starts, every 200ms and once more when it's done
+	if usage.Scrapes < 5 || usage.FailedScrapes != 0 {
+		t.Errorf("wanted a scrape every 200ms over 1s got %d, %d failed", usage.Scrapes, usage.FailedScrapes)
+	}
+	if !usage.HasCPU || usage.CPU < 0.45 || usage.CPU > 0.55 {
+		t.Errorf("wanted half a core of target CPU got %v", usage.CPU)
+	}
+
+	usage = scrape("/missing")
+	if usage.Scrapes != 0 || usage.FailedScrapes < 5 || usage.HasCPU {
+		t.Errorf("wanted only failed scrapes got %+v", usage)
+	}
+}
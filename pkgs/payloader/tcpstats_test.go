@@ -0,0 +1,58 @@
+//go:build linux
+
+package payloader
+
+import (
+	"context"
+	"github.com/domsolutions/gopayloader/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPayLoader_RunTCPStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	for _, client := range []string{"fasthttp-1", "nethttp"} {
+		conf := &config.Config{
+			Ctx:           context.Background(),
+			ReqURI:        server.URL,
+			ReqTarget:     20,
+			Conns:         2,
+			ReadTimeout:   5 * time.Second,
+			WriteTimeout:  5 * time.Second,
+			Method:        "GET",
+			Client:        client,
+			VerboseTicker: time.Second,
+			TCPStats:      true,
+		}
+		results, err := NewPayLoader(conf).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if results.CompletedReqs != 20 {
+			t.Fatalf("%s: wanted 20 completed requests got %d; %v", client, results.CompletedReqs, results.Errors)
+		}
+		if results.TCP == nil {
+			t.Fatalf("%s: wanted tcp stats", client)
+		}
+		if results.TCP.Conns < 2 {
+			t.Errorf("%s: wanted at least 2 connections sampled got %d", client, results.TCP.Conns)
+		}
+		if results.TCP.SegmentsOut == 0 {
+			t.Errorf("%s: wanted segments sent", client)
+		}
+		if results.TCP.RTTAverage <= 0 || results.TCP.RTTMax < results.TCP.RTTAverage {
+			t.Errorf("%s: wanted rtt average within max got %v max %v", client, results.TCP.RTTAverage, results.TCP.RTTMax)
+		}
+	}
+
+	conf := &config.Config{ReqURI: server.URL, ReqTarget: 1, Conns: 1, Method: "GET", Client: "fasthttp-2", TCPStats: true}
+	if err := conf.Validate(); err == nil {
+		t.Error("wanted error for tcp stats with fasthttp-2")
+	}
+}
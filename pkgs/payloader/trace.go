@@ -0,0 +1,52 @@
+package payloader
+
+import (
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/pterm/pterm"
+	"net/http"
+	"strings"
+)
+
+// traceReflected are headers holding credentials, a server which allows TRACE echoes them back in the response body
+// where they can be read by scripts which can't read the headers themselves i.e. cross-site tracing
+var traceReflected = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-Api-Key"}
+
+// traceAuthHeaders returns the credential headers a TRACE run sends, including JWTs and HMAC signatures
+func traceAuthHeaders(conf *config.Config) []string {
+	var found []string
+	add := func(name string) {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		for _, f := range found {
+			if f == name {
+				return
+			}
+		}
+		found = append(found, name)
+	}
+	for _, h := range conf.Headers {
+		name, _, _ := strings.Cut(h, ":")
+		for _, r := range traceReflected {
+			if strings.EqualFold(strings.TrimSpace(name), r) {
+				add(name)
+			}
+		}
+	}
+	if conf.SendJWT {
+		add(conf.JwtHeader)
+	}
+	if conf.HMACSecret != "" {
+		add(conf.HMACHeader)
+	}
+	return found
+}
+
+// warnTraceCredentials warns when TRACE requests carry credentials, it's allowed so servers' TRACE handling can be
+// tested but anything logging or caching the responses would keep them
+func (p *PayLoader) warnTraceCredentials() {
+	if p.config.Method != http.MethodTrace {
+		return
+	}
+	if auth := traceAuthHeaders(p.config); len(auth) > 0 {
+		pterm.Warning.Printf("TRACE requests are sent with %s, servers which allow TRACE echo them back in the response\n", strings.Join(auth, ", "))
+	}
+}
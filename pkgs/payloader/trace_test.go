@@ -0,0 +1,53 @@
+package payloader
+
+import (
+	"bytes"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/pterm/pterm"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTraceAuthHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.Config
+		want   []string
+	}{
+		{name: "no credentials", config: config.Config{Headers: []string{"Accept: */*"}}},
+		{name: "authorization", config: config.Config{Headers: []string{"authorization: Bearer abc", "Accept: */*"}}, want: []string{"Authorization"}},
+		{name: "jwt", config: config.Config{SendJWT: true, JwtHeader: "x-jwt"}, want: []string{"X-Jwt"}},
+		{name: "hmac signature", config: config.Config{HMACSecret: "secret", HMACHeader: "X-Signature"}, want: []string{"X-Signature"}},
+		// listed once
+		{name: "jwt in authorization", config: config.Config{Headers: []string{"Authorization: Basic abc"}, SendJWT: true, JwtHeader: "authorization"}, want: []string{"Authorization"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := traceAuthHeaders(&tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wanted %v got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPayLoader_WarnTraceCredentials(t *testing.T) {
+	out := &bytes.Buffer{}
+	pterm.SetDefaultOutput(out)
+	pterm.DisableStyling()
+	defer func() {
+		pterm.SetDefaultOutput(os.Stdout)
+		pterm.EnableStyling()
+	}()
+
+	headers := []string{"Authorization: Bearer abc", "Cookie: session=1"}
+	NewPayLoader(&config.Config{Method: "GET", Headers: headers}).warnTraceCredentials()
+	if out.Len() != 0 {
+		t.Errorf("wanted no warning for GET requests got %q", out.String())
+	}
+	NewPayLoader(&config.Config{Method: "TRACE", Headers: headers}).warnTraceCredentials()
+	if !strings.Contains(out.String(), "TRACE requests are sent with Authorization, Cookie") {
+		t.Errorf("wanted a warning about the credentials got %q", out.String())
+	}
+}
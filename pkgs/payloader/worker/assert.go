@@ -0,0 +1,107 @@
+package worker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	"strings"
+)
+
+type headerAssertion struct {
+	key     string
+	val     string
+	present bool
+}
+
+func getHeaderAssertions(config *http_clients.Config) []headerAssertion {
+	assertions := make([]headerAssertion, 0, len(config.ExpectHeaders)+len(config.ExpectPresent))
+	for _, h := range config.ExpectHeaders {
+		header := strings.SplitN(h, ":", 2)
+		assertions = append(assertions, headerAssertion{
+			key: strings.TrimSpace(header[0]),
+			val: strings.TrimSpace(header[1]),
+		})
+	}
+	for _, h := range config.ExpectPresent {
+		assertions = append(assertions, headerAssertion{
+			key:     strings.TrimSpace(h),
+			present: true,
+		})
+	}
+	return assertions
+}
+
+// assertHeaders errors are used as keys in Stats.Errors so they don't include the received value, otherwise
+// every distinct value would be counted separately
+func (w *WorkerBase) assertHeaders() error {
+	for _, a := range w.assertions {
+		val, ok := w.resp.Header(a.key)
+		if !ok {
+			return fmt.Errorf("expected response header %s to be present", a.key)
+		}
+		if !a.present && val != a.val {
+			return fmt.Errorf("expected response header %s to be %s", a.key, a.val)
+		}
+	}
+	return nil
+}
+
+// jsonAssertion checks a field of JSON responses, only every nth response is checked when sampling
+type jsonAssertion struct {
+	path   *jsonpath.Path
+	equals string
+	every  int
+	seen   int
+}
+
+func newJSONAssertion(config *http_clients.Config) *jsonAssertion {
+	if config.AssertJSONPath == nil {
+		return nil
+	}
+	every := config.ValidateEvery
+	if every < 1 {
+		every = 1
+	}
+	return &jsonAssertion{path: config.AssertJSONPath, equals: config.AssertJSONEquals, every: every}
+}
+
+// assertJSON errors are used as keys in Stats.Errors so like assertHeaders they don't include the received value
+func (w *WorkerBase) assertJSON() error {
+	a := w.jsonAssertion
+	a.seen++
+	if (a.seen-1)%a.every != 0 {
+		return nil
+	}
+
+	body, err := w.resp.Body()
+	if err != nil {
+		return fmt.Errorf("failed reading response body; %v", err)
+	}
+	var v any
+	d := json.NewDecoder(bytes.NewReader(body))
+	// numbers are compared as written rather than after a round trip through float64
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("expected response body to be JSON for json path %s", a.path)
+	}
+
+	field, ok := a.path.Lookup(v)
+	if !ok {
+		return fmt.Errorf("expected json path %s to be present", a.path)
+	}
+	if a.equals != "" && !jsonEquals(field, a.equals) {
+		return fmt.Errorf("expected json path %s to be %s", a.path, a.equals)
+	}
+	return nil
+}
+
+// jsonEquals compares strings as is and anything else by its JSON i.e. 42, true or null
+func jsonEquals(v any, want string) bool {
+	if s, ok := v.(string); ok {
+		return s == want
+	}
+	b, err := json.Marshal(v)
+	return err == nil && string(b) == want
+}
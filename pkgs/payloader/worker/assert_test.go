@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/jsonpath"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_AssertHeaders(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Cache", "HIT")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+
+	tests := []struct {
+		name          string
+		expect        []string
+		expectPresent []string
+		wantErr       string
+	}{
+		{
+			name:   "header value matches",
+			expect: []string{"Content-Type: application/json"},
+		},
+		{
+			name:    "header value mismatch",
+			expect:  []string{"Content-Type: text/html"},
+			wantErr: "expected response header Content-Type to be text/html",
+		},
+		{
+			name:          "header present",
+			expectPresent: []string{"x-cache"},
+		},
+		{
+			name:          "header absent",
+			expectPresent: []string{"X-Missing"},
+			wantErr:       "expected response header X-Missing to be present",
+		},
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, tt := range tests {
+			t.Run(client+" "+tt.name, func(t *testing.T) {
+				s := runWorker(t, &http_clients.Config{
+					ReqURI:        server.URL,
+					ReqTarget:     10,
+					Client:        client,
+					ExpectHeaders: tt.expect,
+					ExpectPresent: tt.expectPresent,
+				}).Stats()
+				if tt.wantErr == "" {
+					if s.CompletedReqs != 10 {
+						t.Errorf("wanted 10 completed reqs got %d; %v", s.CompletedReqs, s.Errors)
+					}
+					return
+				}
+				if s.FailedReqs != 10 || s.Errors[tt.wantErr] != 10 {
+					t.Errorf("wanted 10 failed reqs with %q got %d; %v", tt.wantErr, s.FailedReqs, s.Errors)
+				}
+			})
+		}
+	}
+}
+
+func TestWorkerBase_AssertJSON(t *testing.T) {
+	var reqs atomic.Int64
+	// every 4th response is degraded
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		status := "ok"
+		if reqs.Add(1)%4 == 0 {
+			status = "degraded"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":%q,"items":[{"id":1},{"id":2}]}`, status)
+	})
+
+	tests := []struct {
+		name          string
+		path          string
+		equals        string
+		validateEvery int
+		failed        int64
+		err           string
+	}{
+		{name: "mismatched", path: "$.status", equals: "ok", failed: 5, err: "expected json path $.status to be ok"},
+		{name: "matched number", path: "$.items[-1].id", equals: "2"},
+		{name: "present", path: "$['items'][0]"},
+		{name: "missing", path: "$.items[2].id", failed: 20, err: "expected json path $.items[2].id to be present"},
+		// only responses 1, 5, 9... are checked which are never degraded
+		{name: "sampled", path: "$.status", equals: "ok", validateEvery: 4},
+	}
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, tt := range tests {
+			t.Run(client+" "+tt.name, func(t *testing.T) {
+				path, err := jsonpath.Parse(tt.path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reqs.Store(0)
+				s := runWorker(t, &http_clients.Config{
+					ReqURI:           server.URL,
+					ReqTarget:        20,
+					Client:           client,
+					AssertJSONPath:   path,
+					AssertJSONEquals: tt.equals,
+					ValidateEvery:    tt.validateEvery,
+				}).Stats()
+				if s.FailedReqs != tt.failed || s.CompletedReqs != 20-tt.failed {
+					t.Errorf("wanted %d failed reqs got %d failed %d completed; %v", tt.failed, s.FailedReqs, s.CompletedReqs, s.Errors)
+				}
+				if tt.err != "" && s.Errors[tt.err] != uint(tt.failed) {
+					t.Errorf("wanted %d errors %s got %v", tt.failed, tt.err, s.Errors)
+				}
+			})
+		}
+	}
+}
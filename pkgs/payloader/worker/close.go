@@ -0,0 +1,27 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math/rand"
+)
+
+// connCloser decides which requests are sent with Connection: close for --close-rate, seeded from --seed like
+// logSampler so the same requests close their connection on every run
+type connCloser struct {
+	rand *rand.Rand
+	rate float64
+}
+
+func newConnCloser(config *http_clients.Config) *connCloser {
+	if config.CloseRate <= 0 {
+		return nil
+	}
+	return &connCloser{
+		rand: config.NewRand(http_clients.RandCloseRate),
+		rate: config.CloseRate,
+	}
+}
+
+func (w *WorkerBase) setConnectionClose() {
+	w.req.SetConnectionClose(w.connCloser.rand.Float64() < w.connCloser.rate)
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_SetConnectionClose(t *testing.T) {
+	if newConnCloser(&http_clients.Config{}) != nil {
+		t.Error("wanted no closer without --close-rate")
+	}
+
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, rate := range []float64{0, 0.2} {
+			conns.Store(0)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    server.URL,
+				ReqTarget: 500,
+				Client:    client,
+				Seed:      1,
+				CloseRate: rate,
+			}).Stats()
+			if s.CompletedReqs != 500 {
+				t.Fatalf("%s: wanted 500 completed reqs got %d; %v", client, s.CompletedReqs, s.Errors)
+			}
+
+			// the first connection plus one for each of the ~100 requests which closed theirs
+			got := conns.Load()
+			if rate == 0 && got != 1 {
+				t.Errorf("%s: wanted 1 connection without a close rate got %d", client, got)
+			}
+			if rate > 0 && (got < 70 || got > 130) {
+				t.Errorf("%s: wanted around 100 connections with close rate %g got %d", client, rate, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+)
+
+// comparer replays every request against a second target so its responses can be checked for divergences from
+// the main target, i.e. shadow traffic when validating a migration
+type comparer struct {
+	client  http_clients.GoPayLoaderClient
+	req     http_clients.Request
+	resp    http_clients.Response
+	headers []string
+}
+
+func newComparer(config *http_clients.Config) (*comparer, error) {
+	c := *config
+	c.ReqURI = config.CompareURI
+	// handshakes are only reported for the main target
+	c.TLSStats = nil
+	// SRV targets are for the main target
+	c.DialAddr = ""
+	c.ConnTracker = nil
+
+	client, err := getClient(&c)
+	if err != nil {
+		return nil, err
+	}
+	req, err := getReq(client, &c)
+	if err != nil {
+		return nil, err
+	}
+
+	return &comparer{
+		client:  client,
+		req:     req,
+		resp:    client.NewResponse(),
+		headers: config.CompareHeaders,
+	}, nil
+}
+
+// compare sends the request to the compare target and returns the first way its response diverges from the main
+// response, or an empty string if they match. Divergences are used as keys in Stats.Divergences so they don't
+// include header values or bodies
+func (w *WorkerBase) compare() string {
+	c := w.comparer
+	err := c.client.Do(c.req, c.resp)
+	defer c.resp.Close()
+	if err != nil {
+		return fmt.Sprintf("compare target request failed; %v", err)
+	}
+
+	if want, got := w.resp.StatusCode(), c.resp.StatusCode(); want != got {
+		return fmt.Sprintf("status code %d, compare target %d", want, got)
+	}
+
+	for _, h := range c.headers {
+		want, wantOk := w.resp.Header(h)
+		got, gotOk := c.resp.Header(h)
+		if want != got || wantOk != gotOk {
+			return fmt.Sprintf("header %s differs", h)
+		}
+	}
+
+	want, err := hashBody(w.resp)
+	if err != nil {
+		return fmt.Sprintf("failed reading response body; %v", err)
+	}
+	got, err := hashBody(c.resp)
+	if err != nil {
+		return fmt.Sprintf("failed reading compare target response body; %v", err)
+	}
+	if want != got {
+		return "body differs"
+	}
+	return ""
+}
+
+func hashBody(resp http_clients.Response) ([sha256.Size]byte, error) {
+	body, err := resp.Body()
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(body), nil
+}
+
+func (w *WorkerBase) recordDivergence(divergence string) {
+	if divergence == "" {
+		return
+	}
+	w.stats.DivergedReqs++
+	w.stats.Divergences[divergence]++
+}
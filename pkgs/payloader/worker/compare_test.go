@@ -0,0 +1,77 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestWorkerBase_Compare(t *testing.T) {
+	handler := func(body, version string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Version", version)
+			_, _ = w.Write([]byte(body))
+		}
+	}
+	primary := testServer(t, handler(`{"status":"ok"}`, "1"))
+	same := testServer(t, handler(`{"status":"ok"}`, "2"))
+	different := testServer(t, handler(`{"status":"changed"}`, "1"))
+	missing := testServer(t, http.NotFound)
+
+	tests := []struct {
+		name            string
+		compare         string
+		compareHeaders  []string
+		wantDivergences map[string]uint
+	}{
+		{
+			name:            "matching responses",
+			compare:         same.URL,
+			wantDivergences: map[string]uint{},
+		},
+		{
+			name:            "different bodies",
+			compare:         different.URL,
+			wantDivergences: map[string]uint{"body differs": 10},
+		},
+		{
+			name:            "different header",
+			compare:         same.URL,
+			compareHeaders:  []string{"x-version"},
+			wantDivergences: map[string]uint{"header x-version differs": 10},
+		},
+		{
+			name:            "different status code",
+			compare:         missing.URL,
+			wantDivergences: map[string]uint{"status code 200, compare target 404": 10},
+		},
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, tt := range tests {
+			t.Run(client+" "+tt.name, func(t *testing.T) {
+				s := runWorker(t, &http_clients.Config{
+					ReqURI:         primary.URL,
+					ReqTarget:      10,
+					Client:         client,
+					CompareURI:     tt.compare,
+					CompareHeaders: tt.compareHeaders,
+				}).Stats()
+				if s.CompletedReqs != 10 {
+					t.Errorf("wanted 10 completed reqs got %d; %v", s.CompletedReqs, s.Errors)
+				}
+				var want int64
+				for _, count := range tt.wantDivergences {
+					want += int64(count)
+				}
+				if s.DivergedReqs != want {
+					t.Errorf("wanted %d diverged reqs got %d", want, s.DivergedReqs)
+				}
+				if !reflect.DeepEqual(s.Divergences, tt.wantDivergences) {
+					t.Errorf("wanted divergences %v got %v", tt.wantDivergences, s.Divergences)
+				}
+			})
+		}
+	}
+}
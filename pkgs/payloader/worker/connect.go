@@ -0,0 +1,66 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"time"
+)
+
+const (
+	connectBackoffInitial = 100 * time.Millisecond
+	connectBackoffMax     = 2 * time.Second
+	// resetRetries is how many times a reset request is resent with --retry-on-reset
+	resetRetries = 3
+	// errConnectionReset is the error counted for requests failed by a reset, the full error includes the local port
+	// so would be counted separately for every connection
+	errConnectionReset = "connection reset by peer"
+	// errHeaderTooLarge is counted instead of each client's own error, some of which include the headers read so far
+	errHeaderTooLarge = "response headers too large, raise --max-header-size"
+	// errPartialWrite is counted for requests cut off part way through being written, the connection is closed so the
+	// next request opens a new one
+	errPartialWrite = "request partially written, connection discarded"
+)
+
+// retryConnect resends the worker's first request while its connection can't be opened, up to --connect-retries
+// times, so a target which is still starting doesn't fail the run. Once the first request gets through or retries
+// run out, dial errors fail requests like any other error
+func (w *WorkerBase) retryConnect(err error, begin *int64) error {
+	defer func() {
+		w.connectRetries = 0
+	}()
+	_, err = w.retry(err, begin, w.connectRetries, http_clients.IsDialError)
+	return err
+}
+
+// retryReset resends a request the target reset the connection for with --retry-on-reset, i.e. an idle keep-alive
+// connection it had already closed, counting each retry
+func (w *WorkerBase) retryReset(err error, begin *int64) error {
+	retries, err := w.retry(err, begin, resetRetries, http_clients.IsResetError)
+	w.stats.RetriedResets += int64(retries)
+	return err
+}
+
+// retry resends the request while it fails with a retryable error, up to attempts times with exponential backoff.
+// begin is reset before each attempt so time waiting isn't counted as latency, returns how many times the request
+// was resent and the last error
+func (w *WorkerBase) retry(err error, begin *int64, attempts int, retryable func(error) bool) (int, error) {
+	backoff := connectBackoffInitial
+	attempt := 0
+	for ; attempt < attempts && retryable(err); attempt++ {
+		t := time.NewTimer(backoff)
+		select {
+		case <-w.config.Ctx.Done():
+			// user cancelled
+			t.Stop()
+			return attempt, err
+		case <-t.C:
+		}
+
+		backoff *= 2
+		if backoff > connectBackoffMax {
+			backoff = connectBackoffMax
+		}
+		*begin = time.Now().UnixNano()
+		err = w.client.Do(w.req, w.resp)
+	}
+	return attempt, err
+}
@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerBase_RetryConnect(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	// nothing listening yet so without retries every request fails
+	s := runWorker(t, &http_clients.Config{ReqURI: "http://" + addr, ReqTarget: 10}).Stats()
+	if s.CompletedReqs != 0 || s.FailedReqs != 10 {
+		t.Fatalf("wanted all 10 requests to fail without retries got %d completed %d failed", s.CompletedReqs, s.FailedReqs)
+	}
+
+	go func() {
+		// start the server once the worker is retrying
+		time.Sleep(300 * time.Millisecond)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			t.Errorf("failed to start delayed server; %v", err)
+			return
+		}
+		server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})}
+		go server.Serve(ln)
+		t.Cleanup(func() {
+			server.Close()
+		})
+	}()
+
+	var maxLatency time.Duration
+	stats := make(chan http_clients.ReqTiming)
+	w, err := NewWorker(&http_clients.Config{
+		Ctx:            context.Background(),
+		ReqURI:         "http://" + addr,
+		ReqTarget:      10,
+		Method:         "GET",
+		Client:         HttpClientFastHTTP1,
+		ReadTimeout:    time.Second,
+		WriteTimeout:   time.Second,
+		ConnectRetries: 10,
+		StartTrigger:   &sync.WaitGroup{},
+		ReqStats:       stats,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		wg := &sync.WaitGroup{}
+		wg.Add(1)
+		w.Run(wg)
+		close(stats)
+	}()
+	for timing := range stats {
+		if latency := time.Duration(timing.Done - timing.Sent); latency > maxLatency {
+			maxLatency = latency
+		}
+	}
+
+	if s := w.Stats(); s.CompletedReqs != 10 || s.FailedReqs != 0 {
+		t.Fatalf("wanted all 10 requests to complete after retrying got %d completed %d failed; %v", s.CompletedReqs, s.FailedReqs, s.Errors)
+	}
+	if maxLatency >= 100*time.Millisecond {
+		t.Errorf("wanted time waiting to connect left out of latency got max %s", maxLatency)
+	}
+}
+
+func TestWorkerBase_RetryReset(t *testing.T) {
+	var reqs, resets atomic.Int64
+	var partial atomic.Bool
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if reqs.Add(1)%3 != 1 {
+			return
+		}
+		resets.Add(1)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if partial.Load() {
+			// fasthttp resends requests itself if the connection is closed before the first byte of the response, as
+			// that's how a keep-alive connection closed while idle looks
+			conn.Write([]byte("HTTP/1.1 200 OK\r\n"))
+		}
+		// closing without lingering sends a RST instead of a FIN
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	})
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, retry := range []bool{false, true} {
+			reqs.Store(0)
+			resets.Store(0)
+			partial.Store(client == HttpClientFastHTTP1)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    server.URL,
+				ReqTarget: 10,
+				// clients resend idempotent requests themselves
+				Method:       "POST",
+				Client:       client,
+				RetryOnReset: retry,
+			}).Stats()
+
+			if retry {
+				if s.CompletedReqs != 10 || s.RetriedResets != resets.Load() {
+					t.Errorf("%s: wanted all 10 requests to complete after %d retries got %d completed %d retries; %v",
+						client, resets.Load(), s.CompletedReqs, s.RetriedResets, s.Errors)
+				}
+				continue
+			}
+			if s.FailedReqs != 4 || s.Errors[errConnectionReset] != 4 || s.RetriedResets != 0 {
+				t.Errorf("%s: wanted 4 requests to fail from resets without retries got %d failed %d retries; %v",
+					client, s.FailedReqs, s.RetriedResets, s.Errors)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"os"
+	"strings"
+)
+
+// contentHasher sends a hash of every request's method, path and body so the target can spot duplicate requests,
+// for testing idempotency keys or request deduplication. The header is the hex SHA-256 of "<method> <path>\n<body>"
+// where path includes the query
+type contentHasher struct {
+	header string
+	// body is nil with --body-template-file as it's rendered for every request
+	body []byte
+	// fixed is the hash of every request when nothing about them changes between requests
+	fixed string
+	buf   []byte
+	hex   [2 * sha256.Size]byte
+}
+
+func newContentHasher(config *http_clients.Config) (*contentHasher, error) {
+	if config.ContentHashHeader == "" {
+		return nil, nil
+	}
+	h := &contentHasher{header: config.ContentHashHeader}
+	if config.BodyTemplate == nil {
+		h.body = []byte(config.Body)
+		if len(config.BodyFile) > 0 {
+			bb, err := os.ReadFile(config.BodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read body file %v", err)
+			}
+			h.body = bb
+		}
+		if config.URITemplate == nil && config.QueryFuzz == nil && config.DataCSV == nil {
+			h.fixed = h.sum(config.Method, reqTarget(config.ReqURI), h.body)
+		}
+	}
+	return h, nil
+}
+
+func (h *contentHasher) sum(method, target string, body []byte) string {
+	h.buf = append(h.buf[:0], method...)
+	h.buf = append(h.buf, ' ')
+	h.buf = append(h.buf, target...)
+	h.buf = append(h.buf, '\n')
+	h.buf = append(h.buf, body...)
+	sum := sha256.Sum256(h.buf)
+	n := hex.Encode(h.hex[:], sum[:])
+	return string(h.hex[:n])
+}
+
+// reqTarget is the path and query of a request URL
+func reqTarget(uri string) string {
+	if _, rest, ok := strings.Cut(uri, "://"); ok {
+		uri = rest
+	}
+	i := strings.IndexByte(uri, '/')
+	if i < 0 {
+		return "/"
+	}
+	return uri[i:]
+}
+
+func (w *WorkerBase) setContentHash() {
+	h := w.contentHasher
+	val := h.fixed
+	if val == "" {
+		body := h.body
+		if w.config.BodyTemplate != nil {
+			body = w.reqTemplate.buf.Bytes()
+		}
+		val = h.sum(w.reqMethod(), reqTarget(w.reqURL()), body)
+	}
+	w.req.SetHeader(h.header, val)
+	if w.comparer != nil {
+		// the same request is sent to both targets
+		w.comparer.req.SetHeader(h.header, val)
+	}
+}
@@ -0,0 +1,85 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+)
+
+func TestReqTarget(t *testing.T) {
+	tests := []struct {
+		uri  string
+		want string
+	}{
+		{uri: "http://localhost:8080/orders?region=eu", want: "/orders?region=eu"},
+		{uri: "https://localhost", want: "/"},
+		{uri: "localhost:8080/orders", want: "/orders"},
+	}
+	for _, tt := range tests {
+		if got := reqTarget(tt.uri); got != tt.want {
+			t.Errorf("%s: wanted %s got %s", tt.uri, tt.want, got)
+		}
+	}
+}
+
+func TestWorkerBase_SetContentHash(t *testing.T) {
+	tmpl := template.Must(template.New("body").Parse(`{"seq": {{.Index}}}`))
+
+	tests := []struct {
+		name   string
+		client string
+		body   string
+		tmpl   *template.Template
+		// hashes is how many distinct hashes the requests should have
+		hashes int
+	}{
+		{name: "fixed body", client: HttpClientFastHTTP1, body: `{"order":1}`, hashes: 1},
+		{name: "body template", client: HttpClientFastHTTP1, tmpl: tmpl, hashes: 20},
+		{name: "nethttp body template", client: HttpClientNetHTTP, tmpl: tmpl, hashes: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			hashes := make(map[string]bool)
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				sum := sha256.Sum256([]byte(r.Method + " " + r.URL.RequestURI() + "\n" + string(body)))
+				got := r.Header.Get("X-Content-Hash")
+				if want := hex.EncodeToString(sum[:]); got != want {
+					t.Errorf("wanted content hash %s for %s got %q", want, body, got)
+				}
+				mu.Lock()
+				hashes[got] = true
+				mu.Unlock()
+			})
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:            server.URL + "/orders?region=eu",
+				ReqTarget:         20,
+				Method:            "POST",
+				Client:            tt.client,
+				Body:              tt.body,
+				BodyTemplate:      tt.tmpl,
+				ReqIndex:          &atomic.Int64{},
+				ContentHashHeader: "X-Content-Hash",
+			}).Stats()
+			if s.CompletedReqs != 20 {
+				t.Fatalf("wanted 20 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+			// identical requests share a hash and requests with different bodies don't
+			if len(hashes) != tt.hashes {
+				t.Errorf("wanted %d distinct content hashes got %d", tt.hashes, len(hashes))
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"hash"
+	"io"
+)
+
+// downloader streams response bodies for --stream-body, they're discarded or hashed as they're read so a large
+// download never has to fit in memory
+type downloader struct {
+	hash hash.Hash
+	want []byte
+	sum  []byte
+}
+
+func newDownloader(config *http_clients.Config) *downloader {
+	if !config.StreamBody {
+		return nil
+	}
+	d := &downloader{want: config.ExpectSHA256}
+	if d.want != nil {
+		d.hash = sha256.New()
+	}
+	return d
+}
+
+func (w *WorkerBase) download() error {
+	d := w.downloader
+	var dst io.Writer = io.Discard
+	if d.hash != nil {
+		d.hash.Reset()
+		dst = d.hash
+	}
+
+	n, err := w.resp.StreamBody(dst)
+	w.stats.DownloadedBytes += n
+	if err != nil {
+		return fmt.Errorf("failed reading response body; %v", err)
+	}
+	return nil
+}
+
+// verifyChecksum is only called once a body has been hashed by download, the error doesn't include the received
+// checksum so mismatches are counted together in Stats.Errors
+func (w *WorkerBase) verifyChecksum() error {
+	d := w.downloader
+	d.sum = d.hash.Sum(d.sum[:0])
+	if !bytes.Equal(d.sum, d.want) {
+		return fmt.Errorf("expected response body sha256 to be %x", d.want)
+	}
+	w.stats.ChecksumMatches++
+	return nil
+}
@@ -0,0 +1,55 @@
+package worker
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestWorkerBase_Download(t *testing.T) {
+	body := make([]byte, 4<<20)
+	if _, err := rand.Read(body); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(body)
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "download", time.Time{}, bytes.NewReader(body))
+	})
+
+	wrong := sha256.Sum256([]byte("something else"))
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, expect := range [][]byte{nil, sum[:], wrong[:]} {
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:       server.URL,
+				ReqTarget:    5,
+				Client:       client,
+				StreamBody:   true,
+				ExpectSHA256: expect,
+			}).Stats()
+
+			// every body is downloaded whether or not its checksum matches
+			if s.DownloadedBytes != 5*int64(len(body)) {
+				t.Errorf("%s: wanted %d bytes downloaded got %d", client, 5*len(body), s.DownloadedBytes)
+			}
+			switch {
+			case expect == nil:
+				if s.CompletedReqs != 5 || s.ChecksumMatches != 0 {
+					t.Errorf("%s: wanted 5 completed reqs without checksums got %d completed %d matches; %v", client, s.CompletedReqs, s.ChecksumMatches, s.Errors)
+				}
+			case bytes.Equal(expect, sum[:]):
+				if s.ChecksumMatches != 5 || s.CompletedReqs != 5 {
+					t.Errorf("%s: wanted 5 checksum matches got %d; %v", client, s.ChecksumMatches, s.Errors)
+				}
+			default:
+				if key := fmt.Sprintf("expected response body sha256 to be %x", expect); s.FailedReqs != 5 || s.Errors[key] != 5 || s.ChecksumMatches != 0 {
+					t.Errorf("%s: wanted 5 checksum mismatches got %d failed %d matches; %v", client, s.FailedReqs, s.ChecksumMatches, s.Errors)
+				}
+			}
+		}
+	}
+}
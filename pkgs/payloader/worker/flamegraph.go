@@ -0,0 +1,31 @@
+package worker
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/flamegraph"
+	"time"
+)
+
+// addPhases adds how long each phase of the request took to the --latency-flamegraph, end is when the worker timed
+// the response as done so the transfer ends where the latency does
+func (w *WorkerBase) addPhases(end int64) {
+	p := w.resp.Phases()
+	if p.Start == 0 {
+		return
+	}
+	w.config.Flamegraph.Add(flamegraph.Request{
+		Connection: phase(p.Start, p.GotConn),
+		DNS:        phase(p.DNSStart, p.DNSDone),
+		Connect:    phase(p.ConnectStart, p.ConnectDone),
+		TLS:        phase(p.TLSStart, p.TLSDone),
+		TTFB:       phase(p.GotConn, p.FirstByte),
+		Transfer:   phase(p.FirstByte, end),
+	})
+}
+
+// phase is the time between from and to, 0 if either didn't happen
+func phase(from, to int64) time.Duration {
+	if from == 0 || to < from {
+		return 0
+	}
+	return time.Duration(to - from)
+}
@@ -0,0 +1,93 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/flamegraph"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPhase(t *testing.T) {
+	tests := []struct {
+		from int64
+		to   int64
+		want time.Duration
+	}{
+		{from: 100, to: 250, want: 150},
+		// the phase didn't happen, i.e. no DNS lookup for an IP
+		{from: 0, to: 250},
+		{from: 100, to: 0},
+		{from: 250, to: 100},
+	}
+	for _, tt := range tests {
+		if got := phase(tt.from, tt.to); got != tt.want {
+			t.Errorf("phase(%d, %d) wanted %s got %s", tt.from, tt.to, tt.want, got)
+		}
+	}
+}
+
+func TestWorkerBase_AddPhases(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(2 * time.Millisecond)
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "latency.folded")
+	phases, err := flamegraph.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:     server.URL,
+		ReqTarget:  10,
+		Client:     HttpClientNetHTTP,
+		SkipVerify: true,
+		Phases:     true,
+		Flamegraph: phases,
+	}).Stats()
+	if s.CompletedReqs != 10 {
+		t.Fatalf("wanted 10 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+	}
+	if err := phases.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stacks := map[string]int64{}
+	var total int64
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		frames, micros, ok := strings.Cut(line, " ")
+		if !ok {
+			t.Fatalf("wanted a folded stack got %q", line)
+		}
+		n, err := strconv.ParseInt(micros, 10, 64)
+		if err != nil || n <= 0 {
+			t.Fatalf("wanted microseconds in %q", line)
+		}
+		stacks[frames] = n
+		total += n
+	}
+	// the connection connected and did a TLS handshake once, every request waited at least 2ms for its response
+	for _, frames := range []string{"request;connection;connect", "request;connection;tls", "request;ttfb"} {
+		if stacks[frames] == 0 {
+			t.Errorf("wanted time in %s got %s", frames, b)
+		}
+	}
+	if stacks["request;ttfb"] < 10*2000 {
+		t.Errorf("wanted at least 20ms waiting for the first byte got %dus", stacks["request;ttfb"])
+	}
+	// the phases make up the latency of every request, rounded down to microseconds
+	if latency := s.Latency.Microseconds(); total > latency || total < latency*9/10 {
+		t.Errorf("wanted phases totalling the latency %dus got %dus; %s", latency, total, b)
+	}
+}
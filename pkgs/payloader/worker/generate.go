@@ -5,8 +5,10 @@ import (
 	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
 	"github.com/domsolutions/gopayloader/pkgs/http-clients/fasthttp"
 	"github.com/domsolutions/gopayloader/pkgs/http-clients/nethttp"
+	"io"
 	"os"
 	"strings"
+	"time"
 )
 
 const (
@@ -23,8 +25,30 @@ type ResponseCode int
 type Stats struct {
 	CompletedReqs int64
 	FailedReqs    int64
+	DivergedReqs  int64
+	RetriedResets int64
 	Responses     map[ResponseCode]int64
 	Errors        map[string]uint
+	Divergences   map[string]uint
+	// Slowest is up to --slowest of the worker's slowest completed requests, in no particular order
+	Slowest []SlowRequest
+	// DownloadedBytes and ChecksumMatches are counted for streamed response bodies with --stream-response-body
+	DownloadedBytes int64
+	ChecksumMatches int64
+	// Latency is the total latency of completed requests, for results broken down by target
+	Latency time.Duration
+	// RetryAfterPauses is how many 429 and 503 responses had a Retry-After which was waited out with
+	// --honor-retry-after, RetryAfterWait the total time asked for
+	RetryAfterPauses int64
+	RetryAfterWait   time.Duration
+	// OversizedResps is how many responses were larger than --max-expected-body, LargestOversized the largest of them
+	OversizedResps   int64
+	LargestOversized int64
+	// ThinkTimes is how many pauses were taken between requests with --adaptive-think-time, ThinkTime their total
+	ThinkTimes int64
+	ThinkTime  time.Duration
+	// LatencySample is a random sample of the latencies of completed requests, kept with --conn-outlier-factor
+	LatencySample []time.Duration
 }
 
 func NewWorker(config *http_clients.Config) (Worker, error) {
@@ -39,20 +63,57 @@ func NewWorker(config *http_clients.Config) (Worker, error) {
 		return nil, err
 	}
 
+	base := baseConfig(config, client, req, resp)
+	if config.CompareURI != "" {
+		if base.comparer, err = newComparer(config); err != nil {
+			return nil, err
+		}
+	}
+	if config.HMACSecret != "" {
+		if base.signer, err = newSigner(config); err != nil {
+			return nil, err
+		}
+	}
+	if base.contentHasher, err = newContentHasher(config); err != nil {
+		return nil, err
+	}
+
+	if len(config.Replay) > 0 {
+		w, err := newWorkerReplay(base)
+		if err != nil {
+			return nil, err
+		}
+		if config.JwtStreamReceiver != nil {
+			w.middleware = jwtMiddleware
+		}
+		return w, nil
+	}
+
+	if len(config.Scenario) > 0 {
+		w, err := newWorkerScenario(base)
+		if err != nil {
+			return nil, err
+		}
+		if config.JwtStreamReceiver != nil {
+			w.middleware = jwtMiddleware
+		}
+		return w, nil
+	}
+
 	if config.ReqLimitedOnly() {
 		if config.JwtStreamReceiver != nil {
-			w := &WorkerFixedReqs{baseConfig(config, client, req, resp)}
+			w := &WorkerFixedReqs{base}
 			w.middleware = jwtMiddleware
 			return w, nil
 		}
-		return &WorkerFixedReqs{baseConfig(config, client, req, resp)}, nil
+		return &WorkerFixedReqs{base}, nil
 	}
 
 	if config.UnlimitedReqs() {
-		return &WorkerFixedTime{baseConfig(config, client, req, resp)}, nil
+		return &WorkerFixedTime{base}, nil
 	}
 
-	w := &WorkerFixedTimeRequests{baseConfig(config, client, req, resp)}
+	w := &WorkerFixedTimeRequests{base}
 	if config.JwtStreamReceiver != nil {
 		w.middleware = jwtMiddleware
 	}
@@ -70,6 +131,10 @@ func getReq(client http_clients.GoPayLoaderClient, config *http_clients.Config)
 	}
 	if len(config.Headers) > 0 {
 		for _, h := range config.Headers {
+			if http_clients.IsHeaderTemplate(h) {
+				// set for every request once rendered
+				continue
+			}
 			header := strings.Split(h, ":")
 			req.SetHeader(header[0], header[1])
 		}
@@ -79,33 +144,68 @@ func getReq(client http_clients.GoPayLoaderClient, config *http_clients.Config)
 		req.SetBody([]byte(config.Body))
 	}
 
-	if len(config.BodyFile) > 0 {
+	if len(config.BodyFile) > 0 && config.BodyStream {
+		stat, err := os.Stat(config.BodyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat body file %v", err)
+		}
+		req.SetBodyStream(func() (io.ReadCloser, error) {
+			return os.Open(config.BodyFile)
+		}, stat.Size())
+	} else if len(config.BodyFile) > 0 {
 		bb, err := os.ReadFile(config.BodyFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read body file %v", err)
 		}
 		req.SetBody(bb)
 	}
+
+	if config.ContentLength != "" {
+		// fasthttp only sends the headers as they're set once they're ordered
+		req.SetContentLength(config.ContentLength)
+	}
+	if len(config.HeaderOrder) > 0 || config.ContentLength != "" {
+		req.SetHeaderOrder(config.HeaderOrder)
+	}
 	return req, nil
 }
 
 func jwtMiddleware(w *WorkerBase) {
-	select {
-	case jwt := <-w.config.JwtStreamReceiver:
-		w.req.SetHeader(w.config.JWTHeader, jwt)
+	if w.jwtUses == 0 || w.jwtUses >= w.config.JwtRotateEvery {
+		w.jwt = <-w.config.JwtStreamReceiver
+		w.jwtUses = 0
+	}
+	w.jwtUses++
+	// set every request as replayed requests each have their own
+	w.req.SetHeader(w.config.JWTHeader, w.jwt)
+	if w.comparer != nil {
+		// compare target needs the same token so both responses are for the same request
+		w.comparer.req.SetHeader(w.config.JWTHeader, w.jwt)
 	}
 }
 
 func baseConfig(config *http_clients.Config, client http_clients.GoPayLoaderClient, req http_clients.Request, resp http_clients.Response) *WorkerBase {
 	return &WorkerBase{
-		config:   config,
-		req:      req,
-		resp:     resp,
-		client:   client,
-		reqStats: config.ReqStats,
+		config:         config,
+		req:            req,
+		resp:           resp,
+		client:         client,
+		reqStats:       config.ReqStats,
+		assertions:     getHeaderAssertions(config),
+		jsonAssertion:  newJSONAssertion(config),
+		connCloser:     newConnCloser(config),
+		downloader:     newDownloader(config),
+		logSampler:     newLogSampler(config),
+		reqTemplate:    newReqTemplate(config),
+		queryFuzzer:    newQueryFuzzer(config),
+		tracer:         newTracer(config),
+		latencySample:  newLatencySample(config),
+		thinkTime:      newThinkTime(config),
+		connectRetries: config.ConnectRetries,
 		stats: Stats{
-			Responses: make(map[ResponseCode]int64),
-			Errors:    make(map[string]uint),
+			Responses:   make(map[ResponseCode]int64),
+			Errors:      make(map[string]uint),
+			Divergences: make(map[string]uint),
 		},
 	}
 }
@@ -0,0 +1,190 @@
+package worker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNewWorker_BodyStream(t *testing.T) {
+	const bodySize = 8 * 1024 * 1024
+	const reqs = 5
+
+	body := filepath.Join(t.TempDir(), "body")
+	if err := os.WriteFile(body, bytes.Repeat([]byte("a"), bodySize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var received atomic.Int64
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				n, err := io.Copy(io.Discard, r.Body)
+				if err != nil {
+					t.Error(err)
+				}
+				received.Add(n)
+			})
+
+			w := runWorker(t, &http_clients.Config{
+				ReqURI:     server.URL,
+				ReqTarget:  reqs,
+				Method:     "POST",
+				Client:     client,
+				BodyFile:   body,
+				BodyStream: true,
+			})
+			if s := w.Stats(); s.CompletedReqs != reqs {
+				t.Errorf("wanted %d completed reqs got %d; %v", reqs, s.CompletedReqs, s.Errors)
+			}
+			// the whole file is re-streamed for every request
+			if received.Load() != reqs*bodySize {
+				t.Errorf("wanted the server to receive %d bytes got %d", reqs*bodySize, received.Load())
+			}
+		})
+	}
+}
+
+func TestNewWorker_BinaryBody(t *testing.T) {
+	// bytes which aren't valid UTF-8 or would be taken as the end of a line
+	want := []byte{0x00, 0x01, 0xff, 0x0d, 0x0a, 0x7f, 0x80, 'a'}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mismatched atomic.Int64
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil || !bytes.Equal(body, want) {
+					mismatched.Add(1)
+				}
+			})
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    server.URL,
+				ReqTarget: 10,
+				Method:    "POST",
+				Client:    client,
+				Body:      string(want),
+			}).Stats()
+			if s.CompletedReqs != 10 {
+				t.Errorf("wanted 10 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+			if mismatched.Load() != 0 {
+				t.Errorf("wanted every body to be %x, %d weren't", want, mismatched.Load())
+			}
+		})
+	}
+}
+
+func TestWorker_JwtRotateEvery(t *testing.T) {
+	var mu sync.Mutex
+	var sent []string
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		sent = append(sent, r.Header.Get("some-jwt"))
+		mu.Unlock()
+	})
+
+	jwts := make(chan string, 10)
+	for i := 0; i < 10; i++ {
+		jwts <- fmt.Sprintf("token-%d", i)
+	}
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:            server.URL,
+		ReqTarget:         10,
+		JwtStreamReceiver: jwts,
+		JWTHeader:         "some-jwt",
+		JwtRotateEvery:    4,
+	}).Stats()
+	if s.CompletedReqs != 10 {
+		t.Fatalf("wanted 10 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+	}
+
+	// each token is sent 4 times before the next, the last 2 requests get a token of their own
+	want := []string{"token-0", "token-0", "token-0", "token-0", "token-1", "token-1", "token-1", "token-1", "token-2", "token-2"}
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("wanted tokens %v got %v", want, sent)
+	}
+	if len(jwts) != 7 {
+		t.Errorf("wanted 3 tokens taken got %d", 10-len(jwts))
+	}
+}
+
+func TestNewWorker_ContentLength(t *testing.T) {
+	const body = `{"id":1}`
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	// read as sent, a server would reject the request or read the wrong body
+	type raw struct {
+		head []string
+		body string
+	}
+	sent := make(chan raw, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var req raw
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if line == "\r\n" {
+				break
+			}
+			req.head = append(req.head, strings.TrimSpace(line))
+		}
+		b := make([]byte, len(body))
+		if _, err := io.ReadFull(r, b); err != nil {
+			return
+		}
+		req.body = string(b)
+		sent <- req
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+	}()
+
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:        "http://" + ln.Addr().String() + "/orders",
+		ReqTarget:     1,
+		Method:        "POST",
+		Body:          body,
+		ContentLength: "3",
+	}).Stats()
+	if s.CompletedReqs != 1 {
+		t.Fatalf("wanted 1 completed request got %d; %v", s.CompletedReqs, s.Errors)
+	}
+
+	req := <-sent
+	var got []string
+	for _, h := range req.head[1:] {
+		if name, val, _ := strings.Cut(h, ":"); strings.EqualFold(name, "Content-Length") {
+			got = append(got, strings.TrimSpace(val))
+		}
+	}
+	if len(got) != 1 || got[0] != "3" {
+		t.Errorf("wanted content length 3 got %v", got)
+	}
+	// the whole body is still sent
+	if req.body != body {
+		t.Errorf("wanted body %s got %s", body, req.body)
+	}
+}
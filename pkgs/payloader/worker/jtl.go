@@ -0,0 +1,20 @@
+package worker
+
+import (
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/jtl"
+	"time"
+)
+
+func (w *WorkerBase) writeJTL(begin, end int64, status int, err error) {
+	w.config.JTL.Write(jtl.Sample{
+		Start:    time.Unix(0, begin),
+		Elapsed:  time.Duration(end - begin),
+		Method:   w.reqMethod(),
+		URL:      w.reqURL(),
+		Status:   status,
+		Err:      err,
+		WorkerID: w.config.WorkerID,
+		ReqSize:  w.ReqSize(),
+		RespSize: w.RespSize(),
+	})
+}
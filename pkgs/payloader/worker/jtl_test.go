@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"encoding/csv"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/jtl"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_WriteJTL(t *testing.T) {
+	server := testServer(t, nil)
+	uri := server.URL + "/items/{{.Data.id}}"
+	uriTemplate, err := http_clients.ParseURITemplate(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "results.jtl")
+	writer, err := jtl.Create(path, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runWorker(t, &http_clients.Config{
+		ReqURI:      uri,
+		URITemplate: uriTemplate,
+		DataCSV: &http_clients.DataCSV{
+			Columns: []string{"id", http_clients.DataMethodColumn},
+			Rows:    []map[string]string{{"id": "a", "method": "PUT"}, {"id": "b", "method": "DELETE"}},
+		},
+		ReqIndex:  &atomic.Int64{},
+		ReqTarget: 2,
+		JTL:       writer,
+	})
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("wanted the header and 2 rows got %v", rows)
+	}
+	// each row is labelled with the method and URL of the --data-csv row the request was sent with
+	for i, want := range [][2]string{{"PUT", server.URL + "/items/a"}, {"DELETE", server.URL + "/items/b"}} {
+		if row := rows[i+1]; row[2] != want[0] || row[13] != want[1] {
+			t.Errorf("wanted request %d written as %s %s got %s %s", i, want[0], want[1], row[2], row[13])
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math/rand"
+	"time"
+)
+
+// DefaultLatencySamples is how many latencies each worker keeps without --max-samples, enough for a stable median
+// without growing with the length of the run
+const DefaultLatencySamples = 1024
+
+// latencySample is a reservoir sample of the worker's completed request latencies, used to compare connections with
+// each other for --conn-outlier-factor. Seeded from --seed like the log sampler so runs are repeatable
+type latencySample struct {
+	rand *rand.Rand
+	seen int64
+	size int
+}
+
+func newLatencySample(config *http_clients.Config) *latencySample {
+	if config.LatencySamples <= 0 {
+		return nil
+	}
+	return &latencySample{
+		rand: config.NewRand(http_clients.RandLatencySample),
+		size: config.LatencySamples,
+	}
+}
+
+// add keeps latency in sample with the same chance as every latency seen before it, sample never holds more than
+// the reservoir's size
+func (s *latencySample) add(sample []time.Duration, latency time.Duration) []time.Duration {
+	s.seen++
+	if len(sample) < s.size {
+		if sample == nil {
+			sample = make([]time.Duration, 0, s.size)
+		}
+		return append(sample, latency)
+	}
+	if i := s.rand.Int63n(s.seen); i < int64(s.size) {
+		sample[i] = latency
+	}
+	return sample
+}
+
+func (w *WorkerBase) sampleLatency(latency time.Duration) {
+	w.stats.LatencySample = w.latencySample.add(w.stats.LatencySample, latency)
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLatencySample(t *testing.T) {
+	const size, seen = 100, 10000
+
+	s := newLatencySample(&http_clients.Config{LatencySamples: size, Seed: 1})
+	var sample []time.Duration
+	for i := 0; i < 10*seen; i++ {
+		sample = s.add(sample, time.Duration(i))
+	}
+	if len(sample) != size || cap(sample) != size {
+		t.Fatalf("wanted the sample held at %d latencies got len %d cap %d", size, len(sample), cap(sample))
+	}
+
+	// every latency should be as likely to be kept as any other, so each tenth of the run is about a tenth of the
+	// samples over many runs
+	const runs = 500
+	var tenths [10]int
+	for seed := int64(0); seed < runs; seed++ {
+		s := newLatencySample(&http_clients.Config{LatencySamples: size, Seed: seed})
+		var sample []time.Duration
+		for i := 0; i < seen; i++ {
+			sample = s.add(sample, time.Duration(i))
+		}
+		for _, l := range sample {
+			tenths[int(l)*10/seen]++
+		}
+	}
+	want := float64(size*runs) / 10
+	for i, got := range tenths {
+		if math.Abs(float64(got)-want) > want*0.05 {
+			t.Errorf("wanted about %.0f samples from tenth %d of the run got %d; %v", want, i, got, tenths)
+		}
+	}
+
+	if newLatencySample(&http_clients.Config{}) != nil {
+		t.Error("wanted no sample without LatencySamples")
+	}
+}
+
+func TestWorker_LatencySample(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(time.Millisecond)
+	})
+
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:         server.URL,
+		ReqTarget:      20,
+		LatencySamples: 10,
+	}).Stats()
+	if s.CompletedReqs != 20 {
+		t.Fatalf("wanted 20 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+	}
+	if len(s.LatencySample) != 10 {
+		t.Fatalf("wanted 10 latencies sampled got %d", len(s.LatencySample))
+	}
+	for _, l := range s.LatencySample {
+		if l < time.Millisecond {
+			t.Errorf("wanted sampled latencies of at least 1ms got %s", l)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package worker
+
+// recordOversized counts a response larger than --max-expected-body. It's measured like the results' byte sizes, so
+// includes the headers, and isn't failed as an oversized response can otherwise be fine
+func (w *WorkerBase) recordOversized() {
+	size := w.RespSize()
+	if size <= w.config.MaxExpectedBody {
+		return
+	}
+	w.stats.OversizedResps++
+	if size > w.stats.LargestOversized {
+		w.stats.LargestOversized = size
+	}
+}
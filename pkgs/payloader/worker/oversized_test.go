@@ -0,0 +1,43 @@
+package worker
+
+import (
+	"bytes"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_RecordOversized(t *testing.T) {
+	var reqs atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// every fifth response is bloated
+		if reqs.Add(1)%5 == 0 {
+			w.Write(bytes.Repeat([]byte("x"), 64*1024))
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			reqs.Store(0)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:          server.URL,
+				ReqTarget:       20,
+				Client:          client,
+				MaxExpectedBody: 16 * 1024,
+			}).Stats()
+			// oversized responses are flagged but still succeed
+			if s.CompletedReqs != 20 || s.FailedReqs != 0 {
+				t.Fatalf("wanted 20 completed requests got %d completed %d failed; %v", s.CompletedReqs, s.FailedReqs, s.Errors)
+			}
+			if s.OversizedResps != 4 {
+				t.Errorf("wanted 4 oversized responses got %d", s.OversizedResps)
+			}
+			if s.LargestOversized < 64*1024 || s.LargestOversized > 65*1024 {
+				t.Errorf("wanted largest oversized response around 64KB got %d", s.LargestOversized)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math/rand"
+	"net/url"
+	"strings"
+)
+
+// queryFuzzer generates a new query string for every request from --query-fuzz, appended to any query already in
+// the target URL
+type queryFuzzer struct {
+	rand   *rand.Rand
+	params []byte
+	// buf is the last query sent to the target, compareBuf to the compare target
+	buf        []byte
+	compareBuf []byte
+	// base and compareBase are the queries of the target and compare target URLs
+	base        string
+	compareBase string
+}
+
+func newQueryFuzzer(config *http_clients.Config) *queryFuzzer {
+	if config.QueryFuzz == nil {
+		return nil
+	}
+	return &queryFuzzer{
+		rand:        config.NewRand(http_clients.RandQueryFuzz),
+		base:        rawQuery(config.ReqURI),
+		compareBase: rawQuery(config.CompareURI),
+	}
+}
+
+// rawQuery returns the query of uri, which has already been validated
+func rawQuery(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.RawQuery
+}
+
+func (w *WorkerBase) fuzzQuery() {
+	f := w.queryFuzzer
+	f.params = w.config.QueryFuzz.Append(f.params[:0], f.rand)
+	f.buf = append(withBase(f.buf[:0], f.base), f.params...)
+	w.req.SetQuery(f.buf)
+	if w.comparer != nil {
+		// same generated parameters so both targets get the same request
+		f.compareBuf = append(withBase(f.compareBuf[:0], f.compareBase), f.params...)
+		w.comparer.req.SetQuery(f.compareBuf)
+	}
+}
+
+// reqURL is the target URL including the last generated query or the last URL rendered from its template, or the
+// URL of the --replay-har request being sent
+func (w *WorkerBase) reqURL() string {
+	if w.replayURL != "" {
+		return w.replayURL
+	}
+	if w.reqTemplate != nil && w.config.URITemplate != nil {
+		return w.reqTemplate.uri
+	}
+	if w.queryFuzzer == nil {
+		return w.config.ReqURI
+	}
+	base, _, _ := strings.Cut(w.config.ReqURI, "?")
+	return base + "?" + string(w.queryFuzzer.buf)
+}
+
+func withBase(dst []byte, base string) []byte {
+	if base == "" {
+		return dst
+	}
+	return append(append(dst, base...), '&')
+}
@@ -0,0 +1,54 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+func TestWorkerBase_FuzzQuery(t *testing.T) {
+	fuzz, err := http_clients.ParseQueryFuzz("id=randString(8)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			var queries []string
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				queries = append(queries, r.URL.RawQuery)
+				mu.Unlock()
+			})
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    server.URL + "/items?v=1",
+				ReqTarget: 20,
+				QueryFuzz: fuzz,
+				Client:    client,
+			}).Stats()
+			if s.CompletedReqs != 20 {
+				t.Fatalf("wanted 20 completed reqs got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			// every request has a new query appended to the target's
+			distinct := make(map[string]bool)
+			for _, q := range queries {
+				distinct[q] = true
+				vals, err := url.ParseQuery(q)
+				if err != nil {
+					t.Fatalf("invalid query %s; %v", q, err)
+				}
+				if vals.Get("v") != "1" || len(vals.Get("id")) != 8 {
+					t.Errorf("wanted the target's query kept and an id appended got %s", q)
+				}
+			}
+			if len(distinct) != 20 {
+				t.Errorf("wanted a query generated for every request got %d distinct of 20", len(distinct))
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// retryAfter is how long a 429 or 503 response asked for no more requests to be sent, false if it didn't say. The
+// header is either a number of seconds or a date
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	// already passed
+	return 0, true
+}
+
+// recordRetryAfter holds back the worker's next request for as long as a throttled response asked, with
+// --honor-retry-after
+func (w *WorkerBase) recordRetryAfter(status int) {
+	if status != http.StatusTooManyRequests && status != http.StatusServiceUnavailable {
+		return
+	}
+	value, ok := w.resp.Header("Retry-After")
+	if !ok {
+		return
+	}
+	now := time.Now()
+	d, ok := retryAfter(value, now)
+	if !ok {
+		return
+	}
+	w.retryAfter = now.Add(d)
+	w.stats.RetryAfterPauses++
+	w.stats.RetryAfterWait += d
+}
+
+// waitRetryAfter blocks until the time asked for by the last Retry-After has passed, returns false if ctx is done
+// first
+func (w *WorkerBase) waitRetryAfter(ctx context.Context) bool {
+	wait := time.Until(w.retryAfter)
+	if wait <= 0 {
+		return true
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
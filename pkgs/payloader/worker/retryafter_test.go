@@ -0,0 +1,72 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: "", wantOK: false},
+		{value: "2", want: 2 * time.Second, wantOK: true},
+		{value: " 0 ", want: 0, wantOK: true},
+		{value: "-1", wantOK: false},
+		{value: now.Add(3 * time.Second).Format(http.TimeFormat), want: 3 * time.Second, wantOK: true},
+		// already passed
+		{value: now.Add(-time.Minute).Format(http.TimeFormat), want: 0, wantOK: true},
+		{value: "soon", wantOK: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, ok := retryAfter(tt.value, now)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("wanted %s %v got %s %v", tt.want, tt.wantOK, got, ok)
+			}
+		})
+	}
+}
+
+func TestWorker_HonorRetryAfter(t *testing.T) {
+	for _, honor := range []bool{true, false} {
+		var reqs atomic.Int64
+		server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+			// the first request is rate limited
+			if reqs.Add(1) == 1 {
+				w.Header().Set("Retry-After", "1")
+				w.WriteHeader(http.StatusTooManyRequests)
+			}
+		})
+
+		start := time.Now()
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:          server.URL,
+			ReqTarget:       3,
+			HonorRetryAfter: honor,
+		}).Stats()
+		took := time.Since(start)
+		if s.CompletedReqs != 3 || s.Responses[ResponseCode(http.StatusTooManyRequests)] != 1 {
+			t.Fatalf("wanted 3 completed requests with 1 throttled got %d completed; %v", s.CompletedReqs, s.Responses)
+		}
+
+		if !honor {
+			if s.RetryAfterPauses != 0 || took >= time.Second {
+				t.Errorf("wanted Retry-After ignored got %d pauses and a run of %s", s.RetryAfterPauses, took)
+			}
+			continue
+		}
+		if s.RetryAfterPauses != 1 || s.RetryAfterWait != time.Second {
+			t.Errorf("wanted a pause of 1s got %d pauses of %s in total", s.RetryAfterPauses, s.RetryAfterWait)
+		}
+		if took < time.Second {
+			t.Errorf("wanted the worker to wait out Retry-After got run of %s", took)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math/rand"
+	"time"
+)
+
+// logSampler decides which requests are logged in verbose mode, each worker has its own source seeded from
+// --seed so the same requests are logged on every run and workers don't contend on a shared lock
+type logSampler struct {
+	rand *rand.Rand
+	rate float64
+}
+
+func newLogSampler(config *http_clients.Config) *logSampler {
+	if config.LogSampleRate <= 0 {
+		return nil
+	}
+	return &logSampler{
+		rand: config.NewRand(http_clients.RandLogSample),
+		rate: config.LogSampleRate,
+	}
+}
+
+func (s *logSampler) sample() bool {
+	return s.rand.Float64() < s.rate
+}
+
+// logRequest logs the method and URL the request was sent with, which a template, --data-csv row, --replay-har
+// recording or --scenario step can change from --method and the target URL
+func (w *WorkerBase) logRequest(method, uri string, status int, latency time.Duration, err error) {
+	if !w.logSampler.sample() {
+		return
+	}
+	if err != nil {
+		w.config.RequestLog.Printf("%s %s error %v", method, uri, err)
+		return
+	}
+	w.config.RequestLog.Printf("%s %s %d %s", method, uri, status, latency)
+}
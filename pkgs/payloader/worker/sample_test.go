@@ -0,0 +1,76 @@
+package worker
+
+import (
+	"bytes"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_LogRequest(t *testing.T) {
+	server := testServer(t, nil)
+	uri := server.URL + "/items/{{.Index}}"
+	uriTemplate, err := http_clients.ParseURITemplate(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &bytes.Buffer{}
+	runWorker(t, &http_clients.Config{
+		ReqURI:        uri,
+		URITemplate:   uriTemplate,
+		ReqIndex:      &atomic.Int64{},
+		Method:        "POST",
+		ReqTarget:     3,
+		LogSampleRate: 1,
+		RequestLog:    log.New(out, "", 0),
+	})
+
+	// every request is logged with the URL rendered for it
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("wanted 3 requests logged got %q", lines)
+	}
+	for i, line := range lines {
+		want := "POST " + server.URL + "/items/" + strconv.Itoa(i) + " 200 "
+		if !strings.HasPrefix(line, want) {
+			t.Errorf("wanted request %d logged as %q got %q", i, want, line)
+		}
+	}
+}
+
+func TestLogSampler(t *testing.T) {
+	sampled := func(config *http_clients.Config) []int {
+		s := newLogSampler(config)
+		var got []int
+		for i := 0; i < 2000; i++ {
+			if s.sample() {
+				got = append(got, i)
+			}
+		}
+		return got
+	}
+
+	first := sampled(&http_clients.Config{LogSampleRate: 0.1, Seed: 42})
+	// ~10% of 2000 requests
+	if len(first) < 150 || len(first) > 250 {
+		t.Errorf("wanted around 200 requests sampled got %d", len(first))
+	}
+	// the same requests are sampled with the same seed, other workers sample others
+	if again := sampled(&http_clients.Config{LogSampleRate: 0.1, Seed: 42}); !reflect.DeepEqual(again, first) {
+		t.Error("wanted the same requests sampled with the same seed")
+	}
+	if other := sampled(&http_clients.Config{LogSampleRate: 0.1, Seed: 42, WorkerID: 1}); reflect.DeepEqual(other, first) {
+		t.Error("wanted another worker to sample different requests")
+	}
+	if len(sampled(&http_clients.Config{LogSampleRate: 1})) != 2000 {
+		t.Error("wanted every request sampled at a rate of 1")
+	}
+
+	if newLogSampler(&http_clients.Config{}) != nil {
+		t.Error("wanted no sampler without --log-sample-rate")
+	}
+}
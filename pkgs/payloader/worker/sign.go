@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"hash"
+	"os"
+	"strconv"
+	"time"
+)
+
+// signer sends an HMAC of every request's timestamp and body for targets verifying webhook style signatures, the
+// header is t=<unix seconds>,<algo>=<hex HMAC of "<unix seconds>.<body>">
+type signer struct {
+	header string
+	algo   string
+	mac    hash.Hash
+	// body is nil with --body-template-file as it's rendered for every request
+	body []byte
+	// a fixed body's signature only changes once a second, it's kept until then
+	signedAt int64
+	buf      []byte
+	sum      []byte
+	hex      [2 * sha512.Size]byte
+}
+
+func newSigner(config *http_clients.Config) (*signer, error) {
+	algo := config.HMACAlgo
+	var h func() hash.Hash
+	switch algo {
+	case "sha1":
+		h = sha1.New
+	case "sha512":
+		h = sha512.New
+	default:
+		algo = "sha256"
+		h = sha256.New
+	}
+
+	s := &signer{
+		header: config.HMACHeader,
+		algo:   algo,
+		mac:    hmac.New(h, []byte(config.HMACSecret)),
+	}
+	if config.BodyTemplate == nil {
+		s.body = []byte(config.Body)
+		if len(config.BodyFile) > 0 {
+			bb, err := os.ReadFile(config.BodyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read body file %v", err)
+			}
+			s.body = bb
+		}
+	}
+	return s, nil
+}
+
+func (w *WorkerBase) sign() {
+	s := w.signer
+	now := time.Now().Unix()
+	body := s.body
+	if w.config.BodyTemplate != nil {
+		body = w.reqTemplate.buf.Bytes()
+	} else if now == s.signedAt {
+		// the header set for the last request is still valid
+		return
+	}
+
+	s.buf = strconv.AppendInt(s.buf[:0], now, 10)
+	s.buf = append(s.buf, '.')
+	s.mac.Reset()
+	s.mac.Write(s.buf)
+	s.mac.Write(body)
+	s.sum = s.mac.Sum(s.sum[:0])
+	n := hex.Encode(s.hex[:], s.sum)
+
+	s.buf = append(s.buf[:0], "t="...)
+	s.buf = strconv.AppendInt(s.buf, now, 10)
+	s.buf = append(s.buf, ',')
+	s.buf = append(s.buf, s.algo...)
+	s.buf = append(s.buf, '=')
+	s.buf = append(s.buf, s.hex[:n]...)
+	s.signedAt = now
+
+	val := string(s.buf)
+	w.req.SetHeader(s.header, val)
+	if w.comparer != nil {
+		// same body so the same signature
+		w.comparer.req.SetHeader(s.header, val)
+	}
+}
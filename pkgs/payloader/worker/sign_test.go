@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"hash"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestWorkerBase_Sign(t *testing.T) {
+	const secret = "shh"
+	tmpl := template.Must(template.New("body").Parse(`{"ref": "{{.RandString 8}}"}`))
+
+	for _, tt := range []struct {
+		client string
+		algo   string
+		hash   func() hash.Hash
+		body   string
+		tmpl   *template.Template
+	}{
+		{client: HttpClientFastHTTP1, algo: "sha256", hash: sha256.New, body: `{"event":"order.created"}`},
+		{client: HttpClientNetHTTP, algo: "sha512", hash: sha512.New, tmpl: tmpl},
+		{client: HttpClientFastHTTP2, algo: "sha1", hash: sha1.New, body: "ping"},
+	} {
+		t.Run(tt.client, func(t *testing.T) {
+			var mu sync.Mutex
+			bodies := make(map[string]bool)
+			start := time.Now().Unix()
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				// t=<unix seconds>,<algo>=<hex>
+				ts, sig, ok := strings.Cut(strings.TrimPrefix(r.Header.Get("X-Signature"), "t="), ","+tt.algo+"=")
+				if !ok {
+					t.Errorf("unexpected signature header %q", r.Header.Get("X-Signature"))
+					return
+				}
+				if unix, err := strconv.ParseInt(ts, 10, 64); err != nil || unix < start || unix > time.Now().Unix() {
+					t.Errorf("wanted a timestamp from during the run got %s", ts)
+				}
+				mac := hmac.New(tt.hash, []byte(secret))
+				mac.Write([]byte(ts + "." + string(body)))
+				if want := hex.EncodeToString(mac.Sum(nil)); sig != want {
+					t.Errorf("wanted signature %s for %s got %s", want, body, sig)
+				}
+				mu.Lock()
+				bodies[string(body)] = true
+				mu.Unlock()
+			}))
+			// fasthttp-2 only speaks HTTP/2 over TLS
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:       server.URL,
+				ReqTarget:    20,
+				SkipVerify:   true,
+				Method:       "POST",
+				Client:       tt.client,
+				Body:         tt.body,
+				BodyTemplate: tt.tmpl,
+				ReqIndex:     &atomic.Int64{},
+				HMACSecret:   secret,
+				HMACHeader:   "X-Signature",
+				HMACAlgo:     tt.algo,
+			}).Stats()
+			if s.CompletedReqs != 20 {
+				t.Errorf("wanted 20 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+			// every rendered body is signed rather than the first
+			if tt.tmpl != nil && len(bodies) < 15 {
+				t.Errorf("wanted a distinct body per request got %d", len(bodies))
+			}
+		})
+	}
+}
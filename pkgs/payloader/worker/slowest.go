@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"container/heap"
+	"time"
+)
+
+// SlowRequest is one of the --slowest requests, kept so tail latency can be traced back to actual requests
+type SlowRequest struct {
+	Start    time.Time
+	Latency  time.Duration
+	URL      string
+	Status   int
+	RespSize int64
+	// TraceID is empty unless requests are sent with --trace-header
+	TraceID  string
+	WorkerID int
+}
+
+// slowestHeap is a min-heap on latency so the fastest of the slowest requests is the one replaced
+type slowestHeap []SlowRequest
+
+func (h slowestHeap) Len() int           { return len(h) }
+func (h slowestHeap) Less(i, j int) bool { return h[i].Latency < h[j].Latency }
+func (h slowestHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *slowestHeap) Push(x any) {
+	*h = append(*h, x.(SlowRequest))
+}
+
+func (h *slowestHeap) Pop() any {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
+
+// recordSlowest keeps the request in the worker's Stats.Slowest if it's one of the slowest --slowest requests it's
+// sent, the details are only built when it is so fast requests cost a comparison
+func (w *WorkerBase) recordSlowest(begin, end int64, status int) {
+	latency := time.Duration(end - begin)
+	h := (*slowestHeap)(&w.stats.Slowest)
+	full := h.Len() == w.config.Slowest
+	if full && latency <= (*h)[0].Latency {
+		return
+	}
+
+	r := SlowRequest{
+		Start:    time.Unix(0, begin),
+		Latency:  latency,
+		URL:      w.reqURL(),
+		Status:   status,
+		RespSize: w.RespSize(),
+		TraceID:  w.tracer.id(),
+		WorkerID: w.config.WorkerID,
+	}
+	if !full {
+		heap.Push(h, r)
+		return
+	}
+	(*h)[0] = r
+	heap.Fix(h, 0)
+}
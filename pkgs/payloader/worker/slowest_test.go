@@ -0,0 +1,61 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerBase_RecordSlowest(t *testing.T) {
+	// the nth request to arrive is delayed and marked by its status code so the slowest can be identified
+	slow := map[int64]struct {
+		delay  time.Duration
+		status int
+	}{
+		5:  {delay: 80 * time.Millisecond, status: 203},
+		12: {delay: 50 * time.Millisecond, status: 202},
+		20: {delay: 30 * time.Millisecond, status: 201},
+	}
+	var reqs atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if s, ok := slow[reqs.Add(1)]; ok {
+			time.Sleep(s.delay)
+			w.WriteHeader(s.status)
+		}
+	})
+
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:    server.URL,
+		ReqTarget: 30,
+		Slowest:   3,
+		WorkerID:  2,
+	}).Stats()
+	if len(s.Slowest) != 3 {
+		t.Fatalf("wanted the 3 slowest requests kept got %d", len(s.Slowest))
+	}
+	// kept as a heap, the order is up to the results
+	sort.Slice(s.Slowest, func(i, j int) bool {
+		return s.Slowest[i].Latency > s.Slowest[j].Latency
+	})
+	for i, want := range []int64{5, 12, 20} {
+		got := s.Slowest[i]
+		if got.Status != slow[want].status || got.Latency < slow[want].delay {
+			t.Errorf("wanted slowest #%d to be the %s request with status %d got %s with status %d",
+				i+1, slow[want].delay, slow[want].status, got.Latency, got.Status)
+		}
+		if got.URL != server.URL || got.Start.IsZero() || got.WorkerID != 2 {
+			t.Errorf("wanted slowest #%d to have the URL, start time and worker got %+v", i+1, got)
+		}
+	}
+
+	reqs.Store(0)
+	if s := runWorker(t, &http_clients.Config{ReqURI: server.URL, ReqTarget: 10, Slowest: 50}).Stats(); len(s.Slowest) != 10 {
+		t.Errorf("wanted all 10 requests kept when fewer were sent than asked for got %d", len(s.Slowest))
+	}
+	if s := runWorker(t, &http_clients.Config{ReqURI: server.URL, ReqTarget: 10}).Stats(); s.Slowest != nil {
+		t.Errorf("wanted no slowest requests unless asked for got %d", len(s.Slowest))
+	}
+}
@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"fmt"
+	"time"
+)
+
+// exprResponse is the response being checked against --success-expr
+type exprResponse struct {
+	w       *WorkerBase
+	latency time.Duration
+}
+
+func (r *exprResponse) Status() int {
+	return r.w.resp.StatusCode()
+}
+
+func (r *exprResponse) Latency() time.Duration {
+	return r.latency
+}
+
+func (r *exprResponse) Header(name string) (string, bool) {
+	return r.w.resp.Header(name)
+}
+
+func (r *exprResponse) Body() ([]byte, error) {
+	return r.w.resp.Body()
+}
+
+// checkSuccess fails a request the --success-expr is false for. Like assertion errors the error is a key in
+// Stats.Errors so it's the expression rather than what made it false
+func (w *WorkerBase) checkSuccess(latency time.Duration) error {
+	ok, err := w.config.SuccessExpr.Eval(&exprResponse{w: w, latency: latency})
+	if err != nil {
+		return fmt.Errorf("failed reading response body; %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("expected success expression %s to be true", w.config.SuccessExpr)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"fmt"
+	"github.com/domsolutions/gopayloader/pkgs/expr"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"sync/atomic"
+	"testing"
+)
+
+func TestWorkerBase_CheckSuccess(t *testing.T) {
+	var reqs atomic.Int64
+	// every other response is a cache miss and every 5th order isn't paid
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		n := reqs.Add(1)
+		cache, state := "HIT", "paid"
+		if n%2 == 0 {
+			cache = "MISS"
+		}
+		if n%5 == 0 {
+			state = "pending"
+		}
+		w.Header().Set("X-Cache", cache)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"order":{"state":%q}}`, state)
+	})
+
+	tests := []struct {
+		name   string
+		expr   string
+		failed int64
+	}{
+		{name: "compound success", expr: `status == 200 && latency < 5s && (header("X-Cache") == "HIT" || header("X-Cache") == "MISS")`},
+		{name: "compound failure", expr: `status == 200 && latency < 5s && header("X-Cache") == "HIT"`, failed: 10},
+		{name: "body", expr: `status < 300 && json("$.order.state") == "paid" && contains(body, "order")`, failed: 4},
+		// responses 10 and 20 are both a miss and pending
+		{name: "either", expr: `header("X-Cache") == "HIT" || !matches(body, "pending")`, failed: 2},
+	}
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, tt := range tests {
+			t.Run(client+" "+tt.name, func(t *testing.T) {
+				x, err := expr.Parse(tt.expr)
+				if err != nil {
+					t.Fatal(err)
+				}
+				reqs.Store(0)
+				s := runWorker(t, &http_clients.Config{
+					ReqURI:      server.URL,
+					ReqTarget:   20,
+					Client:      client,
+					SuccessExpr: x,
+				}).Stats()
+				if s.FailedReqs != tt.failed || s.CompletedReqs != 20-tt.failed {
+					t.Errorf("wanted %d failed reqs got %d failed %d completed; %v", tt.failed, s.FailedReqs, s.CompletedReqs, s.Errors)
+				}
+				want := "expected success expression " + tt.expr + " to be true"
+				if tt.failed > 0 && s.Errors[want] != uint(tt.failed) {
+					t.Errorf("wanted %d errors %s got %v", tt.failed, want, s.Errors)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package worker
+
+import (
+	"bytes"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"math/rand"
+	"time"
+)
+
+const templateRandChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// templateData is dot in --body-template-file, -H header and URL templates i.e. {{.Index}}, {{.Time.Unix}} or
+// {{.RandInt 1 100}}
+type templateData struct {
+	// Index is the request's position across all connections, starting from 0
+	Index    int64
+	WorkerID int
+	Time     time.Time
+	// Data is the request's --data-csv row by column name i.e. {{.Data.user}}
+	Data map[string]string
+	// Vars is the connection's session variables extracted from earlier --scenario steps i.e. {{.Vars.token}}
+	Vars map[string]string
+	rand *rand.Rand
+}
+
+// RandInt returns a random int in [min, max)
+func (d *templateData) RandInt(min, max int) (int, error) {
+	if max <= min {
+		return 0, fmt.Errorf("RandInt max %d must be more than min %d", max, min)
+	}
+	return min + d.rand.Intn(max-min), nil
+}
+
+// RandFloat returns a random float in [0, 1)
+func (d *templateData) RandFloat() float64 {
+	return d.rand.Float64()
+}
+
+// RandString returns n random alphanumeric characters
+func (d *templateData) RandString(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = templateRandChars[d.rand.Intn(len(templateRandChars))]
+	}
+	return string(b)
+}
+
+// RandChoice returns one of the given values
+func (d *templateData) RandChoice(values ...string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[d.rand.Intn(len(values))]
+}
+
+// UUID returns a random version 4 UUID i.e. for a per request nonce
+func (d *templateData) UUID() string {
+	var b [16]byte
+	d.rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// reqTemplate renders the request URL, body and templated headers for every request, the templates are parsed once
+// and shared between workers while the buffers and random source belong to the worker. The URL, body and headers of a
+// request are rendered with the same data so i.e. {{.Index}} is the same in all of them
+type reqTemplate struct {
+	buf       bytes.Buffer
+	headerBuf bytes.Buffer
+	uriBuf    bytes.Buffer
+	// uri is the last rendered URL
+	uri  string
+	data templateData
+}
+
+func newReqTemplate(config *http_clients.Config) *reqTemplate {
+	if config.BodyTemplate == nil && len(config.HeaderTemplates) == 0 && config.URITemplate == nil && config.DataCSV == nil {
+		return nil
+	}
+	return &reqTemplate{
+		data: templateData{
+			WorkerID: config.WorkerID,
+			rand:     config.NewRand(http_clients.RandTemplate),
+		},
+	}
+}
+
+// reqMethod is the method of the request being sent, a --data-csv method column, --replay-har recording or --scenario
+// step overrides --method
+func (w *WorkerBase) reqMethod() string {
+	if w.replayMethod != "" {
+		return w.replayMethod
+	}
+	if w.reqTemplate != nil {
+		if method, ok := w.reqTemplate.data.Data[http_clients.DataMethodColumn]; ok {
+			return method
+		}
+	}
+	return w.config.Method
+}
+
+func (w *WorkerBase) renderTemplates() error {
+	t := w.reqTemplate
+	t.data.Index = w.config.ReqIndex.Add(1) - 1
+	t.data.Time = time.Now()
+	if w.config.DataCSV != nil {
+		t.data.Data = w.config.DataCSV.Row(t.data.Index)
+		if method, ok := t.data.Data[http_clients.DataMethodColumn]; ok {
+			w.req.SetMethod(method)
+			if w.comparer != nil {
+				w.comparer.req.SetMethod(method)
+			}
+		}
+	}
+
+	if w.config.URITemplate != nil {
+		t.uriBuf.Reset()
+		if err := w.config.URITemplate.Execute(&t.uriBuf, &t.data); err != nil {
+			return fmt.Errorf("failed to render url template; %v", err)
+		}
+		t.uri = t.uriBuf.String()
+		if err := w.req.SetURI(t.uri); err != nil {
+			return fmt.Errorf("rendered url %s is invalid; %v", t.uri, err)
+		}
+	}
+
+	for _, h := range w.config.HeaderTemplates {
+		t.headerBuf.Reset()
+		if err := h.Value.Execute(&t.headerBuf, &t.data); err != nil {
+			return fmt.Errorf("failed to render header template %s; %v", h.Name, err)
+		}
+		w.req.SetHeader(h.Name, t.headerBuf.String())
+		if w.comparer != nil {
+			w.comparer.req.SetHeader(h.Name, t.headerBuf.String())
+		}
+	}
+
+	if w.config.BodyTemplate == nil {
+		return nil
+	}
+	t.buf.Reset()
+	if err := w.config.BodyTemplate.Execute(&t.buf, &t.data); err != nil {
+		// the error includes the template position, not the data, so is the same for every failing request
+		return fmt.Errorf("failed to render body template; %v", err)
+	}
+
+	w.req.SetBody(t.buf.Bytes())
+	if w.comparer != nil {
+		w.comparer.req.SetBody(t.buf.Bytes())
+	}
+	return nil
+}
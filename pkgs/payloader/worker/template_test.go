@@ -0,0 +1,149 @@
+package worker
+
+import (
+	"encoding/json"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"text/template"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestWorkerBase_RenderBodyTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("body").Option("missingkey=error").Parse(
+		`{"index": {{.Index}}, "worker": {{.WorkerID}}, "ref": "{{.RandString 8}}", "qty": {{.RandInt 1 10}}}`))
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			bodies := make(map[string]bool)
+			indexes := make(map[int]bool)
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				b, err := io.ReadAll(r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				var body struct {
+					Index  int    `json:"index"`
+					Worker int    `json:"worker"`
+					Ref    string `json:"ref"`
+					Qty    int    `json:"qty"`
+				}
+				if err := json.Unmarshal(b, &body); err != nil {
+					t.Errorf("body isn't valid json %s; %v", b, err)
+					return
+				}
+				if body.Worker != 1 || len(body.Ref) != 8 || body.Qty < 1 || body.Qty >= 10 {
+					t.Errorf("unexpected body %s", b)
+				}
+				mu.Lock()
+				bodies[string(b)] = true
+				indexes[body.Index] = true
+				mu.Unlock()
+			})
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:       server.URL,
+				ReqTarget:    50,
+				Method:       "POST",
+				Client:       client,
+				WorkerID:     1,
+				BodyTemplate: tmpl,
+				ReqIndex:     &atomic.Int64{},
+			}).Stats()
+			if s.CompletedReqs != 50 {
+				t.Fatalf("wanted 50 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			if len(bodies) != 50 {
+				t.Errorf("wanted 50 distinct bodies got %d", len(bodies))
+			}
+			for i := 0; i < 50; i++ {
+				if !indexes[i] {
+					t.Errorf("wanted a request with index %d", i)
+				}
+			}
+		})
+	}
+}
+
+func TestWorkerBase_RenderHeaderTemplates(t *testing.T) {
+	headers := []string{"X-Nonce: {{.UUID}}", "X-Seq: {{.Index}}", "X-Static: fixed"}
+	templates, err := http_clients.ParseHeaderTemplates(headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			var mu sync.Mutex
+			nonces, seqs, static := make(map[string]int), make(map[string]int), make(map[string]int)
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				nonces[r.Header.Get("X-Nonce")]++
+				seqs[r.Header.Get("X-Seq")]++
+				static[r.Header.Get("X-Static")]++
+			})
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:          server.URL,
+				ReqTarget:       50,
+				Client:          client,
+				Headers:         headers,
+				HeaderTemplates: templates,
+				ReqIndex:        &atomic.Int64{},
+			}).Stats()
+			if s.CompletedReqs != 50 {
+				t.Fatalf("wanted 50 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			if len(nonces) != 50 {
+				t.Errorf("wanted a distinct nonce for every request got %d distinct", len(nonces))
+			}
+			for nonce := range nonces {
+				if !uuidPattern.MatchString(nonce) {
+					t.Errorf("wanted nonce to be a uuid got %q", nonce)
+				}
+			}
+			for i := 0; i < 50; i++ {
+				if seqs[strconv.Itoa(i)] != 1 {
+					t.Errorf("wanted sequence %d sent once got %v", i, seqs)
+					break
+				}
+			}
+			if static["fixed"] != 50 {
+				t.Errorf("wanted the static header on every request got %v", static)
+			}
+		})
+	}
+}
+
+func TestTemplateData(t *testing.T) {
+	d := &templateData{rand: (&http_clients.Config{}).NewRand(http_clients.RandTemplate)}
+
+	if _, err := d.RandInt(5, 5); err == nil {
+		t.Error("wanted an error when max isn't more than min")
+	}
+	for i := 0; i < 100; i++ {
+		if n, _ := d.RandInt(1, 3); n < 1 || n >= 3 {
+			t.Fatalf("wanted an int in [1, 3) got %d", n)
+		}
+	}
+	if d.RandChoice() != "" {
+		t.Error("wanted no choice without values")
+	}
+	if c := d.RandChoice("a", "b"); c != "a" && c != "b" {
+		t.Errorf("wanted one of the values got %s", c)
+	}
+	if uuid := d.UUID(); !uuidPattern.MatchString(uuid) {
+		t.Errorf("wanted a version 4 uuid got %s", uuid)
+	}
+}
@@ -0,0 +1,91 @@
+package worker
+
+import (
+	"context"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"time"
+)
+
+const (
+	// thinkTimeMaxFactor caps how far consecutive errors back off the think time, as a multiple of the base
+	thinkTimeMaxFactor = 16
+	// thinkTimeMinScale and thinkTimeMaxScale bound how much a response's latency scales the think time
+	thinkTimeMinScale = 0.5
+	thinkTimeMaxScale = 2
+	// thinkTimeLatencyWeight is the weight of the latest latency in the connection's moving average
+	thinkTimeLatencyWeight = 0.2
+)
+
+// thinkTime is how long a connection waits between requests with --adaptive-think-time, it reacts to the last
+// response like a real client would. After a failure, 5xx or 429 it's doubled, from twice the base up to
+// thinkTimeMaxFactor times it. After a success it's the base scaled by how the response's latency compares to the
+// connection's average, so a slow response is followed by a longer pause
+type thinkTime struct {
+	base time.Duration
+	// last is the think time after the previous request
+	last time.Duration
+	// avgLatency is the moving average latency of the connection's successful responses
+	avgLatency time.Duration
+}
+
+func newThinkTime(config *http_clients.Config) *thinkTime {
+	if config.AdaptiveThinkTime <= 0 {
+		return nil
+	}
+	return &thinkTime{base: config.AdaptiveThinkTime}
+}
+
+// next returns the think time after a request which failed or took latency
+func (t *thinkTime) next(failed bool, latency time.Duration) time.Duration {
+	if failed {
+		d := t.last * 2
+		if d < t.base*2 {
+			d = t.base * 2
+		}
+		if d > t.base*thinkTimeMaxFactor {
+			d = t.base * thinkTimeMaxFactor
+		}
+		t.last = d
+		return d
+	}
+
+	scale := 1.0
+	if t.avgLatency > 0 {
+		scale = float64(latency) / float64(t.avgLatency)
+		if scale < thinkTimeMinScale {
+			scale = thinkTimeMinScale
+		}
+		if scale > thinkTimeMaxScale {
+			scale = thinkTimeMaxScale
+		}
+		t.avgLatency += time.Duration(thinkTimeLatencyWeight * float64(latency-t.avgLatency))
+	} else {
+		t.avgLatency = latency
+	}
+	t.last = time.Duration(scale * float64(t.base))
+	return t.last
+}
+
+// recordThinkTime holds back the worker's next request for its think time after this one
+func (w *WorkerBase) recordThinkTime(failed bool, latency time.Duration) {
+	d := w.thinkTime.next(failed, latency)
+	w.thinkUntil = time.Now().Add(d)
+	w.stats.ThinkTimes++
+	w.stats.ThinkTime += d
+}
+
+// waitThinkTime blocks until the think time after the last request has passed, returns false if ctx is done first
+func (w *WorkerBase) waitThinkTime(ctx context.Context) bool {
+	wait := time.Until(w.thinkUntil)
+	if wait <= 0 {
+		return true
+	}
+	t := time.NewTimer(wait)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,88 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestThinkTime(t *testing.T) {
+	const base = 100 * time.Millisecond
+	tt := newThinkTime(&http_clients.Config{AdaptiveThinkTime: base})
+
+	if d := tt.next(false, 10*time.Millisecond); d != base {
+		t.Errorf("wanted the base think time after the first response got %s", d)
+	}
+	if d := tt.next(false, 10*time.Millisecond); d != base {
+		t.Errorf("wanted the base think time after a response as fast as the average got %s", d)
+	}
+
+	// errors back off, doubling up to the cap
+	for i, want := range []time.Duration{2 * base, 4 * base, 8 * base, 16 * base, 16 * base} {
+		if d := tt.next(true, 0); d != want {
+			t.Errorf("error %d: wanted think time %s got %s", i+1, want, d)
+		}
+	}
+
+	if d := tt.next(false, 10*time.Millisecond); d != base {
+		t.Errorf("wanted the base think time once responses succeed again got %s", d)
+	}
+	// a much slower response than the average is scaled up to the max, a much faster one down to the min
+	if d := tt.next(false, time.Second); d != 2*base {
+		t.Errorf("wanted twice the think time after a slow response got %s", d)
+	}
+	if d := tt.next(false, time.Microsecond); d != base/2 {
+		t.Errorf("wanted half the think time after a fast response got %s", d)
+	}
+	// an error right after a short think time still backs off from the base
+	if d := tt.next(true, 0); d != 2*base {
+		t.Errorf("wanted twice the base think time after an error got %s", d)
+	}
+
+	if newThinkTime(&http_clients.Config{}) != nil {
+		t.Error("wanted no think time without --adaptive-think-time")
+	}
+}
+
+func TestWorkerBase_ThinkTime(t *testing.T) {
+	var reqs atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// the first responses are errors, which the connection should back off after
+		if reqs.Add(1) <= 3 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("ok"))
+	})
+
+	const base = 20 * time.Millisecond
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			reqs.Store(0)
+			start := time.Now()
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:            server.URL,
+				ReqTarget:         10,
+				Client:            client,
+				AdaptiveThinkTime: base,
+			}).Stats()
+			took := time.Since(start)
+			if s.CompletedReqs != 10 {
+				t.Fatalf("wanted 10 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+			if s.ThinkTimes != 10 {
+				t.Errorf("wanted a think time after each of the 10 requests got %d", s.ThinkTimes)
+			}
+			// 2x, 4x then 8x the base after the errors, then around the base after the 7 successes
+			if s.ThinkTime < 14*base+7*base/2 {
+				t.Errorf("wanted the think time to back off after errors got %s in total", s.ThinkTime)
+			}
+			// every think time but the last is waited out before the next request
+			if took < 14*base {
+				t.Errorf("wanted the worker to wait out the think times got %s", took)
+			}
+		})
+	}
+}
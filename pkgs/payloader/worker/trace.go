@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"strings"
+)
+
+// tracer sends a new trace ID with every request so a request linked from a metrics exemplar can be found in the
+// target's logs or traces. IDs aren't derived from --seed otherwise repeated runs would reuse them
+type tracer struct {
+	header  string
+	traceID string
+	buf     [24]byte
+}
+
+func newTracer(config *http_clients.Config) *tracer {
+	if config.TraceHeader == "" {
+		return nil
+	}
+	return &tracer{header: config.TraceHeader}
+}
+
+func (t *tracer) id() string {
+	if t == nil {
+		return ""
+	}
+	return t.traceID
+}
+
+func (w *WorkerBase) setTraceID() error {
+	t := w.tracer
+	if _, err := rand.Read(t.buf[:]); err != nil {
+		return err
+	}
+	t.traceID = hex.EncodeToString(t.buf[:16])
+
+	val := t.traceID
+	if strings.EqualFold(t.header, "traceparent") {
+		// W3C trace context, the rest of the random bytes are the parent span ID
+		val = "00-" + t.traceID + "-" + hex.EncodeToString(t.buf[16:]) + "-01"
+	}
+	w.req.SetHeader(t.header, val)
+	if w.comparer != nil {
+		w.comparer.req.SetHeader(t.header, val)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/metrics"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWorkerBase_SetTraceID(t *testing.T) {
+	traceparent := regexp.MustCompile(`^00-([0-9a-f]{32})-[0-9a-f]{16}-01$`)
+	plain := regexp.MustCompile(`^([0-9a-f]{32})$`)
+
+	for _, tt := range []struct {
+		header string
+		want   *regexp.Regexp
+	}{
+		{header: "traceparent", want: traceparent},
+		{header: "X-Trace-Id", want: plain},
+	} {
+		t.Run(tt.header, func(t *testing.T) {
+			var mu sync.Mutex
+			ids := make(map[string]bool)
+			server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+				m := tt.want.FindStringSubmatch(r.Header.Get(tt.header))
+				if m == nil {
+					t.Errorf("wanted trace header matching %s got %q", tt.want, r.Header.Get(tt.header))
+					return
+				}
+				mu.Lock()
+				ids[m[1]] = true
+				mu.Unlock()
+			})
+
+			recorder := metrics.NewRecorder()
+			runWorker(t, &http_clients.Config{
+				ReqURI:      server.URL,
+				ReqTarget:   20,
+				TraceHeader: tt.header,
+				Metrics:     recorder,
+			})
+			if len(ids) != 20 {
+				t.Errorf("wanted a new trace ID for each of 20 requests got %d", len(ids))
+			}
+
+			// the exemplars are requests which were sent
+			out := &strings.Builder{}
+			if err := recorder.Write(out, true); err != nil {
+				t.Fatal(err)
+			}
+			exemplars := regexp.MustCompile(`trace_id="([0-9a-f]{32})"`).FindAllStringSubmatch(out.String(), -1)
+			if len(exemplars) == 0 {
+				t.Fatalf("wanted at least one exemplar got\n%s", out)
+			}
+			for _, e := range exemplars {
+				if !ids[e[1]] {
+					t.Errorf("exemplar trace id %s wasn't sent in a request", e[1])
+				}
+			}
+		})
+	}
+}
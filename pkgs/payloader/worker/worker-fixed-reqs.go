@@ -10,8 +10,11 @@ type WorkerFixedReqs struct {
 
 func (w *WorkerFixedReqs) Run(wg *sync.WaitGroup) {
 	defer wg.Done()
-	defer w.client.CloseConns()
+	defer w.closeConns()
 
+	if !w.delayStart(w.config.Ctx) {
+		return
+	}
 	var i int64
 	for i = 0; i < w.config.ReqTarget; i++ {
 		select {
@@ -19,6 +22,9 @@ func (w *WorkerFixedReqs) Run(wg *sync.WaitGroup) {
 			// user cancelled
 			return
 		default:
+			if !w.throttle(w.config.Ctx) {
+				return
+			}
 			w.run()
 		}
 	}
@@ -12,9 +12,13 @@ type WorkerFixedTimeRequests struct {
 
 func (w *WorkerFixedTimeRequests) Run(wg *sync.WaitGroup) {
 	defer wg.Done()
-	defer w.client.CloseConns()
+	defer w.closeConns()
 
 	w.config.StartTrigger.Wait()
+	// before the time window starts so the requests are still spread over all of it
+	if !w.delayStart(w.config.Ctx) {
+		return
+	}
 	deadline, c := context.WithTimeout(context.Background(), w.config.Until)
 	defer c()
 	newReq := time.NewTicker(w.config.ReqEvery)
@@ -26,14 +30,33 @@ func (w *WorkerFixedTimeRequests) Run(wg *sync.WaitGroup) {
 			return
 		case <-deadline.Done():
 			// required reqs were not completed in time period, finish reqs
-			if w.config.ReqTarget != w.stats.CompletedReqs+w.stats.FailedReqs {
+			if w.sent() < w.config.ReqTarget {
+				if w.config.Pause != nil && !w.config.Pause.Wait(w.config.Ctx) {
+					return
+				}
+				if !w.waitRetryAfter(w.config.Ctx) {
+					return
+				}
 				w.run()
 				continue
 			}
 			return
 		case <-newReq.C:
-			w.run()
+			// last tick can race with the deadline, never send more than the target
+			if w.sent() < w.config.ReqTarget {
+				if w.config.Pause != nil && !w.config.Pause.Wait(w.config.Ctx) {
+					return
+				}
+				if !w.waitRetryAfter(w.config.Ctx) {
+					return
+				}
+				w.run()
+			}
 		}
 	}
 
 }
+
+func (w *WorkerFixedTimeRequests) sent() int64 {
+	return w.stats.CompletedReqs + w.stats.FailedReqs
+}
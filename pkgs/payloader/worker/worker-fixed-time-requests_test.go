@@ -0,0 +1,29 @@
+package worker
+
+import (
+	"context"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"testing"
+	"time"
+)
+
+func TestWorkerFixedTimeRequests_StopsAtTarget(t *testing.T) {
+	server := testServer(t, nil)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// ticks far more often than the target needs, as when the last tick races with the deadline
+	w := runWorker(t, &http_clients.Config{
+		Ctx:       ctx,
+		ReqURI:    server.URL,
+		ReqTarget: 2,
+		Until:     100 * time.Millisecond,
+		ReqEvery:  time.Millisecond,
+	})
+
+	if ctx.Err() != nil {
+		t.Fatal("wanted the worker to stop once the time period ended, it kept sending until cancelled")
+	}
+	if s := w.Stats(); s.CompletedReqs+s.FailedReqs != 2 {
+		t.Errorf("wanted exactly 2 requests sent got %d completed and %d failed", s.CompletedReqs, s.FailedReqs)
+	}
+}
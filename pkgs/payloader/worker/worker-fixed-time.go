@@ -1,8 +1,8 @@
 package worker
 
 import (
+	"context"
 	"sync"
-	"time"
 )
 
 type WorkerFixedTime struct {
@@ -11,19 +11,29 @@ type WorkerFixedTime struct {
 
 func (w *WorkerFixedTime) Run(wg *sync.WaitGroup) {
 	defer wg.Done()
-	defer w.client.CloseConns()
+	defer w.closeConns()
 
 	w.config.StartTrigger.Wait()
-	ticker := time.NewTicker(w.config.Until)
+	deadline, c := context.WithCancel(w.config.Ctx)
+	if w.config.Until != 0 {
+		// otherwise there's only a byte limit, which cancels Ctx once it's reached
+		deadline, c = context.WithTimeout(w.config.Ctx, w.config.Until)
+	}
+	defer c()
 
+	// the batch's wait counts towards the time window
+	if !w.delayStart(deadline) {
+		return
+	}
 	for {
 		select {
-		case <-w.config.Ctx.Done():
-			// user cancelled
-			return
-		case <-ticker.C:
+		case <-deadline.Done():
+			// time window finished or user cancelled
 			return
 		default:
+			if !w.throttle(deadline) {
+				return
+			}
 			w.run()
 		}
 	}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkerReplay sends the requests of a --replay-har recording assigned to its connection, each at the same offset from
+// the start of the run as it was recorded
+type WorkerReplay struct {
+	*WorkerBase
+	offsets []time.Duration
+	reqs    []http_clients.Request
+	methods []string
+	urls    []string
+}
+
+func newWorkerReplay(base *WorkerBase) (*WorkerReplay, error) {
+	config := base.config
+	w := &WorkerReplay{WorkerBase: base}
+	for _, e := range config.Replay {
+		req, err := base.client.NewReq(e.Method, e.URL)
+		if err != nil {
+			return nil, err
+		}
+		if config.DisableKeepAlive {
+			req.SetHeader("Connection", "close")
+		}
+		for _, h := range e.Headers {
+			req.SetHeader(h.Name, h.Value)
+		}
+		// --headers override what was recorded i.e. to replace an expired token
+		for _, h := range config.Headers {
+			header := strings.Split(h, ":")
+			req.SetHeader(header[0], header[1])
+		}
+		if len(e.Body) > 0 {
+			req.SetBody(e.Body)
+		}
+		w.offsets = append(w.offsets, e.Offset)
+		w.reqs = append(w.reqs, req)
+		w.methods = append(w.methods, e.Method)
+		w.urls = append(w.urls, e.URL)
+	}
+	return w, nil
+}
+
+func (w *WorkerReplay) Run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer w.closeConns()
+
+	w.config.StartTrigger.Wait()
+	start := time.Now()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+	// requests are still scheduled from the start of the run, the first catches up once the connection's batch opens
+	if !w.delayStart(w.config.Ctx) {
+		return
+	}
+
+	for i, req := range w.reqs {
+		scheduled := start.Add(w.offsets[i])
+		if wait := time.Until(scheduled); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-w.config.Ctx.Done():
+				// user cancelled
+				return
+			case <-timer.C:
+			}
+		}
+		if w.config.Pause != nil && !w.config.Pause.Wait(w.config.Ctx) {
+			return
+		}
+		if !w.waitRetryAfter(w.config.Ctx) {
+			return
+		}
+		// a request sent late because the one before it was slow still counts from when it was recorded
+		w.scheduled = scheduled.UnixNano()
+		w.req, w.replayMethod, w.replayURL = req, w.methods[i], w.urls[i]
+		w.run()
+	}
+}
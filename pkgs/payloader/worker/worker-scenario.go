@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WorkerScenario sends the --scenario steps in order on its connection, each step is a request towards -r. After the
+// last step, or a step which fails as the steps after it can need what it would have extracted, it starts again from
+// the first step. Session variables are kept for the connection so a later pass can still use them
+type WorkerScenario struct {
+	*WorkerBase
+}
+
+// session is a connection's progress through the --scenario steps, each step has its own request so headers set for
+// one aren't sent with another
+type session struct {
+	step   int
+	reqs   []http_clients.Request
+	target url.URL
+	data   templateData
+	buf    bytes.Buffer
+}
+
+func newWorkerScenario(base *WorkerBase) (*WorkerScenario, error) {
+	config := base.config
+	target, err := url.Parse(config.ReqURI)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{
+		target: *target,
+		data: templateData{
+			WorkerID: config.WorkerID,
+			Vars:     make(map[string]string),
+			rand:     config.NewRand(http_clients.RandScenario),
+		},
+	}
+	for _, step := range config.Scenario {
+		req, err := base.client.NewReq(step.Method, config.ReqURI)
+		if err != nil {
+			return nil, err
+		}
+		if config.DisableKeepAlive {
+			req.SetHeader("Connection", "close")
+		}
+		// --headers are sent with every step, a step's own headers are set after them so they take precedence
+		for _, h := range config.Headers {
+			header := strings.Split(h, ":")
+			req.SetHeader(header[0], header[1])
+		}
+		s.reqs = append(s.reqs, req)
+	}
+	base.session = s
+	// the first step's request until it's sent, for ReqSize
+	base.req = s.reqs[0]
+	return &WorkerScenario{base}, nil
+}
+
+func (w *WorkerScenario) Run(wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer w.closeConns()
+
+	w.config.StartTrigger.Wait()
+	deadline, c := context.WithCancel(w.config.Ctx)
+	if w.config.Until != 0 {
+		deadline, c = context.WithTimeout(w.config.Ctx, w.config.Until)
+	}
+	defer c()
+
+	if !w.delayStart(deadline) {
+		return
+	}
+	for sent := int64(0); w.config.ReqTarget == 0 || sent < w.config.ReqTarget; sent++ {
+		select {
+		case <-deadline.Done():
+			// time window finished or user cancelled
+			return
+		default:
+		}
+		if !w.throttle(deadline) {
+			return
+		}
+		failed := w.stats.FailedReqs
+		w.run()
+		if w.stats.FailedReqs > failed {
+			w.session.step = 0
+		} else {
+			w.session.step = (w.session.step + 1) % len(w.session.reqs)
+		}
+	}
+}
+
+// renderStep renders the path, headers and body of the step being sent with the session's variables
+func (w *WorkerBase) renderStep() error {
+	s := w.session
+	step := w.config.Scenario[s.step]
+	w.req = s.reqs[s.step]
+	s.data.Index = w.config.ReqIndex.Add(1) - 1
+	s.data.Time = time.Now()
+	if w.config.DataCSV != nil {
+		s.data.Data = w.config.DataCSV.Row(s.data.Index)
+	}
+
+	s.buf.Reset()
+	if err := step.Path.Execute(&s.buf, &s.data); err != nil {
+		return fmt.Errorf("failed to render scenario step %s path; %v", step.Name, err)
+	}
+	rendered, err := url.Parse(s.buf.String())
+	if err != nil {
+		return fmt.Errorf("scenario step %s rendered an invalid path; %v", step.Name, err)
+	}
+	// like --replay-har the step keeps its path and query but is sent to the target
+	u := s.target
+	u.Path, u.RawPath, u.RawQuery = rendered.Path, rendered.RawPath, rendered.RawQuery
+	w.replayMethod, w.replayURL = step.Method, u.String()
+	if err := w.req.SetURI(w.replayURL); err != nil {
+		return fmt.Errorf("scenario step %s rendered an invalid url; %v", step.Name, err)
+	}
+
+	for _, h := range step.Headers {
+		s.buf.Reset()
+		if err := h.Value.Execute(&s.buf, &s.data); err != nil {
+			return fmt.Errorf("failed to render scenario step %s header %s; %v", step.Name, h.Name, err)
+		}
+		w.req.SetHeader(h.Name, s.buf.String())
+	}
+
+	if step.Body == nil {
+		return nil
+	}
+	s.buf.Reset()
+	if err := step.Body.Execute(&s.buf, &s.data); err != nil {
+		return fmt.Errorf("failed to render scenario step %s body; %v", step.Name, err)
+	}
+	w.req.SetBody(s.buf.Bytes())
+	return nil
+}
+
+// extractVars sets the session variables the step extracts from its response. Like assertion errors, the errors are
+// keys in Stats.Errors so they don't include the response's values
+func (w *WorkerBase) extractVars() error {
+	s := w.session
+	step := w.config.Scenario[s.step]
+	var body any
+	decoded := false
+	for _, e := range step.Extract {
+		if e.Header != "" {
+			val, ok := w.resp.Header(e.Header)
+			if !ok {
+				return fmt.Errorf("scenario step %s expected response header %s to extract %s", step.Name, e.Header, e.Var)
+			}
+			s.data.Vars[e.Var] = val
+			continue
+		}
+
+		if !decoded {
+			b, err := w.resp.Body()
+			if err != nil {
+				return fmt.Errorf("failed reading response body; %v", err)
+			}
+			d := json.NewDecoder(bytes.NewReader(b))
+			// numbers are kept as written i.e. a large id isn't rounded through float64
+			d.UseNumber()
+			if err := d.Decode(&body); err != nil {
+				return fmt.Errorf("scenario step %s expected response body to be JSON to extract %s", step.Name, e.Var)
+			}
+			decoded = true
+		}
+		field, ok := e.Path.Lookup(body)
+		if !ok {
+			return fmt.Errorf("scenario step %s expected json path %s to extract %s", step.Name, e.Path, e.Var)
+		}
+		s.data.Vars[e.Var] = jsonText(field)
+	}
+	return nil
+}
+
+// jsonText is a string as is and anything else as its JSON i.e. 42, true or an object
+func jsonText(v any) string {
+	if s, ok := v.(string); ok {
+		return s
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
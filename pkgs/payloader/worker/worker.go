@@ -1,14 +1,24 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// errCancelled is returned by process when the run's stopped while the request waits to be sent, it isn't counted
+// as failed
+var errCancelled = errors.New("cancelled before being sent")
+
 type Worker interface {
 	Run(wg *sync.WaitGroup)
 	Stats() Stats
+	// Failed is how many requests have failed so far, unlike Stats it can be read while the worker's running
+	Failed() int64
 	ReqSize() int64
 	RespSize() int64
 }
@@ -20,7 +30,43 @@ type WorkerBase struct {
 	req        http_clients.Request
 	resp       http_clients.Response
 	middleware func(w *WorkerBase)
-	reqStats   chan<- time.Duration
+	reqStats   chan<- http_clients.ReqTiming
+	assertions []headerAssertion
+	comparer   *comparer
+	logSampler *logSampler
+	// reqTemplate is set when the body or headers are rendered from templates on every request
+	reqTemplate *reqTemplate
+	queryFuzzer *queryFuzzer
+	tracer      *tracer
+	// connectRetries is how many times opening the first connection is retried, 0 once a request gets through
+	connectRetries int
+	// scheduled is when the rate limiter scheduled the next request to be sent, 0 without a rate
+	scheduled int64
+	// jsonAssertion is set with --assert-json-path
+	jsonAssertion *jsonAssertion
+	connCloser    *connCloser
+	downloader    *downloader
+	// signer is set with --hmac-secret
+	signer *signer
+	// jwt is the token being sent and jwtUses how many requests it's been sent with, see --jwt-rotate-every
+	jwt     string
+	jwtUses int64
+	// latencySample is set with --conn-outlier-factor
+	latencySample *latencySample
+	// retryAfter is when the next request can be sent after a Retry-After with --honor-retry-after
+	retryAfter time.Time
+	// thinkTime is set with --adaptive-think-time, thinkUntil is when its pause after the last request ends
+	thinkTime  *thinkTime
+	thinkUntil time.Time
+	// contentHasher is set with --content-hash-header
+	contentHasher *contentHasher
+	// replayMethod and replayURL are the method and URL of the --replay-har request or --scenario step being sent
+	replayMethod string
+	replayURL    string
+	// session is set with --scenario
+	session *session
+	// failed is stats.FailedReqs, counted atomically for Failed
+	failed atomic.Int64
 }
 
 func (w *WorkerBase) ReqSize() int64 {
@@ -34,28 +80,178 @@ func (w *WorkerBase) RespSize() int64 {
 	return w.resp.Size()
 }
 
+// delayStart holds back the worker's first request, and so its connection, until its --conn-batch-size batch opens,
+// returns false if ctx is done first
+func (w *WorkerBase) delayStart(ctx context.Context) bool {
+	if w.config.StartDelay <= 0 {
+		return true
+	}
+	t := time.NewTimer(w.config.StartDelay)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// throttle blocks until requests aren't paused, the worker is an active connection and the rate limiter allows the
+// next request, returns false if ctx is done first
+func (w *WorkerBase) throttle(ctx context.Context) bool {
+	if !w.waitRetryAfter(ctx) {
+		return false
+	}
+	if !w.waitThinkTime(ctx) {
+		return false
+	}
+	if w.config.Pause != nil && !w.config.Pause.Wait(ctx) {
+		return false
+	}
+	if w.config.ConnLimiter != nil && !w.config.ConnLimiter.Wait(ctx, w.config.WorkerID) {
+		return false
+	}
+	if w.config.RateLimiter != nil {
+		scheduled, ok := w.config.RateLimiter.Next(ctx)
+		if !ok {
+			return false
+		}
+		w.scheduled = scheduled.UnixNano()
+	}
+	return true
+}
+
 func (w *WorkerBase) run() {
+	if w.config.RateLimiter != nil {
+		// the slot throttle was given is in flight until the request's done, see --max-inflight
+		defer w.config.RateLimiter.Done()
+	}
 	err := w.process()
+	if err == errCancelled {
+		return
+	}
 	if err != nil {
-		if _, ok := w.stats.Errors[err.Error()]; ok {
-			w.stats.Errors[err.Error()]++
+		key := err.Error()
+		if http_clients.IsPartialWriteError(err) {
+			// before resets, which most partial writes are, so they're told apart from resets of whole requests
+			key = errPartialWrite
+		} else if http_clients.IsResetError(err) {
+			key = errConnectionReset
+		} else if http_clients.IsHeaderTooLargeError(err) {
+			key = errHeaderTooLarge
+		}
+		if _, ok := w.stats.Errors[key]; ok {
+			w.stats.Errors[key]++
 		} else {
-			w.stats.Errors[err.Error()] = 1
+			w.stats.Errors[key] = 1
 		}
 		w.stats.FailedReqs++
+		w.failed.Add(1)
 		return
 	}
 	w.stats.CompletedReqs++
 }
 
 func (w *WorkerBase) process() error {
+	if w.session != nil {
+		// like the templates, rendered before the request is timed
+		if err := w.renderStep(); err != nil {
+			return err
+		}
+	}
+	if w.reqTemplate != nil {
+		// rendered before the request is timed so it doesn't add to the latency
+		if err := w.renderTemplates(); err != nil {
+			return err
+		}
+	}
+	if w.queryFuzzer != nil {
+		w.fuzzQuery()
+	}
+	if w.contentHasher != nil {
+		// after the URL and body are rendered so it's what's sent
+		w.setContentHash()
+	}
+	if w.tracer != nil {
+		if err := w.setTraceID(); err != nil {
+			return err
+		}
+	}
+	if w.connCloser != nil {
+		w.setConnectionClose()
+	}
+	if w.signer != nil {
+		// after the body is rendered so it's what's signed
+		w.sign()
+	}
+	if w.config.MethodLimit != nil {
+		// after the templates are rendered as the method can come from --data-csv, waiting for a slot is queueing on
+		// the client so it isn't part of the latency
+		method := w.reqMethod()
+		if !w.config.MethodLimit.Acquire(w.config.Ctx, method) {
+			return errCancelled
+		}
+		defer w.config.MethodLimit.Release(method)
+	}
+
 	begin := time.Now().UnixNano()
 	var end int64
 	var err error
+	var status int
 
 	defer func() {
+		var size int64
+		if err == nil && (w.config.ReqBytes || w.config.ByteLimit != nil) {
+			// counted the same way as the results' byte sizes
+			size = w.ReqSize() + w.RespSize()
+		}
 		if err == nil {
-			w.reqStats <- time.Duration(end - begin)
+			scheduled := begin
+			if w.scheduled != 0 && w.scheduled < begin {
+				scheduled = w.scheduled
+			}
+			var firstByte int64
+			if w.config.TTFB && w.resp != nil {
+				firstByte = w.resp.FirstByte()
+			}
+			var path string
+			if w.config.ReqPath {
+				path, _, _ = strings.Cut(reqTarget(w.reqURL()), "?")
+			}
+			w.reqStats <- http_clients.ReqTiming{Scheduled: scheduled, Sent: begin, FirstByte: firstByte, Done: end, Bytes: size, Status: status, Path: path}
+			w.stats.Latency += time.Duration(end - begin)
+			if w.latencySample != nil {
+				w.sampleLatency(time.Duration(end - begin))
+			}
+		}
+		if w.config.ErrorWindow != nil {
+			// server errors and throttling count too so the load backs off before requests start failing
+			w.config.ErrorWindow.Record(err != nil || status >= 500 || status == 429)
+		}
+		if w.thinkTime != nil {
+			w.recordThinkTime(err != nil || status >= 500 || status == 429, time.Duration(end-begin))
+		}
+		if w.config.Metrics != nil {
+			if err == nil {
+				w.config.Metrics.Observe(time.Duration(end-begin), w.tracer.id())
+			} else {
+				w.config.Metrics.Failed()
+			}
+		}
+		if w.logSampler != nil {
+			w.logRequest(w.reqMethod(), w.reqURL(), status, time.Duration(end-begin), err)
+		}
+		if w.config.JTL != nil {
+			w.writeJTL(begin, end, status, err)
+		}
+		if err == nil && w.config.Flamegraph != nil {
+			w.addPhases(end)
+		}
+		if err == nil && w.config.Slowest > 0 {
+			w.recordSlowest(begin, end, status)
+		}
+		if err == nil && w.config.ByteLimit != nil {
+			w.config.ByteLimit.Add(size)
 		}
 		if w.resp != nil {
 			// this frees up the connection to be used by other requests
@@ -67,22 +263,85 @@ func (w *WorkerBase) process() error {
 		w.middleware(w)
 	}
 
-	if err = w.client.Do(w.req, w.resp); err != nil {
-		end = time.Now().UnixNano()
-		return err
+	err = w.client.Do(w.req, w.resp)
+	if err != nil && w.connectRetries > 0 {
+		err = w.retryConnect(err, &begin)
+	}
+	if err != nil && w.config.RetryOnReset {
+		err = w.retryReset(err, &begin)
+	}
+	if err == nil && w.downloader != nil {
+		// reading the body is part of the latency when it's streamed
+		err = w.download()
 	}
 	end = time.Now().UnixNano()
+	if err != nil {
+		return err
+	}
+	w.connectRetries = 0
+	if w.config.ConnTracker != nil {
+		w.config.ConnTracker.Served()
+	}
 
-	status := w.resp.StatusCode()
+	status = w.resp.StatusCode()
 	_, ok := w.stats.Responses[(ResponseCode(status))]
 	if ok {
 		w.stats.Responses[(ResponseCode(status))]++
-		return nil
+	} else {
+		w.stats.Responses[(ResponseCode(status))] = 1
+	}
+
+	if w.config.HonorRetryAfter {
+		w.recordRetryAfter(status)
+	}
+	if w.config.MaxExpectedBody > 0 {
+		w.recordOversized()
+	}
+
+	if len(w.assertions) > 0 {
+		if err = w.assertHeaders(); err != nil {
+			return err
+		}
+	}
+	if w.jsonAssertion != nil {
+		if err = w.assertJSON(); err != nil {
+			return err
+		}
+	}
+	if w.config.SuccessExpr != nil {
+		if err = w.checkSuccess(time.Duration(end - begin)); err != nil {
+			return err
+		}
+	}
+	if w.session != nil {
+		if err = w.extractVars(); err != nil {
+			return err
+		}
+	}
+	if w.downloader != nil && w.downloader.hash != nil {
+		if err = w.verifyChecksum(); err != nil {
+			return err
+		}
+	}
+
+	if w.comparer != nil {
+		w.recordDivergence(w.compare())
 	}
-	w.stats.Responses[(ResponseCode(status))] = 1
 	return nil
 }
 
+// closeConns closes the worker's connections once it's done
+func (w *WorkerBase) closeConns() {
+	if w.config.ConnTracker != nil {
+		w.config.ConnTracker.Ending()
+	}
+	w.client.CloseConns()
+}
+
 func (w *WorkerBase) Stats() Stats {
 	return w.stats
 }
+
+func (w *WorkerBase) Failed() int64 {
+	return w.failed.Load()
+}
@@ -0,0 +1,917 @@
+package worker
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	http_clients "github.com/domsolutions/gopayloader/pkgs/http-clients"
+	"github.com/domsolutions/gopayloader/pkgs/limiter"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testServer starts a server for the test which responds to every request with handler, or an empty 200 if it's nil
+func testServer(t *testing.T, handler http.HandlerFunc) *httptest.Server {
+	if handler == nil {
+		handler = func(w http.ResponseWriter, r *http.Request) {}
+	}
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// rawRequest is a request as it was sent, net/http servers reject or normalize what some tests need to check
+type rawRequest struct {
+	line    string
+	headers []string
+	body    []byte
+}
+
+// rawServer starts a plain TCP server for the test which sends every request it reads to the returned chan, it
+// responds with an empty 200 to each
+func rawServer(t *testing.T) (string, <-chan rawRequest) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ln.Close()
+	})
+
+	received := make(chan rawRequest, 100)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				for {
+					var req rawRequest
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					req.line = strings.TrimRight(line, "\r\n")
+					var length int64
+					chunked := false
+					for {
+						line, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						line = strings.TrimRight(line, "\r\n")
+						if line == "" {
+							break
+						}
+						req.headers = append(req.headers, line)
+						key, val, _ := strings.Cut(line, ":")
+						val = strings.TrimSpace(val)
+						switch {
+						case strings.EqualFold(key, "content-length"):
+							length, _ = strconv.ParseInt(val, 10, 64)
+						case strings.EqualFold(key, "transfer-encoding"):
+							chunked = strings.EqualFold(val, "chunked")
+						}
+					}
+					body := io.LimitReader(r, length)
+					if chunked {
+						body = httputil.NewChunkedReader(r)
+					}
+					if req.body, err = io.ReadAll(body); err != nil {
+						return
+					}
+					received <- req
+					if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")); err != nil {
+						return
+					}
+				}
+			}(conn)
+		}
+	}()
+	return "http://" + ln.Addr().String(), received
+}
+
+// runWorker creates a fasthttp-1 worker from config, filling in what every worker needs and 1s timeouts unless set,
+// and runs it to completion. Request timings are discarded unless config.ReqStats is set
+func runWorker(t *testing.T, config *http_clients.Config) Worker {
+	if config.Ctx == nil {
+		config.Ctx = context.Background()
+	}
+	if config.Method == "" {
+		config.Method = "GET"
+	}
+	if config.Client == "" {
+		config.Client = HttpClientFastHTTP1
+	}
+	if config.ReadTimeout == 0 {
+		config.ReadTimeout, config.WriteTimeout = time.Second, time.Second
+	}
+	config.StartTrigger = &sync.WaitGroup{}
+	if config.ReqStats == nil {
+		stats := make(chan http_clients.ReqTiming)
+		go func() {
+			for range stats {
+			}
+		}()
+		defer close(stats)
+		config.ReqStats = stats
+	}
+
+	w, err := NewWorker(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	w.Run(wg)
+	return w
+}
+
+func TestWorker_ConnDuration(t *testing.T) {
+	tests := []struct {
+		name           string
+		reqs           int64
+		maxIdleConnDur time.Duration
+		maxConnDur     time.Duration
+		wantMultiConns bool
+	}{
+		{name: "reuses connection", reqs: 4},
+		{name: "closes idle connections", reqs: 4, maxIdleConnDur: 50 * time.Millisecond, wantMultiConns: true},
+		{name: "recycles old connections", reqs: 20, maxConnDur: 200 * time.Millisecond, wantMultiConns: true},
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		for _, tt := range tests {
+			t.Run(client+" "+tt.name, func(t *testing.T) {
+				var newConns atomic.Int64
+				server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+				server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+					if state == http.StateNew {
+						newConns.Add(1)
+					}
+				}
+				server.Start()
+				defer server.Close()
+
+				// spread over a second
+				s := runWorker(t, &http_clients.Config{
+					ReqURI:              server.URL,
+					ReqTarget:           tt.reqs,
+					Until:               time.Second,
+					ReqEvery:            time.Second / time.Duration(tt.reqs),
+					Client:              client,
+					MaxIdleConnDuration: tt.maxIdleConnDur,
+					MaxConnDuration:     tt.maxConnDur,
+				}).Stats()
+				if s.FailedReqs != 0 {
+					t.Errorf("wanted no failed requests got %d; %v", s.FailedReqs, s.Errors)
+				}
+
+				conns := newConns.Load()
+				if tt.wantMultiConns && conns < 3 {
+					t.Errorf("wanted connections to be recycled got %d connections for %d requests", conns, s.CompletedReqs)
+				}
+				if !tt.wantMultiConns && conns != 1 {
+					t.Errorf("wanted a single connection got %d", conns)
+				}
+			})
+		}
+	}
+}
+
+func TestWorker_RawPath(t *testing.T) {
+	const path = "/files/a%2Fb/%7euser/./../x%zz?q=%20&r=a+b"
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			addr, received := rawServer(t)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    addr + path,
+				RawPath:   true,
+				ReqTarget: 5,
+				Client:    client,
+			}).Stats()
+			if s.CompletedReqs != 5 {
+				t.Fatalf("wanted 5 completed reqs got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			for i := 0; i < 5; i++ {
+				if uri := strings.Fields((<-received).line)[1]; uri != path {
+					t.Errorf("wanted path %s sent unchanged got %s", path, uri)
+				}
+			}
+		})
+	}
+}
+
+func TestWorker_Pause(t *testing.T) {
+	var received atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+	})
+
+	pause := limiter.NewPause()
+	pause.Pause()
+	done := make(chan Stats)
+	go func() {
+		done <- runWorker(t, &http_clients.Config{
+			ReqURI:    server.URL,
+			ReqTarget: 10,
+			Pause:     pause,
+		}).Stats()
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	if received.Load() != 0 {
+		t.Fatalf("wanted no requests while paused got %d", received.Load())
+	}
+	pause.Resume()
+	if s := <-done; s.CompletedReqs != 10 {
+		t.Errorf("wanted 10 completed reqs once resumed got %d; %v", s.CompletedReqs, s.Errors)
+	}
+}
+
+func TestWorker_ClosesConnsWhenDone(t *testing.T) {
+	var active atomic.Int64
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		switch state {
+		case http.StateNew:
+			active.Add(1)
+		case http.StateClosed, http.StateHijacked:
+			active.Add(-1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	// a worker retired by --ramp-down stops at its own time, it shouldn't hold its connection open for the rest of the
+	// run
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		runWorker(t, &http_clients.Config{
+			ReqURI:    server.URL,
+			ReqTarget: 5,
+			Until:     100 * time.Millisecond,
+			ReqEvery:  20 * time.Millisecond,
+			Client:    client,
+		})
+		deadline := time.Now().Add(time.Second)
+		for active.Load() != 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if active.Load() != 0 {
+			t.Errorf("%s: wanted the connection closed once the worker was done got %d open", client, active.Load())
+		}
+	}
+}
+
+func TestWorker_TimeoutJitter(t *testing.T) {
+	var mu sync.Mutex
+	var held []time.Duration
+	// never responds, the handler returns once the client times out and closes the connection
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		<-r.Context().Done()
+		mu.Lock()
+		held = append(held, time.Since(start))
+		mu.Unlock()
+	})
+
+	const jitter = 300 * time.Millisecond
+	for _, tt := range []struct {
+		client string
+		// timeout the client uses without jitter
+		base time.Duration
+	}{
+		{client: HttpClientFastHTTP1, base: 100 * time.Millisecond},
+		{client: HttpClientNetHTTP, base: 200 * time.Millisecond},
+	} {
+		t.Run(tt.client, func(t *testing.T) {
+			mu.Lock()
+			held = nil
+			mu.Unlock()
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:        server.URL,
+				ReqTarget:     4,
+				ReadTimeout:   100 * time.Millisecond,
+				WriteTimeout:  100 * time.Millisecond,
+				TimeoutJitter: jitter,
+				Seed:          1,
+				// fasthttp retries idempotent requests which time out
+				Method: "POST",
+				Client: tt.client,
+			}).Stats()
+			if s.FailedReqs != 4 {
+				t.Fatalf("wanted all 4 requests to time out got %d failed", s.FailedReqs)
+			}
+
+			// wait for the server to see the last connection close
+			deadline := time.Now().Add(time.Second)
+			for {
+				mu.Lock()
+				n := len(held)
+				mu.Unlock()
+				if n >= 4 || time.Now().After(deadline) {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			min, max := held[0], held[0]
+			for _, d := range held {
+				// the server sees the request a little after the client starts its timeout and the close a little
+				// after it fires
+				if d < tt.base-20*time.Millisecond || d > tt.base+jitter+100*time.Millisecond {
+					t.Errorf("wanted requests to time out between %s and %s got %s", tt.base, tt.base+jitter, d)
+				}
+				if d < min {
+					min = d
+				}
+				if d > max {
+					max = d
+				}
+			}
+			if max-min < 20*time.Millisecond {
+				t.Errorf("wanted request timeouts to vary got %v", held)
+			}
+		})
+	}
+}
+
+func TestWorker_ByteLimit(t *testing.T) {
+	body := strings.Repeat("a", 1000)
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	limit := limiter.NewBytes(100*1024, cancel)
+	w := runWorker(t, &http_clients.Config{
+		Ctx:       ctx,
+		ReqURI:    server.URL,
+		ByteLimit: limit,
+	})
+
+	// the worker stops once the limit's reached, every completed request was counted
+	perReq := w.ReqSize() + w.RespSize()
+	if s := w.Stats(); limit.Total() != s.CompletedReqs*perReq {
+		t.Errorf("wanted %d requests of %d bytes counted got %d bytes", s.CompletedReqs, perReq, limit.Total())
+	}
+	if limit.Total() < 100*1024 || limit.Total() > 100*1024+perReq {
+		t.Errorf("wanted the worker to stop at the limit got %d bytes", limit.Total())
+	}
+}
+
+func TestWorker_DialAddr(t *testing.T) {
+	var hosts sync.Map
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		hosts.Store(r.Host, true)
+	})
+
+	// the target host doesn't resolve, requests only get through by dialing the connection's --srv-record target
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:    "http://srv.test:8080",
+			DialAddr:  server.Listener.Addr().String(),
+			ReqTarget: 5,
+			Client:    client,
+		}).Stats()
+		if s.CompletedReqs != 5 {
+			t.Errorf("%s: wanted 5 completed reqs got %d; %v", client, s.CompletedReqs, s.Errors)
+		}
+	}
+	// the target's host is still sent
+	if _, ok := hosts.Load("srv.test:8080"); !ok {
+		t.Error("wanted requests sent with the target's host")
+	}
+}
+
+// testResolver resolves every host to 127.0.0.1
+type testResolver struct{}
+
+func (testResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return []string{"127.0.0.1"}, nil
+}
+
+func TestWorker_Resolver(t *testing.T) {
+	var hosts sync.Map
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		hosts.Store(r.Host, true)
+	})
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := "dns.test:" + port
+
+	// the target host only resolves with the resolver, i.e. --dns-server or --doh-url
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:    "http://" + target,
+			Resolver:  testResolver{},
+			ReqTarget: 5,
+			Client:    client,
+		}).Stats()
+		if s.CompletedReqs != 5 {
+			t.Errorf("%s: wanted 5 completed reqs got %d; %v", client, s.CompletedReqs, s.Errors)
+		}
+	}
+	if _, ok := hosts.Load(target); !ok {
+		t.Errorf("wanted requests with host %s", target)
+	}
+}
+
+func TestWorker_SourcePorts(t *testing.T) {
+	var mu sync.Mutex
+	ports := map[int]bool{}
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		_, port, _ := net.SplitHostPort(r.RemoteAddr)
+		p, _ := strconv.Atoi(port)
+		mu.Lock()
+		ports[p] = true
+		mu.Unlock()
+	})
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		sourcePorts, err := http_clients.NewSourcePorts("45050-45099")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:      server.URL,
+			ReqTarget:   5,
+			Client:      client,
+			SourcePorts: sourcePorts,
+		}).Stats()
+		if s.CompletedReqs != 5 {
+			t.Errorf("%s: wanted 5 completed reqs got %d; %v", client, s.CompletedReqs, s.Errors)
+		}
+	}
+	if len(ports) == 0 {
+		t.Fatal("wanted requests from the source ports")
+	}
+	for p := range ports {
+		if p < 45050 || p > 45099 {
+			t.Errorf("connection from port %d outside of range", p)
+		}
+	}
+}
+
+func TestWorker_MethodLimit(t *testing.T) {
+	var reqs atomic.Int64
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		reqs.Add(1)
+	})
+
+	// another worker has the only POST slot so nothing is sent until the run's stopped
+	methods := limiter.NewMethods(map[string]int{"POST": 1})
+	methods.Acquire(context.Background(), "POST")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	s := runWorker(t, &http_clients.Config{
+		Ctx:         ctx,
+		ReqURI:      server.URL,
+		ReqTarget:   5,
+		Method:      "POST",
+		MethodLimit: methods,
+	}).Stats()
+	// requests waiting for a slot when the run's stopped weren't sent so haven't failed
+	if s.CompletedReqs != 0 || s.FailedReqs != 0 || reqs.Load() != 0 {
+		t.Fatalf("wanted no requests sent while waiting for a slot got %d completed %d failed", s.CompletedReqs, s.FailedReqs)
+	}
+
+	methods.Release("POST")
+	s = runWorker(t, &http_clients.Config{
+		ReqURI:      server.URL,
+		ReqTarget:   5,
+		Method:      "POST",
+		MethodLimit: methods,
+	}).Stats()
+	if s.CompletedReqs != 5 {
+		t.Errorf("wanted 5 completed requests once the slot was free got %d; %v", s.CompletedReqs, s.Errors)
+	}
+}
+
+func TestWorker_MaxHeaderSize(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// ~20KB of cookies, over fasthttp's default of 4KB
+		for i := 0; i < 20; i++ {
+			w.Header().Add("Set-Cookie", fmt.Sprintf("c%d=%s", i, strings.Repeat("x", 1000)))
+		}
+	})
+
+	for _, tt := range []struct {
+		client        string
+		maxHeaderSize int
+		fail          bool
+	}{
+		{client: HttpClientFastHTTP1, fail: true},
+		{client: HttpClientFastHTTP1, maxHeaderSize: 64 << 10},
+		{client: HttpClientNetHTTP},
+		{client: HttpClientNetHTTP, maxHeaderSize: 4 << 10, fail: true},
+	} {
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:        server.URL,
+			ReqTarget:     5,
+			Client:        tt.client,
+			MaxHeaderSize: tt.maxHeaderSize,
+		}).Stats()
+		if !tt.fail {
+			if s.CompletedReqs != 5 {
+				t.Errorf("%s with max header size %d: wanted 5 completed requests got %d; %v", tt.client, tt.maxHeaderSize, s.CompletedReqs, s.Errors)
+			}
+			continue
+		}
+		// counted together whatever the client's own error
+		if s.FailedReqs != 5 || s.Errors[errHeaderTooLarge] != 5 {
+			t.Errorf("%s with max header size %d: wanted 5 requests failed by too large headers got %v", tt.client, tt.maxHeaderSize, s.Errors)
+		}
+	}
+}
+
+func TestWorker_StartDelay(t *testing.T) {
+	var mu sync.Mutex
+	var opened []time.Time
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			mu.Lock()
+			opened = append(opened, time.Now())
+			mu.Unlock()
+		}
+	}
+	server.Start()
+	defer server.Close()
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		mu.Lock()
+		opened = nil
+		mu.Unlock()
+		start := time.Now()
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:     server.URL,
+			ReqTarget:  5,
+			Client:     client,
+			StartDelay: 200 * time.Millisecond,
+		}).Stats()
+		if s.CompletedReqs != 5 {
+			t.Errorf("%s: wanted 5 completed requests got %d; %v", client, s.CompletedReqs, s.Errors)
+		}
+		mu.Lock()
+		if len(opened) != 1 || opened[0].Sub(start) < 200*time.Millisecond {
+			t.Errorf("%s: wanted the connection opened after its batch's delay got %v after %s", client, opened, start)
+		}
+		mu.Unlock()
+	}
+
+	// a cancelled run doesn't wait for the batch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	runWorker(t, &http_clients.Config{Ctx: ctx, ReqURI: server.URL, ReqTarget: 5, StartDelay: time.Minute})
+	if took := time.Since(start); took > time.Second {
+		t.Errorf("wanted the worker to stop once ctx was done got %s", took)
+	}
+}
+
+func TestWorker_HTTP10(t *testing.T) {
+	for _, keepAlive := range []bool{true, false} {
+		var mu sync.Mutex
+		var protos []string
+		var conns int
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			protos = append(protos, r.Proto)
+			mu.Unlock()
+		}))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				mu.Lock()
+				conns++
+				mu.Unlock()
+			}
+		}
+		server.Start()
+
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:           server.URL,
+			ReqTarget:        10,
+			HTTP10:           true,
+			DisableKeepAlive: !keepAlive,
+		}).Stats()
+		server.Close()
+		if s.CompletedReqs != 10 {
+			t.Fatalf("keep-alive %v: wanted 10 completed requests got %d; %v", keepAlive, s.CompletedReqs, s.Errors)
+		}
+		for _, proto := range protos {
+			if proto != "HTTP/1.0" {
+				t.Fatalf("keep-alive %v: wanted HTTP/1.0 requests got %s", keepAlive, proto)
+			}
+		}
+		// the target closes after every response unless keep-alive is asked for
+		if keepAlive && conns != 1 {
+			t.Errorf("wanted requests to share 1 kept alive connection got %d connections", conns)
+		}
+		if !keepAlive && conns != 10 {
+			t.Errorf("wanted a connection per request without keep-alive got %d connections", conns)
+		}
+	}
+}
+
+func TestWorker_DiscardBody(t *testing.T) {
+	// bigger than net/http drains itself to reuse the connection
+	body := strings.Repeat("a", 1<<20)
+	run := func(client string, discard, disableKeepAlive bool) (Worker, int64) {
+		var conns atomic.Int64
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}))
+		server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		server.Start()
+		defer server.Close()
+
+		w := runWorker(t, &http_clients.Config{
+			ReqURI:           server.URL,
+			ReqTarget:        20,
+			Client:           client,
+			DiscardBody:      discard,
+			DisableKeepAlive: disableKeepAlive,
+		})
+		if s := w.Stats(); s.CompletedReqs != 20 || s.Responses[200] != 20 {
+			t.Fatalf("%s discard %v: wanted 20 200 responses got %d completed %v; %v", client, discard, s.CompletedReqs, s.Responses, s.Errors)
+		}
+		return w, conns.Load()
+	}
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		// the body has to be drained for the keep-alive connection to take the next request
+		if _, conns := run(client, true, false); conns != 1 {
+			t.Errorf("%s: wanted 1 connection reused for every request got %d", client, conns)
+		}
+	}
+	// closing a response without reading all of its body throws away the connection, so draining it saves
+	// reconnecting on every request
+	if _, conns := run(HttpClientNetHTTP, false, false); conns != 20 {
+		t.Errorf("wanted a new connection for every request when bodies aren't drained got %d", conns)
+	}
+
+	// the connection closes anyway so the body isn't read at all
+	if w, _ := run(HttpClientFastHTTP1, true, true); w.RespSize() >= int64(len(body)) {
+		t.Errorf("wanted the skipped body left out of the response size got %d", w.RespSize())
+	}
+	if w, _ := run(HttpClientFastHTTP1, false, true); w.RespSize() < int64(len(body)) {
+		t.Errorf("wanted the body in the response size got %d", w.RespSize())
+	}
+}
+
+func TestWorker_TTFB(t *testing.T) {
+	// the response starts after 20ms then the rest of the body takes another 50ms
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("start"))
+		w.(http.Flusher).Flush()
+		for i := 0; i < 5; i++ {
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte(strings.Repeat("x", 1024)))
+			w.(http.Flusher).Flush()
+		}
+	}
+	server := testServer(t, handler)
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(handler))
+	defer tlsServer.Close()
+
+	tests := []struct {
+		name   string
+		client string
+		url    string
+		// nethttp only reads the body as part of the latency when it's streamed
+		stream           bool
+		disableKeepAlive bool
+	}{
+		{name: "fasthttp-1", client: HttpClientFastHTTP1, url: server.URL},
+		// the handshake isn't taken as the response
+		{name: "fasthttp-1 tls", client: HttpClientFastHTTP1, url: tlsServer.URL, disableKeepAlive: true},
+		{name: "nethttp tls", client: HttpClientNetHTTP, url: tlsServer.URL, stream: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timings := make(chan http_clients.ReqTiming, 3)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:           tt.url,
+				ReqTarget:        3,
+				Client:           tt.client,
+				SkipVerify:       true,
+				DisableKeepAlive: tt.disableKeepAlive,
+				StreamBody:       tt.stream,
+				TTFB:             true,
+				ReqStats:         timings,
+			}).Stats()
+			if s.CompletedReqs != 3 {
+				t.Fatalf("wanted 3 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			close(timings)
+			for timing := range timings {
+				ttfb, latency := time.Duration(timing.FirstByte-timing.Sent), time.Duration(timing.Done-timing.Sent)
+				if ttfb < 20*time.Millisecond || ttfb > 50*time.Millisecond {
+					t.Errorf("wanted ttfb of ~20ms got %s", ttfb)
+				}
+				if latency < 70*time.Millisecond {
+					t.Errorf("wanted latency to include the ~50ms body got %s", latency)
+				}
+			}
+		})
+	}
+}
+
+func TestWorker_Trace(t *testing.T) {
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			// the request head is read as sent, a server would hide how the lack of a body was framed
+			addr, received := rawServer(t)
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    addr + "/trace",
+				ReqTarget: 1,
+				Method:    "TRACE",
+				Client:    client,
+				Headers:   []string{"X-Trace-Me: yes"},
+			}).Stats()
+			if s.CompletedReqs != 1 {
+				t.Fatalf("wanted 1 completed request got %d; %v", s.CompletedReqs, s.Errors)
+			}
+
+			req := <-received
+			if req.line != "TRACE /trace HTTP/1.1" {
+				t.Errorf("wanted a TRACE request line got %s", req.line)
+			}
+			var traced bool
+			for _, h := range req.headers {
+				name, _, _ := strings.Cut(h, ":")
+				switch strings.ToLower(name) {
+				case "content-length", "content-type", "transfer-encoding":
+					t.Errorf("wanted a TRACE request without a body got header %s", h)
+				case "x-trace-me":
+					traced = true
+				}
+			}
+			if !traced {
+				t.Errorf("wanted the request's headers sent got %v", req.headers)
+			}
+		})
+	}
+}
+
+func TestWorker_TLSFingerprint(t *testing.T) {
+	curves := make(chan []tls.CurveID, 1)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			curves <- info.SupportedCurves
+			return nil, nil
+		},
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		s := runWorker(t, &http_clients.Config{
+			ReqURI:         server.URL,
+			ReqTarget:      1,
+			Client:         client,
+			SkipVerify:     true,
+			TLSFingerprint: http_clients.TLSFingerprintFirefox,
+		}).Stats()
+		if s.CompletedReqs != 1 {
+			t.Fatalf("%s: wanted 1 completed request got %d; %v", client, s.CompletedReqs, s.Errors)
+		}
+		// only firefox offers P-521
+		if c := <-curves; len(c) == 0 || c[len(c)-1] != tls.CurveP521 {
+			t.Errorf("%s: wanted the firefox ClientHello got curves %v", client, c)
+		}
+	}
+}
+
+func TestWorker_ReqPath(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {})
+	timings := make(chan http_clients.ReqTiming, 2)
+	s := runWorker(t, &http_clients.Config{
+		ReqURI:    server.URL + "/search?q=shoes",
+		ReqTarget: 2,
+		ReqPath:   true,
+		ReqStats:  timings,
+	}).Stats()
+	if s.CompletedReqs != 2 {
+		t.Fatalf("wanted 2 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+	}
+	// matched against --slo-latency paths so the query is left off
+	for i := 0; i < 2; i++ {
+		if timing := <-timings; timing.Path != "/search" {
+			t.Errorf("wanted the request's path without its query got %q", timing.Path)
+		}
+	}
+}
+
+func TestWorker_PartialWrite(t *testing.T) {
+	// far more than the socket buffers hold, so the body is still being written when the server resets
+	body := strings.Repeat("x", 32<<20)
+	for _, client := range []string{HttpClientFastHTTP1, HttpClientNetHTTP} {
+		t.Run(client, func(t *testing.T) {
+			ln, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ln.Close()
+
+			var conns atomic.Int64
+			go func() {
+				for {
+					conn, err := ln.Accept()
+					if err != nil {
+						return
+					}
+					conns.Add(1)
+					go func() {
+						// read some of the request and reset the connection part way through its body
+						io.ReadFull(conn, make([]byte, 64<<10))
+						conn.(*net.TCPConn).SetLinger(0)
+						conn.Close()
+					}()
+				}
+			}()
+
+			s := runWorker(t, &http_clients.Config{
+				ReqURI:    "http://" + ln.Addr().String() + "/upload",
+				ReqTarget: 3,
+				Method:    "POST",
+				Client:    client,
+				Body:      body,
+			}).Stats()
+			if s.FailedReqs != 3 || s.Errors[errPartialWrite] != 3 {
+				t.Errorf("wanted 3 partial writes got %d failed; %v", s.FailedReqs, s.Errors)
+			}
+			// every request after a partial write is on a new connection
+			if n := conns.Load(); n != 3 {
+				t.Errorf("wanted 3 connections got %d", n)
+			}
+		})
+	}
+}
+
+func TestWorker_ReqBytes(t *testing.T) {
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 1000)))
+	})
+	timings := make(chan http_clients.ReqTiming, 2)
+	w := runWorker(t, &http_clients.Config{
+		ReqURI:    server.URL,
+		ReqTarget: 2,
+		ReqBytes:  true,
+		ReqStats:  timings,
+	})
+	if s := w.Stats(); s.CompletedReqs != 2 {
+		t.Fatalf("wanted 2 completed requests got %d; %v", s.CompletedReqs, s.Errors)
+	}
+	// measured like the results' byte sizes, the body and the headers of both
+	want := w.ReqSize() + w.RespSize()
+	for i := 0; i < 2; i++ {
+		if timing := <-timings; timing.Bytes != want || timing.Bytes < 1000 {
+			t.Errorf("wanted %d bytes for the request and its 1000 byte body got %d", want, timing.Bytes)
+		}
+	}
+}
+
+func TestWorker_Failed(t *testing.T) {
+	// dropped connections fail the requests, POSTs aren't retried
+	server := testServer(t, func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})
+	w := runWorker(t, &http_clients.Config{
+		ReqURI:    server.URL,
+		ReqTarget: 3,
+		Method:    "POST",
+	})
+	if s := w.Stats(); s.FailedReqs != 3 || w.Failed() != 3 {
+		t.Errorf("wanted 3 failed requests counted for Failed got %d and %d", s.FailedReqs, w.Failed())
+	}
+}
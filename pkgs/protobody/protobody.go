@@ -0,0 +1,47 @@
+package protobody
+
+import (
+	"fmt"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"os"
+)
+
+// Marshal encodes a request body given as JSON into the protobuf message named message i.e. shop.v1.Order. The
+// message is looked up in descriptorSet, a FileDescriptorSet such as written by
+// protoc --include_imports --descriptor_set_out. The JSON uses the protobuf JSON mapping, fields which aren't in the
+// message are an error so a typo isn't silently left out of the body
+func Marshal(descriptorSet, message string, body []byte) ([]byte, error) {
+	b, err := os.ReadFile(descriptorSet)
+	if err != nil {
+		return nil, fmt.Errorf("protobody: failed to read descriptor set; %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("protobody: %s isn't a FileDescriptorSet; %v", descriptorSet, err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("protobody: invalid descriptor set %s, imports need to be included; %v", descriptorSet, err)
+	}
+
+	d, err := files.FindDescriptorByName(protoreflect.FullName(message))
+	if err != nil {
+		return nil, fmt.Errorf("protobody: message %s not found in %s", message, descriptorSet)
+	}
+	md, ok := d.(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("protobody: %s in %s isn't a message", message, descriptorSet)
+	}
+
+	msg := dynamicpb.NewMessage(md)
+	if err := protojson.Unmarshal(body, msg); err != nil {
+		return nil, fmt.Errorf("protobody: body doesn't match message %s; %v", message, err)
+	}
+	// deterministic so the same JSON is always the same bytes i.e. for --hmac-secret signatures to be reproducible
+	return proto.MarshalOptions{Deterministic: true}.Marshal(msg)
+}
@@ -0,0 +1,70 @@
+package protobody
+
+import (
+	"bytes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeDescriptorSet(t *testing.T) string {
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{Name: proto.String(name), Number: proto.Int32(number), Type: typ.Enum(), Label: label.Enum()}
+	}
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:    proto.String("shop.proto"),
+		Package: proto.String("shop.v1"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Order"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("id", 1, descriptorpb.FieldDescriptorProto_TYPE_INT64, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("customer_name", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL),
+				field("items", 3, descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_LABEL_REPEATED),
+			},
+		}},
+	}}}
+	b, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "shop.pb")
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMarshal(t *testing.T) {
+	set := writeDescriptorSet(t)
+
+	b, err := Marshal(set, "shop.v1.Order", []byte(`{"id": "42", "customerName": "ann", "items": ["a", "b"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// field 1 varint 42, field 2 "ann", field 3 "a" and "b"
+	want := []byte{0x08, 42, 0x12, 3, 'a', 'n', 'n', 0x1a, 1, 'a', 0x1a, 1, 'b'}
+	if !bytes.Equal(b, want) {
+		t.Errorf("wanted %x got %x", want, b)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		set     string
+		message string
+		body    string
+	}{
+		{name: "unknown field", set: set, message: "shop.v1.Order", body: `{"id": 1, "total": 2}`},
+		{name: "wrong type", set: set, message: "shop.v1.Order", body: `{"id": "one"}`},
+		{name: "not json", set: set, message: "shop.v1.Order", body: `id=1`},
+		{name: "unknown message", set: set, message: "shop.v1.Refund", body: `{}`},
+		{name: "not a message", set: set, message: "shop.v1.Order.id", body: `{}`},
+		{name: "missing set", set: filepath.Join(t.TempDir(), "missing.pb"), message: "shop.v1.Order", body: `{}`},
+	} {
+		if _, err := Marshal(tt.set, tt.message, []byte(tt.body)); err == nil {
+			t.Errorf("%s: wanted error", tt.name)
+		}
+	}
+}
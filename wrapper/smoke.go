@@ -0,0 +1,90 @@
+package wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/domsolutions/gopayloader/config"
+	"github.com/domsolutions/gopayloader/pkgs/payloader"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/worker"
+	"github.com/pterm/pterm"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Smoke runs a quick check of conf's target and prints a one line verdict instead of the full results, it fails
+// unless every request completed with a 2xx response
+func Smoke(conf *config.Config) error {
+	ctx, cancel := context.WithCancel(conf.Ctx)
+	defer cancel()
+	conf.Ctx = ctx
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+	go func() {
+		select {
+		case <-c:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	results, err := payloader.NewPayLoader(conf).Run()
+	if err != nil {
+		return err
+	}
+	passed, summary := smokeVerdict(conf, results)
+	if !passed {
+		pterm.Error.Printf("FAIL %s\n", summary)
+		return errors.New("smoke test failed")
+	}
+	pterm.Success.Printf("PASS %s\n", summary)
+	return nil
+}
+
+// smokeVerdict sums up the run in one line, listing what went wrong when it didn't pass
+func smokeVerdict(conf *config.Config, results *payloader.GoPayloaderResults) (bool, string) {
+	sent := results.CompletedReqs + results.FailedReqs
+	summary := fmt.Sprintf("%d/%d requests succeeded against %s", results.SuccessfulResps, sent, conf.ReqURI)
+	if results.CompletedReqs > 0 {
+		summary += fmt.Sprintf(", average latency %s", results.Latency.Average.Round(time.Microsecond))
+		for _, p := range results.Latency.Percentiles {
+			if p.Percentile == 99 {
+				summary += fmt.Sprintf(", p99 %s", p.Latency.Round(time.Microsecond))
+			}
+		}
+	}
+	if sent > 0 && results.SuccessfulResps == sent {
+		return true, summary
+	}
+
+	codes := make([]int, 0, len(results.Responses))
+	for code := range results.Responses {
+		codes = append(codes, int(code))
+	}
+	sort.Ints(codes)
+	var responses []string
+	for _, code := range codes {
+		responses = append(responses, fmt.Sprintf("%d: %d", code, results.Responses[worker.ResponseCode(code)]))
+	}
+	if len(responses) > 0 {
+		summary += "; responses " + strings.Join(responses, ", ")
+	}
+	errs := make([]string, 0, len(results.Errors))
+	for err, count := range results.Errors {
+		errs = append(errs, fmt.Sprintf("%s (%d)", err, count))
+	}
+	sort.Strings(errs)
+	if len(errs) > 0 {
+		summary += "; errors " + strings.Join(errs, ", ")
+	}
+	return false, summary
+}
@@ -3,11 +3,14 @@ package wrapper
 import (
 	"context"
 	"errors"
+	"fmt"
 	"github.com/domsolutions/gopayloader/pkgs/payloader/output/cli"
+	"github.com/domsolutions/gopayloader/pkgs/payloader/output/sqlite"
 	"github.com/domsolutions/gopayloader/version"
 	"github.com/pterm/pterm"
 	"os"
 	"os/signal"
+	"runtime"
 	"syscall"
 	"time"
 
@@ -15,37 +18,63 @@ import (
 	"github.com/domsolutions/gopayloader/pkgs/payloader"
 )
 
-func RunGoPayLoader(reqURI, mTLScert, mTLSKey string, disableKeepAlive bool, reqs int64, conns uint, totalTime time.Duration, skipVerify bool, readTimeout, writeTimeout time.Duration, method string, verbose bool, ticker time.Duration, jwtKID, jwtKey, jwtSub, jwtCustomClaimsJSON, jwtIss, jwtAud, jwtHeader, jwtsFilename string, headers []string, body, bodyFile string, client string) error {
-	ctx, cancel := context.WithCancel(context.Background())
+func RunGoPayLoader(conf *config.Config) error {
+	ctx, cancel := context.WithCancel(conf.Ctx)
 	defer cancel()
 
-	conf := config.NewConfig(ctx,
-		reqURI,
-		mTLScert,
-		mTLSKey,
-		disableKeepAlive,
-		reqs,
-		conns,
-		totalTime,
-		skipVerify,
-		readTimeout,
-		writeTimeout,
-		method,
-		verbose,
-		ticker,
-		jwtKID, jwtKey, jwtSub, jwtCustomClaimsJSON, jwtIss, jwtAud, jwtHeader, jwtsFilename, headers, body, bodyFile, client)
+	conf.Ctx = ctx
 	if err := conf.Validate(); err != nil {
 		return err
 	}
+	if conf.ConnsAuto {
+		pterm.Info.Printf("Using %d connections for %d GOMAXPROCS and the open file limit\n", conf.Conns, runtime.GOMAXPROCS(0))
+	}
 
 	pterm.DefaultBasicText.Printf(pterm.LightYellow("Gopayloader v%s HTTP/JWT authentication benchmark tool \n"), version.Version)
 	pterm.DefaultBasicText.Println("https://github.com/domsolutions/gopayloader")
 
-	if verbose {
+	if conf.Verbose {
 		pterm.EnableDebugMessages()
 		pterm.Warning.Println("In verbose mode RPS will be slightly lower due to monitoring, more noticeable in longer running tests")
 	}
 
+	var db *sqlite.DB
+	if conf.SQLitePath != "" && !conf.Estimate {
+		// opened up front so a database which can't be written doesn't lose the results of the run
+		var err error
+		if db, err = sqlite.Open(conf.SQLitePath); err != nil {
+			return err
+		}
+		defer db.Close()
+	}
+
+	if conf.WaitForReady {
+		if err := payloader.WaitForReady(conf); err != nil {
+			return err
+		}
+	}
+
+	if conf.CertInfo {
+		if _, err := payloader.ReportCertInfo(conf); err != nil {
+			return err
+		}
+	}
+
+	if conf.Estimate {
+		est, err := payloader.EstimateCapacity(conf)
+		if err != nil {
+			return err
+		}
+		cli.DisplayEstimate(est, conf.LatencyFormat())
+		return nil
+	}
+	if conf.CompareProtocols {
+		return compareProtocols(conf, cancel)
+	}
+	if conf.Repeat > 1 {
+		return repeat(conf, db, cancel)
+	}
+
 	payload := payloader.NewPayLoader(conf)
 	errPayLoader := make(chan error)
 	resPayLoader := make(chan *payloader.GoPayloaderResults)
@@ -76,7 +105,7 @@ func RunGoPayLoader(reqURI, mTLScert, mTLSKey string, disableKeepAlive bool, req
 
 		select {
 		case results := <-resPayLoader:
-			cli.Display(results)
+			return output(conf, db, results)
 		case err := <-errPayLoader:
 			// user may have cancelled during jwt generation, so there will be no results
 			return err
@@ -86,8 +115,81 @@ func RunGoPayLoader(reqURI, mTLScert, mTLSKey string, disableKeepAlive bool, req
 	case err := <-errPayLoader:
 		return err
 	case results := <-resPayLoader:
-		cli.Display(results)
+		return output(conf, db, results)
 	}
+}
+
+func output(conf *config.Config, db *sqlite.DB, results *payloader.GoPayloaderResults) error {
+	cli.Display(results, conf.LatencyFormat())
+	return save(conf, db, results)
+}
 
+// save stores the results with --sqlite and checks them against --require-rate and --abort-on-slo-breach
+func save(conf *config.Config, db *sqlite.DB, results *payloader.GoPayloaderResults) error {
+	if db != nil {
+		runID, err := db.Save(conf, results)
+		if err != nil {
+			return err
+		}
+		pterm.Success.Printf("Results saved to %s as run %d\n", conf.SQLitePath, runID)
+	}
+
+	if conf.RequireRate && results.RateCheck != nil && !results.RateCheck.Met {
+		return fmt.Errorf("achieved rate of %.2f requests per second is below the required rate of %g", results.RateCheck.Achieved, results.RateCheck.Target)
+	}
+	if results.SLOBreach != nil {
+		return fmt.Errorf("stopped after %s as %s", results.SLOBreach.After.Round(time.Millisecond), results.SLOBreach.Reason)
+	}
+	return nil
+}
+
+func compareProtocols(conf *config.Config, cancel context.CancelFunc) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	go func() {
+		select {
+		case <-c:
+			// the protocol being run stops early and the rest aren't run
+			pterm.Info.Println("User aborted; showing results of protocols run so far")
+			cancel()
+		case <-conf.Ctx.Done():
+		}
+	}()
+
+	results, err := payloader.CompareProtocols(conf)
+	if err != nil {
+		return err
+	}
+	cli.DisplayProtocols(results, conf.LatencyFormat())
+	return nil
+}
+
+func repeat(conf *config.Config, db *sqlite.DB, cancel context.CancelFunc) error {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+
+	go func() {
+		select {
+		case <-c:
+			// the run in progress stops early and the rest aren't run
+			pterm.Info.Println("User aborted; showing results of runs so far")
+			cancel()
+		case <-conf.Ctx.Done():
+		}
+	}()
+
+	results, err := payloader.Repeat(conf)
+	if err != nil {
+		return err
+	}
+	cli.DisplayRepeat(results, conf.LatencyFormat())
+	for _, run := range results.Runs {
+		if err := save(conf, db, run); err != nil {
+			return err
+		}
+	}
 	return nil
 }